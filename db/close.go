@@ -0,0 +1,128 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrActiveTransactions = errors.New("active transactions")
+)
+
+// dbCloser 关闭数据源管理的 DB, 仅执行一次.
+type dbCloser struct {
+	once sync.Once
+	err  error
+}
+
+// close 关闭 DB 的连接池, 包括 dbresolver 注册的从库, 重复调用返回首次结果.
+//
+// 同一连接池仅关闭一次, 错误按 Key 汇总.
+func (c *dbCloser) close(dbs map[string]*gorm.DB) error {
+	c.once.Do(func() {
+		c.err = closeDBs(dbs)
+	})
+	return c.err
+}
+
+func closeDBs(dbs map[string]*gorm.DB) error {
+	keys := make([]string, 0, len(dbs))
+	for key := range dbs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	closed := make(map[interface{}]bool)
+	var errs []string
+	for _, key := range keys {
+		if err := closeDB(dbs[key], closed); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", key, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("close db: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func closeDB(db *gorm.DB, closed map[interface{}]bool) error {
+	if db == nil {
+		return nil
+	}
+	var errs []string
+	closePool := func(pool gorm.ConnPool) error {
+		if prepared, ok := pool.(*gorm.PreparedStmtDB); ok {
+			pool = prepared.ConnPool
+		}
+		c, ok := pool.(interface{ Close() error })
+		if !ok || closed[pool] {
+			return nil
+		}
+		closed[pool] = true
+		if err := c.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+		return nil
+	}
+	if resolver, ok := db.Config.Plugins[(&dbresolver.DBResolver{}).Name()].(*dbresolver.DBResolver); ok {
+		_ = resolver.Call(closePool)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		_ = closePool(sqlDB)
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// closeSources 依次关闭数据源, 汇总错误.
+func closeSources(ctx context.Context, sources ...Source) error {
+	var errs []string
+	for _, s := range sources {
+		if err := s.Close(ctx); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Close 等待进行中的根事务结束后关闭数据源.
+//
+// 等待期间开启根事务返回 ErrSourceClosed. ctx 结束时仍有进行中的事务则不关闭,
+// 返回包含事务数的 ErrActiveTransactions, 之后可继续开启事务.
+// 重复调用返回首次关闭结果.
+func (p *TransProvider) Close(ctx context.Context) error {
+	p.metrics.closing.Store(true)
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		active := atomic.LoadInt64(&p.metrics.active)
+		if active == 0 {
+			return p.ForceClose(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			p.metrics.closing.Store(false)
+			return fmt.Errorf("%w: %d", ErrActiveTransactions, active)
+		case <-ticker.C:
+		}
+	}
+}
+
+// ForceClose 不等待进行中的事务, 立即关闭数据源, 之后开启根事务返回 ErrSourceClosed.
+func (p *TransProvider) ForceClose(ctx context.Context) error {
+	p.metrics.closed.Store(true)
+	p.metrics.untrackAll()
+	return p.loadSource().Close(ctx)
+}
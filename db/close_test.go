@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func TestProviderClose(t *testing.T) {
+	p, _ := newRWSQLiteProvider(t, new(tenantItem))
+	db := p.UseWriteDB(context.Background())
+	primary, _ := db.DB()
+	pools := append(replicaPools(t, db), primary)
+
+	// 进行中的事务阻止关闭.
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Transaction(context.Background(), func(ctx context.Context) error {
+			close(entered)
+			<-release
+			return p.UseDB(ctx).Create(&tenantItem{Name: "a"}).Error
+		})
+	}()
+	<-entered
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if err := p.Close(ctx); !errors.Is(err, ErrActiveTransactions) {
+		t.Fatalf("close with active transaction = %v, want ErrActiveTransactions", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("transaction after refused close = %v", err)
+	}
+	// 关闭失败后可继续开启事务.
+	if err := p.Transaction(context.Background(), func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("new transaction after refused close = %v", err)
+	}
+
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for i, pool := range pools {
+		if err := pool.Ping(); err == nil {
+			t.Errorf("pool %d still open after Close", i)
+		}
+	}
+	// 重复关闭返回首次结果.
+	if err := p.Close(context.Background()); err != nil {
+		t.Errorf("second close = %v", err)
+	}
+}
+
+func TestProviderCloseRejectsNewTransactions(t *testing.T) {
+	p := newSQLiteProvider(t, new(tenantItem))
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Transaction(context.Background(), func(ctx context.Context) error {
+			close(entered)
+			<-release
+			return p.UseDB(ctx).Create(&tenantItem{Name: "a"}).Error
+		})
+	}()
+	<-entered
+	closed := make(chan error, 1)
+	go func() {
+		closed <- p.Close(context.Background())
+	}()
+	for !p.metrics.closing.Load() {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 等待关闭期间不再开启根事务.
+	if err := p.Transaction(context.Background(), func(context.Context) error { return nil }); !errors.Is(err, ErrSourceClosed) {
+		t.Errorf("transaction while closing = %v, want ErrSourceClosed", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("active transaction = %v", err)
+	}
+	if err := <-closed; err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Transaction(context.Background(), func(context.Context) error { return nil }); !errors.Is(err, ErrSourceClosed) {
+		t.Errorf("transaction after close = %v, want ErrSourceClosed", err)
+	}
+}
+
+func TestCloseDBsSharedPool(t *testing.T) {
+	p := newSQLiteProvider(t)
+	db := p.UseWriteDB(context.Background())
+	// 多个 Key 共享连接池时仅关闭一次.
+	if err := closeDBs(map[string]*gorm.DB{"a": db, "b": db}); err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	if err := sqlDB.Ping(); err == nil {
+		t.Error("shared pool still open")
+	}
+}
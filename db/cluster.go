@@ -0,0 +1,233 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrSourceClosed           = errors.New("source closed")
+	ErrSourceNotReconnectable = errors.New("source is not reconnectable")
+)
+
+// Reconnector 代表可重新建立连接的数据源.
+type Reconnector interface {
+	// Reconnect 重新建立连接, 成功后替换原连接, 原连接池在等待期后关闭.
+	Reconnect(ctx context.Context) error
+}
+
+// ClusterSource 代表通过集群写入端点及读取端点访问的数据源, 如 AWS Aurora.
+//
+// 端点为故障转移时切换指向的 DNS 名, 已建立的连接不随之切换, 故障转移后通过 Reconnect 重新解析并建立连接.
+type ClusterSource struct {
+	opts   Options
+	writer string
+	reader string
+	dial   Dialector
+	config *gorm.Config
+	drain  time.Duration
+
+	mut     sync.RWMutex
+	writeDB *gorm.DB
+	readDB  *gorm.DB
+	// 等待关闭的连接池.
+	retiring map[*time.Timer]map[string]*gorm.DB
+	closed   bool
+	closer   dbCloser
+}
+
+// ClusterOption 定义 NewClusterSource 选项.
+type ClusterOption func(*ClusterSource)
+
+// WithClusterDrainPeriod 设置 Reconnect 替换的连接池关闭前等待时间, 不大于 0 时为 DefaultReloadDrainPeriod.
+func WithClusterDrainPeriod(drain time.Duration) ClusterOption {
+	return func(s *ClusterSource) {
+		s.drain = drain
+	}
+}
+
+var (
+	_ Source      = new(ClusterSource)
+	_ Reconnector = new(ClusterSource)
+)
+
+// NewClusterSource 创建集群数据源, 分别连接写入端点及读取端点.
+//
+// 端点格式为 host 或 host:port, 替代 opts 的 Host 及 Port, 未指定端口时使用 opts 的 Port.
+// 其余配置使用 opts. 库名为端点.
+func NewClusterSource(
+	opts *Options, writerEndpoint, readerEndpoint string, dial Dialector, config *gorm.Config, clusterOpts ...ClusterOption,
+) (Source, error) {
+	s := &ClusterSource{
+		opts:     *opts,
+		writer:   writerEndpoint,
+		reader:   readerEndpoint,
+		dial:     dial,
+		config:   config,
+		retiring: make(map[*time.Timer]map[string]*gorm.DB),
+	}
+	for _, opt := range clusterOpts {
+		opt(s)
+	}
+	if s.drain <= 0 {
+		s.drain = DefaultReloadDrainPeriod
+	}
+	writeDB, readDB, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	s.writeDB, s.readDB = writeDB, readDB
+	return s, nil
+}
+
+// open 连接写入端点及读取端点, 任一失败时关闭已创建的连接.
+func (s *ClusterSource) open() (*gorm.DB, *gorm.DB, error) {
+	writeDB, err := s.openEndpoint(s.writer)
+	if err != nil {
+		return nil, nil, err
+	}
+	readDB, err := s.openEndpoint(s.reader)
+	if err != nil {
+		_ = closeDBs(map[string]*gorm.DB{s.writer: writeDB})
+		return nil, nil, err
+	}
+	return writeDB, readDB, nil
+}
+
+func (s *ClusterSource) openEndpoint(endpoint string) (*gorm.DB, error) {
+	opts := s.opts
+	opts.Socket = ""
+	if host, port, err := net.SplitHostPort(endpoint); err == nil {
+		n, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, fmt.Errorf("endpoint %s: invalid port: %w", endpoint, err)
+		}
+		opts.Host, opts.Port = &host, &n
+	} else {
+		opts.Host = &endpoint
+	}
+	db, err := opts.OpenDB(s.dial, s.config)
+	if err != nil {
+		return nil, fmt.Errorf("endpoint %s: %w", endpoint, err)
+	}
+	return db, nil
+}
+
+// Reconnect 重新连接写入端点及读取端点, 用于故障转移后切换到新的实例.
+//
+// 新连接全部创建成功后替换原连接, 之后开启的事务使用新连接, 进行中的事务继续使用原连接,
+// 原连接池在等待期后关闭, 期间进行中的事务可继续完成, 见 WithClusterDrainPeriod.
+// 创建失败时保留原连接. 数据源关闭后返回 ErrSourceClosed.
+func (s *ClusterSource) Reconnect(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mut.RLock()
+	closed := s.closed
+	s.mut.RUnlock()
+	if closed {
+		return ErrSourceClosed
+	}
+	writeDB, readDB, err := s.open()
+	if err != nil {
+		return err
+	}
+	s.mut.Lock()
+	if s.closed {
+		s.mut.Unlock()
+		_ = closeDBs(clusterPools(writeDB, readDB))
+		return ErrSourceClosed
+	}
+	s.retire(clusterPools(s.writeDB, s.readDB))
+	s.writeDB, s.readDB = writeDB, readDB
+	s.mut.Unlock()
+	return nil
+}
+
+// clusterPools 按角色返回写库及读库连接池, 写入端点与读取端点相同时同样分别关闭.
+func clusterPools(writeDB, readDB *gorm.DB) map[string]*gorm.DB {
+	return map[string]*gorm.DB{"writer": writeDB, "reader": readDB}
+}
+
+// retire 在 drain 后关闭连接池, 需持有 mut.
+func (s *ClusterSource) retire(dbs map[string]*gorm.DB) {
+	var timer *time.Timer
+	timer = time.AfterFunc(s.drain, func() {
+		s.mut.Lock()
+		delete(s.retiring, timer)
+		s.mut.Unlock()
+		_ = closeDBs(dbs)
+	})
+	s.retiring[timer] = dbs
+}
+
+func (s *ClusterSource) getWriteDBName(context.Context) string {
+	return s.writer
+}
+
+func (s *ClusterSource) getWriteDB(context.Context) *gorm.DB {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.writeDB
+}
+
+func (s *ClusterSource) getReadDBName(context.Context) string {
+	return s.reader
+}
+
+func (s *ClusterSource) getReadDB(context.Context) *gorm.DB {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.readDB
+}
+
+// dbs 按端点返回当前连接, 写入端点与读取端点相同时返回写库.
+func (s *ClusterSource) dbs() map[string]*gorm.DB {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	dbs := map[string]*gorm.DB{s.reader: s.readDB}
+	dbs[s.writer] = s.writeDB
+	return dbs
+}
+
+// Close 关闭当前连接及等待关闭的连接池, 之后 Reconnect 返回 ErrSourceClosed.
+func (s *ClusterSource) Close(context.Context) error {
+	s.mut.Lock()
+	s.closed = true
+	dbs := clusterPools(s.writeDB, s.readDB)
+	for timer, retired := range s.retiring {
+		if !timer.Stop() {
+			continue
+		}
+		for key, db := range retired {
+			dbs[fmt.Sprintf("retiring-%s-%p", key, db)] = db
+		}
+	}
+	s.retiring = nil
+	s.mut.Unlock()
+	return s.closer.close(dbs)
+}
+
+func (s *ClusterSource) Dialector() gorm.Dialector {
+	return commonDialector(s.dbs())
+}
+
+// Reconnect 重新建立数据源连接, 数据源未实现 Reconnector 时返回 ErrSourceNotReconnectable.
+func (p *TransProvider) Reconnect(ctx context.Context) error {
+	s, ok := p.loadSource().(Reconnector)
+	if !ok {
+		return ErrSourceNotReconnectable
+	}
+	if err := s.Reconnect(ctx); err != nil {
+		return err
+	}
+	// 连接池统计跟随新连接.
+	p.metrics.untrackAll()
+	return nil
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeResolver 模拟端点 DNS 解析, 按当前解析结果返回连接.
+type fakeResolver struct {
+	mut   sync.Mutex
+	hosts map[string]*sql.DB
+}
+
+func (r *fakeResolver) point(endpoint string, db *sql.DB) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.hosts[endpoint] = db
+}
+
+func (r *fakeResolver) dialector() Dialector {
+	return func(opts *Options) (gorm.Dialector, error) {
+		r.mut.Lock()
+		defer r.mut.Unlock()
+		return mysql.New(mysql.Config{Conn: r.hosts[deref(opts.Host)], SkipInitializeWithVersion: true}), nil
+	}
+}
+
+func newClusterSource(t *testing.T, drain time.Duration) (Source, *fakeResolver, *fakeConnector) {
+	writer, writerFake := newFakeDB(nil)
+	reader, _ := newFakeDB(nil)
+	r := &fakeResolver{hosts: map[string]*sql.DB{"writer.cluster": writer, "reader.cluster": reader}}
+	opts := &Options{Port: Ptr(3306), DBName: Ptr("app"), UserName: Ptr("app")}
+	s, err := NewClusterSource(opts, "writer.cluster", "reader.cluster", r.dialector(),
+		&gorm.Config{Logger: logger.Discard}, WithClusterDrainPeriod(drain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, r, writerFake
+}
+
+func TestClusterSourceReconnect(t *testing.T) {
+	s, r, oldFake := newClusterSource(t, 50*time.Millisecond)
+	defer s.(*ClusterSource).Close(context.Background())
+	p := NewProvider(s)
+	ctx := context.Background()
+	oldWriter, _ := p.UseWriteDB(ctx).DB()
+
+	// 故障转移, 写入端点解析到新实例.
+	newWriter, newFake := newFakeDB(nil)
+	r.point("writer.cluster", newWriter)
+	if err := p.Reconnect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.UseWriteDB(ctx).Exec("UPDATE tenant_items SET name = ?", "a").Error; err != nil {
+		t.Fatal(err)
+	}
+	if log := newFake.statements(); len(log) != 1 {
+		t.Errorf("new writer statements = %+v", log)
+	}
+	if log := oldFake.statements(); len(log) != 0 {
+		t.Errorf("old writer statements = %+v", log)
+	}
+
+	// 原连接池等待期内仍可使用, 之后关闭.
+	if err := oldWriter.Ping(); err != nil {
+		t.Fatalf("old writer closed before drain: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for oldWriter.Ping() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("old writer not closed after drain")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClusterSourceCloseRetiring(t *testing.T) {
+	s, r, _ := newClusterSource(t, time.Hour)
+	ctx := context.Background()
+	oldWriter, _ := s.getWriteDB(ctx).DB()
+
+	newWriter, _ := newFakeDB(nil)
+	r.point("writer.cluster", newWriter)
+	if err := s.(Reconnector).Reconnect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.(*ClusterSource).Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := oldWriter.Ping(); err == nil {
+		t.Error("retiring writer not closed by Close")
+	}
+	if err := newWriter.Ping(); err == nil {
+		t.Error("current writer not closed by Close")
+	}
+	if err := s.(Reconnector).Reconnect(ctx); err != ErrSourceClosed {
+		t.Errorf("Reconnect after Close = %v, want ErrSourceClosed", err)
+	}
+}
+
+func TestClusterSourceSameEndpoint(t *testing.T) {
+	// 每次连接创建新的连接池, 同 gorm 打开 DSN.
+	var (
+		mut    sync.Mutex
+		opened []*sql.DB
+	)
+	dial := func(*Options) (gorm.Dialector, error) {
+		conn, _ := newFakeDB(nil)
+		mut.Lock()
+		opened = append(opened, conn)
+		mut.Unlock()
+		return mysql.New(mysql.Config{Conn: conn, SkipInitializeWithVersion: true}), nil
+	}
+	opts := &Options{Port: Ptr(3306), DBName: Ptr("app"), UserName: Ptr("app")}
+	s, err := NewClusterSource(opts, "db.single", "db.single", dial,
+		&gorm.Config{Logger: logger.Discard}, WithClusterDrainPeriod(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := s.(Reconnector).Reconnect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(opened) != 4 {
+		t.Fatalf("opened %d pools, want 4", len(opened))
+	}
+	for i, conn := range opened {
+		if err := conn.Ping(); err == nil {
+			t.Errorf("pool %d not closed", i)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"gorm.io/gorm"
+	"regexp"
+	"strings"
+)
+
+// UseCommand 实现 Provider.UseCommand.
+//
+// 事务内返回事务连接, 执行的语句随事务提交或回滚.
+// 事务外按语句读写分离: QueryContext, QueryRowContext 执行的 SELECT 使用读库, 加锁的 SELECT
+// (FOR UPDATE 等), SELECT ... INTO 及其他语句使用写库; ExecContext, PrepareContext 始终使用写库.
+// ForceWrite, AfterWrite 标记的调用 context 同样使用写库.
+// 两种情况下 PrepareContext 均可用, 事务内预编译语句在事务结束后失效.
+//
+// 无匹配 DB 时 panic.
+func (p *TransProvider) UseCommand(ctx context.Context) Command {
+	cmd, err := p.TryUseCommand(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+// TryUseCommand 同 UseCommand, 以错误代替 panic.
+func (p *TransProvider) TryUseCommand(ctx context.Context) (Command, error) {
+	db, err := p.findTransDBStrict(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if db != nil {
+		return db.Statement.ConnPool, nil
+	}
+	db = p.getWriteDB(ctx)
+	if db == nil {
+		return nil, p.dbNotFound(ctx)
+	}
+	write, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	read := p.getReadDB(ctx)
+	if read == nil {
+		return write, nil
+	}
+	return &splitCommand{write: write, read: read}, nil
+}
+
+type forceWriteCtxKey struct{}
+
+// ForceWrite 标记 UseCommand 返回的执行接口使用写库执行查询, 作用于传入 QueryContext 等方法的 context.
+func ForceWrite(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceWriteCtxKey{}, true)
+}
+
+func isForceWrite(ctx context.Context) bool {
+	force, _ := ctx.Value(forceWriteCtxKey{}).(bool)
+	return force
+}
+
+// splitCommand 按语句选择读库或写库的执行接口.
+type splitCommand struct {
+	write *sql.DB
+	// 读库 DB, 配置从库时每次查询按 dbresolver 策略选择从库.
+	read *gorm.DB
+}
+
+func (c *splitCommand) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return c.write.ExecContext(ctx, query, args...)
+}
+
+func (c *splitCommand) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return c.write.PrepareContext(ctx, query)
+}
+
+func (c *splitCommand) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return c.choose(ctx, query).QueryContext(ctx, query, args...)
+}
+
+func (c *splitCommand) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return c.choose(ctx, query).QueryRowContext(ctx, query, args...)
+}
+
+// choose 返回执行查询语句的连接池.
+func (c *splitCommand) choose(ctx context.Context, query string) gorm.ConnPool {
+	if isForceWrite(ctx) || isAfterWrite(ctx) || !isReadStatement(query) {
+		return c.write
+	}
+	return readPool(ctx, c.read)
+}
+
+// dbResolverCallback dbresolver 注册在各类语句前的回调名, 根据语句及 Clauses 将 Statement.ConnPool 切换为选中的连接池.
+//
+// dbresolver 未导出选择连接池的方法, 通过该回调复用其从库选择策略, 名称随 go.mod 中的 dbresolver 版本固定,
+// 升级时由测试确认.
+const dbResolverCallback = "gorm:db_resolver"
+
+// readPool 返回 DB 的读连接池, 通过 dbresolver 回调选择从库, 未注册 dbresolver 时返回 DB 连接池.
+func readPool(ctx context.Context, db *gorm.DB) gorm.ConnPool {
+	if resolve := db.Callback().Row().Get(dbResolverCallback); resolve != nil {
+		tx := db.Session(&gorm.Session{NewDB: true, Context: ctx})
+		resolve(tx)
+		return tx.Statement.ConnPool
+	}
+	return db.Statement.ConnPool
+}
+
+// writeClause 使 SELECT 需在主库执行的子句: 加锁读及 SELECT ... INTO, 如 PostgreSQL 创建表,
+// MySQL 写入文件或变量. 不区分字符串字面量, 误判时使用写库.
+var writeClause = regexp.MustCompile(`(?i)\bFOR\s+(UPDATE|SHARE|NO\s+KEY\s+UPDATE|KEY\s+SHARE)\b|\bLOCK\s+IN\s+SHARE\s+MODE\b|\bINTO\b`)
+
+// isReadStatement 判断语句是否为只读的 SELECT, 依据跳过空白及注释后的首个关键字.
+//
+// WITH 等其他开头的语句, 加锁的 SELECT 及 SELECT ... INTO 视为写语句.
+func isReadStatement(query string) bool {
+	query = skipSpaceAndComments(query)
+	if len(query) < len("SELECT") || !strings.EqualFold(query[:len("SELECT")], "SELECT") {
+		return false
+	}
+	if len(query) > len("SELECT") && isIdentChar(query[len("SELECT")]) {
+		return false
+	}
+	return !writeClause.MatchString(query)
+}
+
+// skipSpaceAndComments 跳过语句开头的空白, 左括号及 --, #, /* */ 注释.
+func skipSpaceAndComments(query string) string {
+	for {
+		query = strings.TrimLeft(query, " \t\r\n(")
+		switch {
+		case strings.HasPrefix(query, "--"), strings.HasPrefix(query, "#"):
+			i := strings.IndexByte(query, '\n')
+			if i < 0 {
+				return ""
+			}
+			query = query[i+1:]
+		case strings.HasPrefix(query, "/*"):
+			i := strings.Index(query[2:], "*/")
+			if i < 0 {
+				return ""
+			}
+			query = query[i+4:]
+		default:
+			return query
+		}
+	}
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// UseCommand 返回首个 Provider 的执行接口.
+func (c *Composite) UseCommand(ctx context.Context) Command {
+	return c.providers[0].UseCommand(ctx)
+}
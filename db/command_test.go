@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
+	"testing"
+)
+
+func TestIsReadStatement(t *testing.T) {
+	for query, want := range map[string]bool{
+		"SELECT 1":                     true,
+		"  /* hint */ select * from t": true,
+		"-- c\n(SELECT a FROM t) UNION (SELECT b FROM u)": true,
+		"SELECT * FROM t FOR UPDATE":                      false,
+		"SELECT * FROM t FOR NO KEY UPDATE":               false,
+		"SELECT * FROM t LOCK IN SHARE MODE":              false,
+		"SELECT * INTO new_table FROM t":                  false,
+		"SELECT a INTO @a FROM t":                         false,
+		"SELECT * FROM t INTO OUTFILE '/tmp/t.csv'":       false,
+		"SELECTED":                             false,
+		"WITH x AS (SELECT 1) SELECT * FROM x": false,
+		"UPDATE t SET a = 1":                   false,
+	} {
+		if got := isReadStatement(query); got != want {
+			t.Errorf("isReadStatement(%q) = %v, want %v", query, got, want)
+		}
+	}
+}
+
+func TestUseCommandReadWriteSplit(t *testing.T) {
+	writeDB, writeFake := newFakeDB(nil)
+	readDB, readFake := newFakeDB(nil)
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: writeDB, SkipInitializeWithVersion: true}), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: []gorm.Dialector{mysql.New(mysql.Config{Conn: readDB, SkipInitializeWithVersion: true})},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Callback().Row().Get(dbResolverCallback) == nil {
+		t.Fatalf("dbresolver callback %q not registered", dbResolverCallback)
+	}
+	p := NewProvider(NewSource("main", db))
+	ctx := context.Background()
+	cmd := p.UseCommand(ctx)
+
+	for _, c := range []struct {
+		query string
+		ctx   context.Context
+		read  bool
+	}{
+		{"SELECT 1", ctx, true},
+		{"SELECT * FROM t FOR UPDATE", ctx, false},
+		{"SELECT * INTO t2 FROM t", ctx, false},
+		{"SELECT 2", ForceWrite(ctx), false},
+	} {
+		rows, err := cmd.QueryContext(c.ctx, c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = rows.Close()
+		reads, writes := readFake.statements(), writeFake.statements()
+		if got := len(reads) == 1 && len(writes) == 0; got != c.read {
+			t.Errorf("%q: read statements %+v, write statements %+v", c.query, reads, writes)
+		}
+	}
+	if _, err := cmd.ExecContext(ctx, "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(writeFake.statements()) != 1 || len(readFake.statements()) != 0 {
+		t.Error("ExecContext not routed to the primary")
+	}
+
+	// 事务内使用事务连接.
+	err = p.Transaction(ctx, func(ctx context.Context) error {
+		rows, err := p.UseCommand(ctx).QueryContext(ctx, "SELECT 1")
+		if err != nil {
+			return err
+		}
+		return rows.Close()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reads := readFake.statements(); len(reads) != 0 {
+		t.Errorf("read statements in transaction = %+v", reads)
+	}
+}
+
+func TestUseCommandMustTransactionRollback(t *testing.T) {
+	p := newSQLiteProvider(t, new(tenantItem))
+	ctx := context.Background()
+	failed := errors.New("failed")
+
+	func() {
+		defer func() {
+			if r := recover(); r != failed {
+				t.Fatalf("recovered %v, want %v", r, failed)
+			}
+		}()
+		p.MustTransaction(ctx, func(ctx context.Context) {
+			cmd := p.UseCommand(ctx)
+			if _, err := cmd.ExecContext(ctx, "INSERT INTO tenant_items (name) VALUES (?)", "exec"); err != nil {
+				t.Fatal(err)
+			}
+			stmt, err := cmd.PrepareContext(ctx, "INSERT INTO tenant_items (name) VALUES (?)")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stmt.Close()
+			if _, err := stmt.ExecContext(ctx, "prepared"); err != nil {
+				t.Fatal(err)
+			}
+			// 事务内可见未提交的写入.
+			var n int
+			if err := cmd.QueryRowContext(ctx, "SELECT COUNT(*) FROM tenant_items").Scan(&n); err != nil || n != 2 {
+				t.Errorf("count in transaction = %d, %v, want 2", n, err)
+			}
+			panic(failed)
+		})
+	}()
+	if n := countItems(t, p); n != 0 {
+		t.Fatalf("%d rows after rollback, want 0", n)
+	}
+
+	// 事务外 PrepareContext 使用连接池.
+	stmt, err := p.UseCommand(ctx).PrepareContext(ctx, "INSERT INTO tenant_items (name) VALUES (?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if _, err := stmt.ExecContext(ctx, "pooled"); err != nil {
+		t.Fatal(err)
+	}
+	if n := countItems(t, p); n != 1 {
+		t.Errorf("%d rows after pooled insert, want 1", n)
+	}
+}
@@ -0,0 +1,37 @@
+package db
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"mini_transaction/transaction"
+	"strings"
+)
+
+// commentEscaper 转义注释内容, 防止提前闭合注释.
+var commentEscaper = strings.NewReplacer("/*", "/ *", "*/", "* /")
+
+// transComment 在语句前添加事务注释.
+type transComment struct {
+	content string
+}
+
+func newTransComment(tc transaction.TransContext) transComment {
+	content := "/* tx:" + tc.ID()
+	if label := tc.Label(); label != "" {
+		content += " label:" + commentEscaper.Replace(label)
+	}
+	return transComment{content: content + " */"}
+}
+
+// ModifyStatement 将注释设置为各类语句首个子句的前置表达式.
+func (c transComment) ModifyStatement(stmt *gorm.Statement) {
+	for _, name := range []string{"SELECT", "INSERT", "UPDATE", "DELETE"} {
+		cl := stmt.Clauses[name]
+		cl.BeforeExpression = c
+		stmt.Clauses[name] = cl
+	}
+}
+
+func (c transComment) Build(builder clause.Builder) {
+	builder.WriteString(c.content)
+}
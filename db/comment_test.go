@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"mini_transaction/transaction"
+	"strings"
+	"testing"
+)
+
+func TestTransactionComment(t *testing.T) {
+	base, fake := newFakeMySQLProvider(t)
+	p := NewProviderWithOptions(base.loadSource(), WithTransactionComment())
+	ctx := transaction.WithLabel(context.Background(), "check*/out")
+
+	var sqls []string
+	var txID interface{}
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		txID = transaction.LogFields(ctx)["tx_id"]
+		dry := p.UseDB(ctx).Session(&gorm.Session{DryRun: true})
+		sqls = append(sqls,
+			dry.Create(&tenantItem{Name: "a"}).Statement.SQL.String(),
+			dry.Where("id = ?", 1).Find(new([]tenantItem)).Statement.SQL.String(),
+			dry.Model(&tenantItem{ID: 1}).Update("name", "b").Statement.SQL.String(),
+			dry.Delete(&tenantItem{ID: 1}).Statement.SQL.String(),
+		)
+		// 注释随语句一起发送给数据库.
+		if err := p.UseDB(ctx).Model(&tenantItem{ID: 1}).Update("name", "c").Error; err != nil {
+			return err
+		}
+		sqls = append(sqls, lastStatement(fake))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "/* tx:" + txID.(string) + " label:check* /out */ "
+	for _, sql := range sqls {
+		if !strings.HasPrefix(sql, want) {
+			t.Errorf("sql = %q, want prefix %q", sql, want)
+		}
+	}
+
+	// 事务外不添加注释.
+	sql := p.UseDB(context.Background()).Session(&gorm.Session{DryRun: true}).Find(new([]tenantItem)).Statement.SQL.String()
+	if strings.HasPrefix(sql, "/*") {
+		t.Errorf("sql outside transaction = %q", sql)
+	}
+}
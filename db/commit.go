@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+	"mini_transaction/transaction"
+	"time"
+)
+
+// CommitTimeoutError 代表提交超时.
+//
+// COMMIT 已发送到数据库, 事务最终可能已提交, 调用方需自行确认结果.
+// 匹配 transaction.ErrOutcomeUnknown 及 context.DeadlineExceeded, 事务的提交及回滚回调均不执行.
+type CommitTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *CommitTimeoutError) Error() string {
+	return fmt.Sprintf("commit timeout after %s, outcome unknown", e.Timeout)
+}
+
+func (e *CommitTimeoutError) Is(target error) bool {
+	return target == transaction.ErrOutcomeUnknown
+}
+
+func (e *CommitTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// runTransaction 开启事务执行 fc, 成功时提交, 返回错误或 panic 时回滚.
+//
+// 同 gorm.DB.Transaction, 提交作为独立阶段执行以支持提交超时.
+func (p *TransProvider) runTransaction(db *gorm.DB, fc func(tx *gorm.DB) error) (err error) {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	// 提交开始后不再回滚, 提交失败时事务已由数据库回滚.
+	committing := false
+	panicked := true
+	defer func() {
+		if !committing && (panicked || err != nil) {
+			tx.Rollback()
+		}
+	}()
+	err = fc(tx)
+	panicked = false
+	if err != nil {
+		return err
+	}
+	committing = true
+	return p.commit(tx)
+}
+
+// commit 提交事务, 设置提交超时时超时返回 *CommitTimeoutError.
+//
+// database/sql 提交不接受 context, 超时后 COMMIT 继续执行直到驱动返回, 由 DSN 读写超时限制,
+// 结果通过 DB Logger 输出.
+func (p *TransProvider) commit(tx *gorm.DB) error {
+	if p.commitTimeout <= 0 {
+		return tx.Commit().Error
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- tx.Commit().Error
+	}()
+	timer := time.NewTimer(p.commitTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		go func() {
+			if err := <-done; err != nil {
+				tx.Logger.Error(tx.Statement.Context, "commit finished after timeout %s: %v", p.commitTimeout, err)
+			} else {
+				tx.Logger.Warn(tx.Statement.Context, "commit succeeded after timeout %s", p.commitTimeout)
+			}
+		}()
+		return &CommitTimeoutError{Timeout: p.commitTimeout}
+	}
+}
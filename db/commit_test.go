@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"mini_transaction/transaction"
+	"testing"
+	"time"
+)
+
+// lastStatement 返回最后执行的语句.
+func lastStatement(fake *fakeConnector) string {
+	stmts := fake.statements()
+	if len(stmts) == 0 {
+		return ""
+	}
+	return stmts[len(stmts)-1].query
+}
+
+func TestAbortCommitOnContextDone(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		opts  []ProviderOption
+		abort bool
+	}{
+		{"default", nil, true},
+		{"abort", []ProviderOption{AbortCommitOnContextDone(true)}, true},
+		{"commit anyway", []ProviderOption{AbortCommitOnContextDone(false)}, false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			base, fake := newFakeMySQLProvider(t)
+			p := NewProviderWithOptions(base.loadSource(), c.opts...)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			rollbacked := false
+			err := p.Transaction(ctx, func(ctx context.Context) error {
+				p.OnRollbacked(ctx, func(context.Context, error) { rollbacked = true })
+				if err := p.UseDB(ctx).Exec("UPDATE tenant_items SET name = ?", "a").Error; err != nil {
+					return err
+				}
+				cancel()
+				return nil
+			})
+			last := lastStatement(fake)
+			if c.abort {
+				if !errors.Is(err, context.Canceled) || last != "ROLLBACK" || !rollbacked {
+					t.Errorf("err = %v, last statement %q, rollbacked %v, want abort", err, last, rollbacked)
+				}
+				return
+			}
+			if err != nil || last != "COMMIT" || rollbacked {
+				t.Errorf("err = %v, last statement %q, rollbacked %v, want commit", err, last, rollbacked)
+			}
+		})
+	}
+}
+
+func TestCommitTimeout(t *testing.T) {
+	base, fake := newFakeMySQLProvider(t)
+	p := NewProviderWithOptions(base.loadSource(), WithCommitTimeout(20*time.Millisecond))
+	ctx := context.Background()
+
+	var committed, rollbacked bool
+	run := func() error {
+		committed, rollbacked = false, false
+		return p.Transaction(ctx, func(ctx context.Context) error {
+			p.OnCommitted(ctx, func(context.Context) { committed = true })
+			p.OnRollbacked(ctx, func(context.Context, error) { rollbacked = true })
+			return p.UseDB(ctx).Exec("UPDATE tenant_items SET name = ?", "a").Error
+		})
+	}
+
+	if err := run(); err != nil || !committed {
+		t.Fatalf("err = %v, committed %v, want commit", err, committed)
+	}
+
+	// 提交超时: 结果未知, 提交及回滚回调均不执行.
+	fake.commitDelay = 200 * time.Millisecond
+	start := time.Now()
+	err := run()
+	var timeoutErr *CommitTimeoutError
+	if !errors.As(err, &timeoutErr) || !errors.Is(err, transaction.ErrOutcomeUnknown) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want *CommitTimeoutError", err)
+	}
+	if elapsed := time.Since(start); elapsed >= fake.commitDelay {
+		t.Errorf("returned after %v, want before the commit finished", elapsed)
+	}
+	if committed || rollbacked {
+		t.Errorf("committed %v, rollbacked %v, want no callbacks", committed, rollbacked)
+	}
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"gorm.io/gorm"
+)
+
+// Composite 组合多个 Provider, 用于依次写入多个物理库.
+type Composite struct {
+	providers []*TransProvider
+}
+
+var _ Provider = new(Composite)
+
+// CompositeProvider 组合多个 Provider.
+//
+// UseDB 等方法使用首个 Provider, 任一 Provider 在事务内时 InTransaction 返回 true.
+// 未传入 Provider 时 panic.
+func CompositeProvider(providers ...*TransProvider) *Composite {
+	if len(providers) == 0 {
+		panic("db: CompositeProvider requires at least one provider")
+	}
+	return &Composite{providers: providers}
+}
+
+// UseDB 返回首个 Provider 的 DB.
+func (c *Composite) UseDB(ctx context.Context) *gorm.DB {
+	return c.providers[0].UseDB(ctx)
+}
+
+// UseWriteDB 返回首个 Provider 的写库.
+func (c *Composite) UseWriteDB(ctx context.Context) *gorm.DB {
+	return c.providers[0].UseWriteDB(ctx)
+}
+
+// UseReadDB 返回首个 Provider 的读库.
+func (c *Composite) UseReadDB(ctx context.Context) *gorm.DB {
+	return c.providers[0].UseReadDB(ctx)
+}
+
+// ExecRaw 通过首个 Provider 执行 SQL.
+func (c *Composite) ExecRaw(ctx context.Context, sql string, args ...interface{}) (int64, error) {
+	return c.providers[0].ExecRaw(ctx, sql, args...)
+}
+
+// QueryRaw 通过首个 Provider 执行查询.
+func (c *Composite) QueryRaw(ctx context.Context, dest interface{}, sql string, args ...interface{}) error {
+	return c.providers[0].QueryRaw(ctx, dest, sql, args...)
+}
+
+// InTransaction 任一 Provider 在事务内时返回 true.
+func (c *Composite) InTransaction(ctx context.Context) bool {
+	for _, p := range c.providers {
+		if p.InTransaction(ctx) {
+			return true
+		}
+	}
+	return false
+}
+
+// CompositeTransaction 在全部 Provider 开启事务后执行回调.
+//
+// 事务以逆序嵌套开启, 首个 Provider 最先提交, 回调返回错误时全部回滚.
+//
+// 非原子操作: 提交失败时, 之前的 Provider 已提交的数据不会回滚, 之后的 Provider 回滚.
+func (c *Composite) CompositeTransaction(ctx context.Context, callback func(context.Context) error) error {
+	return c.transaction(ctx, len(c.providers)-1, callback)
+}
+
+// transaction 以 i 之前的 Provider 在内层嵌套开启事务, 使首个 Provider 最先提交.
+func (c *Composite) transaction(ctx context.Context, i int, callback func(context.Context) error) error {
+	if i < 0 {
+		return callback(ctx)
+	}
+	return c.providers[i].Transaction(ctx, func(ctx context.Context) error {
+		return c.transaction(ctx, i-1, callback)
+	})
+}
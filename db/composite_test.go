@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCompositeTransaction(t *testing.T) {
+	first := newSQLiteProvider(t, new(tenantItem))
+	second, fake := newFakeMySQLProvider(t)
+	c := CompositeProvider(first, second)
+	ctx := context.Background()
+	write := func(ctx context.Context) error {
+		if !c.InTransaction(ctx) {
+			t.Error("composite callback not in transaction")
+		}
+		if err := c.UseDB(ctx).Create(&tenantItem{Name: "item"}).Error; err != nil {
+			return err
+		}
+		return second.UseDB(ctx).Exec("UPDATE tenant_items SET name = ?", "item").Error
+	}
+
+	// 回调失败时全部回滚.
+	errRollback := errors.New("rollback")
+	err := c.CompositeTransaction(ctx, func(ctx context.Context) error {
+		if err := write(ctx); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatal(err)
+	}
+	if n := countItems(t, first); n != 0 {
+		t.Errorf("first has %d rows after rollback, want 0", n)
+	}
+	if got := lastStatement(fake); got != "ROLLBACK" {
+		t.Errorf("second last statement = %q, want ROLLBACK", got)
+	}
+
+	// 后一个 Provider 提交失败不影响已提交的首个 Provider.
+	errCommit := errors.New("connection lost")
+	fake.errs = map[string]error{"COMMIT": errCommit}
+	err = c.CompositeTransaction(ctx, write)
+	if !errors.Is(err, errCommit) {
+		t.Fatalf("err = %v, want %v", err, errCommit)
+	}
+	if n := countItems(t, first); n != 1 {
+		t.Errorf("first has %d rows, want 1 committed", n)
+	}
+	if c.InTransaction(ctx) {
+		t.Error("InTransaction outside transaction")
+	}
+}
+
+func TestCompositeProviderEmpty(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("CompositeProvider() did not panic")
+		}
+	}()
+	CompositeProvider()
+}
@@ -0,0 +1,210 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrConnNotPinnable         = errors.New("connection pool cannot be pinned")
+	ErrRowStatementUnsupported = errors.New("connection setting unsupported for Row and Rows statements")
+)
+
+// pinnedConnKey 独占连接在语句实例中存储的 Key, 同一语句的多个 connSettingPlugin 共享.
+const pinnedConnKey = "mini_transaction:pinned_conn"
+
+// connSettingPlugin 通过 gorm 回调在语句所在连接上设置及恢复会话级配置.
+//
+// 在读写分离插件切换连接池后执行, 非事务连接池替换为独占连接, 保证设置与语句使用同一连接.
+// 写语句在 gorm 默认事务开启前设置, 提交后恢复, 默认事务同样使用独占连接.
+// 执行前读取原值, 执行后恢复. 配置值通过 db.Set(name, value) 绑定, 未绑定时不执行.
+//
+// Row, Rows 语句返回时结果集未读取, 无法在同一连接上恢复配置, 仅事务内且配置了 setLocal 时支持,
+// 其他情况返回 ErrRowStatementUnsupported.
+type connSettingPlugin struct {
+	name string
+	// 读取当前值的查询.
+	get string
+	// 当前值的扫描目标.
+	newValue func() interface{}
+	// 设置语句, 以配置值为参数.
+	set string
+	// 事务内的设置语句, 作用至事务结束, 无需恢复. 为空时事务内同样读取并恢复原值.
+	setLocal string
+	// 获取独占连接失败时的错误.
+	unpinnable error
+}
+
+func (p connSettingPlugin) Name() string {
+	return p.name
+}
+
+func (p connSettingPlugin) Initialize(db *gorm.DB) error {
+	reset := p.name + ":reset"
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:begin_transaction").Register(p.name, p.before); err != nil {
+		return err
+	}
+	if err := cb.Create().After("gorm:commit_or_rollback_transaction").Register(reset, p.after); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register(p.name, p.before); err != nil {
+		return err
+	}
+	if err := cb.Query().After("gorm:query").Register(reset, p.after); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:begin_transaction").Register(p.name, p.before); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:commit_or_rollback_transaction").Register(reset, p.after); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:begin_transaction").Register(p.name, p.before); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:commit_or_rollback_transaction").Register(reset, p.after); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register(p.name, p.beforeRow); err != nil {
+		return err
+	}
+	if err := cb.Raw().Before("gorm:raw").Register(p.name, p.before); err != nil {
+		return err
+	}
+	return cb.Raw().After("gorm:raw").Register(reset, p.after)
+}
+
+// prevKey 原值在语句实例中存储的 Key.
+func (p connSettingPlugin) prevKey() string {
+	return p.name + ":prev"
+}
+
+// pinKey 插件使用独占连接的标记在语句实例中存储的 Key.
+func (p connSettingPlugin) pinKey() string {
+	return p.name + ":pinned"
+}
+
+// pinnedConn 代表独占连接及替换前的连接池.
+type pinnedConn struct {
+	conn *sql.Conn
+	pool gorm.ConnPool
+	// 使用独占连接的插件数, 最后一个插件恢复配置后释放.
+	refs int
+}
+
+// before 语句执行前设置配置.
+func (p connSettingPlugin) before(db *gorm.DB) {
+	v, ok := db.Get(p.name)
+	if !ok || db.Error != nil {
+		return
+	}
+	stmt := db.Statement
+	if isTxConnPool(stmt.ConnPool) && p.setLocal != "" {
+		if _, err := stmt.ConnPool.ExecContext(stmt.Context, p.setLocal, v); err != nil {
+			_ = db.AddError(err)
+		}
+		return
+	}
+	if !isTxConnPool(stmt.ConnPool) && !p.pin(db) {
+		return
+	}
+	prev := p.newValue()
+	if err := stmt.ConnPool.QueryRowContext(stmt.Context, p.get).Scan(prev); err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	db.InstanceSet(p.prevKey(), prev)
+	if _, err := stmt.ConnPool.ExecContext(stmt.Context, p.set, v); err != nil {
+		_ = db.AddError(err)
+	}
+}
+
+// beforeRow Row, Rows 语句执行前设置配置, 仅支持事务内的 setLocal.
+func (p connSettingPlugin) beforeRow(db *gorm.DB) {
+	v, ok := db.Get(p.name)
+	if !ok || db.Error != nil {
+		return
+	}
+	stmt := db.Statement
+	if !isTxConnPool(stmt.ConnPool) || p.setLocal == "" {
+		_ = db.AddError(fmt.Errorf("%s: %w", p.name, ErrRowStatementUnsupported))
+		return
+	}
+	if _, err := stmt.ConnPool.ExecContext(stmt.Context, p.setLocal, v); err != nil {
+		_ = db.AddError(err)
+	}
+}
+
+// pin 以独占连接替换语句连接池, 已由其他插件替换时复用.
+func (p connSettingPlugin) pin(db *gorm.DB) bool {
+	stmt := db.Statement
+	if v, ok := db.InstanceGet(pinnedConnKey); ok {
+		v.(*pinnedConn).refs++
+		db.InstanceSet(p.pinKey(), true)
+		return true
+	}
+	sqlDB, err := sqlDBOf(stmt.ConnPool)
+	if err != nil {
+		_ = db.AddError(p.unpinnable)
+		return false
+	}
+	conn, err := sqlDB.Conn(stmt.Context)
+	if err != nil {
+		_ = db.AddError(err)
+		return false
+	}
+	db.InstanceSet(pinnedConnKey, &pinnedConn{conn: conn, pool: stmt.ConnPool, refs: 1})
+	db.InstanceSet(p.pinKey(), true)
+	stmt.ConnPool = conn
+	return true
+}
+
+// after 语句执行后恢复原值, 最后一个插件释放独占连接.
+func (p connSettingPlugin) after(db *gorm.DB) {
+	stmt := db.Statement
+	var pc *pinnedConn
+	if _, ok := db.InstanceGet(p.pinKey()); ok {
+		v, _ := db.InstanceGet(pinnedConnKey)
+		pc = v.(*pinnedConn)
+	}
+	if prev, ok := db.InstanceGet(p.prevKey()); ok {
+		// gorm 默认事务提交后语句连接池已还原, 使用独占连接恢复.
+		var conn gorm.ConnPool = stmt.ConnPool
+		if pc != nil {
+			conn = pc.conn
+		}
+		// 使用独立 context, 语句超时后仍可恢复.
+		if _, err := conn.ExecContext(context.Background(), p.set, prev); err != nil {
+			_ = db.AddError(err)
+		}
+	}
+	if pc == nil {
+		return
+	}
+	if pc.refs--; pc.refs > 0 {
+		return
+	}
+	stmt.ConnPool = pc.pool
+	_ = pc.conn.Close()
+}
+
+// isTxConnPool 判断连接池是否为事务连接.
+func isTxConnPool(pool gorm.ConnPool) bool {
+	_, ok := pool.(gorm.TxCommitter)
+	return ok
+}
+
+// sqlDBOf 返回连接池对应的 *sql.DB.
+func sqlDBOf(pool gorm.ConnPool) (*sql.DB, error) {
+	switch pool := pool.(type) {
+	case *sql.DB:
+		return pool, nil
+	case gorm.GetDBConnector:
+		return pool.GetDBConn()
+	}
+	return nil, ErrConnNotPinnable
+}
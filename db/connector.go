@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// passwordConnector 每次建立连接时获取密码.
+type passwordConnector struct {
+	drv  driver.Driver
+	opts *Options
+	dsn  func(opts *Options, password string) string
+	// RotateCredentials 设置的账号密码, 未轮换时为 nil.
+	creds atomic.Pointer[credentials]
+	// 通过 openConnector 创建的连接池, 关闭时从 connectors 移除.
+	sqlDB *sql.DB
+
+	// 恢复连接最大存活时间的定时器, 见 rotatablePool.cycle.
+	cycleMut   sync.Mutex
+	cycleTimer *time.Timer
+}
+
+// credentials 轮换后的账号密码.
+type credentials struct {
+	userName string
+	password string
+}
+
+// NewPasswordConnector 创建每次建立连接时获取密码的 driver.Connector.
+//
+// 配置 PasswordProvider 时通过其获取密码, 否则使用 Password.
+// 密码轮换后新建连接使用新密码, 已有连接不受影响.
+//
+// dsn 使用配置及密码生成连接串.
+func NewPasswordConnector(drv driver.Driver, opts *Options, dsn func(opts *Options, password string) string) driver.Connector {
+	return &passwordConnector{drv: drv, opts: opts, dsn: dsn}
+}
+
+func (c *passwordConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	opts, password, err := c.credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.connect(ctx, opts, password)
+}
+
+// connect 使用配置及密码建立连接.
+func (c *passwordConnector) connect(ctx context.Context, opts *Options, password string) (driver.Conn, error) {
+	dsn := c.dsn(opts, password)
+	if dc, ok := c.drv.(driver.DriverContext); ok {
+		connector, err := dc.OpenConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return connector.Connect(ctx)
+	}
+	return c.drv.Open(dsn)
+}
+
+func (c *passwordConnector) Driver() driver.Driver {
+	return c.drv
+}
+
+// Close 由 sql.DB.Close 调用, 移除轮换记录并停止恢复连接最大存活时间的定时器.
+func (c *passwordConnector) Close() error {
+	if c.sqlDB != nil {
+		connectors.Delete(c.sqlDB)
+	}
+	c.cycleMut.Lock()
+	defer c.cycleMut.Unlock()
+	if c.cycleTimer != nil {
+		c.cycleTimer.Stop()
+		c.cycleTimer = nil
+	}
+	return nil
+}
+
+// credentials 返回建立连接使用的配置及密码, 已轮换时使用轮换的账号密码.
+func (c *passwordConnector) credentials(ctx context.Context) (*Options, string, error) {
+	creds := c.creds.Load()
+	if creds == nil {
+		password, err := c.opts.password(ctx)
+		return c.opts, password, err
+	}
+	return c.withCredentials(creds), creds.password, nil
+}
+
+// withCredentials 返回使用轮换账号的配置副本, 账号为空时沿用原账号.
+func (c *passwordConnector) withCredentials(creds *credentials) *Options {
+	opts := *c.opts
+	if creds.userName != "" {
+		opts.UserName = &creds.userName
+	}
+	return &opts
+}
+
+// useConnector 判断是否通过 passwordConnector 建立连接.
+func (o *Options) useConnector() bool {
+	return o.PasswordProvider != nil || o.RotatableCredentials
+}
+
+// password 获取数据库密码.
+func (o *Options) password(ctx context.Context) (string, error) {
+	if o.PasswordProvider == nil {
+		return deref(o.Password), nil
+	}
+	password, err := o.PasswordProvider(ctx)
+	if err != nil {
+		return "", fmt.Errorf("resolve password for %s: %w", o.fullName(), err)
+	}
+	return password, nil
+}
+
+// PasswordFunc 获取数据库密码, 用于对接密钥管理服务.
+type PasswordFunc func(ctx context.Context, o *Options) (string, error)
+
+// WithPasswordFunc 创建连接时通过 f 获取密码, 替代 Password 及 PasswordProvider, 主库及从库均使用.
+//
+// 密码仅在创建时获取一次, 需每次建立连接时获取时使用 PasswordProvider.
+// f 返回错误时创建失败, 错误包含库地址, 不包含密码.
+func WithPasswordFunc(f PasswordFunc) OpenOption {
+	return func(o *openOptions) {
+		o.passwordFunc = f
+	}
+}
+
+// resolvePassword 通过 f 获取密码, 返回使用该密码的配置副本, f 为空时返回 o.
+func (o *Options) resolvePassword(ctx context.Context, f PasswordFunc) (*Options, error) {
+	if f == nil {
+		return o, nil
+	}
+	password, err := f(ctx, o)
+	if err != nil {
+		return nil, fmt.Errorf("resolve password for %s: %w", o.fullName(), err)
+	}
+	resolved := *o
+	resolved.Password = &password
+	resolved.PasswordProvider = nil
+	return &resolved, nil
+}
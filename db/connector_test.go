@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPasswordConnectorRotatingPassword(t *testing.T) {
+	drv := &passwordDriver{fake: &fakeConnector{}}
+	var calls int32
+	passwords := []string{"secret-1", "secret-2"}
+	errVault := errors.New("vault sealed")
+	opts := &Options{
+		UserName: Ptr("app"),
+		Password: Ptr("static"),
+		PasswordProvider: func(context.Context) (string, error) {
+			n := atomic.AddInt32(&calls, 1)
+			if int(n) > len(passwords) {
+				return "", errVault
+			}
+			return passwords[n-1], nil
+		},
+	}
+	connector := NewPasswordConnector(drv, opts, func(opts *Options, password string) string {
+		return deref(opts.UserName) + ":" + password
+	})
+	sqlDB := openConnector(connector)
+	defer sqlDB.Close()
+	sqlDB.SetMaxIdleConns(0)
+	ctx := context.Background()
+
+	// 每次建立连接获取密码, 而非每条语句.
+	for _, want := range passwords {
+		conn, err := sqlDB.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			if _, err := conn.ExecContext(ctx, "SELECT 1"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		_ = conn.Close()
+		if got := drv.lastDSN(); got != "app:"+want {
+			t.Errorf("dsn = %q, want app:%s", got, want)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("PasswordProvider called %d times, want 2", n)
+	}
+
+	// 获取密码失败时建立连接失败, 错误不包含密码.
+	_, err := sqlDB.Conn(ctx)
+	if !errors.Is(err, errVault) {
+		t.Fatalf("err = %v, want %v", err, errVault)
+	}
+	if strings.Contains(err.Error(), "static") {
+		t.Errorf("error contains password: %v", err)
+	}
+}
+
+func TestWithPasswordFunc(t *testing.T) {
+	var dialed []string
+	dial := func(o *Options) (gorm.Dialector, error) {
+		dialed = append(dialed, deref(o.Password))
+		sqlDB, _ := newFakeDB(nil)
+		return mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), nil
+	}
+	options := func(host string) *Options {
+		return &Options{Host: Ptr(host), Port: Ptr(3306), UserName: Ptr("app"), DBName: Ptr("app"), Password: Ptr("yaml-secret")}
+	}
+	opts := &RWOptions{Write: options("w"), Reads: []*Options{options("r")}}
+	config := &gorm.Config{Logger: logger.Discard, DisableAutomaticPing: true}
+
+	// 未设置时使用 Password.
+	if _, err := opts.OpenDB(dial, config); err != nil {
+		t.Fatal(err)
+	}
+	vault := func(_ context.Context, o *Options) (string, error) {
+		return "vault-" + *o.Host, nil
+	}
+	if _, err := opts.OpenDB(dial, config, WithPasswordFunc(vault)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"yaml-secret", "yaml-secret", "vault-w", "vault-r"}
+	if strings.Join(dialed, ",") != strings.Join(want, ",") {
+		t.Errorf("dialed passwords = %v, want %v", dialed, want)
+	}
+	if *opts.Write.Password != "yaml-secret" {
+		t.Error("PasswordFunc modified the options")
+	}
+
+	errVault := errors.New("vault sealed")
+	_, err := opts.OpenDB(dial, config, WithPasswordFunc(func(context.Context, *Options) (string, error) {
+		return "", errVault
+	}))
+	if !errors.Is(err, errVault) {
+		t.Fatalf("err = %v, want %v", err, errVault)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "w:3306/app") || strings.Contains(msg, "yaml-secret") {
+		t.Errorf("error %q should name the db and omit the password", msg)
+	}
+}
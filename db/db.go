@@ -3,11 +3,21 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"gorm.io/gorm"
 	"gorm.io/plugin/dbresolver"
 	"math/rand"
 	"mini_transaction/transaction"
 	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrDBNotFound                  = errors.New("matching database not found")
+	ErrStaleTransactionContext     = errors.New("stale transaction context")
+	ErrConcurrentNestedTransaction = errors.New("concurrent nested transaction on the same context")
 )
 
 type Command interface {
@@ -24,6 +34,9 @@ type Provider interface {
 	//
 	// 不在事务上下文内时, 依据执行语句动态选择读库或写库.
 	//
+	// 返回 DB 的 Statement.Context 派生自传入 context, 模型钩子 (BeforeCreate, AfterSave 等)
+	// 可通过 tx.Statement.Context 调用 InTransaction, OnCommitted 等方法.
+	//
 	// 无匹配 DB 时 panic.
 	UseDB(context.Context) *gorm.DB
 
@@ -32,32 +45,153 @@ type Provider interface {
 	// 无匹配 DB 时 panic.
 	UseWriteDB(context.Context) *gorm.DB
 
+	// UseReadDB 实现通过 context 选择读库, 用于导出等大量读取的场景.
+	//
+	// 事务外返回读库, 配置从库时读取从库. 事务内默认返回事务 DB 以读取未提交的写入,
+	// 通过 WithReplicaRead 标记的 context 仍返回读库.
+	//
+	// 无匹配 DB 时 panic.
+	UseReadDB(context.Context) *gorm.DB
+
 	// UseCommand 返回标准库兼容的执行接口.
 	UseCommand(context.Context) Command
+
+	// ExecRaw 通过写库执行 SQL, 返回影响行数.
+	ExecRaw(ctx context.Context, sql string, args ...interface{}) (int64, error)
+
+	// QueryRaw 执行查询 SQL 并将结果写入 dest.
+	QueryRaw(ctx context.Context, dest interface{}, sql string, args ...interface{}) error
 }
 
 func NewProvider(source Source, scopes ...func(*gorm.DB) *gorm.DB) *TransProvider {
+	return NewProviderWithOptions(source, WithScopes(scopes...))
+}
+
+// NewProviderWithOptions 通过选项创建 Provider.
+func NewProviderWithOptions(source Source, opts ...ProviderOption) *TransProvider {
 	p := &TransProvider{
-		Source:   source,
-		scopes:   scopes,
-		txSuffix: strconv.FormatInt(rand.Int63(), 10),
+		txSuffix:                 strconv.FormatInt(rand.Int63(), 10),
+		abortCommitOnContextDone: true,
+		metrics:                  new(providerMetrics),
+	}
+	p.managerOpts = append(p.managerOpts, transaction.WithDBName(p.getWriteDBName))
+	for _, opt := range opts {
+		opt(p)
 	}
+	p.SwapSource(source)
 	lookupDB := func(ctx context.Context) interface{} {
 		return p.lookupDB(ctx, true)
 	}
-	p.Manager = transaction.NewManager(p.getCtxKey, lookupDB, p.transaction)
+	p.Manager = transaction.NewManager(p.getCtxKey, lookupDB, p.transaction, p.managerOpts...)
 	return p
 }
 
 type TransProvider struct {
-	Source
 	transaction.Manager
 
+	// 当前数据源, 通过 SwapSource 原子替换.
+	source   atomic.Pointer[Source]
 	txSuffix string
 	scopes   []func(*gorm.DB) *gorm.DB
+	// 事务管理器选项.
+	managerOpts []transaction.ManagerOption
+	// 事务配额, 由 NewQuotaProvider 设置.
+	quota *QuotaOptions
+	// context 结束时放弃提交.
+	abortCommitOnContextDone bool
+	// 为事务内语句添加事务注释.
+	txComment bool
+	// 事务语句数限制.
+	statementLimit *StatementLimit
+	// 事务语句统计.
+	stats *statsOptions
+	// 严格校验事务上下文.
+	strict bool
+	// 事务计数及已使用的 DB.
+	metrics *providerMetrics
+	// 嵌套事务使用保存点.
+	nestedSavepoint bool
+	// 提交超时, 0 为不限制.
+	commitTimeout time.Duration
+	// 可靠提交回调配置.
+	durable *durableProviderOptions
+	// 并发根事务数限制.
+	throttle *throttle
+	// WithGORMConfig 设置的 GORM 配置.
+	gormConfig *gorm.Config
+}
+
+var (
+	_ transaction.Manager   = new(TransProvider)
+	_ Source                = new(TransProvider)
+	_ Provider              = new(TransProvider)
+	_ transaction.Unwrapper = new(TransProvider)
+)
+
+// SwapSource 原子替换数据源.
+//
+// 替换后开启的事务使用新数据源, 进行中的事务继续使用开启时的 DB.
+//
+// 事务上下文的 Key 由写库名生成, 新旧数据源写库名不一致时,
+// 进行中事务的 context 在新数据源下不再被识别为事务上下文.
+func (p *TransProvider) SwapSource(source Source) {
+	p.source.Store(&source)
+	// 连接池统计在使用时跟随新数据源.
+	p.metrics.untrackAll()
+}
+
+func (p *TransProvider) loadSource() Source {
+	return *p.source.Load()
+}
+
+// Dialector 返回当前数据源的数据库方言.
+func (p *TransProvider) Dialector() gorm.Dialector {
+	return p.loadSource().Dialector()
+}
+
+// Unwrap 实现 transaction.Unwrapper, 用于 transaction.OnCommittedStrict 等包级函数查找扩展接口.
+func (p *TransProvider) Unwrap() transaction.Manager {
+	return p.Manager
+}
+
+func (p *TransProvider) getWriteDBName(ctx context.Context) string {
+	return p.loadSource().getWriteDBName(ctx)
+}
+
+func (p *TransProvider) getWriteDB(ctx context.Context) *gorm.DB {
+	source := p.loadSource()
+	db := source.getWriteDB(ctx)
+	p.metrics.trackDB(source.getWriteDBName(ctx), db)
+	return db
+}
+
+func (p *TransProvider) getReadDBName(ctx context.Context) string {
+	return p.loadSource().getReadDBName(ctx)
+}
+
+// readPicker 由动态选择读库的数据源实现, 一次选择同时返回读库名及读库.
+type readPicker interface {
+	readDBAndName(ctx context.Context) (string, *gorm.DB)
+}
+
+func (s *namedSource) readDBAndName(ctx context.Context) (string, *gorm.DB) {
+	if picker, ok := s.Source.(readPicker); ok {
+		return picker.readDBAndName(ctx)
+	}
+	return s.Source.getReadDBName(ctx), s.Source.getReadDB(ctx)
 }
 
-var _ transaction.Manager = new(TransProvider)
+func (p *TransProvider) getReadDB(ctx context.Context) *gorm.DB {
+	source := p.loadSource()
+	if picker, ok := source.(readPicker); ok {
+		name, db := picker.readDBAndName(ctx)
+		p.metrics.trackDB(name, db)
+		return db
+	}
+	db := source.getReadDB(ctx)
+	p.metrics.trackDB(source.getReadDBName(ctx), db)
+	return db
+}
 
 type transCtxKey string
 
@@ -67,27 +201,247 @@ type transCtxKey string
 //
 // 返回的 key 需要转换为私有类型, 防止内容污染.
 func (p *TransProvider) getCtxKey(ctx context.Context) interface{} {
-	name := p.getWriteDBName(ctx)
+	return p.ctxKeyOf(p.getWriteDBName(ctx))
+}
+
+// ctxKeyOf 返回库名对应的事务上下文 Key.
+func (p *TransProvider) ctxKeyOf(name string) interface{} {
 	return transCtxKey(name + "." + p.txSuffix)
 }
 
+// UseDB 实现 Provider.UseDB.
+//
+// 事务外的只读 context 返回读库, AfterWrite 标记的时长内返回写库.
+func (p *TransProvider) UseDB(ctx context.Context) *gorm.DB {
+	db, err := p.TryUseDB(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+// TryUseDB 同 UseDB, 以错误代替 panic.
+func (p *TransProvider) TryUseDB(ctx context.Context) (*gorm.DB, error) {
+	db, err := p.findTransDBStrict(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if db != nil {
+		return p.useDB(ctx, db)
+	}
+	if isAfterWrite(ctx) {
+		db = p.getWriteDB(ctx)
+		if db == nil {
+			return nil, p.dbNotFound(ctx)
+		}
+		return p.useDB(ctx, db.Clauses(dbresolver.Write))
+	}
+	if transaction.IsReadOnly(ctx) {
+		db = p.getReadDB(ctx)
+		if db == nil {
+			return nil, p.dbNotFound(ctx)
+		}
+		return p.useDB(ctx, db.Clauses(dbresolver.Read))
+	}
+	return p.useDB(ctx, p.getWriteDB(ctx))
+}
+
+// UseReadDB 实现 Provider.UseReadDB.
+func (p *TransProvider) UseReadDB(ctx context.Context) *gorm.DB {
+	db, err := p.TryUseReadDB(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+// TryUseReadDB 同 UseReadDB, 以错误代替 panic.
+func (p *TransProvider) TryUseReadDB(ctx context.Context) (*gorm.DB, error) {
+	if !isReplicaRead(ctx) {
+		db, err := p.findTransDBStrict(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if db != nil {
+			return p.useDB(ctx, db)
+		}
+	}
+	db := p.getReadDB(ctx)
+	if db == nil {
+		return nil, p.dbNotFound(ctx)
+	}
+	return p.useDB(ctx, db.Clauses(dbresolver.Read))
+}
+
+// UseWriteDB 实现 Provider.UseWriteDB.
+func (p *TransProvider) UseWriteDB(ctx context.Context) *gorm.DB {
+	db, err := p.TryUseWriteDB(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+// TryUseWriteDB 同 UseWriteDB, 以错误代替 panic.
+func (p *TransProvider) TryUseWriteDB(ctx context.Context) (*gorm.DB, error) {
+	db, err := p.findTransDBStrict(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if db == nil {
+		db = p.getWriteDB(ctx)
+		if db == nil {
+			return nil, p.dbNotFound(ctx)
+		}
+		db = db.Clauses(dbresolver.Write)
+	}
+	db, err = p.useDB(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	return withRowLockTimeout(ctx, db), nil
+}
+
+// ExecRaw 实现 Provider.ExecRaw.
+func (p *TransProvider) ExecRaw(ctx context.Context, sql string, args ...interface{}) (int64, error) {
+	db := p.UseWriteDB(ctx).Exec(sql, args...)
+	return db.RowsAffected, db.Error
+}
+
+// QueryRaw 实现 Provider.QueryRaw.
+func (p *TransProvider) QueryRaw(ctx context.Context, dest interface{}, sql string, args ...interface{}) error {
+	return p.UseDB(ctx).Raw(sql, args...).Scan(dest).Error
+}
+
+// useDB 绑定 context 并应用 scopes.
+//
+// 绑定的 context 携带事务上下文, 模型钩子依赖 Statement.Context 查找事务, 不可省略.
+func (p *TransProvider) useDB(ctx context.Context, db *gorm.DB) (*gorm.DB, error) {
+	if db == nil {
+		return nil, p.dbNotFound(ctx)
+	}
+	if p.gormConfig != nil {
+		db = withGORMConfig(db, p.gormConfig)
+	}
+	opCtx, cancel := applyOperationTimeout(ctx)
+	db = applyQueryLogger(ctx, db.WithContext(opCtx)).Scopes(p.scopes...)
+	if cancel != nil {
+		db = withOperationCancel(db, cancel)
+	}
+	if p.statementLimit != nil {
+		db = p.withStatementCounter(ctx, db)
+	}
+	if p.stats != nil {
+		db = p.withStats(ctx, db)
+	}
+	if p.quota != nil {
+		db = p.withQuota(ctx, db)
+	}
+	return db, nil
+}
+
+// findTransDBStrict 查找事务上下文 DB.
+//
+// 严格模式下 context 携带已结束的事务上下文时返回 ErrStaleTransactionContext.
+// context 超出 transaction.NewBoundaryEnforcingManager 的回调范围时返回 transaction.ErrContextBoundaryViolation.
+func (p *TransProvider) findTransDBStrict(ctx context.Context) (*gorm.DB, error) {
+	if err := transaction.CheckBoundary(ctx); err != nil {
+		return nil, err
+	}
+	if !p.strict {
+		return p.findTransDB(ctx), nil
+	}
+	tc, ok := ctx.Value(p.getCtxKey(ctx)).(transaction.TransContext)
+	if !ok {
+		return nil, nil
+	}
+	if !tc.InTransaction() {
+		return nil, fmt.Errorf("%w: transaction %s ended at %s",
+			ErrStaleTransactionContext, tc.ID(), tc.EndedAt().Format(time.RFC3339Nano))
+	}
+	return tc.GetTransDB().(*gorm.DB), nil
+}
+
+// derive 创建共享数据源及事务上下文的 Provider.
+//
+// 数据源委托给 p, p 替换数据源后派生 Provider 同样生效.
+func (p *TransProvider) derive() *TransProvider {
+	d := &TransProvider{
+		Manager:        p.Manager,
+		txSuffix:       p.txSuffix,
+		scopes:         p.scopes,
+		gormConfig:     p.gormConfig,
+		statementLimit: p.statementLimit,
+		stats:          p.stats,
+		strict:         p.strict,
+		metrics:        p.metrics,
+	}
+	d.SwapSource(p)
+	return d
+}
+
+// lookupErrorSource 由可返回 DB 查找失败原因的数据源实现.
+type lookupErrorSource interface {
+	lookupError(ctx context.Context) error
+}
+
+// dbNotFound 返回 DB 查找失败的错误, 数据源未提供原因时返回 ErrDBNotFound.
+func (p *TransProvider) dbNotFound(ctx context.Context) error {
+	if s, ok := p.loadSource().(lookupErrorSource); ok {
+		if err := s.lookupError(ctx); err != nil {
+			return err
+		}
+	}
+	return ErrDBNotFound
+}
+
 // lookupDB 查找非事务上下文 DB.
 func (p *TransProvider) lookupDB(ctx context.Context, write bool) *gorm.DB {
 	if write {
-		return p.getWriteDB(ctx).Clauses(dbresolver.Write)
+		db := p.getWriteDB(ctx)
+		if db == nil {
+			return nil
+		}
+		return db.Clauses(dbresolver.Write)
 	}
 	return p.getReadDB(ctx)
 }
 
-// findTransDB 查找事务上下文 DB.
-func (p *TransProvider) findTransDB(ctx context.Context) *gorm.DB {
+// findTransContext 查找事务上下文, 不在事务内返回 nil.
+func (p *TransProvider) findTransContext(ctx context.Context) transaction.TransContext {
 	tc, ok := ctx.Value(p.getCtxKey(ctx)).(transaction.TransContext)
 	if ok && tc.InTransaction() {
+		return tc
+	}
+	return nil
+}
+
+// findTransDB 查找事务上下文 DB.
+func (p *TransProvider) findTransDB(ctx context.Context) *gorm.DB {
+	if tc := p.findTransContext(ctx); tc != nil {
 		return tc.GetTransDB().(*gorm.DB)
 	}
 	return nil
 }
 
+// nestedGuardKey 嵌套事务进行标记在事务范围数据中的 Key, 以事务上下文节点区分.
+type nestedGuardKey struct {
+	tc transaction.TransContext
+}
+
+// enterNested 标记从 context 所在事务节点开启嵌套事务.
+//
+// 同一节点已有进行中的嵌套事务时返回 false, 顺序调用不受影响.
+func (p *TransProvider) enterNested(ctx context.Context) (release func(), ok bool) {
+	tc := p.findTransContext(ctx)
+	v, _ := tc.LoadOrStore(nestedGuardKey{tc: tc}, new(int32))
+	flag := v.(*int32)
+	if !atomic.CompareAndSwapInt32(flag, 0, 1) {
+		return nil, false
+	}
+	return func() { atomic.StoreInt32(flag, 0) }, true
+}
+
 // isInTransaction 判断当前 context 是否在事务上下文.
 func (p *TransProvider) isInTransaction(ctx context.Context) bool {
 	tc, ok := ctx.Value(p.getCtxKey(ctx)).(transaction.TransContext)
@@ -97,13 +451,66 @@ func (p *TransProvider) isInTransaction(ctx context.Context) bool {
 // transaction 执行数据库事务.
 func (p *TransProvider) transaction(ctx context.Context, db interface{}, callback func(db interface{}, bindCtx func(context.Context)) error) error {
 	if p.isInTransaction(ctx) {
+		release, ok := p.enterNested(ctx)
+		if !ok {
+			return ErrConcurrentNestedTransaction
+		}
+		defer release()
+		if p.nestedSavepoint {
+			return p.savepointTransaction(ctx, db.(*gorm.DB), callback)
+		}
 		return callback(db, func(ctx context.Context) {
 			db.(*gorm.DB).Statement.Context = ctx
 		})
 	}
-	return db.(*gorm.DB).Transaction(func(db *gorm.DB) error {
-		return callback(db, func(ctx context.Context) {
-			db.Statement.Context = ctx
+	// XATransaction 内使用 XA 分支, 由 XATransaction 提交或回滚.
+	if branch := takeXABranch(ctx, p.Manager); branch != nil {
+		var tc transaction.TransContext
+		defer func() { releaseNamedLocks(tc) }()
+		return callback(branch.db, func(ctx context.Context) {
+			branch.db.Statement.Context = ctx
+			tc = p.findTransContext(ctx)
 		})
+	}
+	if db.(*gorm.DB) == nil {
+		return p.dbNotFound(ctx)
+	}
+	if p.throttle != nil {
+		release, err := p.throttle.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+	var tc transaction.TransContext
+	// 命名锁随根事务结束释放, 不论提交结果.
+	defer func() { releaseNamedLocks(tc) }()
+	// panic 时记录为回滚.
+	committed := false
+	end, err := p.metrics.begin()
+	if err != nil {
+		return err
+	}
+	defer func() { end(committed) }()
+	err = p.runTransaction(db.(*gorm.DB), func(db *gorm.DB) error {
+		if err := callback(db, func(ctx context.Context) {
+			db.Statement.Context = ctx
+			tc = p.findTransContext(ctx)
+			if p.txComment && tc != nil {
+				newTransComment(tc).ModifyStatement(db.Statement)
+			}
+		}); err != nil {
+			return err
+		}
+		// 提交前 context 已结束, 回滚而非尝试提交.
+		if p.abortCommitOnContextDone && ctx.Err() != nil {
+			return fmt.Errorf("abort commit: %w", ctx.Err())
+		}
+		return nil
 	})
+	committed = err == nil
+	if p.stats != nil && tc != nil {
+		p.reportStats(ctx, tc, err)
+	}
+	return err
 }
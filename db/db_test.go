@@ -0,0 +1,218 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"mini_transaction/transaction"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// countItems 返回 p 写库中 tenantItem 的记录数.
+func countItems(t *testing.T, p *TransProvider) int64 {
+	t.Helper()
+	var n int64
+	if err := p.UseWriteDB(context.Background()).Model(new(tenantItem)).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestSwapSource(t *testing.T) {
+	a, b := newSQLiteProvider(t, new(tenantItem)), newSQLiteProvider(t, new(tenantItem))
+	p := NewProvider(a.loadSource())
+	ctx := context.Background()
+	create := func(ctx context.Context) error {
+		return p.UseDB(ctx).Create(&tenantItem{Name: "item"}).Error
+	}
+
+	if err := p.Transaction(ctx, create); err != nil {
+		t.Fatal(err)
+	}
+	// 进行中的事务继续使用开启时的 DB.
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		p.SwapSource(b.loadSource())
+		return create(ctx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 替换后开启的事务使用新数据源.
+	if err := p.Transaction(ctx, create); err != nil {
+		t.Fatal(err)
+	}
+	if na, nb := countItems(t, a), countItems(t, b); na != 2 || nb != 1 {
+		t.Errorf("rows in a, b = %d, %d, want 2, 1", na, nb)
+	}
+}
+
+func TestStrictTransactionContext(t *testing.T) {
+	base := newSQLiteProvider(t, new(tenantItem))
+	p := NewProviderWithOptions(base.loadSource(), WithStrictTransactionContext())
+	ctx := context.Background()
+
+	var stale, escaped context.Context
+	var txID string
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		stale = ctx
+		txID = transaction.LogFields(ctx)["tx_id"].(string)
+		return p.EscapeTransaction(ctx, func(ctx context.Context) error {
+			escaped = ctx
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tryAccessors := map[string]func(context.Context) error{
+		"TryUseDB": func(ctx context.Context) error {
+			_, err := p.TryUseDB(ctx)
+			return err
+		},
+		"TryUseWriteDB": func(ctx context.Context) error {
+			_, err := p.TryUseWriteDB(ctx)
+			return err
+		},
+		"TryUseCommand": func(ctx context.Context) error {
+			_, err := p.TryUseCommand(ctx)
+			return err
+		},
+	}
+	for name, try := range tryAccessors {
+		err := try(stale)
+		if !errors.Is(err, ErrStaleTransactionContext) || !strings.Contains(err.Error(), txID) {
+			t.Errorf("%s(stale) err = %v, want ErrStaleTransactionContext with tx %s", name, err, txID)
+		}
+		// EscapeTransaction 清除标记后的 context 不受影响.
+		if err := try(escaped); err != nil {
+			t.Errorf("%s(escaped) err = %v", name, err)
+		}
+	}
+
+	accessors := map[string]func(context.Context){
+		"UseDB":      func(ctx context.Context) { p.UseDB(ctx) },
+		"UseWriteDB": func(ctx context.Context) { p.UseWriteDB(ctx) },
+		"UseCommand": func(ctx context.Context) { p.UseCommand(ctx) },
+	}
+	for name, use := range accessors {
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if !errors.Is(err, ErrStaleTransactionContext) {
+					t.Errorf("%s(stale) panicked with %v, want ErrStaleTransactionContext", name, err)
+				}
+			}()
+			use(stale)
+		}()
+		use(escaped)
+	}
+
+	// 非严格模式下使用非事务 DB.
+	_ = base.Transaction(ctx, func(ctx context.Context) error {
+		stale = ctx
+		return nil
+	})
+	if _, err := base.TryUseDB(stale); err != nil {
+		t.Errorf("non-strict TryUseDB(stale) err = %v", err)
+	}
+}
+
+func TestExecRawQueryRaw(t *testing.T) {
+	p := newSQLiteProvider(t, new(tenantItem))
+	ctx := context.Background()
+	for _, name := range []string{"a", "b"} {
+		if err := p.UseDB(ctx).Create(&tenantItem{Name: name}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := func(ctx context.Context) []string {
+		t.Helper()
+		var names []string
+		if err := p.QueryRaw(ctx, &names, "SELECT name FROM tenant_items ORDER BY id"); err != nil {
+			t.Fatal(err)
+		}
+		return names
+	}
+
+	n, err := p.ExecRaw(ctx, "UPDATE tenant_items SET name = ? WHERE name = ?", "c", "a")
+	if err != nil || n != 1 {
+		t.Fatalf("ExecRaw = %d, %v, want 1 row", n, err)
+	}
+	if got := names(ctx); !reflect.DeepEqual(got, []string{"c", "b"}) {
+		t.Errorf("names = %v, want [c b]", got)
+	}
+
+	// 事务内读取到未提交的数据, 回滚后撤销.
+	errRollback := errors.New("rollback")
+	err = p.Transaction(ctx, func(ctx context.Context) error {
+		n, err := p.ExecRaw(ctx, "UPDATE tenant_items SET name = ?", "d")
+		if err != nil || n != 2 {
+			t.Errorf("ExecRaw in transaction = %d, %v, want 2 rows", n, err)
+		}
+		if got := names(ctx); !reflect.DeepEqual(got, []string{"d", "d"}) {
+			t.Errorf("names in transaction = %v, want [d d]", got)
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatal(err)
+	}
+	if got := names(ctx); !reflect.DeepEqual(got, []string{"c", "b"}) {
+		t.Errorf("names after rollback = %v, want [c b]", got)
+	}
+}
+
+func TestConcurrentNestedTransaction(t *testing.T) {
+	p := newSQLiteProvider(t, new(tenantItem))
+	ctx := context.Background()
+
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		// 顺序嵌套调用不受影响.
+		for i := 0; i < 3; i++ {
+			if err := p.Transaction(ctx, func(ctx context.Context) error {
+				return p.UseDB(ctx).Create(&tenantItem{Name: "item"}).Error
+			}); err != nil {
+				return err
+			}
+		}
+
+		entered, done := make(chan struct{}), make(chan struct{})
+		var wg sync.WaitGroup
+		var firstErr, secondErr error
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			firstErr = p.Transaction(ctx, func(ctx context.Context) error {
+				close(entered)
+				<-done
+				return p.UseDB(ctx).Create(&tenantItem{Name: "item"}).Error
+			})
+		}()
+		go func() {
+			defer wg.Done()
+			defer close(done)
+			<-entered
+			secondErr = p.Transaction(ctx, func(context.Context) error {
+				t.Error("concurrent nested callback called")
+				return nil
+			})
+		}()
+		wg.Wait()
+		if firstErr != nil {
+			return firstErr
+		}
+		if !errors.Is(secondErr, ErrConcurrentNestedTransaction) {
+			t.Errorf("concurrent nested err = %v, want %v", secondErr, ErrConcurrentNestedTransaction)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countItems(t, p); n != 4 {
+		t.Errorf("%d rows after commit, want 4", n)
+	}
+}
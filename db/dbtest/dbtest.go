@@ -0,0 +1,42 @@
+// Package dbtest 提供基于 SQLite 的 Provider, 用于不依赖 MySQL 的测试.
+package dbtest
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"mini_transaction/db"
+	"path/filepath"
+	"testing"
+)
+
+// sqliteParams 测试数据库连接参数.
+//
+// WAL 模式下事务外读取不等待进行中的事务, 开启事务时获取写锁, 避免事务内读后写因锁升级失败.
+const sqliteParams = "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_txlock=immediate"
+
+// NewProvider 创建基于 SQLite 临时文件数据库的 Provider, 完成 models 迁移, 测试结束时关闭.
+//
+// 默认通过保存点实现嵌套事务, 内层事务回滚不影响外层, opts 在默认选项后应用.
+// 与 MySQL 不同, SQLite 以库为单位加锁, 并发写事务串行执行, 等待超过 5 秒返回 SQLITE_BUSY.
+func NewProvider(t testing.TB, models []interface{}, opts ...db.ProviderOption) *db.TransProvider {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "test.db")
+	o := &db.Options{Dialect: db.DialectSQLite, DBName: db.Ptr(name + sqliteParams)}
+	gdb, err := o.OpenDB(db.SQLiteDialector(), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("dbtest: open sqlite: %v", err)
+	}
+	if err := gdb.AutoMigrate(models...); err != nil {
+		if sqlDB, err := gdb.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+		t.Fatalf("dbtest: migrate: %v", err)
+	}
+	opts = append([]db.ProviderOption{db.WithNestedSavepoint()}, opts...)
+	p := db.NewProviderWithOptions(db.NewSource(t.Name(), gdb), opts...)
+	t.Cleanup(func() {
+		_ = p.ForceClose(context.Background())
+	})
+	return p
+}
@@ -0,0 +1,80 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type item struct {
+	ID   int64
+	Name string
+}
+
+func TestNewProviderNestedSavepoint(t *testing.T) {
+	p := NewProvider(t, []interface{}{&item{}})
+	ctx := context.Background()
+	errInner := errors.New("inner")
+
+	committed := 0
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		if err := p.UseDB(ctx).Create(&item{Name: "outer"}).Error; err != nil {
+			return err
+		}
+		p.OnCommitted(ctx, func(context.Context) { committed++ })
+		err := p.Transaction(ctx, func(ctx context.Context) error {
+			if err := p.UseDB(ctx).Create(&item{Name: "inner"}).Error; err != nil {
+				return err
+			}
+			return errInner
+		})
+		if !errors.Is(err, errInner) {
+			t.Errorf("inner error = %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	if err := p.UseDB(ctx).Model(&item{}).Pluck("name", &names).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "outer" {
+		t.Errorf("names = %v, want [outer]", names)
+	}
+	if committed != 1 {
+		t.Errorf("OnCommitted called %d times", committed)
+	}
+}
+
+func TestNewProviderReadOutsideTransaction(t *testing.T) {
+	p := NewProvider(t, []interface{}{&item{}})
+	ctx := context.Background()
+
+	err := p.Transaction(ctx, func(txCtx context.Context) error {
+		if err := p.UseDB(txCtx).Create(&item{Name: "pending"}).Error; err != nil {
+			return err
+		}
+		// 事务外读取不等待进行中的事务, 且不可见未提交数据.
+		done := make(chan error, 1)
+		go func() {
+			var n int64
+			err := p.UseDB(ctx).Model(&item{}).Count(&n).Error
+			if err == nil && n != 0 {
+				err = errors.New("uncommitted row visible")
+			}
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(time.Second):
+			return errors.New("read outside transaction blocked")
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path/filepath"
+	"testing"
+)
+
+func TestOptionsYAMLPartial(t *testing.T) {
+	var o Options
+	err := yaml.Unmarshal([]byte(`
+db_name: app
+password: ""
+`), &o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Host != nil || o.Port != nil || o.UserName != nil {
+		t.Fatalf("omitted keys not nil: host %v, port %v, username %v", o.Host, o.Port, o.UserName)
+	}
+	if o.Password == nil || *o.Password != "" {
+		t.Fatalf("empty password = %v, want pointer to empty string", o.Password)
+	}
+
+	out, err := yaml.Marshal(&o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Options
+	if err := yaml.Unmarshal(out, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Host != nil || back.Port != nil || deref(back.DBName) != "app" || back.Password == nil {
+		t.Errorf("round trip = %s", out)
+	}
+}
+
+func TestOptionsWithDefaults(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		opts Options
+		host string
+		port int
+	}{
+		{"mysql", Options{}, DefaultHost, DefaultPort},
+		{"postgres", Options{Dialect: DialectPostgres}, DefaultHost, DefaultPostgresPort},
+		{"configured", Options{Host: Ptr("db"), Port: Ptr(6033)}, "db", 6033},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			d := c.opts.WithDefaults()
+			if deref(d.Host) != c.host || deref(d.Port) != c.port || d.Password == nil {
+				t.Errorf("defaults = %s:%d, password %v", deref(d.Host), deref(d.Port), d.Password)
+			}
+		})
+	}
+
+	d := (&Options{Socket: "/tmp/mysql.sock"}).WithDefaults()
+	if d.Host != nil {
+		t.Errorf("host = %q with socket", *d.Host)
+	}
+	d = (&Options{Dialect: DialectSQLite}).WithDefaults()
+	if d.Host != nil || d.Port != nil {
+		t.Errorf("sqlite address = %v:%v", d.Host, d.Port)
+	}
+}
+
+func TestOpenDBAppliesDefaults(t *testing.T) {
+	var got *Options
+	dial := func(o *Options) (gorm.Dialector, error) {
+		got = o
+		return SQLiteDialector()(&Options{DBName: Ptr(SQLiteMemory)})
+	}
+	o := &Options{Dialect: DialectPostgres, DBName: Ptr("app")}
+	db, err := o.OpenDB(dial, &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDB(db, make(map[interface{}]bool))
+	if deref(got.Host) != DefaultHost || deref(got.Port) != DefaultPostgresPort || got.Password == nil {
+		t.Errorf("dialed with %v:%v", got.Host, got.Port)
+	}
+	if o.Host != nil || o.Port != nil {
+		t.Error("OpenDB modified the options")
+	}
+}
+
+func TestOpenDBGORMOptions(t *testing.T) {
+	for _, prepare := range []bool{false, true} {
+		o := &Options{DBName: Ptr(filepath.Join(t.TempDir(), "test.db")), PrepareStmt: prepare, DryRun: true}
+		config := &gorm.Config{Logger: logger.Discard}
+		db, err := o.OpenDB(SQLiteDialector(), config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, isPrepared := db.Statement.ConnPool.(*gorm.PreparedStmtDB)
+		if isPrepared != prepare {
+			t.Errorf("PrepareStmt %v: ConnPool = %T", prepare, db.Statement.ConnPool)
+		}
+		if !db.DryRun {
+			t.Errorf("PrepareStmt %v: DryRun not applied", prepare)
+		}
+		// 传入的配置不被修改.
+		if config.PrepareStmt || config.DryRun {
+			t.Errorf("PrepareStmt %v: OpenDB modified the gorm config", prepare)
+		}
+		closeDB(db, make(map[interface{}]bool))
+	}
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"mini_transaction/transaction"
+	"time"
+)
+
+// DurableCallback 代表持久化的可靠提交回调数据.
+type DurableCallback struct {
+	ID        uint64 `gorm:"primaryKey"`
+	TxID      string `gorm:"size:32;index"`
+	Payload   []byte
+	Done      bool `gorm:"index"`
+	CreatedAt time.Time
+}
+
+// TableDurableAdapter 以数据表实现 transaction.DurableAdapter.
+//
+// 通过同一 Provider 的事务 DB 写入, 回调数据与业务数据同时提交.
+type TableDurableAdapter struct {
+	p     *TransProvider
+	table string
+}
+
+var _ transaction.DurableAdapter = new(TableDurableAdapter)
+
+// NewTableDurableAdapter 创建数据表可靠提交回调适配器.
+func NewTableDurableAdapter(p *TransProvider, table string) *TableDurableAdapter {
+	return &TableDurableAdapter{p: p, table: table}
+}
+
+// Migrate 创建或更新数据表.
+func (a *TableDurableAdapter) Migrate(ctx context.Context) error {
+	return a.p.UseWriteDB(ctx).Table(a.table).AutoMigrate(&DurableCallback{})
+}
+
+func (a *TableDurableAdapter) Persist(ctx context.Context, txID string, payloads [][]byte) error {
+	rows := make([]DurableCallback, 0, len(payloads))
+	for _, payload := range payloads {
+		rows = append(rows, DurableCallback{TxID: txID, Payload: payload})
+	}
+	return a.p.UseWriteDB(ctx).Table(a.table).Create(&rows).Error
+}
+
+func (a *TableDurableAdapter) MarkDone(ctx context.Context, txID string) error {
+	return a.p.UseWriteDB(ctx).Table(a.table).Where("tx_id = ?", txID).Update("done", true).Error
+}
+
+func (a *TableDurableAdapter) Pending(ctx context.Context) (map[string][][]byte, error) {
+	var rows []DurableCallback
+	if err := a.p.UseWriteDB(ctx).Table(a.table).Where("done = ?", false).Order("id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	pending := make(map[string][][]byte)
+	for _, row := range rows {
+		pending[row.TxID] = append(pending[row.TxID], row.Payload)
+	}
+	return pending, nil
+}
+
+// DurableAdapter 返回 WithDurableCallbacks 创建的适配器, 未设置时返回 nil.
+func (p *TransProvider) DurableAdapter() *TableDurableAdapter {
+	if p.durable == nil {
+		return nil
+	}
+	return p.durable.adapter
+}
+
+// ReplayDurableCallbacks 重新执行未完成的可靠提交回调, 见 transaction.ReplayDurable.
+func (p *TransProvider) ReplayDurableCallbacks(ctx context.Context) error {
+	if p.durable == nil {
+		return nil
+	}
+	return transaction.ReplayDurable(ctx, p.durable.adapter, p.durable.handler)
+}
+
+// durableProviderOptions 定义 Provider 可靠提交回调配置.
+type durableProviderOptions struct {
+	adapter *TableDurableAdapter
+	handler func(ctx context.Context, payload []byte) error
+}
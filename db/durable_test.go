@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"mini_transaction/transaction"
+	"reflect"
+	"testing"
+)
+
+func TestDurableCallbacks(t *testing.T) {
+	base := newSQLiteProvider(t, new(tenantItem))
+	var (
+		handled []string
+		fail    bool
+	)
+	errHandler := errors.New("webhook down")
+	p := NewProviderWithOptions(base.loadSource(), WithDurableCallbacks("durable_callbacks", func(_ context.Context, payload []byte) error {
+		if fail {
+			return errHandler
+		}
+		handled = append(handled, string(payload))
+		return nil
+	}))
+	ctx := context.Background()
+	if err := p.DurableAdapter().Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	pending := func() map[string][][]byte {
+		t.Helper()
+		pending, err := p.DurableAdapter().Pending(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pending
+	}
+	transact := func(payload string, txErr error) error {
+		return p.Transaction(ctx, func(ctx context.Context) error {
+			if err := p.UseDB(ctx).Create(&tenantItem{Name: payload}).Error; err != nil {
+				return err
+			}
+			if !transaction.OnCommittedDurable(ctx, []byte(payload)) {
+				t.Error("OnCommittedDurable returned false")
+			}
+			return txErr
+		})
+	}
+
+	// 提交后执行并标记完成.
+	if err := transact("a", nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(handled, []string{"a"}) || len(pending()) != 0 {
+		t.Errorf("handled %v, pending %v, want [a] done", handled, pending())
+	}
+
+	// 回滚时回调数据随事务回滚.
+	if err := transact("b", errors.New("rollback")); err == nil {
+		t.Fatal("transaction not rolled back")
+	}
+	if len(handled) != 1 || len(pending()) != 0 {
+		t.Errorf("handled %v, pending %v after rollback", handled, pending())
+	}
+
+	// 回调失败时保留, 重放后完成.
+	fail = true
+	if err := transact("c", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := pending(); len(got) != 1 {
+		t.Fatalf("pending = %v, want the failed callback", got)
+	}
+	fail = false
+	if err := p.ReplayDurableCallbacks(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(handled, []string{"a", "c"}) || len(pending()) != 0 {
+		t.Errorf("handled %v, pending %v after replay, want [a c] done", handled, pending())
+	}
+
+	if transaction.OnCommittedDurable(ctx, []byte("outside")) {
+		t.Error("OnCommittedDurable returned true outside transaction")
+	}
+}
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"sync"
+)
+
+var (
+	ErrDBAlreadyExists  = errors.New("db already exists")
+	ErrSourceNotDynamic = errors.New("source is not a DynamicSource")
+)
+
+// DynamicSource 代表可在运行时增删数据库的数据源, 按 key 路由, 读写使用同一 DB.
+//
+// 用于租户独立数据库等需要动态接入数据库的场景.
+type DynamicSource struct {
+	router func(context.Context) string
+
+	mut    sync.RWMutex
+	dbs    map[string]*gorm.DB
+	closer dbCloser
+}
+
+var _ Source = new(DynamicSource)
+
+// NewDynamicSource 创建动态数据源, router 返回 context 对应的 key, 同时作为库名.
+func NewDynamicSource(router func(context.Context) string, dbs map[string]*gorm.DB) *DynamicSource {
+	s := &DynamicSource{router: router, dbs: make(map[string]*gorm.DB, len(dbs))}
+	for key, db := range dbs {
+		s.dbs[key] = db
+	}
+	installSourcePlugins(s.dbs)
+	return s
+}
+
+// AddWriteDB 添加数据库, key 已存在时返回 ErrDBAlreadyExists.
+func (s *DynamicSource) AddWriteDB(key string, db *gorm.DB) error {
+	if db == nil {
+		return ErrDBNotFound
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if _, ok := s.dbs[key]; ok {
+		return ErrDBAlreadyExists
+	}
+	if err := InstallPlugins(db); err != nil {
+		return err
+	}
+	s.dbs[key] = db
+	return nil
+}
+
+// RemoveWriteDB 移除数据库, key 不存在时返回 ErrDBNotFound.
+//
+// 进行中的事务继续使用开启时的 DB, 移除后开启的事务返回 ErrDBNotFound.
+// 不关闭数据库连接.
+func (s *DynamicSource) RemoveWriteDB(key string) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if _, ok := s.dbs[key]; !ok {
+		return ErrDBNotFound
+	}
+	delete(s.dbs, key)
+	return nil
+}
+
+func (s *DynamicSource) lookup(ctx context.Context) *gorm.DB {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.dbs[s.router(ctx)]
+}
+
+func (s *DynamicSource) getWriteDBName(ctx context.Context) string {
+	return s.router(ctx)
+}
+
+func (s *DynamicSource) getWriteDB(ctx context.Context) *gorm.DB {
+	return s.lookup(ctx)
+}
+
+func (s *DynamicSource) getReadDBName(ctx context.Context) string {
+	return s.router(ctx)
+}
+
+func (s *DynamicSource) getReadDB(ctx context.Context) *gorm.DB {
+	return s.lookup(ctx)
+}
+
+// Close 关闭当前持有的 DB, 已移除的 DB 不关闭.
+func (s *DynamicSource) Close(context.Context) error {
+	s.mut.RLock()
+	dbs := make(map[string]*gorm.DB, len(s.dbs))
+	for key, db := range s.dbs {
+		dbs[key] = db
+	}
+	s.mut.RUnlock()
+	return s.closer.close(dbs)
+}
+
+// Dialector 返回当前持有 DB 共同的数据库方言.
+func (s *DynamicSource) Dialector() gorm.Dialector {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return commonDialector(s.dbs)
+}
+
+// AddWriteDB 向 DynamicSource 添加数据库, 数据源不是 DynamicSource 时返回 ErrSourceNotDynamic.
+func (p *TransProvider) AddWriteDB(key string, db *gorm.DB) error {
+	s, ok := p.loadSource().(*DynamicSource)
+	if !ok {
+		return ErrSourceNotDynamic
+	}
+	return s.AddWriteDB(key, db)
+}
+
+// RemoveWriteDB 从 DynamicSource 移除数据库, 见 DynamicSource.RemoveWriteDB.
+func (p *TransProvider) RemoveWriteDB(key string) error {
+	s, ok := p.loadSource().(*DynamicSource)
+	if !ok {
+		return ErrSourceNotDynamic
+	}
+	if err := s.RemoveWriteDB(key); err != nil {
+		return err
+	}
+	p.metrics.untrackDB(key)
+	return nil
+}
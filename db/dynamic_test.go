@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path/filepath"
+	"testing"
+)
+
+type tenantCtxKey struct{}
+
+func TestDynamicSource(t *testing.T) {
+	opts := &Options{Dialect: DialectSQLite, DBName: Ptr(filepath.Join(t.TempDir(), "a.db"))}
+	tenantDB, err := opts.OpenDB(SQLiteDialector(), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tenantDB.AutoMigrate(new(tenantItem)); err != nil {
+		t.Fatal(err)
+	}
+	p := NewProvider(NewDynamicSource(func(ctx context.Context) string {
+		key, _ := ctx.Value(tenantCtxKey{}).(string)
+		return key
+	}, nil))
+	t.Cleanup(func() {
+		_ = closeDB(tenantDB, make(map[interface{}]bool))
+	})
+	ctx := context.WithValue(context.Background(), tenantCtxKey{}, "a")
+	create := func(ctx context.Context) error {
+		return p.UseDB(ctx).Create(&tenantItem{Name: "a"}).Error
+	}
+
+	if err := p.Transaction(ctx, create); !errors.Is(err, ErrDBNotFound) {
+		t.Fatalf("err before add = %v, want ErrDBNotFound", err)
+	}
+	if err := p.AddWriteDB("a", tenantDB); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddWriteDB("a", tenantDB); !errors.Is(err, ErrDBAlreadyExists) {
+		t.Errorf("duplicate add err = %v, want ErrDBAlreadyExists", err)
+	}
+	if err := p.Transaction(ctx, create); err != nil {
+		t.Fatal(err)
+	}
+
+	// 进行中的事务在移除后正常完成.
+	err = p.Transaction(ctx, func(ctx context.Context) error {
+		if err := p.RemoveWriteDB("a"); err != nil {
+			return err
+		}
+		return create(ctx)
+	})
+	if err != nil {
+		t.Fatalf("transaction spanning removal = %v", err)
+	}
+	if err := p.Transaction(ctx, create); !errors.Is(err, ErrDBNotFound) {
+		t.Errorf("err after remove = %v, want ErrDBNotFound", err)
+	}
+	if err := p.RemoveWriteDB("a"); !errors.Is(err, ErrDBNotFound) {
+		t.Errorf("second remove err = %v, want ErrDBNotFound", err)
+	}
+
+	var count int64
+	if err := tenantDB.Model(new(tenantItem)).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("tenant rows = %d, want 2", count)
+	}
+}
+
+func TestAddWriteDBNotDynamic(t *testing.T) {
+	p := newSQLiteProvider(t)
+	if err := p.AddWriteDB("a", p.UseWriteDB(context.Background())); !errors.Is(err, ErrSourceNotDynamic) {
+		t.Errorf("err = %v, want ErrSourceNotDynamic", err)
+	}
+}
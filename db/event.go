@@ -0,0 +1,22 @@
+package db
+
+import (
+	"context"
+)
+
+// eventPublisher 由 transaction.Manager 实现, 见 transaction.WithEventPublisher.
+type eventPublisher interface {
+	PublishEvent(ctx context.Context, event interface{}) bool
+}
+
+// PublishEvent 发布领域事件.
+//
+// 事务内事件在根事务提交后批量发布, 事务外立即同步发布.
+// 未设置 WithEventPublisher 时返回 false.
+func (p *TransProvider) PublishEvent(ctx context.Context, event interface{}) bool {
+	m, ok := p.Manager.(eventPublisher)
+	if !ok {
+		return false
+	}
+	return m.PublishEvent(ctx, event)
+}
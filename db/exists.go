@@ -0,0 +1,23 @@
+package db
+
+import (
+	"context"
+	"gorm.io/gorm"
+)
+
+// Exists 检查满足条件的记录是否存在.
+//
+// 执行 SELECT EXISTS(SELECT 1 FROM <table> WHERE ...), conds 同 gorm.DB.Where.
+// 记录不存在时返回 (false, nil), 查询失败时返回 (false, err).
+func Exists[T any](ctx context.Context, p Provider, conds ...interface{}) (bool, error) {
+	db := p.UseDB(ctx)
+	sub := db.Session(&gorm.Session{NewDB: true}).Model(new(T)).Select("1")
+	if len(conds) > 0 {
+		sub = sub.Where(conds[0], conds[1:]...)
+	}
+	var exists bool
+	if err := db.Raw("SELECT EXISTS(?)", sub).Scan(&exists).Error; err != nil {
+		return false, err
+	}
+	return exists, nil
+}
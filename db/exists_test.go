@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestExists(t *testing.T) {
+	p := newSQLiteProvider(t, new(tenantItem))
+	ctx := context.Background()
+	if err := p.UseWriteDB(ctx).Create(&tenantItem{Name: "a"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		conds []interface{}
+		want  bool
+	}{
+		{nil, true},
+		{[]interface{}{"name = ?", "a"}, true},
+		{[]interface{}{"name = ?", "b"}, false},
+		{[]interface{}{&tenantItem{Name: "a"}}, true},
+	} {
+		got, err := Exists[tenantItem](ctx, p, c.conds...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("Exists(%v) = %v, want %v", c.conds, got, c.want)
+		}
+	}
+
+	// 查询失败.
+	got, err := Exists[tenantItem](ctx, p, "missing_column = ?", 1)
+	if err == nil || got {
+		t.Errorf("Exists with invalid column = (%v, %v), want (false, error)", got, err)
+	}
+}
+
+func TestExistsInTransaction(t *testing.T) {
+	p := newSQLiteProvider(t, new(tenantItem))
+	ctx := context.Background()
+	errRollback := errors.New("rollback")
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		if err := p.UseDB(ctx).Create(&tenantItem{Name: "tx"}).Error; err != nil {
+			return err
+		}
+		// 事务内可见未提交的写入.
+		got, err := Exists[tenantItem](ctx, p, "name = ?", "tx")
+		if err != nil {
+			return err
+		}
+		if !got {
+			t.Error("Exists in transaction = false, want true")
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("err = %v, want %v", err, errRollback)
+	}
+	got, err := Exists[tenantItem](ctx, p, "name = ?", "tx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got {
+		t.Error("Exists after rollback = true, want false")
+	}
+}
@@ -0,0 +1,159 @@
+package db
+
+import (
+	"expvar"
+	"gorm.io/gorm"
+	"sync"
+	"sync/atomic"
+)
+
+// providerMetrics 记录 Provider 事务计数及已使用的 DB.
+type providerMetrics struct {
+	active    int64
+	started   expvar.Int
+	commits   expvar.Int
+	rollbacks expvar.Int
+	// DB 名到 *gorm.DB.
+	dbs sync.Map
+	// RegisterExpvars 注册的连接池统计, 未注册时为 nil.
+	pool atomic.Pointer[expvar.Map]
+	// 并发根事务数限制, 未设置时为 nil.
+	throttle *throttle
+	// 保证 RegisterExpvars 仅注册一次.
+	register sync.Once
+	// Close 等待进行中的事务期间不再开启根事务.
+	closing atomic.Bool
+	// 数据源已关闭.
+	closed atomic.Bool
+}
+
+// trackDB 记录 DB 以便统计连接池, 同名 DB 已替换时更新记录.
+func (m *providerMetrics) trackDB(name string, db *gorm.DB) {
+	if db == nil {
+		return
+	}
+	if cur, ok := m.dbs.Load(name); ok && cur == db {
+		return
+	}
+	m.dbs.Store(name, db)
+	if pool := m.pool.Load(); pool != nil {
+		pool.Set(name, poolStatsVar(db))
+	}
+}
+
+// untrackDB 移除 DB 统计.
+func (m *providerMetrics) untrackDB(name string) {
+	m.dbs.Delete(name)
+	if pool := m.pool.Load(); pool != nil {
+		pool.Delete(name)
+	}
+}
+
+// untrackAll 移除全部 DB 统计, 用于替换或关闭数据源后释放旧 DB.
+func (m *providerMetrics) untrackAll() {
+	m.dbs.Range(func(key, _ interface{}) bool {
+		m.untrackDB(key.(string))
+		return true
+	})
+}
+
+// begin 记录根事务开启, 返回的函数记录事务结束. 数据源正在关闭或已关闭时返回 ErrSourceClosed.
+//
+// 先计数再检查关闭标记, Close 先设置标记再等待计数归零, 保证关闭后不再有事务开启.
+func (m *providerMetrics) begin() (func(committed bool), error) {
+	atomic.AddInt64(&m.active, 1)
+	if m.closing.Load() || m.closed.Load() {
+		atomic.AddInt64(&m.active, -1)
+		return nil, ErrSourceClosed
+	}
+	m.started.Add(1)
+	return func(committed bool) {
+		atomic.AddInt64(&m.active, -1)
+		if committed {
+			m.commits.Add(1)
+		} else {
+			m.rollbacks.Add(1)
+		}
+	}, nil
+}
+
+// poolStats 返回 DB 的连接池统计.
+func poolStats(db *gorm.DB) (map[string]interface{}, bool) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, false
+	}
+	s := sqlDB.Stats()
+	return map[string]interface{}{
+		"open_connections": s.OpenConnections,
+		"in_use":           s.InUse,
+		"idle":             s.Idle,
+		"wait_count":       s.WaitCount,
+		"wait_duration":    s.WaitDuration.String(),
+	}, true
+}
+
+// poolStatsVar 返回读取时计算连接池统计的 expvar.Func.
+func poolStatsVar(db *gorm.DB) expvar.Func {
+	return func() interface{} {
+		s, _ := poolStats(db)
+		return s
+	}
+}
+
+// PublishExpvar 通过 expvar 发布 Provider 统计, 同 p.RegisterExpvars(prefix).
+func PublishExpvar(p *TransProvider, prefix string) {
+	p.RegisterExpvars(prefix)
+}
+
+// RegisterExpvars 通过 expvar 发布 Provider 统计, 计数在事务开启及结束时更新, 其余变量读取时计算.
+//
+// 注册以下变量:
+//   - <prefix>.started: 已开启的根事务数, *expvar.Int.
+//   - <prefix>.committed: 已提交的根事务数, *expvar.Int.
+//   - <prefix>.rolled_back: 已回滚的根事务数, *expvar.Int.
+//   - <prefix>.active_transactions: 进行中的根事务数.
+//   - <prefix>.pool: 按 DB 名统计的连接池状态, *expvar.Map, 仅包含已使用过的 DB.
+//   - <prefix>.throttle_waiting, <prefix>.throttle_held: 等待及持有的根事务名额数,
+//     仅在设置 WithMaxConcurrentTransactions 时注册.
+//
+// prefix 用于区分多个 Provider. 同一 Provider 重复调用无效果, 已被注册的变量名跳过.
+func (p *TransProvider) RegisterExpvars(prefix string) {
+	m := p.metrics
+	m.register.Do(func() {
+		publishVar(prefix+".started", &m.started)
+		publishVar(prefix+".committed", &m.commits)
+		publishVar(prefix+".rolled_back", &m.rollbacks)
+		publishFunc(prefix+".active_transactions", func() interface{} {
+			return atomic.LoadInt64(&m.active)
+		})
+		pool := new(expvar.Map)
+		m.pool.Store(pool)
+		m.dbs.Range(func(key, value interface{}) bool {
+			pool.Set(key.(string), poolStatsVar(value.(*gorm.DB)))
+			return true
+		})
+		publishVar(prefix+".pool", pool)
+		if t := m.throttle; t != nil {
+			publishFunc(prefix+".throttle_waiting", func() interface{} {
+				return atomic.LoadInt64(&t.waiting)
+			})
+			publishFunc(prefix+".throttle_held", func() interface{} {
+				return atomic.LoadInt64(&t.held)
+			})
+		}
+	})
+}
+
+// publishFunc 注册 expvar.Func, 变量名已存在时跳过.
+func publishFunc(name string, f func() interface{}) {
+	publishVar(name, expvar.Func(f))
+}
+
+// publishVar 注册 expvar 变量, 变量名已存在时跳过.
+func publishVar(name string, v expvar.Var) {
+	if expvar.Get(name) != nil {
+		return
+	}
+	expvar.Publish(name, v)
+}
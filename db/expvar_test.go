@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestRegisterExpvars(t *testing.T) {
+	p, _ := newFakeMySQLProvider(t)
+	p.RegisterExpvars("expvar_test")
+	// 重复注册及旧入口无效果.
+	p.RegisterExpvars("expvar_test")
+	PublishExpvar(p, "expvar_test_other")
+	if expvar.Get("expvar_test_other.committed") != nil {
+		t.Error("PublishExpvar registered a second set of variables")
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		err := p.Transaction(ctx, func(ctx context.Context) error {
+			return p.UseWriteDB(ctx).Exec("UPDATE tenant_items SET name = ?", "a").Error
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = p.Transaction(ctx, func(ctx context.Context) error {
+		return errors.New("rollback")
+	})
+
+	for name, want := range map[string]string{
+		"expvar_test.started":             "3",
+		"expvar_test.committed":           "2",
+		"expvar_test.rolled_back":         "1",
+		"expvar_test.active_transactions": "0",
+	} {
+		v := expvar.Get(name)
+		if v == nil {
+			t.Errorf("%s not registered", name)
+			continue
+		}
+		if got := v.String(); got != want {
+			t.Errorf("%s = %s, want %s", name, got, want)
+		}
+	}
+	pool, ok := expvar.Get("expvar_test.pool").(*expvar.Map)
+	if !ok || pool.Get("mysql") == nil {
+		t.Errorf("pool stats = %v, want entry for mysql", expvar.Get("expvar_test.pool"))
+	}
+}
+
+// trackedDBs 返回连接池统计记录的 DB.
+func trackedDBs(p *TransProvider) map[string]*gorm.DB {
+	dbs := make(map[string]*gorm.DB)
+	p.metrics.dbs.Range(func(key, value interface{}) bool {
+		dbs[key.(string)] = value.(*gorm.DB)
+		return true
+	})
+	return dbs
+}
+
+func TestTrackedDBsPruned(t *testing.T) {
+	a, b := newSQLiteProvider(t), newSQLiteProvider(t)
+	dbA, dbB := a.loadSource().getWriteDB(context.Background()), b.loadSource().getWriteDB(context.Background())
+	p := NewProvider(a.loadSource())
+	p.RegisterExpvars("tracked_test")
+	pool := expvar.Get("tracked_test.pool").(*expvar.Map)
+	ctx := context.Background()
+
+	p.UseWriteDB(ctx)
+	if dbs := trackedDBs(p); len(dbs) != 1 || dbs["sqlite"] != dbA {
+		t.Fatalf("tracked = %v, want sqlite of a", dbs)
+	}
+
+	// 替换数据源后释放旧 DB, 使用时记录新 DB.
+	p.SwapSource(b.loadSource())
+	if dbs := trackedDBs(p); len(dbs) != 0 || pool.Get("sqlite") != nil {
+		t.Fatalf("tracked after SwapSource = %v", dbs)
+	}
+	p.UseWriteDB(ctx)
+	if dbs := trackedDBs(p); dbs["sqlite"] != dbB {
+		t.Fatalf("tracked = %v, want sqlite of b", dbs)
+	}
+
+	// 同名 DB 替换时更新记录.
+	p.metrics.trackDB("sqlite", dbA)
+	if dbs := trackedDBs(p); dbs["sqlite"] != dbA {
+		t.Fatalf("tracked = %v, want replaced sqlite", dbs)
+	}
+
+	if err := p.ForceClose(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if dbs := trackedDBs(p); len(dbs) != 0 || pool.Get("sqlite") != nil {
+		t.Errorf("tracked after ForceClose = %v", dbs)
+	}
+}
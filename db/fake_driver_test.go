@@ -0,0 +1,184 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeStatement 代表 fakeConnector 记录的语句.
+type fakeStatement struct {
+	conn  int
+	query string
+	args  []interface{}
+}
+
+// fakeSleepQuery 以该前缀开头的查询阻塞至 context 结束.
+const fakeSleepQuery = "SELECT SLEEP"
+
+// fakeConnector 记录各连接执行的语句, 查询按前缀返回单行单列结果.
+type fakeConnector struct {
+	mut     sync.Mutex
+	conns   int
+	log     []fakeStatement
+	results map[string]interface{}
+	// 语句按前缀返回的错误, Key 为 "COMMIT" 时提交返回该错误.
+	errs map[string]error
+	// 剩余失败的 Ping 次数.
+	pingFailures int
+	// 提交耗时.
+	commitDelay time.Duration
+	// 查询处理, 返回 ok 时以 value 作为单行单列结果, 优先于 results.
+	query func(ctx context.Context, conn int, query string, args []driver.NamedValue) (value interface{}, ok bool, err error)
+}
+
+func newFakeDB(results map[string]interface{}) (*sql.DB, *fakeConnector) {
+	c := &fakeConnector{results: results}
+	return sql.OpenDB(c), c
+}
+
+func (c *fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.conns++
+	return &fakeConn{c: c, id: c.conns}, nil
+}
+
+func (c *fakeConnector) Driver() driver.Driver {
+	return nil
+}
+
+func (c *fakeConnector) record(conn int, query string, args []driver.NamedValue) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	values := make([]interface{}, 0, len(args))
+	for _, arg := range args {
+		values = append(values, arg.Value)
+	}
+	c.log = append(c.log, fakeStatement{conn: conn, query: query, args: values})
+}
+
+// errOf 返回 errs 中匹配语句前缀的错误.
+func (c *fakeConnector) errOf(query string) error {
+	for prefix, err := range c.errs {
+		if strings.HasPrefix(query, prefix) {
+			return err
+		}
+	}
+	return nil
+}
+
+// statements 返回已记录的语句并清空记录.
+func (c *fakeConnector) statements() []fakeStatement {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	log := c.log
+	c.log = nil
+	return log
+}
+
+type fakeConn struct {
+	c  *fakeConnector
+	id int
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fake: prepare unsupported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	c.c.record(c.id, "BEGIN", nil)
+	return fakeTx{c}, nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.c.record(c.id, query, args)
+	if err := c.c.errOf(query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.c.record(c.id, query, args)
+	if strings.HasPrefix(query, fakeSleepQuery) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if err := c.c.errOf(query); err != nil {
+		return nil, err
+	}
+	if c.c.query != nil {
+		if v, ok, err := c.c.query(ctx, c.id, query, args); err != nil {
+			return nil, err
+		} else if ok {
+			return &fakeRows{values: []driver.Value{v}}, nil
+		}
+	}
+	for prefix, v := range c.c.results {
+		if strings.HasPrefix(query, prefix) {
+			return &fakeRows{values: []driver.Value{v}}, nil
+		}
+	}
+	return &fakeRows{}, nil
+}
+
+func (c *fakeConn) Ping(context.Context) error {
+	c.c.mut.Lock()
+	defer c.c.mut.Unlock()
+	if c.c.pingFailures > 0 {
+		c.c.pingFailures--
+		return errors.New("fake: connection refused")
+	}
+	return nil
+}
+
+type fakeTx struct {
+	c *fakeConn
+}
+
+func (tx fakeTx) Commit() error {
+	time.Sleep(tx.c.c.commitDelay)
+	tx.c.c.record(tx.c.id, "COMMIT", nil)
+	return tx.c.c.errs["COMMIT"]
+}
+
+func (tx fakeTx) Rollback() error {
+	tx.c.c.record(tx.c.id, "ROLLBACK", nil)
+	return nil
+}
+
+// fakeRows 返回至多一行单列结果.
+type fakeRows struct {
+	values []driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"value"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0] = r.values[0]
+	r.values = nil
+	return nil
+}
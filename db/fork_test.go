@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"mini_transaction/transaction"
+	"sync"
+	"testing"
+)
+
+func TestForkContextConcurrentReads(t *testing.T) {
+	p := newSQLiteProvider(t, new(tenantItem))
+	ctx := context.Background()
+	if err := p.UseDB(ctx).Create(&tenantItem{Name: "committed"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := transaction.ForkContext(ctx, p); !errors.Is(err, transaction.ErrNotInTransaction) {
+		t.Fatalf("ForkContext outside transaction err = %v, want %v", err, transaction.ErrNotInTransaction)
+	}
+
+	counts := make([]int64, 8)
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		if err := p.UseDB(ctx).Create(&tenantItem{Name: "pending"}).Error; err != nil {
+			return err
+		}
+		forked, err := transaction.ForkContext(ctx, p)
+		if err != nil {
+			return err
+		}
+		if p.InTransaction(forked) {
+			t.Error("forked context is still in transaction")
+		}
+
+		var wg sync.WaitGroup
+		errs := make([]error, len(counts))
+		for i := range counts {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = p.UseDB(forked).Model(new(tenantItem)).Count(&counts[i]).Error
+			}(i)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+		// 事务 DB 仍可正常使用.
+		return p.UseDB(ctx).Create(&tenantItem{Name: "after fork"}).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 只读 context 读取不到事务未提交的数据.
+	for i, n := range counts {
+		if n != 1 {
+			t.Errorf("goroutine %d counted %d rows, want 1", i, n)
+		}
+	}
+	if n := countItems(t, p); n != 3 {
+		t.Errorf("%d rows after commit, want 3", n)
+	}
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"sync"
+)
+
+var globalPlugins struct {
+	mut     sync.Mutex
+	plugins []gorm.Plugin
+}
+
+// RegisterGlobalPlugin 注册全局插件, 之后通过 Options.OpenDB 等方法创建的 DB 均应用该插件.
+//
+// 同名插件替换已注册的插件. 不作用于已创建的 DB, 创建时 DB 已注册同名插件则跳过.
+func RegisterGlobalPlugin(plugin gorm.Plugin) {
+	globalPlugins.mut.Lock()
+	defer globalPlugins.mut.Unlock()
+	for i, p := range globalPlugins.plugins {
+		if p.Name() == plugin.Name() {
+			globalPlugins.plugins[i] = plugin
+			return
+		}
+	}
+	globalPlugins.plugins = append(globalPlugins.plugins, plugin)
+}
+
+// UnregisterGlobalPlugin 移除全局插件, 之后创建的 DB 不再应用, 已创建的 DB 不受影响.
+func UnregisterGlobalPlugin(name string) {
+	globalPlugins.mut.Lock()
+	defer globalPlugins.mut.Unlock()
+	for i, p := range globalPlugins.plugins {
+		if p.Name() == name {
+			globalPlugins.plugins = append(globalPlugins.plugins[:i:i], globalPlugins.plugins[i+1:]...)
+			return
+		}
+	}
+}
+
+// useGlobalPlugins 为 DB 应用全局插件, 按注册顺序.
+//
+// DB 已注册同名插件时跳过, 如通过 gorm.Config.Plugins 传入的插件.
+func useGlobalPlugins(db *gorm.DB) error {
+	globalPlugins.mut.Lock()
+	plugins := append([]gorm.Plugin(nil), globalPlugins.plugins...)
+	globalPlugins.mut.Unlock()
+	for _, plugin := range plugins {
+		if _, ok := db.Config.Plugins[plugin.Name()]; ok {
+			continue
+		}
+		if err := db.Use(plugin); err != nil {
+			return fmt.Errorf("use global plugin %s: %w", plugin.Name(), err)
+		}
+	}
+	return nil
+}
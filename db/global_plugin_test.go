@@ -0,0 +1,76 @@
+package db
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// countingPlugin 统计查询语句数.
+type countingPlugin struct {
+	queries int64
+}
+
+func (p *countingPlugin) Name() string {
+	return "test:counting"
+}
+
+func (p *countingPlugin) Initialize(db *gorm.DB) error {
+	return db.Callback().Query().After("gorm:query").Register(p.Name(), func(*gorm.DB) {
+		atomic.AddInt64(&p.queries, 1)
+	})
+}
+
+func TestGlobalPluginAcrossDBs(t *testing.T) {
+	plugin := &countingPlugin{}
+	RegisterGlobalPlugin(plugin)
+	defer UnregisterGlobalPlugin(plugin.Name())
+
+	dir := t.TempDir()
+	opts := make(MultiRWOptions)
+	for i := 0; i < 3; i++ {
+		key := fmt.Sprintf("db%d", i)
+		opts[key] = &RWOptions{Write: &Options{Dialect: DialectSQLite, DBName: Ptr(filepath.Join(dir, key+".db"))}}
+	}
+	dbs, err := opts.OpenDBs(SQLiteDialector(), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDBs(dbs)
+	for _, db := range dbs {
+		var n int
+		if err := db.Raw("SELECT 1").Find(&n).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := atomic.LoadInt64(&plugin.queries); got != 3 {
+		t.Errorf("queries = %d, want 3", got)
+	}
+}
+
+func TestGlobalPluginAlreadyRegistered(t *testing.T) {
+	plugin := &countingPlugin{}
+	RegisterGlobalPlugin(plugin)
+	defer UnregisterGlobalPlugin(plugin.Name())
+
+	// 同一插件同时通过 gorm.Config.Plugins 传入.
+	opts := &Options{Dialect: DialectSQLite, DBName: Ptr(SQLiteMemory)}
+	db, err := opts.OpenDB(SQLiteDialector(), &gorm.Config{
+		Logger:  logger.Discard,
+		Plugins: map[string]gorm.Plugin{plugin.Name(): plugin},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDB(db, make(map[interface{}]bool))
+	var n int
+	if err := db.Raw("SELECT 1").Find(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt64(&plugin.queries); got != 1 {
+		t.Errorf("queries = %d, want 1", got)
+	}
+}
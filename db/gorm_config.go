@@ -0,0 +1,69 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+)
+
+var (
+	ErrUnsupportedGORMConfig = errors.New("unsupported gorm config")
+)
+
+// checkGORMConfig 校验 WithGORMConfig 的配置, 不支持按 Provider 覆盖的配置返回 ErrUnsupportedGORMConfig.
+func checkGORMConfig(cfg *gorm.Config) error {
+	if cfg == nil {
+		return fmt.Errorf("%w: nil config", ErrUnsupportedGORMConfig)
+	}
+	var fields []string
+	if cfg.NamingStrategy != nil {
+		fields = append(fields, "NamingStrategy")
+	}
+	if cfg.PrepareStmt {
+		fields = append(fields, "PrepareStmt")
+	}
+	if cfg.DisableAutomaticPing {
+		fields = append(fields, "DisableAutomaticPing")
+	}
+	if cfg.ClauseBuilders != nil {
+		fields = append(fields, "ClauseBuilders")
+	}
+	if cfg.ConnPool != nil {
+		fields = append(fields, "ConnPool")
+	}
+	if cfg.Dialector != nil {
+		fields = append(fields, "Dialector")
+	}
+	if cfg.Plugins != nil {
+		fields = append(fields, "Plugins")
+	}
+	if len(fields) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnsupportedGORMConfig, strings.Join(fields, ", "))
+	}
+	return nil
+}
+
+// withGORMConfig 返回使用 cfg 覆盖配置的会话, 不影响 db.
+func withGORMConfig(db *gorm.DB, cfg *gorm.Config) *gorm.DB {
+	// Session 复制 Config, 修改副本.
+	tx := db.Session(&gorm.Session{})
+	c := tx.Config
+	if cfg.Logger != nil {
+		c.Logger = cfg.Logger
+	}
+	if cfg.NowFunc != nil {
+		c.NowFunc = cfg.NowFunc
+	}
+	c.SkipDefaultTransaction = cfg.SkipDefaultTransaction
+	c.FullSaveAssociations = cfg.FullSaveAssociations
+	c.DryRun = cfg.DryRun
+	c.DisableForeignKeyConstraintWhenMigrating = cfg.DisableForeignKeyConstraintWhenMigrating
+	c.IgnoreRelationshipsWhenMigrating = cfg.IgnoreRelationshipsWhenMigrating
+	c.DisableNestedTransaction = cfg.DisableNestedTransaction
+	c.AllowGlobalUpdate = cfg.AllowGlobalUpdate
+	c.QueryFields = cfg.QueryFields
+	c.CreateBatchSize = cfg.CreateBatchSize
+	c.TranslateError = cfg.TranslateError
+	return tx
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// traceLogger 记录 Trace 的 SQL.
+type traceLogger struct {
+	logger.Interface
+	sqls []string
+}
+
+func (l *traceLogger) LogMode(logger.LogLevel) logger.Interface { return l }
+
+func (l *traceLogger) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	sql, _ := fc()
+	l.sqls = append(l.sqls, sql)
+}
+
+func TestWithGORMConfig(t *testing.T) {
+	openLogger := &traceLogger{Interface: logger.Discard}
+	opts := &Options{Dialect: DialectSQLite, DBName: Ptr(filepath.Join(t.TempDir(), "cfg.db"))}
+	db, err := opts.OpenDB(SQLiteDialector(), &gorm.Config{Logger: openLogger, AllowGlobalUpdate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(new(tenantItem)); err != nil {
+		t.Fatal(err)
+	}
+	openLogger.sqls = nil
+
+	providerLogger := &traceLogger{Interface: logger.Discard}
+	p := NewProviderWithOptions(NewSource("sqlite", db), WithGORMConfig(&gorm.Config{Logger: providerLogger}))
+	defer p.ForceClose(context.Background())
+	ctx := context.Background()
+
+	var n int64
+	if err := p.UseDB(ctx).Model(new(tenantItem)).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	err = p.Transaction(ctx, func(ctx context.Context) error {
+		return p.UseDB(ctx).Create(&tenantItem{Name: "a"}).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(providerLogger.sqls) != 2 {
+		t.Errorf("provider logger traced %q, want the count and insert", providerLogger.sqls)
+	}
+	if len(openLogger.sqls) != 0 {
+		t.Errorf("open logger traced %q", openLogger.sqls)
+	}
+
+	// 布尔开关按配置值覆盖.
+	err = p.UseWriteDB(ctx).Model(new(tenantItem)).Update("name", "b").Error
+	if !errors.Is(err, gorm.ErrMissingWhereClause) {
+		t.Errorf("global update error = %v, want %v", err, gorm.ErrMissingWhereClause)
+	}
+	if !db.Config.AllowGlobalUpdate || db.Config.Logger != openLogger {
+		t.Error("WithGORMConfig modified the source DB config")
+	}
+}
+
+func TestWithGORMConfigUnsupported(t *testing.T) {
+	for name, cfg := range map[string]*gorm.Config{
+		"nil":            nil,
+		"NamingStrategy": {NamingStrategy: schema.NamingStrategy{SingularTable: true}},
+		"PrepareStmt":    {PrepareStmt: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if err, _ := recover().(error); !errors.Is(err, ErrUnsupportedGORMConfig) {
+					t.Errorf("recovered %v, want %v", err, ErrUnsupportedGORMConfig)
+				}
+			}()
+			WithGORMConfig(cfg)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"testing"
+)
+
+// hookItem 在 AfterSave 中通过 Statement.Context 注册提交回调.
+type hookItem struct {
+	ID     int64
+	Name   string
+	p      *TransProvider
+	events *[]string
+}
+
+func (h *hookItem) AfterSave(tx *gorm.DB) error {
+	ctx := tx.Statement.Context
+	if !h.p.InTransaction(ctx) {
+		*h.events = append(*h.events, "save outside transaction")
+		return nil
+	}
+	*h.events = append(*h.events, "save")
+	registered := h.p.OnCommitted(ctx, func(context.Context) {
+		*h.events = append(*h.events, "committed")
+	})
+	if !registered {
+		return errors.New("OnCommitted not registered")
+	}
+	return nil
+}
+
+func TestModelHookOnCommitted(t *testing.T) {
+	p := newSQLiteProvider(t, new(hookItem))
+	ctx := context.Background()
+	var events []string
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		item := &hookItem{Name: "a", p: p, events: &events}
+		if err := p.UseDB(ctx).Create(item).Error; err != nil {
+			return err
+		}
+		if err := p.UseWriteDB(ctx).Model(item).Update("name", "b").Error; err != nil {
+			return err
+		}
+		// 提交前不执行.
+		if len(events) != 2 || events[0] != "save" || events[1] != "save" {
+			t.Errorf("events before commit = %q, want [save save]", events)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"save", "save", "committed", "committed"}
+	if len(events) != len(want) {
+		t.Fatalf("events = %q, want %q", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("events = %q, want %q", events, want)
+		}
+	}
+}
+
+func TestModelHookOnCommittedRollback(t *testing.T) {
+	p := newSQLiteProvider(t, new(hookItem))
+	ctx := context.Background()
+	var events []string
+	errRollback := errors.New("rollback")
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		if err := p.UseDB(ctx).Create(&hookItem{Name: "a", p: p, events: &events}).Error; err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("err = %v, want %v", err, errRollback)
+	}
+	if len(events) != 1 || events[0] != "save" {
+		t.Errorf("events = %q, want [save]", events)
+	}
+}
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"mini_transaction/transaction"
+	"time"
+)
+
+// TransactionInfo 代表 context 所在事务的状态.
+type TransactionInfo struct {
+	// 是否在事务内.
+	Active bool
+	// 事务嵌套深度, 根事务为 1, 不在事务内为 0.
+	Depth int
+	// 根事务 ID.
+	TransactionID string
+	// 写库名.
+	DBName string
+	// 根事务开始时间.
+	StartedAt time.Time
+}
+
+// Inspect 返回 context 所在事务的状态, 用于排查问题, 不修改事务上下文.
+//
+// EscapeTransaction 后的 context 返回 Active 为 false.
+func (p *TransProvider) Inspect(ctx context.Context) TransactionInfo {
+	info := TransactionInfo{DBName: p.getWriteDBName(ctx)}
+	tc := p.findTransContext(ctx)
+	if tc == nil {
+		return info
+	}
+	info.Active = true
+	info.Depth = tc.Depth()
+	info.TransactionID = tc.ID()
+	info.StartedAt = tc.StartedAt()
+	return info
+}
+
+// WithTransContext 将事务上下文存入 context, UseDB 等方法使用其事务 DB.
+//
+// 用于测试中注入 transaction.NewTransContext 创建的事务上下文, 事务 DB 须为 *gorm.DB.
+func (p *TransProvider) WithTransContext(ctx context.Context, tc transaction.TransContext) context.Context {
+	return transaction.ContextWithTransContext(ctx, p.getCtxKey(ctx), tc)
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"mini_transaction/transaction"
+	"testing"
+	"time"
+)
+
+func TestInspect(t *testing.T) {
+	p := newSQLiteProvider(t, new(tenantItem))
+	ctx := context.Background()
+
+	if info := p.Inspect(ctx); info != (TransactionInfo{DBName: "sqlite"}) {
+		t.Errorf("outside transaction = %+v", info)
+	}
+
+	start := time.Now()
+	var root, nested, escaped TransactionInfo
+	var txID string
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		root = p.Inspect(ctx)
+		txID = transaction.LogFields(ctx)["tx_id"].(string)
+		_ = p.EscapeTransaction(ctx, func(ctx context.Context) error {
+			escaped = p.Inspect(ctx)
+			return nil
+		})
+		return p.Transaction(ctx, func(ctx context.Context) error {
+			nested = p.Inspect(ctx)
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !root.Active || root.Depth != 1 || root.TransactionID != txID || root.DBName != "sqlite" ||
+		root.StartedAt.Before(start) || root.StartedAt.After(time.Now()) {
+		t.Errorf("root = %+v, want active depth 1 tx %s", root, txID)
+	}
+	want := root
+	want.Depth = 2
+	if nested != want {
+		t.Errorf("nested = %+v, want %+v", nested, want)
+	}
+	if escaped.Active || escaped.Depth != 0 || escaped.TransactionID != "" {
+		t.Errorf("escaped = %+v, want inactive", escaped)
+	}
+}
+
+func TestWithTransContext(t *testing.T) {
+	p := newSQLiteProvider(t, new(tenantItem))
+	tx := p.UseWriteDB(context.Background()).Begin()
+	defer tx.Rollback()
+	tc := transaction.NewTransContext(nil, tx)
+	ctx := p.WithTransContext(context.Background(), tc)
+
+	if !p.InTransaction(ctx) {
+		t.Fatal("injected trans context not in transaction")
+	}
+	if err := p.UseDB(ctx).Create(&tenantItem{Name: "a"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	committed := false
+	if !p.OnCommitted(ctx, func(context.Context) { committed = true }) {
+		t.Fatal("OnCommitted returned false")
+	}
+	if info := p.Inspect(ctx); !info.Active || info.TransactionID != tc.ID() {
+		t.Errorf("Inspect = %+v, want tx %s", info, tc.ID())
+	}
+	// 事务 DB 未提交, 写库连接读不到.
+	if n := countItems(t, p); n != 0 {
+		t.Errorf("%d rows outside injected transaction, want 0", n)
+	}
+	tc.End(false, nil)
+	tc.Finish()
+	if !committed {
+		t.Error("OnCommitted callback not fired by End")
+	}
+}
@@ -0,0 +1,231 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"gorm.io/gorm"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultLagCacheTTL 默认复制延迟缓存时间.
+	DefaultLagCacheTTL = 5 * time.Second
+	// DefaultLagQueryTimeout 默认复制延迟查询超时.
+	DefaultLagQueryTimeout = time.Second
+)
+
+// LagAwareReplica 定义按复制延迟路由的从库.
+type LagAwareReplica struct {
+	// 从库数据源, 使用其读库.
+	Source Source
+	// 最大允许复制延迟, 超过时跳过该从库.
+	MaxLagSeconds float64
+}
+
+// LagQuery 查询从库复制延迟秒数.
+type LagQuery func(ctx context.Context, db *gorm.DB) (float64, error)
+
+// LagAwareOption 定义延迟感知数据源选项.
+type LagAwareOption func(*lagAwareSource)
+
+// WithLagCacheTTL 设置复制延迟缓存时间, 默认为 DefaultLagCacheTTL.
+func WithLagCacheTTL(ttl time.Duration) LagAwareOption {
+	return func(s *lagAwareSource) {
+		s.ttl = ttl
+	}
+}
+
+// WithLagQueryTimeout 设置单次复制延迟查询超时, 默认为 DefaultLagQueryTimeout, 超时视为超过阈值.
+func WithLagQueryTimeout(timeout time.Duration) LagAwareOption {
+	return func(s *lagAwareSource) {
+		s.queryTimeout = timeout
+	}
+}
+
+// WithLagQuery 设置复制延迟查询, 默认为 ShowSlaveStatusLag.
+func WithLagQuery(query LagQuery) LagAwareOption {
+	return func(s *lagAwareSource) {
+		s.query = query
+	}
+}
+
+// lagAwareSource 跳过复制延迟超过阈值的从库.
+type lagAwareSource struct {
+	primary  Source
+	replicas []*lagReplica
+	ttl      time.Duration
+	query    LagQuery
+	// 单次延迟查询超时.
+	queryTimeout time.Duration
+}
+
+// lagReplica 缓存从库复制延迟.
+type lagReplica struct {
+	LagAwareReplica
+
+	// mut 保护以下字段, 查询延迟时不持有.
+	mut       sync.Mutex
+	lag       float64
+	checkedAt time.Time
+	// 正在刷新延迟.
+	refreshing bool
+}
+
+// NewLagAwareSource 创建按复制延迟路由读库的数据源.
+//
+// 写库使用 primary 的写库. 读库按 replicas 顺序选择首个复制延迟不超过 MaxLagSeconds 的从库,
+// 全部超过时使用 primary 的读库. 延迟查询失败视为超过阈值.
+//
+// 延迟按从库缓存, 缓存过期时由一个调用方刷新, 刷新期间其他调用方使用过期的延迟, 首次查询完成前视为超过阈值.
+// TransProvider 每次获取读库只选择一次, 读库名与读库一致.
+func NewLagAwareSource(primary Source, replicas []LagAwareReplica, opts ...LagAwareOption) Source {
+	s := &lagAwareSource{
+		primary:      primary,
+		ttl:          DefaultLagCacheTTL,
+		query:        ShowSlaveStatusLag,
+		queryTimeout: DefaultLagQueryTimeout,
+	}
+	for _, r := range replicas {
+		s.replicas = append(s.replicas, &lagReplica{LagAwareReplica: r})
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ShowSlaveStatusLag 通过 SHOW SLAVE STATUS 查询 Seconds_Behind_Master.
+//
+// 未配置复制或复制线程停止时返回 +Inf.
+func ShowSlaveStatusLag(ctx context.Context, db *gorm.DB) (float64, error) {
+	rows, err := db.WithContext(ctx).Raw("SHOW SLAVE STATUS").Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return math.Inf(1), rows.Err()
+	}
+	var status map[string]interface{}
+	if status, err = scanRowMap(rows); err != nil {
+		return 0, err
+	}
+	var lag sql.NullFloat64
+	if err := lag.Scan(status["Seconds_Behind_Master"]); err != nil {
+		return 0, err
+	}
+	if !lag.Valid {
+		return math.Inf(1), nil
+	}
+	return lag.Float64, nil
+}
+
+// scanRowMap 以列名读取当前行.
+func scanRowMap(rows *sql.Rows) (map[string]interface{}, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return nil, err
+	}
+	row := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		if b, ok := values[i].([]byte); ok {
+			values[i] = string(b)
+		}
+		row[column] = values[i]
+	}
+	return row, nil
+}
+
+// pickRead 返回用于读的数据源.
+func (s *lagAwareSource) pickRead(ctx context.Context) Source {
+	for _, r := range s.replicas {
+		if r.healthy(ctx, s) {
+			return r.Source
+		}
+	}
+	return s.primary
+}
+
+// healthy 判断从库复制延迟是否在阈值内, 缓存过期时重新查询.
+func (r *lagReplica) healthy(ctx context.Context, s *lagAwareSource) bool {
+	r.mut.Lock()
+	stale := r.checkedAt.IsZero() || time.Since(r.checkedAt) >= s.ttl
+	if !stale || r.refreshing {
+		defer r.mut.Unlock()
+		return !r.checkedAt.IsZero() && r.lag <= r.MaxLagSeconds
+	}
+	r.refreshing = true
+	r.mut.Unlock()
+
+	lag, ok := r.queryLag(ctx, s)
+
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.refreshing = false
+	if ok {
+		r.lag, r.checkedAt = lag, time.Now()
+	}
+	return !r.checkedAt.IsZero() && r.lag <= r.MaxLagSeconds
+}
+
+// queryLag 在查询超时内查询复制延迟, 查询失败返回 +Inf. 调用方 context 结束导致的失败不缓存, 返回 false.
+func (r *lagReplica) queryLag(ctx context.Context, s *lagAwareSource) (float64, bool) {
+	queryCtx := ctx
+	if s.queryTimeout > 0 {
+		var cancel context.CancelFunc
+		queryCtx, cancel = context.WithTimeout(ctx, s.queryTimeout)
+		defer cancel()
+	}
+	lag, err := s.query(queryCtx, r.Source.getReadDB(ctx))
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, false
+		}
+		return math.Inf(1), true
+	}
+	return lag, true
+}
+
+func (s *lagAwareSource) getWriteDBName(ctx context.Context) string {
+	return s.primary.getWriteDBName(ctx)
+}
+
+func (s *lagAwareSource) getWriteDB(ctx context.Context) *gorm.DB {
+	return s.primary.getWriteDB(ctx)
+}
+
+func (s *lagAwareSource) getReadDBName(ctx context.Context) string {
+	return s.pickRead(ctx).getReadDBName(ctx)
+}
+
+func (s *lagAwareSource) getReadDB(ctx context.Context) *gorm.DB {
+	return s.pickRead(ctx).getReadDB(ctx)
+}
+
+// readDBAndName 选择一次读库, 返回读库名及读库.
+func (s *lagAwareSource) readDBAndName(ctx context.Context) (string, *gorm.DB) {
+	read := s.pickRead(ctx)
+	return read.getReadDBName(ctx), read.getReadDB(ctx)
+}
+
+func (s *lagAwareSource) Close(ctx context.Context) error {
+	sources := []Source{s.primary}
+	for _, r := range s.replicas {
+		sources = append(sources, r.Source)
+	}
+	return closeSources(ctx, sources...)
+}
+
+func (s *lagAwareSource) Dialector() gorm.Dialector {
+	return s.primary.Dialector()
+}
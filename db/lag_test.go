@@ -0,0 +1,165 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// lagStub 按连接池返回预设的复制延迟, 并记录查询次数.
+type lagStub struct {
+	mut     sync.Mutex
+	lags    map[*sql.DB]float64
+	queries int
+}
+
+func (s *lagStub) query(_ context.Context, db *gorm.DB) (float64, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return 0, err
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.queries++
+	lag, ok := s.lags[sqlDB]
+	if !ok {
+		return 0, errors.New("lag unknown")
+	}
+	return lag, nil
+}
+
+func (s *lagStub) set(db *gorm.DB, lag float64) {
+	sqlDB, _ := db.DB()
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.lags[sqlDB] = lag
+}
+
+// openLagTestDBs 创建主库及两个从库.
+func openLagTestDBs(t *testing.T) (primary, r1, r2 *gorm.DB) {
+	t.Helper()
+	dir := t.TempDir()
+	dbs := make([]*gorm.DB, 0, 3)
+	for _, name := range []string{"primary", "r1", "r2"} {
+		opts := &Options{Dialect: DialectSQLite, DBName: Ptr(filepath.Join(dir, name+".db"))}
+		db, err := opts.OpenDB(SQLiteDialector(), &gorm.Config{Logger: logger.Discard})
+		if err != nil {
+			t.Fatal(err)
+		}
+		dbs = append(dbs, db)
+	}
+	return dbs[0], dbs[1], dbs[2]
+}
+
+func TestLagAwareSource(t *testing.T) {
+	primary, r1, r2 := openLagTestDBs(t)
+	stub := &lagStub{lags: make(map[*sql.DB]float64)}
+	stub.set(r1, 30)
+	stub.set(r2, 2)
+	s := NewLagAwareSource(NewSource("primary", primary), []LagAwareReplica{
+		{Source: NewSource("r1", r1), MaxLagSeconds: 5},
+		{Source: NewSource("r2", r2), MaxLagSeconds: 5},
+	}, WithLagQuery(stub.query), WithLagCacheTTL(time.Hour))
+	t.Cleanup(func() {
+		_ = s.Close(context.Background())
+	})
+	ctx := context.Background()
+
+	// 跳过延迟超过阈值的从库.
+	if name := s.getReadDBName(ctx); name != "r2" {
+		t.Errorf("read db = %s, want r2", name)
+	}
+	if name := s.getWriteDBName(ctx); name != "primary" {
+		t.Errorf("write db = %s, want primary", name)
+	}
+
+	// 缓存期内不重新查询.
+	queries := stub.queries
+	stub.set(r2, 60)
+	if name := s.getReadDBName(ctx); name != "r2" {
+		t.Errorf("cached read db = %s, want r2", name)
+	}
+	if stub.queries != queries {
+		t.Errorf("lag queried %d times within ttl", stub.queries-queries)
+	}
+}
+
+func TestLagAwareSourceFallback(t *testing.T) {
+	primary, r1, r2 := openLagTestDBs(t)
+	stub := &lagStub{lags: make(map[*sql.DB]float64)}
+	stub.set(r1, 30)
+	// r2 延迟查询失败视为超过阈值.
+	s := NewLagAwareSource(NewSource("primary", primary), []LagAwareReplica{
+		{Source: NewSource("r1", r1), MaxLagSeconds: 5},
+		{Source: NewSource("r2", r2), MaxLagSeconds: 5},
+	}, WithLagQuery(stub.query), WithLagCacheTTL(0))
+	t.Cleanup(func() {
+		_ = s.Close(context.Background())
+	})
+	ctx := context.Background()
+
+	if name := s.getReadDBName(ctx); name != "primary" {
+		t.Errorf("read db = %s, want primary", name)
+	}
+	// 缓存过期后按新延迟选择.
+	stub.set(r1, 1)
+	if name := s.getReadDBName(ctx); name != "r1" {
+		t.Errorf("read db after recovery = %s, want r1", name)
+	}
+}
+
+func TestLagAwareSourceSlowReplica(t *testing.T) {
+	primary, r1, _ := openLagTestDBs(t)
+	release := make(chan struct{})
+	var calls int32
+	query := func(ctx context.Context, db *gorm.DB) (float64, error) {
+		// 首次查询正常返回, 之后模拟挂起的从库.
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return 1, nil
+		}
+		select {
+		case <-release:
+			return 1, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+	s := NewLagAwareSource(NewSource("primary", primary), []LagAwareReplica{
+		{Source: NewSource("r1", r1), MaxLagSeconds: 5},
+	}, WithLagQuery(query), WithLagCacheTTL(0), WithLagQueryTimeout(100*time.Millisecond))
+	t.Cleanup(func() {
+		close(release)
+		_ = s.Close(context.Background())
+	})
+	ctx := context.Background()
+	if name := s.getReadDBName(ctx); name != "r1" {
+		t.Fatalf("read db = %s, want r1", name)
+	}
+
+	refreshed := make(chan string)
+	go func() {
+		refreshed <- s.getReadDBName(ctx)
+	}()
+	for atomic.LoadInt32(&calls) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	// 刷新期间其他调用方不等待, 使用过期的延迟.
+	start := time.Now()
+	if name := s.getReadDBName(ctx); name != "r1" {
+		t.Errorf("read db during refresh = %s, want r1", name)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("read during refresh blocked %v", elapsed)
+	}
+	// 查询超时视为超过阈值.
+	if name := <-refreshed; name != "primary" {
+		t.Errorf("read db after query timeout = %s, want primary", name)
+	}
+}
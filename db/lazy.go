@@ -0,0 +1,140 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+	"sync"
+)
+
+// LazySource 代表按需创建连接的多库数据源.
+//
+// 首次路由到某个 Key 时创建连接, 同一 Key 并发路由时仅创建一次. 创建失败时不缓存,
+// 之后的路由重新创建, 错误通过 TryUseDB 等返回错误的方法及 Transaction 返回.
+type LazySource struct {
+	router func(context.Context) string
+	dbs    map[string]*lazyDB
+	closer dbCloser
+}
+
+var _ Source = new(LazySource)
+
+// lazyDB 按需创建的连接.
+type lazyDB struct {
+	open func() (*gorm.DB, error)
+
+	mut sync.Mutex
+	db  *gorm.DB
+	err error
+}
+
+// ToLazySource 转换配置为按需创建连接的数据源, 路由同 ToSource.
+func (o MultiRWOptions) ToLazySource(dial Dialector, config *gorm.Config, router func(context.Context) string, opts ...OpenOption) *LazySource {
+	s := &LazySource{router: router, dbs: make(map[string]*lazyDB, len(o))}
+	for key, opt := range o {
+		if opt == nil {
+			continue
+		}
+		key, opt := key, opt
+		s.dbs[key] = &lazyDB{open: func() (*gorm.DB, error) {
+			db, err := opt.OpenDB(dial, config, opts...)
+			if err != nil {
+				return nil, fmt.Errorf("open db %s: %w", key, err)
+			}
+			return db, nil
+		}}
+	}
+	return s
+}
+
+// Warmup 预先创建指定 Key 的连接, 返回首个错误.
+func (s *LazySource) Warmup(keys ...string) error {
+	for _, key := range keys {
+		l, ok := s.dbs[key]
+		if !ok {
+			return s.unknownKey(key)
+		}
+		if _, err := l.get(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unknownKey 返回 key 未配置的错误.
+func (s *LazySource) unknownKey(key string) error {
+	return &UnknownDBKeyError{Requested: key, Known: s.Keys()}
+}
+
+// get 返回连接, 未创建时创建.
+func (l *lazyDB) get() (*gorm.DB, error) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	if l.db != nil {
+		return l.db, nil
+	}
+	l.db, l.err = l.open()
+	return l.db, l.err
+}
+
+func (s *LazySource) lookup(ctx context.Context) (*gorm.DB, error) {
+	key := s.router(ctx)
+	l, ok := s.dbs[key]
+	if !ok {
+		return nil, s.unknownKey(key)
+	}
+	return l.get()
+}
+
+// lookupError 返回 context 对应连接最近一次的创建错误, 不重新创建.
+func (s *LazySource) lookupError(ctx context.Context) error {
+	key := s.router(ctx)
+	l, ok := s.dbs[key]
+	if !ok {
+		return s.unknownKey(key)
+	}
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	return l.err
+}
+
+func (s *LazySource) getWriteDBName(ctx context.Context) string {
+	return s.router(ctx)
+}
+
+func (s *LazySource) getWriteDB(ctx context.Context) *gorm.DB {
+	db, _ := s.lookup(ctx)
+	return db
+}
+
+func (s *LazySource) getReadDBName(ctx context.Context) string {
+	return s.router(ctx)
+}
+
+func (s *LazySource) getReadDB(ctx context.Context) *gorm.DB {
+	db, _ := s.lookup(ctx)
+	return db
+}
+
+// opened 返回已创建的连接.
+func (s *LazySource) opened() map[string]*gorm.DB {
+	dbs := make(map[string]*gorm.DB)
+	for key, l := range s.dbs {
+		l.mut.Lock()
+		if l.db != nil {
+			dbs[key] = l.db
+		}
+		l.mut.Unlock()
+	}
+	return dbs
+}
+
+// Close 关闭已创建的连接.
+func (s *LazySource) Close(context.Context) error {
+	return s.closer.close(s.opened())
+}
+
+// Dialector 返回已创建连接共同的数据库方言, 未创建连接时返回 nil.
+func (s *LazySource) Dialector() gorm.Dialector {
+	return commonDialector(s.opened())
+}
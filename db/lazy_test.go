@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLazySource(t *testing.T) {
+	dir := t.TempDir()
+	var opens int64
+	errDial := errors.New("dial failed")
+	failing := int64(1)
+	dial := func(o *Options) (gorm.Dialector, error) {
+		atomic.AddInt64(&opens, 1)
+		if filepath.Base(*o.DBName) == "bad.db" && atomic.AddInt64(&failing, -1) >= 0 {
+			return nil, errDial
+		}
+		return SQLiteDialector()(o)
+	}
+	opts := MultiRWOptions{}
+	for _, key := range []string{"a", "b", "bad"} {
+		opts[key] = &RWOptions{Write: &Options{Dialect: DialectSQLite, DBName: Ptr(filepath.Join(dir, key+".db"))}}
+	}
+	s := opts.ToLazySource(dial, &gorm.Config{Logger: logger.Discard}, func(ctx context.Context) string {
+		key, _ := ctx.Value(tenantCtxKey{}).(string)
+		return key
+	})
+	p := NewProvider(s)
+	t.Cleanup(func() {
+		_ = p.ForceClose(context.Background())
+	})
+	if opens != 0 {
+		t.Fatalf("opened %d dbs before first use", opens)
+	}
+
+	// 并发路由到同一 Key 仅创建一次.
+	ctx := context.WithValue(context.Background(), tenantCtxKey{}, "a")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.TryUseDB(ctx); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if opens != 1 {
+		t.Errorf("opened %d times for key a, want 1", opens)
+	}
+
+	// 创建失败返回错误, 之后的路由重新创建.
+	badCtx := context.WithValue(context.Background(), tenantCtxKey{}, "bad")
+	err := p.Transaction(badCtx, func(context.Context) error { return nil })
+	if !errors.Is(err, errDial) {
+		t.Errorf("transaction err = %v, want %v", err, errDial)
+	}
+	if _, err := p.TryUseDB(badCtx); err != nil {
+		t.Errorf("retry after failed open = %v", err)
+	}
+
+	if err := s.Warmup("b"); err != nil {
+		t.Fatal(err)
+	}
+	if opened := s.opened(); len(opened) != 3 {
+		t.Errorf("opened keys = %d, want 3", len(opened))
+	}
+	var unknown *UnknownDBKeyError
+	if err := s.Warmup("c"); !errors.As(err, &unknown) || unknown.Requested != "c" {
+		t.Errorf("warmup unknown key err = %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// MySQL 锁等待超时错误码.
+	mysqlErrLockWaitTimeout = 1205
+	// MySQL NOWAIT 加锁失败错误码.
+	mysqlErrLockNoWait = 3572
+)
+
+// LockWaitTimeoutError 代表行锁等待超时或 NOWAIT 加锁失败.
+//
+// 此类错误可安全重试.
+type LockWaitTimeoutError struct {
+	Err error
+}
+
+func (e *LockWaitTimeoutError) Error() string {
+	return fmt.Sprintf("lock wait timeout: %v", e.Err)
+}
+
+func (e *LockWaitTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary 标记错误可重试.
+func (e *LockWaitTimeoutError) Temporary() bool {
+	return true
+}
+
+// RowLockOption 定义行锁选项.
+type RowLockOption func(*clause.Locking)
+
+// RowLockNoWait 行已被锁定时立即失败.
+func RowLockNoWait() RowLockOption {
+	return func(l *clause.Locking) {
+		l.Options = "NOWAIT"
+	}
+}
+
+// RowLockSkipLocked 跳过已被锁定的行.
+//
+// 目标行已被锁定时返回 gorm.ErrRecordNotFound.
+func RowLockSkipLocked() RowLockOption {
+	return func(l *clause.Locking) {
+		l.Options = "SKIP LOCKED"
+	}
+}
+
+// WithRowLock 在事务内锁定一行后执行回调.
+//
+// 不在事务上下文内时开启新事务, 在事务上下文内时加入当前事务.
+//
+// 通过事务 DB 执行 SELECT ... FOR UPDATE, 查询结果写入 model.
+//
+// 锁等待超时返回 *LockWaitTimeoutError.
+func WithRowLock(
+	ctx context.Context,
+	p *TransProvider,
+	model interface{},
+	conds []interface{},
+	fn func(context.Context) error,
+	opts ...RowLockOption,
+) error {
+	locking := clause.Locking{Strength: "UPDATE"}
+	for _, opt := range opts {
+		opt(&locking)
+	}
+	return p.Transaction(ctx, func(ctx context.Context) error {
+		if err := p.UseWriteDB(ctx).Clauses(locking).Take(model, conds...).Error; err != nil {
+			return wrapLockError(err)
+		}
+		return fn(ctx)
+	})
+}
+
+// wrapLockError 将锁等待超时错误转换为 *LockWaitTimeoutError.
+func wrapLockError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case mysqlErrLockWaitTimeout, mysqlErrLockNoWait:
+			return &LockWaitTimeoutError{Err: err}
+		}
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"strings"
+	"testing"
+)
+
+// lockedRow 匹配 fakeRows 的单列结果.
+type lockedRow struct {
+	Value int64
+}
+
+func TestWithRowLock(t *testing.T) {
+	p, fake := newFakeMySQLProvider(t)
+	fake.results = map[string]interface{}{"SELECT": int64(1)}
+	ctx := context.Background()
+
+	var item lockedRow
+	called := false
+	err := WithRowLock(ctx, p, &item, []interface{}{"id = ?", 1}, func(ctx context.Context) error {
+		called = p.InTransaction(ctx)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !called {
+		t.Error("callback not run in transaction")
+	}
+	stmts := fake.statements()
+	if len(stmts) != 3 || stmts[0].query != "BEGIN" || stmts[2].query != "COMMIT" {
+		t.Fatalf("statements = %+v", stmts)
+	}
+	if q := stmts[1].query; !strings.HasSuffix(q, "FOR UPDATE") || stmts[1].conn != stmts[0].conn {
+		t.Errorf("lock statement = %+v", stmts[1])
+	}
+
+	for _, c := range []struct {
+		opt    RowLockOption
+		suffix string
+	}{
+		{RowLockNoWait(), "FOR UPDATE NOWAIT"},
+		{RowLockSkipLocked(), "FOR UPDATE SKIP LOCKED"},
+	} {
+		err := WithRowLock(ctx, p, &item, nil, func(context.Context) error { return nil }, c.opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stmts := fake.statements(); len(stmts) != 3 || !strings.HasSuffix(stmts[1].query, c.suffix) {
+			t.Errorf("statements = %+v, want lock suffix %q", stmts, c.suffix)
+		}
+	}
+}
+
+func TestWithRowLockWaitTimeout(t *testing.T) {
+	p, fake := newFakeMySQLProvider(t)
+	fake.errs = map[string]error{"SELECT": &mysql.MySQLError{Number: mysqlErrLockWaitTimeout, Message: "Lock wait timeout exceeded"}}
+	ctx := context.Background()
+
+	var item lockedRow
+	err := WithRowLock(ctx, p, &item, nil, func(context.Context) error {
+		t.Error("callback run after lock failure")
+		return nil
+	})
+	var lockErr *LockWaitTimeoutError
+	if !errors.As(err, &lockErr) || !lockErr.Temporary() {
+		t.Fatalf("err = %v, want *LockWaitTimeoutError", err)
+	}
+	if stmts := fake.statements(); stmts[len(stmts)-1].query != "ROLLBACK" {
+		t.Errorf("statements = %+v, want rollback", stmts)
+	}
+
+	// 加入已开启的事务.
+	fake.errs = nil
+	fake.results = map[string]interface{}{"SELECT": int64(1)}
+	err = p.Transaction(ctx, func(ctx context.Context) error {
+		return WithRowLock(ctx, p, &item, nil, func(context.Context) error { return nil })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	begins := 0
+	for _, stmt := range fake.statements() {
+		if stmt.query == "BEGIN" {
+			begins++
+		}
+	}
+	if begins != 1 {
+		t.Errorf("BEGIN executed %d times, want 1", begins)
+	}
+}
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRowLockTimeoutUnsupported = errors.New("row lock timeout: connection pool cannot be pinned")
+)
+
+type rowLockTimeoutKey struct{}
+
+// rowLockTimeoutPlugin 在语句所在连接上设置行锁等待超时, 执行后恢复原会话值.
+var rowLockTimeoutPlugin = connSettingPlugin{
+	name:       "mini_transaction:row_lock_timeout",
+	get:        "SELECT @@SESSION.innodb_lock_wait_timeout",
+	newValue:   func() interface{} { return new(int64) },
+	set:        "SET SESSION innodb_lock_wait_timeout = ?",
+	unpinnable: ErrRowLockTimeoutUnsupported,
+}
+
+// WithRowLockTimeout 设置 UseWriteDB 返回 DB 的 MySQL 行锁等待超时, 单位秒.
+//
+// 语句执行前在同一连接上设置 innodb_lock_wait_timeout, 执行后恢复为设置前的会话值.
+// 事务外语句独占一个连接执行. Row, Rows 及 Raw().Scan 等返回结果集的语句无法在同一连接上恢复,
+// 返回 ErrRowStatementUnsupported, 使用 Raw().Find 代替.
+func WithRowLockTimeout(ctx context.Context, timeoutSec int) context.Context {
+	return context.WithValue(ctx, rowLockTimeoutKey{}, timeoutSec)
+}
+
+// withRowLockTimeout 为 DB 绑定 context 中的行锁等待超时.
+func withRowLockTimeout(ctx context.Context, db *gorm.DB) *gorm.DB {
+	timeout, ok := ctx.Value(rowLockTimeoutKey{}).(int)
+	if !ok || timeout <= 0 {
+		return db
+	}
+	if err := checkPlugin(db, rowLockTimeoutPlugin.name); err != nil {
+		_ = db.AddError(err)
+		return db
+	}
+	return db.Set(rowLockTimeoutPlugin.name, timeout)
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"testing"
+)
+
+func newRowLockTimeoutProvider(t *testing.T) (*TransProvider, *fakeConnector) {
+	sqlDB, fake := newFakeDB(map[string]interface{}{
+		"SELECT @@SESSION.innodb_lock_wait_timeout": int64(7),
+	})
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewProvider(NewSource("mysql", db)), fake
+}
+
+func TestRowLockTimeoutRestoresSessionValue(t *testing.T) {
+	p, fake := newRowLockTimeoutProvider(t)
+	ctx := WithRowLockTimeout(context.Background(), 3)
+
+	if err := p.UseWriteDB(ctx).Model(&tenantItem{ID: 1}).Update("name", "b").Error; err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"SELECT @@SESSION.innodb_lock_wait_timeout",
+		"SET SESSION innodb_lock_wait_timeout = ?",
+		"BEGIN",
+		"UPDATE `tenant_items` SET `name`=? WHERE `id` = ?",
+		"COMMIT",
+		"SET SESSION innodb_lock_wait_timeout = ?",
+	}
+	log := fake.statements()
+	if len(log) != len(want) {
+		t.Fatalf("statements = %+v", log)
+	}
+	for i, stmt := range log {
+		if stmt.query != want[i] {
+			t.Errorf("statement %d = %q, want %q", i, stmt.query, want[i])
+		}
+		if stmt.conn != log[0].conn {
+			t.Errorf("statement %d ran on conn %d, want %d", i, stmt.conn, log[0].conn)
+		}
+	}
+	if got := log[1].args[0]; got != int64(3) {
+		t.Errorf("timeout = %v", got)
+	}
+	if got := log[5].args[0]; got != int64(7) {
+		t.Errorf("restored timeout = %v, want previous session value 7", got)
+	}
+}
+
+func TestRowLockTimeoutInTransaction(t *testing.T) {
+	p, fake := newRowLockTimeoutProvider(t)
+
+	err := p.Transaction(context.Background(), func(ctx context.Context) error {
+		ctx = WithRowLockTimeout(ctx, 3)
+		var items []tenantItem
+		return p.UseWriteDB(ctx).Raw("SELECT * FROM tenant_items FOR UPDATE").Find(&items).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"BEGIN",
+		"SELECT @@SESSION.innodb_lock_wait_timeout",
+		"SET SESSION innodb_lock_wait_timeout = ?",
+		"SELECT * FROM tenant_items FOR UPDATE",
+		"SET SESSION innodb_lock_wait_timeout = ?",
+		"COMMIT",
+	}
+	log := fake.statements()
+	if len(log) != len(want) {
+		t.Fatalf("statements = %+v", log)
+	}
+	for i, stmt := range log {
+		if stmt.query != want[i] {
+			t.Errorf("statement %d = %q, want %q", i, stmt.query, want[i])
+		}
+	}
+}
+
+func TestRowLockTimeoutRowStatement(t *testing.T) {
+	p, fake := newRowLockTimeoutProvider(t)
+	ctx := WithRowLockTimeout(context.Background(), 3)
+
+	var n int
+	err := p.UseWriteDB(ctx).Raw("SELECT count(*) FROM tenant_items FOR UPDATE").Scan(&n).Error
+	if !errors.Is(err, ErrRowStatementUnsupported) {
+		t.Fatalf("Scan error = %v, want ErrRowStatementUnsupported", err)
+	}
+	err = p.Transaction(context.Background(), func(ctx context.Context) error {
+		return p.UseWriteDB(WithRowLockTimeout(ctx, 3)).Raw("SELECT 1").Scan(&n).Error
+	})
+	if !errors.Is(err, ErrRowStatementUnsupported) {
+		t.Fatalf("Scan error in transaction = %v, want ErrRowStatementUnsupported", err)
+	}
+	for _, stmt := range fake.statements() {
+		if stmt.query != "BEGIN" && stmt.query != "ROLLBACK" {
+			t.Errorf("unexpected statement %q", stmt.query)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"fmt"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"net/url"
+	"time"
+)
+
+const (
+	// DefaultCharset 未配置 Charset 时使用的字符集.
+	DefaultCharset = "utf8mb4"
+	// DefaultLoc 连接串 loc 参数默认值.
+	DefaultLoc = "Local"
+)
+
+var (
+	// 未配置超时时使用的默认值.
+	DefaultTimeout      = 100 * time.Millisecond
+	DefaultReadTimeout  = 2 * time.Second
+	DefaultWriteTimeout = 5 * time.Second
+)
+
+// mysqlBuiltinParams MySQL 连接串内置参数, collation 仅在配置 Collation 时为内置参数.
+var mysqlBuiltinParams = map[string]bool{
+	"charset": true, "parseTime": true, "loc": true,
+	"timeout": true, "readTimeout": true, "writeTimeout": true,
+}
+
+// mysqlCollationBuiltinParams 配置 Collation 时的 MySQL 连接串内置参数.
+var mysqlCollationBuiltinParams = func() map[string]bool {
+	params := map[string]bool{"collation": true}
+	for key := range mysqlBuiltinParams {
+		params[key] = true
+	}
+	return params
+}()
+
+// MySQLDialectorOption 定义 DefaultMySQLDialector 选项.
+type MySQLDialectorOption func(*mysqlDialector)
+
+// mysqlDialector MySQL 连接串配置.
+type mysqlDialector struct {
+	driverName   string
+	charset      string
+	loc          string
+	timeout      time.Duration
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+// WithMySQLDriverName 设置 database/sql 驱动名, 未设置时为 mysql.
+//
+// 配置 PasswordProvider 或 RotatableCredentials 时直接使用 go-sql-driver/mysql, 忽略驱动名.
+func WithMySQLDriverName(driverName string) MySQLDialectorOption {
+	return func(d *mysqlDialector) {
+		d.driverName = driverName
+	}
+}
+
+// WithMySQLDefaultCharset 设置未配置 Charset 时使用的字符集, 默认为 DefaultCharset.
+func WithMySQLDefaultCharset(charset string) MySQLDialectorOption {
+	return func(d *mysqlDialector) {
+		d.charset = charset
+	}
+}
+
+// WithMySQLLoc 设置连接串 loc 参数, 默认为 DefaultLoc.
+func WithMySQLLoc(loc string) MySQLDialectorOption {
+	return func(d *mysqlDialector) {
+		d.loc = loc
+	}
+}
+
+// WithMySQLDefaultTimeouts 设置未配置超时时使用的连接、读及写超时, 为 0 的参数保持默认值.
+func WithMySQLDefaultTimeouts(timeout, readTimeout, writeTimeout time.Duration) MySQLDialectorOption {
+	return func(d *mysqlDialector) {
+		if timeout > 0 {
+			d.timeout = timeout
+		}
+		if readTimeout > 0 {
+			d.readTimeout = readTimeout
+		}
+		if writeTimeout > 0 {
+			d.writeTimeout = writeTimeout
+		}
+	}
+}
+
+func newMySQLDialector(opts []MySQLDialectorOption) *mysqlDialector {
+	d := &mysqlDialector{
+		charset:      DefaultCharset,
+		loc:          DefaultLoc,
+		timeout:      DefaultTimeout,
+		readTimeout:  DefaultReadTimeout,
+		writeTimeout: DefaultWriteTimeout,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// DefaultMySQLDialector 返回 MySQL 方言转换函数.
+//
+// 连接串见 MySQLDSN. 配置 PasswordProvider 或 RotatableCredentials 时每次建立连接时获取密码.
+func DefaultMySQLDialector(opts ...MySQLDialectorOption) Dialector {
+	d := newMySQLDialector(opts)
+	return func(opts *Options) (gorm.Dialector, error) {
+		if opts.useConnector() {
+			connector := NewPasswordConnector(&mysqldriver.MySQLDriver{}, opts, d.dsn)
+			return mysql.New(mysql.Config{Conn: openConnector(connector)}), nil
+		}
+		return mysql.New(mysql.Config{DriverName: d.driverName, DSN: d.dsn(opts, deref(opts.Password))}), nil
+	}
+}
+
+// MySQLDSN 生成 MySQL 连接串.
+//
+// 设置 Socket 时通过 unix socket 连接, 否则使用 tcp. 超时未配置时使用默认值,
+// 排序规则仅在配置时设置, 未配置时可通过 Params 设置. Params 按 Key 排序附加在内置参数后, 值经 URL 编码,
+// 与内置参数同名时忽略.
+func MySQLDSN(opts *Options, password string, dialectorOpts ...MySQLDialectorOption) string {
+	return newMySQLDialector(dialectorOpts).dsn(opts, password)
+}
+
+func (d *mysqlDialector) dsn(opts *Options, password string) string {
+	f := "%s:%s@%s/%s?charset=%s&parseTime=true&loc=%s&timeout=%s&readTimeout=%s&writeTimeout=%s"
+	dsn := fmt.Sprintf(f, deref(opts.UserName), password, mysqlAddr(opts),
+		deref(opts.DBName), orDefault(opts.Charset, d.charset), url.QueryEscape(d.loc),
+		millisOrDefault(opts.TimeoutInMills, d.timeout),
+		millisOrDefault(opts.ReadTimeoutInMills, d.readTimeout),
+		millisOrDefault(opts.WriteTimeoutInMills, d.writeTimeout))
+	builtin := mysqlBuiltinParams
+	if opts.Collation != "" {
+		dsn += "&collation=" + url.QueryEscape(opts.Collation)
+		builtin = mysqlCollationBuiltinParams
+	}
+	for _, key := range opts.paramKeys(builtin) {
+		dsn += "&" + key + "=" + url.QueryEscape(opts.Params[key])
+	}
+	return dsn
+}
+
+func mysqlAddr(opts *Options) string {
+	if opts.Socket != "" {
+		return fmt.Sprintf("unix(%s)", opts.Socket)
+	}
+	return fmt.Sprintf("tcp(%s:%d)", deref(opts.Host), deref(opts.Port))
+}
+
+func orDefault(v, def string) string {
+	if v != "" {
+		return v
+	}
+	return def
+}
+
+func millisOrDefault(millis uint, def time.Duration) time.Duration {
+	if millis > 0 {
+		return time.Duration(millis) * time.Millisecond
+	}
+	return def
+}
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"errors"
+	"gorm.io/driver/mysql"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMySQLDSNCollation(t *testing.T) {
+	base := Options{Host: Ptr("db"), Port: Ptr(3306), DBName: Ptr("app"), UserName: Ptr("u")}
+
+	o := base
+	o.Params = map[string]string{"collation": "utf8mb4_bin", "tls": "true"}
+	dsn := MySQLDSN(&o, "p")
+	if !strings.HasSuffix(dsn, "&collation=utf8mb4_bin&tls=true") {
+		t.Errorf("dsn = %s, want collation from Params", dsn)
+	}
+
+	o.Collation = "utf8mb4_general_ci"
+	dsn = MySQLDSN(&o, "p")
+	if strings.Count(dsn, "collation=") != 1 || !strings.Contains(dsn, "&collation=utf8mb4_general_ci") {
+		t.Errorf("dsn = %s, want only the Collation field", dsn)
+	}
+
+	o = base
+	o.Params = map[string]string{"charset": "latin1"}
+	if dsn := MySQLDSN(&o, "p"); strings.Contains(dsn, "latin1") {
+		t.Errorf("dsn = %s, builtin charset overridden by Params", dsn)
+	}
+}
+
+func TestMySQLDSNCharset(t *testing.T) {
+	base := Options{Host: Ptr("db"), Port: Ptr(3306), DBName: Ptr("app"), UserName: Ptr("u")}
+
+	// 未配置时使用 utf8mb4, 不设置排序规则.
+	dsn := MySQLDSN(&base, "p")
+	if !strings.Contains(dsn, "?charset=utf8mb4&") || strings.Contains(dsn, "collation=") {
+		t.Errorf("default dsn = %s", dsn)
+	}
+
+	o := base
+	o.Charset = "utf8mb3"
+	o.Collation = "utf8mb3_unicode_ci"
+	dsn = MySQLDSN(&o, "p")
+	if !strings.Contains(dsn, "?charset=utf8mb3&") || !strings.HasSuffix(dsn, "&collation=utf8mb3_unicode_ci") {
+		t.Errorf("dsn = %s, want utf8mb3 with collation", dsn)
+	}
+	if dsn := MySQLDSN(&base, "p", WithMySQLDefaultCharset("latin1")); !strings.Contains(dsn, "?charset=latin1&") {
+		t.Errorf("dsn = %s, want dialector default charset", dsn)
+	}
+}
+
+func TestMySQLDSNAddress(t *testing.T) {
+	const params = "?charset=utf8mb4&parseTime=true&loc=Local&timeout=100ms&readTimeout=2s&writeTimeout=5s"
+	for _, c := range []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{"tcp", Options{Host: Ptr("db"), Port: Ptr(3306), DBName: Ptr("app"), UserName: Ptr("u")}, "u:p@tcp(db:3306)/app" + params},
+		{"unix", Options{Socket: "/cloudsql/app.sock", Port: Ptr(3306), DBName: Ptr("app"), UserName: Ptr("u")}, "u:p@unix(/cloudsql/app.sock)/app" + params},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if dsn := MySQLDSN(&c.opts, "p"); dsn != c.want {
+				t.Errorf("dsn = %s\nwant %s", dsn, c.want)
+			}
+		})
+	}
+
+	o := &Options{Host: Ptr("db"), Socket: "/cloudsql/app.sock", DBName: Ptr("app"), UserName: Ptr("u")}
+	if _, err := o.OpenDB(DefaultMySQLDialector(), nil); !errors.Is(err, ErrHostAndSocket) {
+		t.Errorf("OpenDB with host and socket = %v, want ErrHostAndSocket", err)
+	}
+}
+
+func TestMySQLDSNParams(t *testing.T) {
+	o := Options{
+		Host: Ptr("db"), Port: Ptr(3306), DBName: Ptr("app"), UserName: Ptr("u"),
+		Params: map[string]string{
+			"rejectReadOnly":       "true",
+			"clientFoundRows":      "true",
+			"allowNativePasswords": "true",
+			"connectionAttributes": "program_name:svc a&b",
+			"parseTime":            "false",
+		},
+	}
+	want := "&allowNativePasswords=true&clientFoundRows=true&connectionAttributes=program_name%3Asvc+a%26b&rejectReadOnly=true"
+	dsn := MySQLDSN(&o, "p")
+	// 按 Key 排序附加在内置参数后, 值经 URL 编码.
+	if !strings.HasSuffix(dsn, want) {
+		t.Errorf("dsn = %s, want suffix %s", dsn, want)
+	}
+	// 与内置参数同名时忽略.
+	if strings.Contains(dsn, "parseTime=false") {
+		t.Errorf("dsn = %s, builtin parseTime overridden by Params", dsn)
+	}
+	for i := 0; i < 10; i++ {
+		if again := MySQLDSN(&o, "p"); again != dsn {
+			t.Fatalf("dsn not stable: %s != %s", again, dsn)
+		}
+	}
+}
+
+func TestDefaultMySQLDialector(t *testing.T) {
+	base := Options{Host: Ptr("db"), Port: Ptr(3306), DBName: Ptr("app"), UserName: Ptr("u"), Password: Ptr("p")}
+	withTimeouts := base
+	withTimeouts.TimeoutInMills = 250
+	withTimeouts.ReadTimeoutInMills = 1000
+	withTimeouts.WriteTimeoutInMills = 1500
+	for _, c := range []struct {
+		name       string
+		opts       Options
+		dialOpts   []MySQLDialectorOption
+		driverName string
+		query      string
+	}{
+		{
+			name:  "defaults",
+			opts:  base,
+			query: "charset=utf8mb4&parseTime=true&loc=Local&timeout=100ms&readTimeout=2s&writeTimeout=5s",
+		},
+		{
+			name:  "options timeouts",
+			opts:  withTimeouts,
+			query: "charset=utf8mb4&parseTime=true&loc=Local&timeout=250ms&readTimeout=1s&writeTimeout=1.5s",
+		},
+		{
+			name:     "default timeouts",
+			opts:     base,
+			dialOpts: []MySQLDialectorOption{WithMySQLDefaultTimeouts(time.Second, 0, 10*time.Second)},
+			query:    "charset=utf8mb4&parseTime=true&loc=Local&timeout=1s&readTimeout=2s&writeTimeout=10s",
+		},
+		{
+			name:     "options timeouts override defaults",
+			opts:     withTimeouts,
+			dialOpts: []MySQLDialectorOption{WithMySQLDefaultTimeouts(time.Second, time.Second, time.Second)},
+			query:    "charset=utf8mb4&parseTime=true&loc=Local&timeout=250ms&readTimeout=1s&writeTimeout=1.5s",
+		},
+		{
+			name:       "driver, charset and loc",
+			opts:       base,
+			dialOpts:   []MySQLDialectorOption{WithMySQLDriverName("traced-mysql"), WithMySQLDefaultCharset("utf8mb3"), WithMySQLLoc("Asia/Shanghai")},
+			driverName: "traced-mysql",
+			query:      "charset=utf8mb3&parseTime=true&loc=Asia%2FShanghai&timeout=100ms&readTimeout=2s&writeTimeout=5s",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			d, err := DefaultMySQLDialector(c.dialOpts...)(&c.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			md, ok := d.(*mysql.Dialector)
+			if !ok {
+				t.Fatalf("dialector = %T, want *mysql.Dialector", d)
+			}
+			if md.DriverName != c.driverName {
+				t.Errorf("driver name = %q, want %q", md.DriverName, c.driverName)
+			}
+			if want := "u:p@tcp(db:3306)/app?" + c.query; md.DSN != want {
+				t.Errorf("dsn = %s\nwant %s", md.DSN, want)
+			}
+		})
+	}
+}
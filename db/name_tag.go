@@ -0,0 +1,44 @@
+package db
+
+import (
+	"gorm.io/gorm"
+)
+
+const (
+	nameTagPluginName = "mini_transaction:name_tag"
+	// logicalNameKey 逻辑库名在 Statement.Settings 中的 Key.
+	logicalNameKey = "mini_transaction:logical_name"
+)
+
+// nameTagPlugin 为语句标记逻辑库名.
+type nameTagPlugin struct {
+	logicalName string
+}
+
+// NewNameTagPlugin 创建逻辑库名标记插件.
+//
+// 插件在语句执行前将逻辑库名写入 Statement.Settings, 供 tracing 等回调通过
+// LogicalDBName 读取, 避免在 span 中暴露物理地址.
+func NewNameTagPlugin(logicalName string) gorm.Plugin {
+	return &nameTagPlugin{logicalName: logicalName}
+}
+
+func (p *nameTagPlugin) Name() string {
+	return nameTagPluginName
+}
+
+func (p *nameTagPlugin) Initialize(db *gorm.DB) error {
+	tag := func(db *gorm.DB) {
+		db.Statement.Settings.Store(logicalNameKey, p.logicalName)
+	}
+	return registerBeforeStatement(db, nameTagPluginName, tag)
+}
+
+// LogicalDBName 返回语句的逻辑库名.
+//
+// 未注册 NameTagPlugin 时返回空字符串.
+func LogicalDBName(db *gorm.DB) string {
+	name, _ := db.Statement.Settings.Load(logicalNameKey)
+	s, _ := name.(string)
+	return s
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path/filepath"
+	"testing"
+)
+
+func TestNameTagPlugin(t *testing.T) {
+	opts := &Options{
+		Dialect:     DialectSQLite,
+		DBName:      Ptr(filepath.Join(t.TempDir(), "name.db")),
+		LogicalName: "orders",
+	}
+	db, err := opts.OpenDB(SQLiteDialector(), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(new(tenantItem)); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	capture := func(db *gorm.DB) {
+		names = append(names, LogicalDBName(db))
+	}
+	if err := db.Callback().Create().After("gorm:create").Register("test:capture", capture); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Callback().Query().After("gorm:query").Register("test:capture", capture); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewProvider(NewSource("sqlite", db))
+	defer p.ForceClose(context.Background())
+	ctx := context.Background()
+	if err := p.UseWriteDB(ctx).Create(&tenantItem{Name: "a"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	var items []tenantItem
+	if err := p.UseDB(ctx).Find(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "orders" || names[1] != "orders" {
+		t.Errorf("logical names = %q, want [orders orders]", names)
+	}
+
+	// 未设置 LogicalName 时为空.
+	if name := LogicalDBName(newSQLiteProvider(t).UseDB(ctx)); name != "" {
+		t.Errorf("logical name without plugin = %q", name)
+	}
+}
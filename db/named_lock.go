@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"mini_transaction/transaction"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNamedLockNotHeld = errors.New("named lock not held")
+)
+
+// NamedLock 代表通过 GET_LOCK 获取的 MySQL 命名锁.
+//
+// 命名锁属于数据库会话, 持有期间独占一个写库连接, 释放时归还.
+type NamedLock struct {
+	name string
+	conn *sql.Conn
+	// 锁随事务结束释放.
+	tied bool
+
+	once sync.Once
+	err  error
+}
+
+var _ io.Closer = new(NamedLock)
+
+// namedLocksKey 事务持有的命名锁在事务范围数据中的 Key.
+type namedLocksKey struct{}
+
+// namedLocks 记录事务持有的命名锁.
+type namedLocks struct {
+	mut   sync.Mutex
+	locks []*NamedLock
+}
+
+// releaseNamedLocks 释放事务持有的命名锁, 由 TransProvider.transaction 在根事务结束时调用.
+//
+// 不依赖提交或回滚回调, 提交结果未知时同样释放.
+func releaseNamedLocks(tc transaction.TransContext) {
+	if tc == nil {
+		return
+	}
+	v, ok := tc.Load(namedLocksKey{})
+	if !ok {
+		return
+	}
+	held := v.(*namedLocks)
+	held.mut.Lock()
+	locks := held.locks
+	held.locks = nil
+	held.mut.Unlock()
+	for _, lock := range locks {
+		_ = lock.Release()
+	}
+}
+
+// AcquireNamedLock 获取 MySQL 命名锁.
+//
+// 在写库的独立连接上执行 SELECT GET_LOCK(name, timeout), 不使用事务连接,
+// 避免事务结束后连接归还连接池时仍持有锁. 超时未获取返回 *LockWaitTimeoutError.
+//
+// 在事务上下文内时, 锁在事务结束后自动释放, 包括提交结果未知; 不在事务内时需调用 Release.
+func AcquireNamedLock(ctx context.Context, p *TransProvider, name string, timeout time.Duration) (*NamedLock, error) {
+	db := p.getWriteDB(ctx)
+	if db == nil {
+		return nil, p.dbNotFound(ctx)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, timeout.Seconds()).Scan(&acquired); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		_ = conn.Close()
+		return nil, &LockWaitTimeoutError{Err: fmt.Errorf("named lock %q not acquired within %s", name, timeout)}
+	}
+	lock := &NamedLock{name: name, conn: conn}
+	if tc := p.findTransContext(ctx); tc != nil {
+		lock.tied = true
+		v, _ := tc.LoadOrStore(namedLocksKey{}, new(namedLocks))
+		held := v.(*namedLocks)
+		held.mut.Lock()
+		held.locks = append(held.locks, lock)
+		held.mut.Unlock()
+	}
+	return lock, nil
+}
+
+// Release 释放命名锁并归还连接, 重复调用返回首次结果.
+func (l *NamedLock) Release() error {
+	l.once.Do(func() {
+		defer l.conn.Close()
+		var released sql.NullInt64
+		if err := l.conn.QueryRowContext(context.Background(), "SELECT RELEASE_LOCK(?)", l.name).Scan(&released); err != nil {
+			l.err = err
+			return
+		}
+		if !released.Valid || released.Int64 != 1 {
+			l.err = fmt.Errorf("%w: %s", ErrNamedLockNotHeld, l.name)
+		}
+	})
+	return l.err
+}
+
+// Close 实现 io.Closer, 同 Release.
+func (l *NamedLock) Close() error {
+	return l.Release()
+}
+
+// WithNamedLock 持有命名锁执行回调.
+//
+// 不在事务内时回调结束后释放锁, 在事务内时锁随事务结束释放.
+func WithNamedLock(ctx context.Context, p *TransProvider, name string, timeout time.Duration, callback func(context.Context) error) error {
+	lock, err := AcquireNamedLock(ctx, p, name, timeout)
+	if err != nil {
+		return err
+	}
+	if !lock.tied {
+		defer lock.Release()
+	}
+	return callback(ctx)
+}
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNamedLocks 模拟 MySQL GET_LOCK 及 RELEASE_LOCK, 命名锁属于连接.
+type fakeNamedLocks struct {
+	mut    sync.Mutex
+	owners map[string]int
+}
+
+func (l *fakeNamedLocks) query(ctx context.Context, conn int, query string, args []driver.NamedValue) (interface{}, bool, error) {
+	switch query {
+	case "SELECT GET_LOCK(?, ?)":
+		name := args[0].Value.(string)
+		deadline := time.Now().Add(time.Duration(args[1].Value.(float64) * float64(time.Second)))
+		for {
+			if l.tryLock(name, conn) {
+				return int64(1), true, nil
+			}
+			if time.Now().After(deadline) {
+				return int64(0), true, nil
+			}
+			select {
+			case <-ctx.Done():
+				return nil, false, ctx.Err()
+			case <-time.After(5 * time.Millisecond):
+			}
+		}
+	case "SELECT RELEASE_LOCK(?)":
+		l.mut.Lock()
+		defer l.mut.Unlock()
+		name := args[0].Value.(string)
+		if owner, ok := l.owners[name]; !ok || owner != conn {
+			return int64(0), true, nil
+		}
+		delete(l.owners, name)
+		return int64(1), true, nil
+	}
+	return nil, false, nil
+}
+
+func (l *fakeNamedLocks) tryLock(name string, conn int) bool {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	if owner, ok := l.owners[name]; ok && owner != conn {
+		return false
+	}
+	l.owners[name] = conn
+	return true
+}
+
+func newNamedLockProvider(t *testing.T) *TransProvider {
+	p, fake := newFakeMySQLProvider(t)
+	fake.query = (&fakeNamedLocks{owners: make(map[string]int)}).query
+	return p
+}
+
+func TestNamedLockMutualExclusion(t *testing.T) {
+	p := newNamedLockProvider(t)
+	ctx := context.Background()
+	const timeout = 100 * time.Millisecond
+
+	held := make(chan struct{})
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := WithNamedLock(ctx, p, "job", timeout, func(context.Context) error {
+			close(held)
+			<-release
+			return nil
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	<-held
+
+	// 另一 goroutine 等待超时.
+	start := time.Now()
+	_, err := AcquireNamedLock(ctx, p, "job", timeout)
+	var timeoutErr *LockWaitTimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("err = %v, want *LockWaitTimeoutError", err)
+	}
+	if elapsed := time.Since(start); elapsed < timeout || elapsed > 10*timeout {
+		t.Errorf("waited %v, want about %v", elapsed, timeout)
+	}
+
+	// 释放后可获取.
+	close(release)
+	wg.Wait()
+	lock, err := AcquireNamedLock(ctx, p, "job", timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lock.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Errorf("second Release err = %v, want first result", err)
+	}
+}
+
+func TestNamedLockTiedToTransaction(t *testing.T) {
+	p := newNamedLockProvider(t)
+	ctx := context.Background()
+	const timeout = 100 * time.Millisecond
+
+	for _, txErr := range []error{nil, errors.New("rollback")} {
+		err := p.Transaction(ctx, func(ctx context.Context) error {
+			if _, err := AcquireNamedLock(ctx, p, "job", timeout); err != nil {
+				return err
+			}
+			// 事务内锁保持到事务结束.
+			if _, err := AcquireNamedLock(context.Background(), p, "job", timeout); err == nil {
+				t.Error("lock acquired by another session inside the transaction")
+			}
+			return txErr
+		})
+		if !errors.Is(err, txErr) {
+			t.Fatal(err)
+		}
+		// 事务结束后自动释放.
+		lock, err := AcquireNamedLock(ctx, p, "job", 0)
+		if err != nil {
+			t.Fatalf("tx err %v: lock not released after transaction: %v", txErr, err)
+		}
+		_ = lock.Release()
+	}
+}
+
+func TestNamedLockReleasedOnCommitTimeout(t *testing.T) {
+	base, fake := newFakeMySQLProvider(t)
+	fake.query = (&fakeNamedLocks{owners: make(map[string]int)}).query
+	p := NewProviderWithOptions(base.loadSource(), WithCommitTimeout(20*time.Millisecond))
+	ctx := context.Background()
+
+	fake.commitDelay = 200 * time.Millisecond
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		_, err := AcquireNamedLock(ctx, p, "job", 0)
+		return err
+	})
+	var timeoutErr *CommitTimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("err = %v, want *CommitTimeoutError", err)
+	}
+	// 结果未知时不执行回调, 锁同样释放.
+	lock, err := AcquireNamedLock(ctx, p, "job", 0)
+	if err != nil {
+		t.Fatalf("lock not released after commit timeout: %v", err)
+	}
+	_ = lock.Release()
+}
@@ -6,10 +6,30 @@ import (
 	"fmt"
 	"gorm.io/gorm"
 	"gorm.io/plugin/dbresolver"
+	"sort"
+	"time"
 )
 
 var (
 	ErrWriteDBNotConfigured = errors.New("write database not configured")
+	ErrReadDBNotConfigured  = errors.New("read database not configured")
+	ErrHostAndSocket        = errors.New("host and socket are mutually exclusive")
+)
+
+const (
+	// DefaultHost 未配置 Host 及 Socket 时 WithDefaults 使用的地址.
+	DefaultHost = "127.0.0.1"
+	// DefaultPort MySQL 未配置 Port 时 WithDefaults 使用的端口.
+	DefaultPort = 3306
+	// DefaultPostgresPort PostgreSQL 未配置 Port 时 WithDefaults 使用的端口.
+	DefaultPostgresPort = 5432
+)
+
+// Options.Dialect 支持的数据库方言.
+const (
+	DialectMySQL    = "mysql"
+	DialectPostgres = "postgres"
+	DialectSQLite   = "sqlite"
 )
 
 // MultiRWOptions 定义多主从配置.
@@ -20,24 +40,130 @@ type Dialector func(*Options) (gorm.Dialector, error)
 
 // RWOptions 定义主从配置.
 //
-// 支持一主一从模式,一主多从由基础设施支持.
+// 支持一主多从模式, 从库通过 dbresolver 注册.
 type RWOptions struct {
 	// 主库配置.
 	Write *Options `yaml:"write" mapstructure:"write"`
-	// 从库配置.
+	// 从库配置, 兼容单从库配置, 与 Reads 合并.
+	//
+	// YAML 中 read 可为单个从库或从库列表, 列表解析到 Reads.
 	Read *Options `yaml:"read" mapstructure:"read"`
+	// 多从库配置, 每个从库分别应用各自的连接池配置.
+	Reads []*Options `yaml:"-" mapstructure:"reads"`
+	// 从库负载均衡策略, 默认为 ReadPolicyRandom.
+	ReadPolicy ReadPolicy `yaml:"read_policy" mapstructure:"read_policy"`
+}
+
+// readOptions 返回合并后的从库配置, Read 在前.
+func (o *RWOptions) readOptions() ([]*Options, error) {
+	var reads []*Options
+	if o.Read != nil {
+		reads = append(reads, o.Read)
+	}
+	for i, opt := range o.Reads {
+		if opt == nil {
+			return nil, fmt.Errorf("%w: reads[%d] is nil", ErrReadDBNotConfigured, i)
+		}
+		reads = append(reads, opt)
+	}
+	return reads, nil
+}
+
+// rwOptionsYAML RWOptions 的 YAML 结构.
+type rwOptionsYAML struct {
+	Write      *Options    `yaml:"write"`
+	Read       replicaList `yaml:"read,omitempty"`
+	ReadPolicy ReadPolicy  `yaml:"read_policy,omitempty"`
+}
+
+// replicaList YAML 中的从库配置, 兼容单个从库.
+type replicaList []*Options
+
+func (l *replicaList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var list []*Options
+	if err := unmarshal(&list); err == nil {
+		*l = list
+		return nil
+	}
+	var single *Options
+	if err := unmarshal(&single); err != nil {
+		return err
+	}
+	*l = replicaList{single}
+	return nil
+}
+
+// UnmarshalYAML 解析 YAML, read 为单个从库时设置 Read, 为列表时设置 Reads.
+func (o *RWOptions) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v struct {
+		Write      *Options    `yaml:"write"`
+		Read       interface{} `yaml:"read"`
+		ReadPolicy ReadPolicy  `yaml:"read_policy"`
+	}
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	var reads rwOptionsYAML
+	if err := unmarshal(&reads); err != nil {
+		return err
+	}
+	*o = RWOptions{Write: reads.Write, ReadPolicy: reads.ReadPolicy}
+	if _, isList := v.Read.([]interface{}); isList {
+		o.Reads = reads.Read
+	} else if len(reads.Read) == 1 {
+		o.Read = reads.Read[0]
+	}
+	return nil
+}
+
+// MarshalYAML 输出 YAML, 仅配置 Read 时 read 为单个从库, 否则为合并后的从库列表.
+func (o RWOptions) MarshalYAML() (interface{}, error) {
+	v := rwOptionsYAML{Write: o.Write, ReadPolicy: o.ReadPolicy}
+	if o.Read != nil && len(o.Reads) == 0 {
+		return struct {
+			Write      *Options   `yaml:"write"`
+			Read       *Options   `yaml:"read"`
+			ReadPolicy ReadPolicy `yaml:"read_policy,omitempty"`
+		}{Write: o.Write, Read: o.Read, ReadPolicy: o.ReadPolicy}, nil
+	}
+	reads, err := o.readOptions()
+	if err != nil {
+		return nil, err
+	}
+	v.Read = reads
+	return v, nil
 }
 
 // Options 定义数据库配置.
 type Options struct {
-	// 地址信息.
-	Host string `yaml:"host" mapstructure:"host"`
-	Port int    `yaml:"port" mapstructure:"port"`
+	// 数据库方言, 用于校验及默认值, 取值为 DialectMySQL, DialectPostgres 或 DialectSQLite, 为空时为 DialectMySQL.
+	// 需与打开时使用的 Dialector 一致.
+	Dialect string `yaml:"dialect" mapstructure:"dialect"`
+
+	// 地址信息, 未配置时为 nil, 默认值见 WithDefaults.
+	Host *string `yaml:"host" mapstructure:"host"`
+	Port *int    `yaml:"port" mapstructure:"port"`
+	// Unix socket 路径, 设置后通过 socket 连接并忽略 Port, 不可与 Host 同时设置.
+	Socket string `yaml:"socket" mapstructure:"socket"`
 
-	// 认证配置项.
-	DBName   string `yaml:"db_name" mapstructure:"db_name"`
-	UserName string `yaml:"username" mapstructure:"username"`
-	Password string `yaml:"password" mapstructure:"password"`
+	// 逻辑库名, 设置后注册 NameTagPlugin, 用于 tracing 展示.
+	LogicalName string `yaml:"logical_name" mapstructure:"logical_name"`
+
+	// 认证配置项, 未配置时为 nil, 用于区分未配置与配置为空.
+	DBName   *string `yaml:"db_name" mapstructure:"db_name"`
+	UserName *string `yaml:"username" mapstructure:"username"`
+	Password *string `yaml:"password" mapstructure:"password"`
+	// PostgreSQL sslmode, 未设置时为 DefaultPostgresSSLMode.
+	SSLMode string `yaml:"ssl_mode" mapstructure:"ssl_mode"`
+	// MySQL 字符集及排序规则, 字符集未设置时为 utf8mb4, 排序规则未设置时不指定.
+	Charset   string `yaml:"charset" mapstructure:"charset"`
+	Collation string `yaml:"collation" mapstructure:"collation"`
+	// 附加到连接串的驱动参数, 与内置参数冲突时忽略, 按 Key 排序.
+	Params map[string]string `yaml:"params" mapstructure:"params"`
+	// 动态密码, 设置后替代 Password, 用于对接密钥管理服务.
+	PasswordProvider func(ctx context.Context) (string, error) `yaml:"-" mapstructure:"-"`
+	// 允许通过 RotateCredentials 在线轮换账号密码, 设置后连接方式同配置 PasswordProvider.
+	RotatableCredentials bool `yaml:"rotatable_credentials" mapstructure:"rotatable_credentials"`
 
 	// 超时配置项.
 	TimeoutInMills      uint `yaml:"timeout_in_mills" mapstructure:"timeout_in_mills"`
@@ -47,84 +173,412 @@ type Options struct {
 	// 连接池配置项.
 	MaxIdleConns uint `yaml:"max_idle_conns" mapstructure:"max_idle_conns"`
 	MaxOpenConns uint `yaml:"max_open_conns" mapstructure:"max_open_conns"`
+	// 连接最大存活及空闲时间, 0 保持 database/sql 默认值.
+	ConnMaxLifetimeInSecs uint `yaml:"conn_max_lifetime_in_secs" mapstructure:"conn_max_lifetime_in_secs"`
+	ConnMaxIdleTimeInSecs uint `yaml:"conn_max_idle_time_in_secs" mapstructure:"conn_max_idle_time_in_secs"`
+
+	// 从库权重, 用于 ReadPolicyWeighted, 0 按 1 处理.
+	ReadWeight uint `yaml:"read_weight" mapstructure:"read_weight"`
+
+	// GORM 配置项, 为 true 时覆盖 gorm.Config 对应配置.
+	PrepareStmt          bool `yaml:"prepare_stmt" mapstructure:"prepare_stmt"`
+	DryRun               bool `yaml:"dry_run" mapstructure:"dry_run"`
+	DisableAutomaticPing bool `yaml:"disable_automatic_ping" mapstructure:"disable_automatic_ping"`
+
+	// 语句执行前回调, 用于设置会话变量、注入 tracing 信息或修改语句.
+	//
+	// 在 OpenDB 中注册为各类语句的前置回调, 返回的 DB 设置了错误时中止执行.
+	// 执行 SQL 需使用 db.Statement.ConnPool, 事务外连接池可能在不同连接上执行,
+	// 连接级会话变量优先通过 DSN 设置. 读库配置的 QueryHookFn 不生效, 读库语句使用写库配置.
+	QueryHookFn func(db *gorm.DB) *gorm.DB `yaml:"-" mapstructure:"-"`
 }
 
-// OpenDBs 创建数据库连接列表.
-func (o MultiRWOptions) OpenDBs(dial Dialector, config *gorm.Config) (map[string]*gorm.DB, error) {
+// OpenDBs 按 Key 顺序创建数据库连接列表, 创建前校验配置, 见 Validate.
+//
+// 任一创建失败时关闭已创建的连接.
+func (o MultiRWOptions) OpenDBs(dial Dialector, config *gorm.Config, opts ...OpenOption) (map[string]*gorm.DB, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	openOpts := newOpenOptions(opts)
 	dbs := make(map[string]*gorm.DB)
-	for key, opt := range o {
+	for _, key := range o.Keys() {
+		opt := o[key]
 		if opt == nil {
 			continue
 		}
-		db, err := opt.OpenDB(dial, config)
+		keyOpts := opts
+		if openOpts.skipPing[key] {
+			keyOpts = append(opts[:len(opts):len(opts)], withoutStartupPing())
+		}
+		db, err := opt.OpenDB(dial, config, keyOpts...)
 		if err != nil {
-			return nil, err
+			_ = closeDBs(dbs)
+			return nil, fmt.Errorf("open db %s: %w", key, err)
 		}
 		dbs[key] = db
 	}
 	return dbs, nil
 }
 
+// Filter 返回 predicate 为 true 的配置子集, 不修改原配置.
+func (o MultiRWOptions) Filter(predicate func(key string, opt *RWOptions) bool) MultiRWOptions {
+	filtered := make(MultiRWOptions)
+	for key, opt := range o {
+		if predicate(key, opt) {
+			filtered[key] = opt
+		}
+	}
+	return filtered
+}
+
+// Keys 返回排序后的配置 Key.
+func (o MultiRWOptions) Keys() []string {
+	keys := make([]string, 0, len(o))
+	for key := range o {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Len 返回配置数.
+func (o MultiRWOptions) Len() int {
+	return len(o)
+}
+
+// DetectKeyCollisions 检测两层配置打平后的 Key 冲突.
+//
+// 打平 Key 为 外层 Key + separator + 内层 Key, 返回排序后的重复 Key.
+// 例如 "a.b" + "c" 与 "a" + "b.c" 在 separator 为 "." 时冲突.
+func DetectKeyCollisions(groups map[string]MultiRWOptions, separator string) []string {
+	counts := make(map[string]int)
+	for outer, opts := range groups {
+		for inner := range opts {
+			counts[outer+separator+inner]++
+		}
+	}
+	var keys []string
+	for key, n := range counts {
+		if n > 1 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // ToSource 转换配置为数据源.
-func (o MultiRWOptions) ToSource(dial Dialector, config *gorm.Config, router func(context.Context) string) (Source, error) {
+//
+// router 返回未配置的 Key 时, UseDB 等方法以 *UnknownDBKeyError panic 或返回,
+// 通过 WithFallbackKey 可路由到指定 Key.
+func (o MultiRWOptions) ToSource(dial Dialector, config *gorm.Config, router func(context.Context) string, opts ...RouteOption) (Source, error) {
+	dbs, err := o.OpenDBs(dial, config)
+	if err != nil {
+		return nil, err
+	}
+	router, err = fallbackRouter(dbs, router, opts)
+	if err != nil {
+		_ = closeDBs(dbs)
+		return nil, err
+	}
+	s := NewSourceWithFunc(router, RouteWithKey(dbs, router)).(*source)
+	s.dbs = dbs
+	s.dialector = commonDialector(dbs)
+	return s, nil
+}
+
+// ToSourceMap 转换配置为按 Key 区分的数据源.
+//
+// 每个 Key 对应独立的单库数据源, 库名为 Key, 用于分别创建 Provider 管理生命周期.
+// 需要区分外层配置时, 可通过 NewNamedSource 包装.
+func (o MultiRWOptions) ToSourceMap(dial Dialector, config *gorm.Config) (map[string]Source, error) {
 	dbs, err := o.OpenDBs(dial, config)
 	if err != nil {
 		return nil, err
 	}
-	return NewSourceWithFunc(router, RouteWithKey(dbs, router)), nil
+	sources := make(map[string]Source, len(dbs))
+	for key, db := range dbs {
+		sources[key] = NewSource(key, db)
+	}
+	return sources, nil
 }
 
 // OpenDB 创建数据库连接.
-func (o *RWOptions) OpenDB(dial Dialector, config *gorm.Config) (*gorm.DB, error) {
+func (o *RWOptions) OpenDB(dial Dialector, config *gorm.Config, opts ...OpenOption) (*gorm.DB, error) {
 	if o.Write == nil {
 		return nil, ErrWriteDBNotConfigured
 	}
-	db, err := o.Write.OpenDB(dial, config)
+	db, err := o.Write.OpenDB(dial, config, opts...)
 	if err != nil {
 		return nil, err
 	}
+	if err = o.useReplicas(db, dial, opts); err != nil {
+		_ = closeDBs(map[string]*gorm.DB{"": db})
+		return nil, err
+	}
+	return db, nil
+}
 
-	if o.Read == nil {
-		return db, nil
+// useReplicas 为 db 注册从库.
+func (o *RWOptions) useReplicas(db *gorm.DB, dial Dialector, opts []OpenOption) error {
+	reads, err := o.readOptions()
+	if err != nil {
+		return err
+	}
+	if len(reads) == 0 {
+		return nil
 	}
-	rd, err := o.Read.openDB(dial)
+	policy, err := o.ReadPolicy.policy(reads)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	replicas := make([]gorm.Dialector, 0, len(reads))
+	for _, read := range reads {
+		rd, err := read.openDB(dial, newOpenOptions(opts))
+		if err != nil {
+			return fmt.Errorf("read replica %s: %w", read.address(), err)
+		}
+		replicas = append(replicas, replicaDialector{Dialector: rd, opts: read, address: read.address(), ping: newOpenOptions(opts).ping})
 	}
 
-	if err = db.Use(dbresolver.Register(dbresolver.Config{
-		Replicas: []gorm.Dialector{rd},
-	})); err != nil {
-		return nil, err
+	// 连接池配置由 replicaDialector 按从库分别应用, dbresolver 的连接池配置同时作用于主库, 不使用.
+	resolver := dbresolver.Register(dbresolver.Config{
+		Replicas: replicas,
+		Policy:   policy,
+	})
+	return db.Use(resolver)
+}
+
+// replicaDialector 为从库连接错误附加从库地址, 并应用从库的连接池配置.
+//
+// 从库 DB 仅提供连接池, 语句仍使用主库方言.
+type replicaDialector struct {
+	gorm.Dialector
+	opts    *Options
+	address string
+	// 启动连通性检查, 为 nil 时按 gorm 配置检查.
+	ping *startupPing
+}
+
+func (d replicaDialector) Initialize(db *gorm.DB) error {
+	if err := d.Dialector.Initialize(db); err != nil {
+		return fmt.Errorf("read replica %s: %w", d.address, err)
 	}
-	return db, nil
+	if err := d.opts.applyPool(db); err != nil {
+		return fmt.Errorf("read replica %s: %w", d.address, err)
+	}
+	if d.ping != nil {
+		if pinger, ok := db.ConnPool.(interface {
+			PingContext(ctx context.Context) error
+		}); ok {
+			if err := d.ping.do(d.address, pinger); err != nil {
+				return fmt.Errorf("read replica: %w", err)
+			}
+			return nil
+		}
+	}
+	if db.DisableAutomaticPing {
+		return nil
+	}
+	if pinger, ok := db.ConnPool.(interface{ Ping() error }); ok {
+		if err := pinger.Ping(); err != nil {
+			return fmt.Errorf("read replica %s: %w", d.address, err)
+		}
+	}
+	return nil
 }
 
-func (o *Options) openDB(dial Dialector) (gorm.Dialector, error) {
-	dl, err := dial(o)
+func (o *Options) openDB(dial Dialector, openOpts *openOptions) (gorm.Dialector, error) {
+	if o.Host != nil && o.Socket != "" {
+		return nil, fmt.Errorf("%w: host %q, socket %q", ErrHostAndSocket, *o.Host, o.Socket)
+	}
+	c := o.WithDefaults()
+	resolved, err := c.resolvePassword(context.Background(), openOpts.passwordFunc)
+	if err != nil {
+		return nil, err
+	}
+	dl, err := dial(resolved)
 	if err != nil {
 		return nil, err
 	}
 	return dl, nil
 }
 
-// Open 创建数据库连接.
-func (o *Options) OpenDB(dial Dialector, config *gorm.Config) (*gorm.DB, error) {
-	if config == nil {
-		config = &gorm.Config{}
+// OpenDB 按 WithDefaults 填充默认值后创建数据库连接, 不修改 o.
+func (o *Options) OpenDB(dial Dialector, config *gorm.Config, opts ...OpenOption) (*gorm.DB, error) {
+	defaults := o.WithDefaults()
+	o = &defaults
+	openOpts := newOpenOptions(opts)
+	dl, err := o.openDB(dial, openOpts)
+	if err != nil {
+		return nil, err
 	}
-	dl, err := o.openDB(dial)
+	cfg := o.gormConfig(config)
+	// 启动检查代替 gorm.Open 的自动检查, 按退避重试; 跳过检查时不执行自动检查. 从库通过 dbresolver 共享该配置.
+	if openOpts.ping != nil || openOpts.noPing {
+		cfg.DisableAutomaticPing = true
+	}
+	db, err := gorm.Open(dl, cfg)
 	if err != nil {
 		return nil, err
 	}
-	return gorm.Open(dl, config)
+	if err = o.applyPool(db); err != nil {
+		return nil, err
+	}
+	if ping := openOpts.ping; ping != nil {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		if err = ping.do(o.address(), sqlDB); err != nil {
+			return nil, err
+		}
+	}
+	if err = useGlobalPlugins(db); err != nil {
+		return nil, err
+	}
+	if err = InstallPlugins(db); err != nil {
+		return nil, err
+	}
+	if o.LogicalName != "" {
+		if err = db.Use(NewNameTagPlugin(o.LogicalName)); err != nil {
+			return nil, err
+		}
+	}
+	if o.QueryHookFn != nil {
+		if err = registerBeforeStatement(db, queryHookName, o.queryHook); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// queryHookName QueryHookFn 回调名.
+const queryHookName = "mini_transaction:query_hook"
+
+// queryHook 执行 QueryHookFn, 将返回 DB 的错误记录到当前语句.
+func (o *Options) queryHook(db *gorm.DB) {
+	if r := o.QueryHookFn(db); r != nil && r != db && r.Error != nil {
+		_ = db.AddError(r.Error)
+	}
+}
+
+// applyPool 应用连接池配置, 未设置的配置项保持 database/sql 默认值.
+func (o *Options) applyPool(db *gorm.DB) error {
+	if o.MaxIdleConns == 0 && o.MaxOpenConns == 0 && o.ConnMaxLifetimeInSecs == 0 && o.ConnMaxIdleTimeInSecs == 0 {
+		return nil
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if o.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(int(o.MaxIdleConns))
+	}
+	if o.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(int(o.MaxOpenConns))
+	}
+	if o.ConnMaxLifetimeInSecs > 0 {
+		sqlDB.SetConnMaxLifetime(secs(o.ConnMaxLifetimeInSecs))
+	}
+	if o.ConnMaxIdleTimeInSecs > 0 {
+		sqlDB.SetConnMaxIdleTime(secs(o.ConnMaxIdleTimeInSecs))
+	}
+	return nil
+}
+
+func secs(n uint) time.Duration {
+	return time.Duration(n) * time.Second
+}
+
+// gormConfig 合并配置项到 gorm.Config.
+//
+// gorm.Open 会修改传入的配置, 每次创建连接使用独立副本.
+func (o *Options) gormConfig(config *gorm.Config) *gorm.Config {
+	cfg := gorm.Config{}
+	if config != nil {
+		cfg = *config
+	}
+	cfg.PrepareStmt = cfg.PrepareStmt || o.PrepareStmt
+	cfg.DryRun = cfg.DryRun || o.DryRun
+	cfg.DisableAutomaticPing = cfg.DisableAutomaticPing || o.DisableAutomaticPing
+	return &cfg
+}
+
+// paramKeys 返回排除 builtin 后按字典序排列的 Params Key.
+func (o *Options) paramKeys(builtin map[string]bool) []string {
+	keys := make([]string, 0, len(o.Params))
+	for key := range o.Params {
+		if !builtin[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// dialect 返回配置的数据库方言, 未配置时为 DialectMySQL.
+func (o *Options) dialect() string {
+	if o.Dialect == "" {
+		return DialectMySQL
+	}
+	return o.Dialect
+}
+
+func (o *Options) address() string {
+	if o.Socket != "" {
+		return o.Socket
+	}
+	return fmt.Sprintf("%s:%d", deref(o.Host), deref(o.Port))
 }
 
 func (o *Options) fullName() string {
 	if o == nil {
 		return ""
 	}
-	return fmt.Sprintf("%s:%d/%s", o.Host, o.Port, o.DBName)
+	return fmt.Sprintf("%s/%s", o.address(), deref(o.DBName))
+}
+
+// WithDefaults 返回填充默认值的配置副本, OpenDB 创建连接前调用.
+//
+// 未配置 Host 及 Socket 时 Host 为 DefaultHost, 未配置 Port 时 MySQL 为 DefaultPort, PostgreSQL 为 DefaultPostgresPort,
+// SQLite 不设置地址. 未配置 Password 时为空.
+// DBName 及 UserName 无默认值, 未配置时保持 nil, 由 Validate 报告.
+func (o *Options) WithDefaults() Options {
+	c := *o
+	if c.Password == nil {
+		c.Password = Ptr("")
+	}
+	dialect := c.dialect()
+	if dialect == DialectSQLite {
+		return c
+	}
+	if c.Host == nil && c.Socket == "" {
+		c.Host = Ptr(DefaultHost)
+	}
+	if c.Port == nil {
+		port := DefaultPort
+		if dialect == DialectPostgres {
+			port = DefaultPostgresPort
+		}
+		c.Port = &port
+	}
+	return c
+}
+
+// Ptr 返回 v 的指针, 用于在代码中设置指针类型配置项.
+func Ptr[T any](v T) *T {
+	return &v
+}
+
+// deref 返回 p 指向的值, p 为 nil 时返回零值.
+func deref[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
 }
 
 // ToSource 转换配置为数据源.
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestQueryHookFn(t *testing.T) {
+	sqlDB, fake := newFakeDB(nil)
+	// 模拟会话变量: 返回当前连接上最近一次 SET time_zone 的值.
+	fake.query = func(_ context.Context, conn int, query string, _ []driver.NamedValue) (interface{}, bool, error) {
+		if query != "SELECT @@session.time_zone" {
+			return nil, false, nil
+		}
+		fake.mut.Lock()
+		defer fake.mut.Unlock()
+		zone := "SYSTEM"
+		for _, stmt := range fake.log {
+			if stmt.conn == conn && strings.HasPrefix(stmt.query, "SET time_zone = ") {
+				zone = strings.Trim(strings.TrimPrefix(stmt.query, "SET time_zone = "), "'")
+			}
+		}
+		return zone, true, nil
+	}
+	dial := func(*Options) (gorm.Dialector, error) {
+		return mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), nil
+	}
+	opts := &Options{
+		Host: Ptr("db.internal"), Port: Ptr(3306), UserName: Ptr("app"), DBName: Ptr("app"),
+		MaxOpenConns: 1,
+		QueryHookFn: func(db *gorm.DB) *gorm.DB {
+			if _, err := db.Statement.ConnPool.ExecContext(db.Statement.Context, "SET time_zone = '+00:00'"); err != nil {
+				_ = db.AddError(err)
+			}
+			return db
+		},
+	}
+	db, err := opts.OpenDB(dial, &gorm.Config{Logger: logger.Discard, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var zone string
+	if err := db.Raw("SELECT @@session.time_zone").Scan(&zone).Error; err != nil {
+		t.Fatal(err)
+	}
+	if zone != "+00:00" {
+		t.Errorf("time_zone = %q, want +00:00", zone)
+	}
+}
+
+func TestQueryHookFnError(t *testing.T) {
+	errDenied := errors.New("denied")
+	deny := false
+	opts := &Options{
+		DBName: Ptr(filepath.Join(t.TempDir(), "test.db")),
+		QueryHookFn: func(db *gorm.DB) *gorm.DB {
+			if !deny {
+				return db
+			}
+			r := db.Session(&gorm.Session{NewDB: true})
+			_ = r.AddError(errDenied)
+			return r
+		},
+	}
+	db, err := opts.OpenDB(SQLiteDialector(), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(new(tenantItem)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 回调返回的错误中止语句执行.
+	deny = true
+	if err := db.Create(&tenantItem{Name: "a"}).Error; !errors.Is(err, errDenied) {
+		t.Errorf("create err = %v, want %v", err, errDenied)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := sqlDB.QueryRow("SELECT COUNT(*) FROM tenant_items").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("%d rows inserted, want 0", count)
+	}
+}
+
+func TestMultiRWOptionsFilter(t *testing.T) {
+	opts := MultiRWOptions{
+		"order_1": {},
+		"order_2": {},
+		"user_1":  {},
+		"user_2":  {},
+		"user_3":  {},
+	}
+	filtered := opts.Filter(func(key string, _ *RWOptions) bool {
+		return strings.HasPrefix(key, "order_")
+	})
+	if filtered.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", filtered.Len())
+	}
+	if keys := filtered.Keys(); keys[0] != "order_1" || keys[1] != "order_2" {
+		t.Errorf("Keys = %v, want [order_1 order_2]", keys)
+	}
+	if filtered["order_1"] != opts["order_1"] {
+		t.Error("filtered entry is not the original options")
+	}
+	// 原配置不变.
+	if opts.Len() != 5 {
+		t.Errorf("original Len = %d, want 5", opts.Len())
+	}
+}
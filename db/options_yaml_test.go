@@ -0,0 +1,76 @@
+package db
+
+import (
+	"gopkg.in/yaml.v3"
+	"testing"
+)
+
+func TestRWOptionsYAMLSingleRead(t *testing.T) {
+	var o RWOptions
+	err := yaml.Unmarshal([]byte(`
+write:
+  host: w
+read:
+  host: r
+  max_open_conns: 3
+`), &o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Write == nil || *o.Write.Host != "w" {
+		t.Fatalf("write = %+v", o.Write)
+	}
+	if o.Read == nil || *o.Read.Host != "r" || len(o.Reads) != 0 {
+		t.Fatalf("read = %+v, reads = %+v", o.Read, o.Reads)
+	}
+
+	out, err := yaml.Marshal(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back RWOptions
+	if err := yaml.Unmarshal(out, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Read == nil || *back.Read.Host != "r" || len(back.Reads) != 0 {
+		t.Fatalf("round trip = %s", out)
+	}
+}
+
+func TestRWOptionsYAMLReadList(t *testing.T) {
+	var o RWOptions
+	err := yaml.Unmarshal([]byte(`
+write:
+  host: w
+read:
+  - host: r1
+    max_open_conns: 3
+  - host: r2
+    max_open_conns: 5
+read_policy: round_robin
+`), &o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Read != nil || len(o.Reads) != 2 {
+		t.Fatalf("read = %+v, reads = %+v", o.Read, o.Reads)
+	}
+	if *o.Reads[0].Host != "r1" || *o.Reads[1].Host != "r2" {
+		t.Fatalf("reads = %s, %s", *o.Reads[0].Host, *o.Reads[1].Host)
+	}
+	if o.ReadPolicy != ReadPolicyRoundRobin {
+		t.Fatalf("read_policy = %q", o.ReadPolicy)
+	}
+
+	out, err := yaml.Marshal(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back RWOptions
+	if err := yaml.Unmarshal(out, &back); err != nil {
+		t.Fatal(err)
+	}
+	if len(back.Reads) != 2 || *back.Reads[1].Host != "r2" {
+		t.Fatalf("round trip = %s", out)
+	}
+}
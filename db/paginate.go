@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"reflect"
+)
+
+var (
+	ErrInvalidCursor          = errors.New("invalid cursor")
+	ErrCursorUnsupportedModel = errors.New("cursor pagination requires a single integer primary key")
+)
+
+// DefaultPageSize 未设置 PageSize 时的每页条数.
+const DefaultPageSize = 100
+
+// Cursor 代表分页游标, 记录上一页最后一条记录的主键.
+type Cursor struct {
+	// 上一页最后一条记录的主键.
+	LastID int64 `json:"last_id"`
+	// 每页条数, 不大于 0 时为 DefaultPageSize.
+	PageSize int `json:"page_size"`
+}
+
+// Encode 编码游标为 URL 安全的字符串, 用于返回给调用方.
+func (c *Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor 解码 Encode 生成的游标, 格式错误时返回 ErrInvalidCursor.
+func DecodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	c := &Cursor{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return c, nil
+}
+
+func (c *Cursor) pageSize() int {
+	if c == nil || c.PageSize <= 0 {
+		return DefaultPageSize
+	}
+	return c.PageSize
+}
+
+// CursorPaginate 按主键分页读取, 返回当前页及下一页游标, 已读取到末页时下一页游标为 nil.
+//
+// cursor 为 nil 时读取首页. 使用 WHERE pk > LastID ORDER BY pk LIMIT 分页, 分页期间插入的记录
+// 不会导致重复或遗漏. 仅支持单个整数主键, 否则返回 ErrCursorUnsupportedModel.
+//
+// 通过 UseDB 获取 DB, 在事务上下文内读取结果与事务快照一致, 事务外可能读取从库.
+// query 用于添加过滤条件, 不可设置排序及条数.
+func CursorPaginate[T any](
+	ctx context.Context,
+	p Provider,
+	cursor *Cursor,
+	query func(*gorm.DB) *gorm.DB,
+) ([]T, *Cursor, error) {
+	db := p.UseDB(ctx)
+	pk, err := cursorField(db, new(T))
+	if err != nil {
+		return nil, nil, err
+	}
+	column := clause.Column{Table: clause.CurrentTable, Name: pk.DBName}
+	if query != nil {
+		db = query(db)
+	}
+	if cursor != nil {
+		db = db.Where(clause.Gt{Column: column, Value: cursor.LastID})
+	}
+	size := cursor.pageSize()
+	var items []T
+	// 多读取一条判断是否有下一页.
+	err = db.Order(clause.OrderByColumn{Column: column}).Limit(size + 1).Find(&items).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(items) <= size {
+		return items, nil, nil
+	}
+	items = items[:size]
+	last, _ := pk.ValueOf(ctx, reflect.ValueOf(&items[size-1]).Elem())
+	next := &Cursor{LastID: toInt64(reflect.ValueOf(last)), PageSize: size}
+	return items, next, nil
+}
+
+// cursorField 返回模型的整数主键字段.
+func cursorField(db *gorm.DB, model interface{}) (*schema.Field, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	if len(stmt.Schema.PrimaryFields) != 1 {
+		return nil, ErrCursorUnsupportedModel
+	}
+	field := stmt.Schema.PrimaryFields[0]
+	switch field.FieldType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return field, nil
+	}
+	return nil, ErrCursorUnsupportedModel
+}
+
+func toInt64(v reflect.Value) int64 {
+	if v.CanInt() {
+		return v.Int()
+	}
+	return int64(v.Uint())
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"testing"
+)
+
+// paginateAll 分页读取全部记录, 返回读取的 ID 及每页条数.
+func paginateAll(t *testing.T, ctx context.Context, p Provider, cursor *Cursor, query func(*gorm.DB) *gorm.DB) ([]int64, []int) {
+	t.Helper()
+	var ids []int64
+	var pages []int
+	for {
+		items, next, err := CursorPaginate[tenantItem](ctx, p, cursor, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, len(items))
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		if next == nil {
+			return ids, pages
+		}
+		// 游标经编码返回调用方.
+		if cursor, err = DecodeCursor(next.Encode()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCursorPaginate(t *testing.T) {
+	p := newSQLiteProvider(t, new(tenantItem))
+	ctx := context.Background()
+	items := make([]tenantItem, 100)
+	for i := range items {
+		items[i].Name = "item"
+	}
+	if err := p.UseWriteDB(ctx).Create(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name   string
+		cursor *Cursor
+		pages  []int
+	}{
+		{"default", nil, []int{100}},
+		{"size 30", &Cursor{PageSize: 30}, []int{30, 30, 30, 10}},
+		{"size 50", &Cursor{PageSize: 50}, []int{50, 50}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			ids, pages := paginateAll(t, ctx, p, c.cursor, nil)
+			if len(pages) != len(c.pages) {
+				t.Fatalf("pages = %v, want %v", pages, c.pages)
+			}
+			for i := range pages {
+				if pages[i] != c.pages[i] {
+					t.Fatalf("pages = %v, want %v", pages, c.pages)
+				}
+			}
+			if len(ids) != 100 {
+				t.Fatalf("read %d rows, want 100", len(ids))
+			}
+			for i, id := range ids {
+				if id != int64(i+1) {
+					t.Fatalf("ids[%d] = %d, want %d", i, id, i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestCursorPaginateInTransaction(t *testing.T) {
+	p := newSQLiteProvider(t, new(tenantItem))
+	ctx := context.Background()
+	items := make([]tenantItem, 100)
+	for i := range items {
+		items[i].Name = "item"
+	}
+	if err := p.UseWriteDB(ctx).Create(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		// 事务内读取包含未提交的写入.
+		if err := p.UseDB(ctx).Create(&tenantItem{Name: "tx"}).Error; err != nil {
+			return err
+		}
+		ids, _ := paginateAll(t, ctx, p, &Cursor{PageSize: 30}, func(db *gorm.DB) *gorm.DB {
+			return db.Where("name <> ?", "skip")
+		})
+		if len(ids) != 101 {
+			t.Errorf("read %d rows, want 101", len(ids))
+		}
+		seen := make(map[int64]bool)
+		for _, id := range ids {
+			if seen[id] {
+				t.Errorf("duplicate id %d", id)
+			}
+			seen[id] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
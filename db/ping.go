@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// OpenOption 定义创建数据库连接选项.
+type OpenOption func(*openOptions)
+
+type openOptions struct {
+	ping *startupPing
+	// 跳过启动检查的 Key.
+	skipPing map[string]bool
+	// 跳过连通性检查, 包括 gorm 的自动检查.
+	noPing bool
+	// 创建连接时获取密码.
+	passwordFunc PasswordFunc
+}
+
+func newOpenOptions(opts []OpenOption) *openOptions {
+	o := &openOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// startupPing 定义启动连通性检查.
+type startupPing struct {
+	timeout  time.Duration
+	attempts int
+	backoff  time.Duration
+}
+
+// WithStartupPing 创建连接后执行连通性检查, 主库及从库均检查.
+//
+// 每次检查超时为 timeout, 最多尝试 attempts 次, 第 n 次失败后等待 backoff * 2^(n-1).
+// 全部失败时创建失败, 错误包含库地址, OpenDBs 中另包含配置 Key.
+func WithStartupPing(timeout time.Duration, attempts int, backoff time.Duration) OpenOption {
+	if attempts < 1 {
+		attempts = 1
+	}
+	return func(o *openOptions) {
+		o.ping = &startupPing{timeout: timeout, attempts: attempts, backoff: backoff}
+	}
+}
+
+// SkipStartupPing OpenDBs 中跳过指定 Key 的连通性检查, 用于延后创建的数据库.
+//
+// 同时跳过 gorm 的自动检查, 数据库不可达时创建成功, 首次执行语句时返回错误.
+func SkipStartupPing(keys ...string) OpenOption {
+	return func(o *openOptions) {
+		if o.skipPing == nil {
+			o.skipPing = make(map[string]bool)
+		}
+		for _, key := range keys {
+			o.skipPing[key] = true
+		}
+	}
+}
+
+// withoutStartupPing 关闭连通性检查.
+func withoutStartupPing() OpenOption {
+	return func(o *openOptions) {
+		o.ping = nil
+		o.noPing = true
+	}
+}
+
+// do 执行连通性检查, 失败时按退避重试.
+func (p *startupPing) do(address string, pinger interface {
+	PingContext(ctx context.Context) error
+}) error {
+	var err error
+	backoff := p.backoff
+	for i := 0; i < p.attempts; i++ {
+		if i > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		if err = p.ping(pinger); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("ping %s after %d attempts: %w", address, p.attempts, err)
+}
+
+func (p *startupPing) ping(pinger interface {
+	PingContext(ctx context.Context) error
+}) error {
+	ctx := context.Background()
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+	return pinger.PingContext(ctx)
+}
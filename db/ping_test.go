@@ -0,0 +1,63 @@
+package db
+
+import (
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStartupPingRetriesUnreachableDB(t *testing.T) {
+	sqlDB, fake := newFakeDB(nil)
+	fake.pingFailures = 2
+	dial := func(*Options) (gorm.Dialector, error) {
+		return mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), nil
+	}
+	opts := &Options{Host: Ptr("db.internal"), Port: Ptr(3306), UserName: Ptr("app"), DBName: Ptr("app")}
+
+	if _, err := opts.OpenDB(dial, &gorm.Config{Logger: logger.Discard}, WithStartupPing(time.Second, 3, time.Millisecond)); err != nil {
+		t.Fatalf("OpenDB with retries = %v", err)
+	}
+
+	fake.pingFailures = 2
+	if _, err := opts.OpenDB(dial, &gorm.Config{Logger: logger.Discard}, WithStartupPing(time.Second, 2, time.Millisecond)); err == nil {
+		t.Fatal("OpenDB succeeded after exhausting attempts")
+	}
+}
+
+func TestStartupPingOpenDBs(t *testing.T) {
+	fakes := make(map[string]*fakeConnector)
+	dial := func(o *Options) (gorm.Dialector, error) {
+		sqlDB, fake := newFakeDB(nil)
+		fakes[*o.Host] = fake
+		// 未创建的库始终不可达.
+		if *o.Host == "tenant-b.internal" {
+			fake.pingFailures = 1 << 30
+		}
+		return mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), nil
+	}
+	options := func(host string) *RWOptions {
+		return &RWOptions{Write: &Options{Host: Ptr(host), Port: Ptr(3306), UserName: Ptr("app"), DBName: Ptr("app")}}
+	}
+	opts := MultiRWOptions{"a": options("tenant-a.internal"), "b": options("tenant-b.internal")}
+	ping := WithStartupPing(time.Second, 2, time.Millisecond)
+
+	_, err := opts.OpenDBs(dial, &gorm.Config{Logger: logger.Discard}, ping)
+	if err == nil {
+		t.Fatal("OpenDBs succeeded with unreachable db")
+	}
+	if msg := err.Error(); !strings.Contains(msg, "open db b") || !strings.Contains(msg, "tenant-b.internal:3306") {
+		t.Errorf("error %q does not name key and host", msg)
+	}
+
+	// 跳过的 Key 不检查连通性.
+	dbs, err := opts.OpenDBs(dial, &gorm.Config{Logger: logger.Discard}, ping, SkipStartupPing("b"))
+	if err != nil {
+		t.Fatalf("OpenDBs with skipped key = %v", err)
+	}
+	if len(dbs) != 2 {
+		t.Errorf("opened %d dbs, want 2", len(dbs))
+	}
+}
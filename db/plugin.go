@@ -0,0 +1,136 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"sync"
+)
+
+var (
+	ErrPluginNotInstalled = errors.New("plugin not installed")
+)
+
+// installMut 串行执行插件注册.
+var installMut sync.Mutex
+
+// failedPlugin 记录注册失败的插件, 存入 DB 的 Config.Plugins, 避免重复注册.
+//
+// 注册状态随 DB 保存, 同一 DB 派生的 Session 共享, DB 释放后一并回收.
+type failedPlugin struct {
+	name string
+	err  error
+}
+
+func (p failedPlugin) Name() string {
+	return p.name
+}
+
+func (p failedPlugin) Initialize(*gorm.DB) error {
+	return p.err
+}
+
+// InstallPlugins 为 DB 注册内置插件, 包括事务配额, 语句计数, 语句统计, 行锁等待超时, schema 路由及操作超时.
+//
+// 插件仅在对应选项或 context 启用时生效. 通过 Options 创建的 DB 及传入 NewSource, NewWriteReadSource,
+// NewDynamicSource, AddWriteDB 的 DB 自动注册; NewSourceWithFunc 等工厂函数返回的其他 DB 需在使用前调用,
+// 否则启用插件的语句返回 ErrPluginNotInstalled.
+//
+// 注册修改 DB 的回调, 不可与该 DB 的语句并发执行.
+func InstallPlugins(db *gorm.DB) error {
+	for _, plugin := range []gorm.Plugin{
+		quotaPlugin{},
+		statementLimitPlugin{},
+		statsPlugin{},
+		rowLockTimeoutPlugin,
+		schemaRoutingPlugin,
+		operationTimeoutPlugin{},
+	} {
+		if err := usePlugin(db, plugin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// installSourcePlugins 为数据源持有的 DB 注册内置插件.
+//
+// 数据源构造函数不返回错误, 注册失败时启用插件的语句返回注册错误.
+func installSourcePlugins(dbs map[string]*gorm.DB) {
+	for _, db := range dbs {
+		if db != nil {
+			_ = InstallPlugins(db)
+		}
+	}
+}
+
+// usePlugin 为 DB 注册插件, 每个 DB 仅注册一次.
+func usePlugin(db *gorm.DB, plugin gorm.Plugin) error {
+	installMut.Lock()
+	defer installMut.Unlock()
+	if registered, ok := db.Config.Plugins[plugin.Name()]; ok {
+		if failed, ok := registered.(failedPlugin); ok {
+			return failed.err
+		}
+		return nil
+	}
+	err := db.Use(plugin)
+	if err != nil {
+		db.Config.Plugins[plugin.Name()] = failedPlugin{name: plugin.Name(), err: err}
+	}
+	return err
+}
+
+// checkPlugin 检查 DB 已注册插件, 不修改 DB, 可与语句并发执行.
+func checkPlugin(db *gorm.DB, name string) error {
+	registered, ok := db.Config.Plugins[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrPluginNotInstalled, name)
+	}
+	if failed, ok := registered.(failedPlugin); ok {
+		return failed.err
+	}
+	return nil
+}
+
+// registerBeforeStatement 在各类语句执行前注册回调.
+func registerBeforeStatement(db *gorm.DB, name string, fn func(*gorm.DB)) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:create").Register(name, fn); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register(name, fn); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register(name, fn); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register(name, fn); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register(name, fn); err != nil {
+		return err
+	}
+	return cb.Raw().Before("gorm:raw").Register(name, fn)
+}
+
+// registerAfterStatement 在各类语句执行后注册回调.
+func registerAfterStatement(db *gorm.DB, name string, fn func(*gorm.DB)) error {
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:create").Register(name, fn); err != nil {
+		return err
+	}
+	if err := cb.Query().After("gorm:query").Register(name, fn); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register(name, fn); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:delete").Register(name, fn); err != nil {
+		return err
+	}
+	if err := cb.Row().After("gorm:row").Register(name, fn); err != nil {
+		return err
+	}
+	return cb.Raw().After("gorm:raw").Register(name, fn)
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"testing"
+)
+
+// failingPlugin 初始化失败并记录调用次数.
+type failingPlugin struct {
+	calls *int
+	err   error
+}
+
+func (p failingPlugin) Name() string {
+	return "failing"
+}
+
+func (p failingPlugin) Initialize(*gorm.DB) error {
+	*p.calls++
+	return p.err
+}
+
+func TestUsePlugin(t *testing.T) {
+	sqlDB, _ := newFakeDB(nil)
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkPlugin(db, quotaPluginName); !errors.Is(err, ErrPluginNotInstalled) {
+		t.Fatalf("check before install err = %v, want %v", err, ErrPluginNotInstalled)
+	}
+	if err := InstallPlugins(db); err != nil {
+		t.Fatal(err)
+	}
+	// Session 共享注册状态.
+	if err := checkPlugin(db.WithContext(context.Background()), quotaPluginName); err != nil {
+		t.Errorf("check after install err = %v", err)
+	}
+	if err := InstallPlugins(db); err != nil {
+		t.Errorf("second install err = %v", err)
+	}
+
+	// 注册失败记录在 DB 上, 不重复注册.
+	var calls int
+	errInit := errors.New("init failed")
+	for i := 0; i < 2; i++ {
+		if err := usePlugin(db, failingPlugin{calls: &calls, err: errInit}); !errors.Is(err, errInit) {
+			t.Errorf("usePlugin err = %v, want %v", err, errInit)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Initialize called %d times, want 1", calls)
+	}
+	if err := checkPlugin(db, "failing"); !errors.Is(err, errInit) {
+		t.Errorf("check failed plugin err = %v, want %v", err, errInit)
+	}
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+	"math/rand"
+	"sync/atomic"
+)
+
+// ReadPolicy 定义从库负载均衡策略.
+type ReadPolicy string
+
+const (
+	// ReadPolicyRandom 随机选择从库, 默认策略.
+	ReadPolicyRandom ReadPolicy = "random"
+	// ReadPolicyRoundRobin 轮询选择从库.
+	ReadPolicyRoundRobin ReadPolicy = "round_robin"
+	// ReadPolicyWeighted 按从库 ReadWeight 加权随机选择.
+	ReadPolicyWeighted ReadPolicy = "weighted"
+)
+
+// RoundRobinPolicy 轮询选择连接池.
+type RoundRobinPolicy struct {
+	next uint64
+}
+
+var _ dbresolver.Policy = new(RoundRobinPolicy)
+
+func (p *RoundRobinPolicy) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	n := atomic.AddUint64(&p.next, 1) - 1
+	return connPools[n%uint64(len(connPools))]
+}
+
+// WeightedPolicy 按权重随机选择连接池.
+//
+// 权重与连接池按顺序对应, 数量不一致时随机选择.
+type WeightedPolicy struct {
+	weights []uint
+	total   uint
+}
+
+var _ dbresolver.Policy = new(WeightedPolicy)
+
+// NewWeightedPolicy 创建加权策略, 权重为 0 时按 1 处理.
+func NewWeightedPolicy(weights []uint) *WeightedPolicy {
+	p := &WeightedPolicy{weights: make([]uint, len(weights))}
+	for i, w := range weights {
+		if w == 0 {
+			w = 1
+		}
+		p.weights[i] = w
+		p.total += w
+	}
+	return p
+}
+
+func (p *WeightedPolicy) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	if len(connPools) != len(p.weights) {
+		return connPools[rand.Intn(len(connPools))]
+	}
+	n := uint(rand.Int63n(int64(p.total)))
+	for i, w := range p.weights {
+		if n < w {
+			return connPools[i]
+		}
+		n -= w
+	}
+	return connPools[len(connPools)-1]
+}
+
+// policy 转换为 dbresolver.Policy, reads 为合并后的从库配置.
+func (p ReadPolicy) policy(reads []*Options) (dbresolver.Policy, error) {
+	switch p {
+	case "", ReadPolicyRandom:
+		return dbresolver.RandomPolicy{}, nil
+	case ReadPolicyRoundRobin:
+		return new(RoundRobinPolicy), nil
+	case ReadPolicyWeighted:
+		weights := make([]uint, len(reads))
+		for i, read := range reads {
+			weights[i] = read.ReadWeight
+		}
+		return NewWeightedPolicy(weights), nil
+	default:
+		return nil, fmt.Errorf("unknown read policy %q", p)
+	}
+}
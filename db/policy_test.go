@@ -0,0 +1,67 @@
+package db
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// resolveCounts 执行 n 次选择, 返回各连接池被选中的次数.
+func resolveCounts(policy dbresolver.Policy, pools int, n int) []int {
+	connPools := make([]gorm.ConnPool, pools)
+	index := make(map[gorm.ConnPool]int, pools)
+	for i := range connPools {
+		sqlDB, _ := newFakeDB(nil)
+		connPools[i] = sqlDB
+		index[sqlDB] = i
+	}
+	counts := make([]int, pools)
+	for i := 0; i < n; i++ {
+		counts[index[policy.Resolve(connPools)]]++
+	}
+	return counts
+}
+
+func TestRoundRobinPolicy(t *testing.T) {
+	counts := resolveCounts(new(RoundRobinPolicy), 3, 300)
+	for i, c := range counts {
+		if c != 100 {
+			t.Errorf("pool %d selected %d times, want 100", i, c)
+		}
+	}
+}
+
+func TestWeightedPolicy(t *testing.T) {
+	const n = 60000
+	// 权重 0 按 1 处理.
+	weights := []uint{0, 2, 3}
+	counts := resolveCounts(NewWeightedPolicy(weights), 3, n)
+	for i, want := range []float64{1.0 / 6, 2.0 / 6, 3.0 / 6} {
+		if got := float64(counts[i]) / n; math.Abs(got-want) > 0.02 {
+			t.Errorf("pool %d share = %.3f, want %.3f", i, got, want)
+		}
+	}
+}
+
+func TestReadPolicy(t *testing.T) {
+	reads := []*Options{{ReadWeight: 1}, {ReadWeight: 3}}
+	for policy, want := range map[ReadPolicy]dbresolver.Policy{
+		"":                   dbresolver.RandomPolicy{},
+		ReadPolicyRandom:     dbresolver.RandomPolicy{},
+		ReadPolicyRoundRobin: new(RoundRobinPolicy),
+		ReadPolicyWeighted:   NewWeightedPolicy([]uint{1, 3}),
+	} {
+		got, err := policy.policy(reads)
+		if err != nil {
+			t.Fatalf("%q: %v", policy, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: policy = %#v, want %#v", policy, got, want)
+		}
+	}
+	if _, err := ReadPolicy("least_conn").policy(reads); err == nil {
+		t.Error("unknown policy accepted")
+	}
+}
@@ -0,0 +1,179 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// replicaPools 返回 dbresolver 注册的从库连接池, 按注册顺序.
+func replicaPools(t *testing.T, db *gorm.DB) []*sql.DB {
+	t.Helper()
+	primary, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver, ok := db.Config.Plugins[(&dbresolver.DBResolver{}).Name()].(*dbresolver.DBResolver)
+	if !ok {
+		t.Fatal("dbresolver not registered")
+	}
+	var pools []*sql.DB
+	_ = resolver.Call(func(pool gorm.ConnPool) error {
+		if sqlDB, ok := pool.(*sql.DB); ok && sqlDB != primary {
+			pools = append(pools, sqlDB)
+		}
+		return nil
+	})
+	return pools
+}
+
+func TestRWOptionsPoolSettings(t *testing.T) {
+	dir := t.TempDir()
+	opts := &RWOptions{
+		Write: &Options{DBName: Ptr(filepath.Join(dir, "write.db"))},
+		Reads: []*Options{
+			{DBName: Ptr(filepath.Join(dir, "read1.db")), MaxOpenConns: 3},
+			{DBName: Ptr(filepath.Join(dir, "read2.db")), MaxOpenConns: 5},
+		},
+	}
+	db, err := opts.OpenDB(SQLiteDialector(), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDB(db, make(map[interface{}]bool))
+
+	primary, _ := db.DB()
+	if got := primary.Stats().MaxOpenConnections; got != 0 {
+		t.Errorf("write MaxOpenConnections = %d, want unlimited", got)
+	}
+	pools := replicaPools(t, db)
+	if len(pools) != 2 {
+		t.Fatalf("replica pools = %d, want 2", len(pools))
+	}
+	for i, want := range []int{3, 5} {
+		if got := pools[i].Stats().MaxOpenConnections; got != want {
+			t.Errorf("replica %d MaxOpenConnections = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestOptionsPoolSettings(t *testing.T) {
+	opts := &Options{DBName: Ptr(SQLiteMemory), MaxOpenConns: 4}
+	db, err := opts.OpenDB(SQLiteDialector(), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	defer sqlDB.Close()
+	if got := sqlDB.Stats().MaxOpenConnections; got != 4 {
+		t.Errorf("MaxOpenConnections = %d, want 4", got)
+	}
+}
+
+// poolLimits 返回连接池的最大连接时长及最大空闲时长.
+func poolLimits(sqlDB *sql.DB) (lifetime, idleTime time.Duration) {
+	v := reflect.ValueOf(sqlDB).Elem()
+	return time.Duration(v.FieldByName("maxLifetime").Int()), time.Duration(v.FieldByName("maxIdleTime").Int())
+}
+
+func TestOptionsConnMaxLifetime(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		lifetime     uint
+		idleTime     uint
+		wantLifetime time.Duration
+		wantIdleTime time.Duration
+	}{
+		{name: "default"},
+		{name: "lifetime", lifetime: 300, wantLifetime: 300 * time.Second},
+		{name: "idle time", idleTime: 60, wantIdleTime: time.Minute},
+		{name: "both", lifetime: 300, idleTime: 60, wantLifetime: 300 * time.Second, wantIdleTime: time.Minute},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			pool := func(name string) *Options {
+				return &Options{
+					DBName:                Ptr(filepath.Join(dir, name)),
+					ConnMaxLifetimeInSecs: tt.lifetime,
+					ConnMaxIdleTimeInSecs: tt.idleTime,
+				}
+			}
+			opts := &RWOptions{Write: pool("write.db"), Reads: []*Options{pool("read.db")}}
+			db, err := opts.OpenDB(SQLiteDialector(), &gorm.Config{Logger: logger.Discard})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer closeDB(db, make(map[interface{}]bool))
+
+			primary, _ := db.DB()
+			pools := append([]*sql.DB{primary}, replicaPools(t, db)...)
+			if len(pools) != 2 {
+				t.Fatalf("pools = %d, want 2", len(pools))
+			}
+			for i, sqlDB := range pools {
+				lifetime, idleTime := poolLimits(sqlDB)
+				if lifetime != tt.wantLifetime || idleTime != tt.wantIdleTime {
+					t.Errorf("pool %d lifetime = %v, idle time = %v, want %v, %v", i, lifetime, idleTime, tt.wantLifetime, tt.wantIdleTime)
+				}
+			}
+		})
+	}
+}
+
+func TestRWOptionsMergeRead(t *testing.T) {
+	dir := t.TempDir()
+	opts := &RWOptions{
+		Write: &Options{DBName: Ptr(filepath.Join(dir, "write.db"))},
+		Read:  &Options{DBName: Ptr(filepath.Join(dir, "read0.db")), MaxOpenConns: 2},
+		Reads: []*Options{{DBName: Ptr(filepath.Join(dir, "read1.db")), MaxOpenConns: 3}},
+	}
+	db, err := opts.OpenDB(SQLiteDialector(), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDB(db, make(map[interface{}]bool))
+
+	// Read 在前, 与 Reads 合并注册.
+	pools := replicaPools(t, db)
+	if len(pools) != 2 {
+		t.Fatalf("replica pools = %d, want 2", len(pools))
+	}
+	for i, want := range []int{2, 3} {
+		if got := pools[i].Stats().MaxOpenConnections; got != want {
+			t.Errorf("replica %d MaxOpenConnections = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestRWOptionsReplicaErrors(t *testing.T) {
+	dir := t.TempDir()
+	errDial := errors.New("dial failed")
+	dial := func(o *Options) (gorm.Dialector, error) {
+		if deref(o.Host) == "r2" {
+			return nil, errDial
+		}
+		return SQLiteDialector()(o)
+	}
+	write := &Options{DBName: Ptr(filepath.Join(dir, "write.db"))}
+
+	opts := &RWOptions{Write: write, Reads: []*Options{nil}}
+	if _, err := opts.OpenDB(dial, &gorm.Config{Logger: logger.Discard}); !errors.Is(err, ErrReadDBNotConfigured) {
+		t.Errorf("nil replica err = %v, want ErrReadDBNotConfigured", err)
+	}
+
+	opts = &RWOptions{Write: write, Reads: []*Options{
+		{Host: Ptr("r1"), Port: Ptr(3306), DBName: Ptr(filepath.Join(dir, "read1.db"))},
+		{Host: Ptr("r2"), Port: Ptr(3307), DBName: Ptr(filepath.Join(dir, "read2.db"))},
+	}}
+	_, err := opts.OpenDB(dial, &gorm.Config{Logger: logger.Discard})
+	if !errors.Is(err, errDial) || !strings.Contains(err.Error(), "r2:3307") {
+		t.Errorf("dial err = %v, want error naming r2:3307", err)
+	}
+}
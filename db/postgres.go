@@ -0,0 +1,68 @@
+package db
+
+import (
+	"fmt"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// DefaultPostgresSSLMode 未配置 SSLMode 时使用的 sslmode.
+const DefaultPostgresSSLMode = "disable"
+
+// PostgresDialector 返回 PostgreSQL 方言转换函数.
+//
+// driverName 为 database/sql 驱动名, 为空时使用 pgx. 配置 PasswordProvider 或 RotatableCredentials 时
+// 通过 pgx 驱动在每次建立连接时获取密码, 忽略 driverName.
+//
+// 嵌套事务使用保存点时, PostgreSQL 语句失败后事务进入中止状态, 需回滚到保存点后才可继续使用,
+// 默认的嵌套事务合并模式下语句失败将导致整个事务无法继续.
+func PostgresDialector(driverName string) Dialector {
+	return func(opts *Options) (gorm.Dialector, error) {
+		if opts.useConnector() {
+			connector := NewPasswordConnector(stdlib.GetDefaultDriver(), opts, PostgresDSN)
+			return postgres.New(postgres.Config{Conn: openConnector(connector)}), nil
+		}
+		return postgres.New(postgres.Config{DriverName: driverName, DSN: PostgresDSN(opts, deref(opts.Password))}), nil
+	}
+}
+
+// PostgresDSN 生成 PostgreSQL keyword/value 格式连接串.
+//
+// connect_timeout 由 TimeoutInMills 换算为秒, 不足 1 秒按 1 秒处理, 未配置时不设置.
+// Params 按 Key 排序附加在内置参数后, 与内置参数同名时忽略.
+func PostgresDSN(opts *Options, password string) string {
+	sslMode := opts.SSLMode
+	if sslMode == "" {
+		sslMode = DefaultPostgresSSLMode
+	}
+	pairs := []string{
+		"host=" + quotePostgresValue(deref(opts.Host)),
+		fmt.Sprintf("port=%d", deref(opts.Port)),
+		"user=" + quotePostgresValue(deref(opts.UserName)),
+		"password=" + quotePostgresValue(password),
+		"dbname=" + quotePostgresValue(deref(opts.DBName)),
+		"sslmode=" + quotePostgresValue(sslMode),
+	}
+	if opts.TimeoutInMills > 0 {
+		pairs = append(pairs, fmt.Sprintf("connect_timeout=%d", (opts.TimeoutInMills+999)/1000))
+	}
+	for _, key := range opts.paramKeys(postgresBuiltinParams) {
+		pairs = append(pairs, key+"="+quotePostgresValue(opts.Params[key]))
+	}
+	return strings.Join(pairs, " ")
+}
+
+// postgresBuiltinParams PostgresDSN 内置参数.
+var postgresBuiltinParams = map[string]bool{
+	"host": true, "port": true, "user": true, "password": true,
+	"dbname": true, "sslmode": true, "connect_timeout": true,
+}
+
+// quotePostgresValue 以单引号包裹连接串值, 转义反斜杠及单引号.
+func quotePostgresValue(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
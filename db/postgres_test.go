@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestPostgresDSN(t *testing.T) {
+	opts := &Options{
+		Host: Ptr("pg.internal"), Port: Ptr(5432), UserName: Ptr("app"), DBName: Ptr("orders"),
+		TimeoutInMills: 1500,
+		Params:         map[string]string{"application_name": "svc", "sslmode": "require"},
+	}
+	want := "host='pg.internal' port=5432 user='app' password='it\\'s' dbname='orders' sslmode='disable' " +
+		"connect_timeout=2 application_name='svc'"
+	if got := PostgresDSN(opts, "it's"); got != want {
+		t.Errorf("dsn = %s\nwant %s", got, want)
+	}
+
+	opts = &Options{Host: Ptr("pg"), Port: Ptr(5432), UserName: Ptr("app"), DBName: Ptr("app"), SSLMode: "verify-full"}
+	want = "host='pg' port=5432 user='app' password='' dbname='app' sslmode='verify-full'"
+	if got := PostgresDSN(opts, ""); got != want {
+		t.Errorf("dsn = %s\nwant %s", got, want)
+	}
+}
+
+// postgresTestOptions 从环境变量读取 PostgreSQL 测试库配置, 未设置 MINI_TRANSACTION_PG_HOST 时跳过测试.
+func postgresTestOptions(t *testing.T) *Options {
+	host := os.Getenv("MINI_TRANSACTION_PG_HOST")
+	if host == "" {
+		t.Skip("MINI_TRANSACTION_PG_HOST not set")
+	}
+	port := 5432
+	if v := os.Getenv("MINI_TRANSACTION_PG_PORT"); v != "" {
+		var err error
+		if port, err = strconv.Atoi(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &Options{
+		Dialect:  DialectPostgres,
+		Host:     Ptr(host),
+		Port:     Ptr(port),
+		UserName: Ptr(os.Getenv("MINI_TRANSACTION_PG_USER")),
+		Password: Ptr(os.Getenv("MINI_TRANSACTION_PG_PASSWORD")),
+		DBName:   Ptr(os.Getenv("MINI_TRANSACTION_PG_DBNAME")),
+	}
+}
+
+func TestPostgresIntegration(t *testing.T) {
+	opts := postgresTestOptions(t)
+	rwOpts := &RWOptions{Write: opts, Reads: []*Options{opts}}
+	db, err := rwOpts.OpenDB(PostgresDialector(""), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := db.Dialector.(*postgres.Dialector); !ok {
+		t.Fatalf("dialector = %T, want *postgres.Dialector", db.Dialector)
+	}
+	if err := db.Migrator().DropTable(new(tenantItem)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(new(tenantItem)); err != nil {
+		t.Fatal(err)
+	}
+	p := NewProviderWithOptions(NewSource("postgres", db), WithNestedSavepoint())
+	t.Cleanup(func() {
+		_ = db.Migrator().DropTable(new(tenantItem))
+		_ = p.ForceClose(context.Background())
+	})
+	ctx := context.Background()
+
+	// 嵌套事务失败回滚到保存点, 外层事务继续执行并提交.
+	errNested := errors.New("nested failed")
+	err = p.Transaction(ctx, func(ctx context.Context) error {
+		if err := p.UseDB(ctx).Create(&tenantItem{Name: "outer"}).Error; err != nil {
+			return err
+		}
+		err := p.Transaction(ctx, func(ctx context.Context) error {
+			if err := p.UseDB(ctx).Create(&tenantItem{Name: "nested"}).Error; err != nil {
+				return err
+			}
+			return errNested
+		})
+		if !errors.Is(err, errNested) {
+			t.Errorf("nested err = %v, want %v", err, errNested)
+		}
+		return p.UseDB(ctx).Create(&tenantItem{Name: "after"}).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	if err := p.UseReadDB(ctx).Model(new(tenantItem)).Order("id").Pluck("name", &names).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "outer" || names[1] != "after" {
+		t.Errorf("names = %v, want [outer after]", names)
+	}
+}
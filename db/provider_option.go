@@ -0,0 +1,156 @@
+package db
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"mini_transaction/transaction"
+	"time"
+)
+
+// ProviderOption 定义 Provider 选项.
+type ProviderOption func(*TransProvider)
+
+// WithScopes 设置 UseDB 等方法返回 DB 时应用的 scopes.
+func WithScopes(scopes ...func(*gorm.DB) *gorm.DB) ProviderOption {
+	return func(p *TransProvider) {
+		p.scopes = append(p.scopes, scopes...)
+	}
+}
+
+// WithGORMConfig 设置 UseDB 等方法返回 DB 的 GORM 配置, 覆盖创建连接时的配置.
+//
+// 用于同一数据源按 Provider 区分配置, 如迁移使用详细日志, 业务仅记录慢查询.
+// 布尔开关及 CreateBatchSize 按 cfg 的值覆盖, Logger, NowFunc 为 nil 时沿用原配置.
+//
+// NamingStrategy 等影响模型解析或连接的配置按 DB 缓存, 无法按 Provider 覆盖,
+// cfg 为 nil 或设置了这些配置时 panic.
+func WithGORMConfig(cfg *gorm.Config) ProviderOption {
+	if err := checkGORMConfig(cfg); err != nil {
+		panic(err)
+	}
+	c := *cfg
+	return func(p *TransProvider) {
+		p.gormConfig = &c
+	}
+}
+
+// WithManagerOptions 设置事务管理器选项.
+func WithManagerOptions(opts ...transaction.ManagerOption) ProviderOption {
+	return func(p *TransProvider) {
+		p.managerOpts = append(p.managerOpts, opts...)
+	}
+}
+
+// AbortCommitOnContextDone 设置回调成功但 context 已结束时是否放弃提交.
+//
+// 默认为 true, 回滚事务并返回包装 ctx.Err() 的错误.
+//
+// 设置为 false 时忽略 context 状态继续提交.
+func AbortCommitOnContextDone(abort bool) ProviderOption {
+	return func(p *TransProvider) {
+		p.abortCommitOnContextDone = abort
+	}
+}
+
+// WithTransactionComment 为事务内语句添加事务注释.
+//
+// 注释形如 /* tx:<事务 ID> label:<事务标签> */, 便于从慢查询日志、锁等待定位业务事务.
+//
+// 注释在事务内保持不变, 开启 PrepareStmt 时每个事务产生独立的预编译语句.
+// 原生 SQL(Raw/Exec) 不添加注释.
+// SQLite 方言自定义了 INSERT 子句构建, INSERT 语句不带注释.
+func WithTransactionComment() ProviderOption {
+	return func(p *TransProvider) {
+		p.txComment = true
+	}
+}
+
+// WithStatementLimit 限制单个事务的语句数.
+//
+// 嵌套事务与根事务共享语句数.
+func WithStatementLimit(limit StatementLimit) ProviderOption {
+	return func(p *TransProvider) {
+		p.statementLimit = &limit
+	}
+}
+
+// WithTransactionStats 统计事务内语句数、影响行数及数据库耗时.
+//
+// report 不为 nil 时, 在根事务结束后以统计结果回调.
+func WithTransactionStats(report func(ctx context.Context, stats TransactionStats, err error)) ProviderOption {
+	return func(p *TransProvider) {
+		p.stats = &statsOptions{report: report}
+	}
+}
+
+// WithStrictTransactionContext 开启事务上下文严格校验.
+//
+// 使用已结束事务的 context 获取 DB 时, UseDB 等方法以包含事务 ID 及结束时间的
+// ErrStaleTransactionContext panic, TryUseDB 等方法返回该错误, 而非使用非事务 DB.
+//
+// EscapeTransaction 清除事务标记后的 context 不受影响.
+func WithStrictTransactionContext() ProviderOption {
+	return func(p *TransProvider) {
+		p.strict = true
+	}
+}
+
+// WithPprofLabels 为根事务回调设置 pprof 标签, tx_db 为写库名.
+//
+// 见 transaction.WithPprofLabels.
+func WithPprofLabels() ProviderOption {
+	return func(p *TransProvider) {
+		p.managerOpts = append(p.managerOpts, transaction.WithPprofLabels(nil))
+	}
+}
+
+// DisallowNested 禁止嵌套事务, 见 transaction.DisallowNested.
+func DisallowNested() ProviderOption {
+	return WithManagerOptions(transaction.DisallowNested())
+}
+
+// WithNestedSavepoint 嵌套事务使用保存点, 默认嵌套事务加入外层事务.
+//
+// 嵌套事务回调返回错误或 panic 时回滚到保存点, 外层事务捕获 panic 或处理错误后可继续使用.
+// 嵌套事务的提交及回滚回调仍注册在根事务上, 随根事务执行.
+func WithNestedSavepoint() ProviderOption {
+	return func(p *TransProvider) {
+		p.nestedSavepoint = true
+	}
+}
+
+// WithCommitTimeout 设置提交阶段超时, 与语句超时分别限制.
+//
+// 提交超时返回 *CommitTimeoutError, 事务结果未知, 提交及回滚回调均不执行.
+// 数据库可能在超时后完成提交, 需要时由调用方依据业务数据确认.
+func WithCommitTimeout(d time.Duration) ProviderOption {
+	return func(p *TransProvider) {
+		p.commitTimeout = d
+	}
+}
+
+// WithMaxConcurrentTransactions 限制并发根事务数为 n, 嵌套事务不占用名额.
+//
+// 开启根事务前获取名额, 最多等待 wait, 超时返回 ErrTransactionThrottled, wait 为 0 时不等待.
+// 等待及持有的名额数通过 RegisterExpvars 发布.
+func WithMaxConcurrentTransactions(n int, wait time.Duration) ProviderOption {
+	return func(p *TransProvider) {
+		p.throttle = newThrottle(n, wait)
+		p.metrics.throttle = p.throttle
+	}
+}
+
+// WithDurableCallbacks 开启可靠提交回调, 回调数据存储在 table 表中.
+//
+// 通过 transaction.OnCommittedDurable 注册回调, 服务启动时调用 ReplayDurableCallbacks 重新执行未完成的回调.
+func WithDurableCallbacks(table string, handler func(ctx context.Context, payload []byte) error) ProviderOption {
+	return func(p *TransProvider) {
+		p.durable = &durableProviderOptions{adapter: NewTableDurableAdapter(p, table), handler: handler}
+		p.managerOpts = append(p.managerOpts, transaction.WithDurableCallbacks(p.durable.adapter, handler))
+	}
+}
+
+// WithEventPublisher 设置领域事件发布, 见 transaction.PublishEvent 及 TransProvider.PublishEvent.
+func WithEventPublisher(publisher transaction.EventPublisher) ProviderOption {
+	return WithManagerOptions(transaction.WithEventPublisher(publisher))
+}
@@ -0,0 +1,27 @@
+package db
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type queryLoggerKey struct{}
+
+// WithQueryLogger 设置请求级 GORM 日志.
+//
+// 使用返回 context 调用 UseDB 等方法时, 返回的 DB 以 logger 替代 DB 配置的日志, 用于附加请求 ID 等信息.
+func WithQueryLogger(ctx context.Context, logger logger.Interface) context.Context {
+	return context.WithValue(ctx, queryLoggerKey{}, logger)
+}
+
+// applyQueryLogger 为 DB 设置 context 中的请求级日志.
+//
+// 保留语句已有的条件及 scopes, 不使用 NewDB.
+func applyQueryLogger(ctx context.Context, db *gorm.DB) *gorm.DB {
+	l, ok := ctx.Value(queryLoggerKey{}).(logger.Interface)
+	if !ok || l == nil {
+		return db
+	}
+	return db.Session(&gorm.Session{Logger: l})
+}
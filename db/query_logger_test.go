@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+	"gorm.io/gorm/logger"
+	"testing"
+)
+
+func TestWithQueryLogger(t *testing.T) {
+	p := newSQLiteProvider(t, new(tenantItem))
+	reqLogger := &traceLogger{Interface: logger.Discard}
+	ctx := WithQueryLogger(context.Background(), reqLogger)
+
+	var items []tenantItem
+	if err := p.UseDB(ctx).Where("name = ?", "a").Find(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(reqLogger.sqls) != 1 {
+		t.Fatalf("traced %d statements outside transaction, want 1", len(reqLogger.sqls))
+	}
+
+	// 事务内同样使用请求级日志.
+	reqLogger.sqls = nil
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		return p.UseDB(ctx).Create(&tenantItem{Name: "a"}).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reqLogger.sqls) != 1 {
+		t.Errorf("traced %v in transaction, want 1 statement", reqLogger.sqls)
+	}
+
+	// 未设置日志的 context 不受影响.
+	reqLogger.sqls = nil
+	if err := p.UseDB(context.Background()).Find(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(reqLogger.sqls) != 0 {
+		t.Errorf("request logger traced %v without context value", reqLogger.sqls)
+	}
+}
@@ -0,0 +1,175 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+const quotaPluginName = "mini_transaction:quota"
+
+var (
+	ErrQuotaExceeded = errors.New("transaction quota exceeded")
+)
+
+// QuotaOptions 定义单个事务的配额.
+//
+// 配额为 0 时不限制.
+type QuotaOptions struct {
+	// 单个事务最大语句数.
+	MaxQueriesPerTransaction int
+	// 单个事务最大读取字节数, 按查询结果估算.
+	MaxBytesReadPerTransaction int64
+}
+
+// NewQuotaProvider 创建限制事务配额的 Provider.
+//
+// 返回的 Provider 与 base 共享数据源及事务上下文.
+//
+// 超出配额时语句返回 ErrQuotaExceeded, 此后当前事务的语句均返回
+// ErrQuotaExceeded, 事务回调返回错误后回滚.
+func NewQuotaProvider(base *TransProvider, opts QuotaOptions) *TransProvider {
+	p := base.derive()
+	p.quota = &opts
+	return p
+}
+
+// quotaKey 事务配额在事务范围数据中的 Key.
+type quotaKey struct {
+	quota *QuotaOptions
+}
+
+// quotaTracker 记录单个事务的配额使用情况.
+type quotaTracker struct {
+	opts      *QuotaOptions
+	queries   int64
+	bytesRead int64
+	exceeded  int32
+}
+
+// withQuota 为事务内 DB 绑定配额记录.
+func (p *TransProvider) withQuota(ctx context.Context, db *gorm.DB) *gorm.DB {
+	tc := p.findTransContext(ctx)
+	if tc == nil {
+		return db
+	}
+	if err := checkPlugin(db, quotaPluginName); err != nil {
+		_ = db.AddError(err)
+		return db
+	}
+	tracker, _ := tc.LoadOrStore(quotaKey{quota: p.quota}, &quotaTracker{opts: p.quota})
+	return db.Set(quotaPluginName, tracker)
+}
+
+// beforeStatement 语句执行前计数.
+func (t *quotaTracker) beforeStatement() error {
+	if atomic.LoadInt32(&t.exceeded) == 1 {
+		return ErrQuotaExceeded
+	}
+	queries := atomic.AddInt64(&t.queries, 1)
+	if max := t.opts.MaxQueriesPerTransaction; max > 0 && queries > int64(max) {
+		atomic.StoreInt32(&t.exceeded, 1)
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// afterQuery 查询执行后累计读取字节数.
+func (t *quotaTracker) afterQuery(n int64) error {
+	bytesRead := atomic.AddInt64(&t.bytesRead, n)
+	if max := t.opts.MaxBytesReadPerTransaction; max > 0 && bytesRead > max {
+		atomic.StoreInt32(&t.exceeded, 1)
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// quotaPlugin 通过 gorm 回调执行配额检查.
+//
+// 配额记录由 withQuota 绑定到语句, 未绑定的语句不受影响.
+type quotaPlugin struct{}
+
+func (quotaPlugin) Name() string {
+	return quotaPluginName
+}
+
+func (quotaPlugin) Initialize(db *gorm.DB) error {
+	before := func(db *gorm.DB) {
+		if tracker, ok := getQuotaTracker(db); ok {
+			if err := tracker.beforeStatement(); err != nil {
+				_ = db.AddError(err)
+			}
+		}
+	}
+	afterQuery := func(db *gorm.DB) {
+		if tracker, ok := getQuotaTracker(db); ok && db.Error == nil {
+			if err := tracker.afterQuery(estimateSize(db.Statement.ReflectValue, 0)); err != nil {
+				_ = db.AddError(err)
+			}
+		}
+	}
+	if err := registerBeforeStatement(db, quotaPluginName, before); err != nil {
+		return err
+	}
+	return db.Callback().Query().After("gorm:query").Register(quotaPluginName+":after", afterQuery)
+}
+
+func getQuotaTracker(db *gorm.DB) (*quotaTracker, bool) {
+	v, ok := db.Get(quotaPluginName)
+	if !ok {
+		return nil, false
+	}
+	tracker, ok := v.(*quotaTracker)
+	return tracker, ok
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// maxEstimateDepth 限制估算递归深度, 避免关联关系成环.
+const maxEstimateDepth = 8
+
+// estimateSize 估算查询结果占用字节数.
+func estimateSize(v reflect.Value, depth int) int64 {
+	if !v.IsValid() || depth > maxEstimateDepth {
+		return 0
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		return estimateSize(v.Elem(), depth+1)
+	case reflect.String:
+		return int64(v.Len())
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return int64(v.Len())
+		}
+		var n int64
+		for i := 0; i < v.Len(); i++ {
+			n += estimateSize(v.Index(i), depth+1)
+		}
+		return n
+	case reflect.Map:
+		var n int64
+		iter := v.MapRange()
+		for iter.Next() {
+			n += estimateSize(iter.Key(), depth+1) + estimateSize(iter.Value(), depth+1)
+		}
+		return n
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return int64(v.Type().Size())
+		}
+		var n int64
+		for i := 0; i < v.NumField(); i++ {
+			n += estimateSize(v.Field(i), depth+1)
+		}
+		return n
+	default:
+		return int64(v.Type().Size())
+	}
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestQuotaMaxQueries(t *testing.T) {
+	base := newSQLiteProvider(t, new(tenantItem))
+	p := NewQuotaProvider(base, QuotaOptions{MaxQueriesPerTransaction: 3})
+	ctx := context.Background()
+
+	statements := 0
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		for i := 0; i < 10; i++ {
+			if err := p.UseDB(ctx).Create(&tenantItem{Name: "item"}).Error; err != nil {
+				return err
+			}
+			statements++
+		}
+		return nil
+	})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("err = %v, want %v", err, ErrQuotaExceeded)
+	}
+	if statements != 3 {
+		t.Errorf("executed %d statements, want 3", statements)
+	}
+	if n := countItems(t, base); n != 0 {
+		t.Errorf("%d rows after rollback, want 0", n)
+	}
+
+	// 配额按事务计算, 新事务重新计数, 事务外不限制.
+	for i := 0; i < 5; i++ {
+		if err := p.UseDB(ctx).Create(&tenantItem{Name: "item"}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = p.Transaction(ctx, func(ctx context.Context) error {
+		return p.UseDB(ctx).Create(&tenantItem{Name: "item"}).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQuotaMaxBytesRead(t *testing.T) {
+	base := newSQLiteProvider(t, new(tenantItem))
+	ctx := context.Background()
+	items := make([]tenantItem, 20)
+	for i := range items {
+		items[i].Name = "a long enough name"
+	}
+	if err := base.UseWriteDB(ctx).Create(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+	p := NewQuotaProvider(base, QuotaOptions{MaxBytesReadPerTransaction: 100})
+
+	reads := 0
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		for i := 0; i < 10; i++ {
+			var found []tenantItem
+			if err := p.UseDB(ctx).Find(&found).Error; err != nil {
+				return err
+			}
+			reads++
+		}
+		return nil
+	})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("err = %v, want %v", err, ErrQuotaExceeded)
+	}
+	if reads >= 10 {
+		t.Errorf("read %d times without exceeding the quota", reads)
+	}
+}
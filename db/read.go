@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"mini_transaction/transaction"
+	"time"
+)
+
+// WithReadContext 返回读库 context.
+//
+// 返回的 context 清除了 p 的事务标记并标记为只读, UseDB 返回读库.
+// 原 context 不受影响, 外层事务可继续使用并提交.
+//
+// 读库读取不到当前事务未提交的数据.
+func WithReadContext(ctx context.Context, p *TransProvider) context.Context {
+	readCtx := ctx
+	_ = p.EscapeTransaction(ctx, func(ctx context.Context) error {
+		readCtx = ctx
+		return nil
+	})
+	return transaction.WithReadOnly(readCtx)
+}
+
+type replicaReadCtxKey struct{}
+
+// WithReplicaRead 标记 UseReadDB 在事务内同样返回读库.
+//
+// 读库读取不到当前事务未提交的数据, 用于不依赖事务内写入的大量读取.
+func WithReplicaRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaReadCtxKey{}, true)
+}
+
+func isReplicaRead(ctx context.Context) bool {
+	force, _ := ctx.Value(replicaReadCtxKey{}).(bool)
+	return force
+}
+
+// DefaultAfterWriteWindow AfterWrite 默认的读写库回退时长.
+const DefaultAfterWriteWindow = 500 * time.Millisecond
+
+type afterWriteCtxKey struct{}
+
+// AfterWrite 标记 context 刚执行写入, DefaultAfterWriteWindow 内 UseDB 使用写库, 见 AfterWriteFor.
+func AfterWrite(ctx context.Context) context.Context {
+	return AfterWriteFor(ctx, DefaultAfterWriteWindow)
+}
+
+// AfterWriteFor 标记 context 刚执行写入, window 内 UseDB 使用写库.
+//
+// 用于事务外写入后立即读取, 避免读到复制延迟的从库. 只读 context 及 dbresolver 自动路由的查询均使用写库.
+// 事务内始终使用事务 DB, 不受影响.
+func AfterWriteFor(ctx context.Context, window time.Duration) context.Context {
+	return context.WithValue(ctx, afterWriteCtxKey{}, time.Now().Add(window))
+}
+
+// ClearAfterWrite 清除 AfterWrite 标记, UseDB 恢复使用读库.
+func ClearAfterWrite(ctx context.Context) context.Context {
+	return context.WithValue(ctx, afterWriteCtxKey{}, time.Time{})
+}
+
+// isAfterWrite 判断 context 是否在 AfterWrite 标记的时长内.
+func isAfterWrite(ctx context.Context) bool {
+	deadline, ok := ctx.Value(afterWriteCtxKey{}).(time.Time)
+	return ok && time.Now().Before(deadline)
+}
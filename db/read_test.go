@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"mini_transaction/transaction"
+	"testing"
+	"time"
+)
+
+// firstItemName 返回 db 中首个 tenantItem 的名称.
+func firstItemName(t *testing.T, db *gorm.DB) string {
+	t.Helper()
+	var item tenantItem
+	if err := db.Order("id").First(&item).Error; err != nil {
+		t.Fatal(err)
+	}
+	return item.Name
+}
+
+func TestWithReadContext(t *testing.T) {
+	p, replica := newRWSQLiteProvider(t, new(tenantItem))
+	if err := replica.Create(&tenantItem{Name: "replica"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		if err := p.UseDB(ctx).Create(&tenantItem{Name: "primary"}).Error; err != nil {
+			return err
+		}
+		readCtx := WithReadContext(ctx, p)
+		if p.InTransaction(readCtx) {
+			t.Error("read context is still in transaction")
+		}
+		if got := firstItemName(t, p.UseDB(readCtx)); got != "replica" {
+			t.Errorf("UseDB(read context) read %q, want replica", got)
+		}
+		// 外层事务不受影响.
+		if !p.InTransaction(ctx) {
+			t.Error("outer context left transaction")
+		}
+		return p.UseDB(ctx).Create(&tenantItem{Name: "primary"}).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	if err := p.UseWriteDB(ctx).Model(new(tenantItem)).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("write db has %d rows after commit, want 2", n)
+	}
+}
+
+func TestAfterWrite(t *testing.T) {
+	p, replica := newRWSQLiteProvider(t, new(tenantItem))
+	if err := replica.Create(&tenantItem{Name: "replica"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := p.UseWriteDB(context.Background()).Create(&tenantItem{Name: "primary"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	readCtx := transaction.WithReadOnly(context.Background())
+	if got := firstItemName(t, p.UseDB(readCtx)); got != "replica" {
+		t.Fatalf("UseDB(read only) read %q, want replica", got)
+	}
+
+	ctx := AfterWriteFor(readCtx, 50*time.Millisecond)
+	if got := firstItemName(t, p.UseDB(ctx)); got != "primary" {
+		t.Errorf("UseDB within window read %q, want primary", got)
+	}
+	if got := firstItemName(t, p.UseDB(ClearAfterWrite(ctx))); got != "replica" {
+		t.Errorf("UseDB after clear read %q, want replica", got)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if got := firstItemName(t, p.UseDB(ctx)); got != "replica" {
+		t.Errorf("UseDB after expiry read %q, want replica", got)
+	}
+	if got := firstItemName(t, p.UseDB(AfterWrite(readCtx))); got != "primary" {
+		t.Errorf("UseDB within default window read %q, want primary", got)
+	}
+}
+
+func TestUseReadDB(t *testing.T) {
+	p, replica := newRWSQLiteProvider(t, new(tenantItem))
+	if err := replica.Create(&tenantItem{Name: "replica"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	var provider Provider = p
+	ctx := context.Background()
+	if got := firstItemName(t, provider.UseReadDB(ctx)); got != "replica" {
+		t.Errorf("UseReadDB outside transaction read %q, want replica", got)
+	}
+
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		if err := p.UseDB(ctx).Create(&tenantItem{Name: "primary"}).Error; err != nil {
+			return err
+		}
+		// 事务内默认读取事务 DB, 可见未提交的写入.
+		if got := firstItemName(t, provider.UseReadDB(ctx)); got != "primary" {
+			t.Errorf("UseReadDB in transaction read %q, want primary", got)
+		}
+		if got := firstItemName(t, provider.UseReadDB(WithReplicaRead(ctx))); got != "replica" {
+			t.Errorf("UseReadDB(WithReplicaRead) in transaction read %q, want replica", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
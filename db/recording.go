@@ -0,0 +1,425 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrPrepareNotSupported = errors.New("recording: prepared statements not supported")
+)
+
+// QueryRecord 代表一次语句执行的记录.
+type QueryRecord struct {
+	SQL  string        `json:"sql"`
+	Args []interface{} `json:"args,omitempty"`
+	// 查询结果列名及各行数据, 行数据以列名为 Key.
+	Columns []string                 `json:"columns,omitempty"`
+	Rows    []map[string]interface{} `json:"rows,omitempty"`
+	// 非查询语句的执行结果.
+	RowsAffected int64 `json:"rows_affected,omitempty"`
+	LastInsertID int64 `json:"last_insert_id,omitempty"`
+	// 执行错误, 序列化为错误信息, 反序列化后仅保留信息.
+	Err error `json:"-"`
+}
+
+// queryRecordJSON 代表 QueryRecord 的 JSON 格式.
+type queryRecordJSON struct {
+	*queryRecordAlias
+	Err string `json:"err,omitempty"`
+}
+
+type queryRecordAlias QueryRecord
+
+// MarshalJSON 序列化记录, time.Time 及 []byte 以带类型标记的对象保存.
+func (r QueryRecord) MarshalJSON() ([]byte, error) {
+	alias := queryRecordAlias(r)
+	alias.Args = make([]interface{}, len(r.Args))
+	for i, arg := range r.Args {
+		alias.Args[i] = toJSONValue(arg)
+	}
+	alias.Rows = make([]map[string]interface{}, len(r.Rows))
+	for i, row := range r.Rows {
+		alias.Rows[i] = make(map[string]interface{}, len(row))
+		for col, value := range row {
+			alias.Rows[i][col] = toJSONValue(value)
+		}
+	}
+	v := queryRecordJSON{queryRecordAlias: &alias}
+	if r.Err != nil {
+		v.Err = r.Err.Error()
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON 反序列化记录, 整数还原为 int64, 其他数字还原为 float64.
+func (r *QueryRecord) UnmarshalJSON(data []byte) error {
+	v := queryRecordJSON{queryRecordAlias: (*queryRecordAlias)(r)}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	if v.Err != "" {
+		r.Err = errors.New(v.Err)
+	}
+	for i, arg := range r.Args {
+		r.Args[i] = fromJSONValue(arg)
+	}
+	for _, row := range r.Rows {
+		for col, value := range row {
+			row[col] = fromJSONValue(value)
+		}
+	}
+	return nil
+}
+
+// JSON 中带类型标记的值.
+const (
+	jsonTimeKey  = "$time"
+	jsonBytesKey = "$bytes"
+)
+
+// toJSONValue 为 JSON 无法区分的类型附加类型标记.
+func toJSONValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case time.Time:
+		return map[string]string{jsonTimeKey: v.Format(time.RFC3339Nano)}
+	case []byte:
+		return map[string][]byte{jsonBytesKey: v}
+	}
+	return v
+}
+
+// fromJSONValue 还原 toJSONValue 的类型标记, json.Number 转换为 int64 或 float64.
+func fromJSONValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return i
+		}
+		f, _ := strconv.ParseFloat(string(v), 64)
+		return f
+	case map[string]interface{}:
+		if s, ok := v[jsonTimeKey].(string); ok && len(v) == 1 {
+			if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+				return t
+			}
+		}
+		if s, ok := v[jsonBytesKey].(string); ok && len(v) == 1 {
+			if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+				return b
+			}
+		}
+	}
+	return v
+}
+
+// QueryRecording 代表按执行顺序记录的语句, 可序列化为 JSON 持久化.
+type QueryRecording struct {
+	// 录制时数据源的方言名, 回放时以相同方言生成语句.
+	Dialect string        `json:"dialect"`
+	Records []QueryRecord `json:"records"`
+
+	mut sync.Mutex
+	// 回放位置.
+	pos int
+}
+
+// LoadQueryRecording 从 JSON 读取语句记录.
+func LoadQueryRecording(r io.Reader) (*QueryRecording, error) {
+	rec := &QueryRecording{}
+	if err := json.NewDecoder(r).Decode(rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// Save 以 JSON 写入语句记录.
+func (r *QueryRecording) Save(w io.Writer) error {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func (r *QueryRecording) append(record QueryRecord) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.Records = append(r.Records, record)
+}
+
+// recordingSource 录制内部数据源执行的语句.
+type recordingSource struct {
+	inner Source
+	rec   *QueryRecording
+	// 内部 DB 到录制 DB.
+	dbs sync.Map
+}
+
+// NewRecordingSource 创建录制语句的数据源, 读写均通过 inner 执行并按顺序记录到 rec.
+//
+// 通过替换 DB 连接池拦截语句, 查询结果读取后记录, 再以内存结果集返回.
+// 不支持 PrepareStmt 及 dbresolver 读写分离, 用于测试.
+func NewRecordingSource(inner Source, rec *QueryRecording) Source {
+	if dialector := inner.Dialector(); dialector != nil {
+		rec.Dialect = dialector.Name()
+	}
+	return &recordingSource{inner: inner, rec: rec}
+}
+
+// wrap 返回录制 DB, 同一内部 DB 复用.
+func (s *recordingSource) wrap(db *gorm.DB) *gorm.DB {
+	if db == nil {
+		return nil
+	}
+	if v, ok := s.dbs.Load(db); ok {
+		return v.(*gorm.DB)
+	}
+	wrapped := db.Session(&gorm.Session{})
+	wrapped.Statement.ConnPool = &recordingPool{pool: db.Statement.ConnPool, rec: s.rec}
+	v, _ := s.dbs.LoadOrStore(db, wrapped)
+	return v.(*gorm.DB)
+}
+
+func (s *recordingSource) getWriteDBName(ctx context.Context) string {
+	return s.inner.getWriteDBName(ctx)
+}
+
+func (s *recordingSource) getWriteDB(ctx context.Context) *gorm.DB {
+	return s.wrap(s.inner.getWriteDB(ctx))
+}
+
+func (s *recordingSource) getReadDBName(ctx context.Context) string {
+	return s.inner.getReadDBName(ctx)
+}
+
+func (s *recordingSource) getReadDB(ctx context.Context) *gorm.DB {
+	return s.wrap(s.inner.getReadDB(ctx))
+}
+
+func (s *recordingSource) Close(ctx context.Context) error {
+	return s.inner.Close(ctx)
+}
+
+func (s *recordingSource) Dialector() gorm.Dialector {
+	return s.inner.Dialector()
+}
+
+// recordingPool 执行并记录语句.
+type recordingPool struct {
+	pool gorm.ConnPool
+	rec  *QueryRecording
+}
+
+func (p *recordingPool) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, ErrPrepareNotSupported
+}
+
+func (p *recordingPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	record := QueryRecord{SQL: query, Args: args}
+	res, err := p.pool.ExecContext(ctx, query, args...)
+	if err != nil {
+		record.Err = err
+	} else {
+		record.RowsAffected, _ = res.RowsAffected()
+		record.LastInsertID, _ = res.LastInsertId()
+	}
+	p.rec.append(record)
+	return res, err
+}
+
+func (p *recordingPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	record := p.query(ctx, query, args)
+	return memoryRows(ctx, record)
+}
+
+func (p *recordingPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	record := p.query(ctx, query, args)
+	return memoryRow(ctx, record)
+}
+
+// query 执行查询并读取全部结果.
+func (p *recordingPool) query(ctx context.Context, query string, args []interface{}) QueryRecord {
+	record := QueryRecord{SQL: query, Args: args}
+	record.Columns, record.Rows, record.Err = readRows(p.pool.QueryContext(ctx, query, args...))
+	p.rec.append(record)
+	return record
+}
+
+// readRows 读取结果集, 行数据以列名为 Key.
+func readRows(rows *sql.Rows, err error) ([]string, []map[string]interface{}, error) {
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+	var result []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, nil, err
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			row[col] = values[i]
+		}
+		result = append(result, row)
+	}
+	return columns, result, rows.Err()
+}
+
+func (p *recordingPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	var (
+		tx  gorm.ConnPool
+		err error
+	)
+	switch beginner := p.pool.(type) {
+	case gorm.TxBeginner:
+		tx, err = beginner.BeginTx(ctx, opts)
+	case gorm.ConnPoolBeginner:
+		tx, err = beginner.BeginTx(ctx, opts)
+	default:
+		return nil, gorm.ErrInvalidTransaction
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &recordingTx{recordingPool{pool: tx, rec: p.rec}}, nil
+}
+
+func (p *recordingPool) GetDBConn() (*sql.DB, error) {
+	return sqlDBOf(p.pool)
+}
+
+// recordingTx 执行并记录事务内语句.
+type recordingTx struct {
+	recordingPool
+}
+
+func (t *recordingTx) Commit() error {
+	return t.pool.(gorm.TxCommitter).Commit()
+}
+
+func (t *recordingTx) Rollback() error {
+	return t.pool.(gorm.TxCommitter).Rollback()
+}
+
+var (
+	memoryDB     *sql.DB
+	memoryDBOnce sync.Once
+)
+
+// memoryQuery 内存结果集查询语句, 记录作为唯一参数传入.
+const memoryQuery = "memory"
+
+// memoryRows 以内存结果集返回记录的查询结果.
+func memoryRows(ctx context.Context, record QueryRecord) (*sql.Rows, error) {
+	if record.Err != nil {
+		return nil, record.Err
+	}
+	return openMemoryDB().QueryContext(ctx, memoryQuery, record)
+}
+
+// memoryRow 以内存结果集返回记录的单行查询结果.
+func memoryRow(ctx context.Context, record QueryRecord) *sql.Row {
+	return openMemoryDB().QueryRowContext(ctx, memoryQuery, record)
+}
+
+// openMemoryDB 返回内存结果集连接池, 驱动无状态, 全局共享.
+func openMemoryDB() *sql.DB {
+	memoryDBOnce.Do(func() {
+		memoryDB = sql.OpenDB(memoryConnector{})
+	})
+	return memoryDB
+}
+
+// memoryConnector 实现返回记录结果集的 database/sql 驱动.
+type memoryConnector struct{}
+
+func (memoryConnector) Connect(context.Context) (driver.Conn, error) {
+	return memoryConn{}, nil
+}
+
+func (memoryConnector) Driver() driver.Driver {
+	return nil
+}
+
+type memoryConn struct{}
+
+func (memoryConn) Prepare(string) (driver.Stmt, error) {
+	return nil, ErrPrepareNotSupported
+}
+
+func (memoryConn) Close() error {
+	return nil
+}
+
+func (memoryConn) Begin() (driver.Tx, error) {
+	return nil, gorm.ErrInvalidTransaction
+}
+
+// CheckNamedValue 接受任意参数, 使 QueryRecord 原样传入 QueryContext.
+func (memoryConn) CheckNamedValue(*driver.NamedValue) error {
+	return nil
+}
+
+func (memoryConn) QueryContext(_ context.Context, _ string, args []driver.NamedValue) (driver.Rows, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("recording: %d memory query args", len(args))
+	}
+	record, ok := args[0].Value.(QueryRecord)
+	if !ok {
+		return nil, fmt.Errorf("recording: unexpected memory query arg %T", args[0].Value)
+	}
+	if record.Err != nil {
+		return nil, record.Err
+	}
+	return &memoryResultRows{columns: record.Columns, rows: record.Rows}, nil
+}
+
+// memoryResultRows 实现 driver.Rows.
+type memoryResultRows struct {
+	columns []string
+	rows    []map[string]interface{}
+	pos     int
+}
+
+func (r *memoryResultRows) Columns() []string {
+	return r.columns
+}
+
+func (r *memoryResultRows) Close() error {
+	return nil
+}
+
+func (r *memoryResultRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	row := r.rows[r.pos]
+	r.pos++
+	for i, col := range r.columns {
+		dest[i] = row[col]
+	}
+	return nil
+}
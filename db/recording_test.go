@@ -0,0 +1,92 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path/filepath"
+	"testing"
+)
+
+// recordedOps 执行录制及回放的操作, 返回查询结果.
+func recordedOps(t *testing.T, p *TransProvider) ([]tenantItem, int64) {
+	t.Helper()
+	ctx := context.Background()
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		for _, name := range []string{"a", "b", "c"} {
+			if err := p.UseDB(ctx).Create(&tenantItem{Name: name}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []tenantItem
+	if err := p.UseDB(ctx).Order("id").Find(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	if err := p.UseDB(ctx).Model(&tenantItem{}).Where("name <> ?", "a").Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return items, n
+}
+
+func TestRecordingReplay(t *testing.T) {
+	opts := &Options{Dialect: DialectSQLite, DBName: Ptr(filepath.Join(t.TempDir(), "rec.db"))}
+	db, err := opts.OpenDB(SQLiteDialector(), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDB(db, make(map[interface{}]bool))
+	if err := db.AutoMigrate(&tenantItem{}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &QueryRecording{}
+	recorded, recordedCount := recordedOps(t, NewProvider(NewRecordingSource(NewSource("sqlite", db), rec)))
+	var buf bytes.Buffer
+	if err := rec.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadQueryRecording(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := NewReplaySource(loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, replayedCount := recordedOps(t, NewProvider(source))
+	if len(replayed) != 3 || len(replayed) != len(recorded) {
+		t.Fatalf("replayed = %+v, recorded = %+v", replayed, recorded)
+	}
+	for i := range recorded {
+		if replayed[i] != recorded[i] {
+			t.Errorf("row %d = %+v, want %+v", i, replayed[i], recorded[i])
+		}
+	}
+	if replayedCount != 2 || replayedCount != recordedCount {
+		t.Errorf("count = %d, recorded %d", replayedCount, recordedCount)
+	}
+
+	// 记录用尽.
+	var items []tenantItem
+	err = NewProvider(source).UseDB(context.Background()).Find(&items).Error
+	if !errors.Is(err, ErrReplayExhausted) {
+		t.Errorf("error = %v, want ErrReplayExhausted", err)
+	}
+}
+
+func TestNewReplaySourceUnsupportedDialect(t *testing.T) {
+	for _, dialect := range []string{"", "oracle"} {
+		if _, err := NewReplaySource(&QueryRecording{Dialect: dialect}); err == nil {
+			t.Errorf("dialect %q: no error", dialect)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package db
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"mini_transaction/transaction"
+	"sort"
+)
+
+// MultiSource 代表可枚举库的数据源, 用于健康检查、迁移等运维操作.
+type MultiSource interface {
+	Source
+	// Keys 返回排序后的库名.
+	Keys() []string
+	// DB 返回库名对应的 DB, 不存在时返回 false.
+	DB(name string) (*gorm.DB, bool)
+}
+
+var (
+	_ MultiSource = new(source)
+	_ MultiSource = new(DynamicSource)
+	_ MultiSource = new(LazySource)
+	_ MultiSource = new(ReloadableSource)
+	_ MultiSource = new(ClusterSource)
+)
+
+// sortedKeys 返回排序后的 Key.
+func sortedKeys(dbs map[string]*gorm.DB) []string {
+	keys := make([]string, 0, len(dbs))
+	for key := range dbs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Keys 返回持有的库名, 通过工厂函数创建时返回空.
+func (s *source) Keys() []string {
+	return sortedKeys(s.dbs)
+}
+
+func (s *source) DB(name string) (*gorm.DB, bool) {
+	db, ok := s.dbs[name]
+	return db, ok
+}
+
+// Keys 返回当前持有的库名.
+func (s *DynamicSource) Keys() []string {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return sortedKeys(s.dbs)
+}
+
+func (s *DynamicSource) DB(name string) (*gorm.DB, bool) {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	db, ok := s.dbs[name]
+	return db, ok
+}
+
+// Keys 返回配置的库名, 包括未创建连接的库.
+func (s *LazySource) Keys() []string {
+	keys := make([]string, 0, len(s.dbs))
+	for key := range s.dbs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// DB 返回库名对应的 DB, 未创建时创建, 创建失败时返回 false.
+func (s *LazySource) DB(name string) (*gorm.DB, bool) {
+	l, ok := s.dbs[name]
+	if !ok {
+		return nil, false
+	}
+	db, err := l.get()
+	return db, err == nil
+}
+
+// Keys 返回当前配置的库名.
+func (s *ReloadableSource) Keys() []string {
+	return sortedKeys(s.state.Load().dbs)
+}
+
+func (s *ReloadableSource) DB(name string) (*gorm.DB, bool) {
+	db, ok := s.state.Load().dbs[name]
+	return db, ok
+}
+
+// Keys 返回写入端点及读取端点.
+func (s *ClusterSource) Keys() []string {
+	return sortedKeys(s.dbs())
+}
+
+func (s *ClusterSource) DB(name string) (*gorm.DB, bool) {
+	db, ok := s.dbs()[name]
+	return db, ok
+}
+
+// sourceKeys 返回数据源的库名, 包装的数据源返回被包装数据源的库名.
+func sourceKeys(s Source) []string {
+	var keys []string
+	for _, inner := range unwrapSource(s) {
+		if ms, ok := inner.(MultiSource); ok {
+			keys = append(keys, ms.Keys()...)
+		}
+	}
+	return keys
+}
+
+// sourceDB 返回数据源中库名对应的 DB.
+func sourceDB(s Source, name string) (*gorm.DB, bool) {
+	for _, inner := range unwrapSource(s) {
+		if ms, ok := inner.(MultiSource); ok {
+			if db, ok := ms.DB(name); ok {
+				return db, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// unwrapSource 返回包装数据源及其被包装的数据源, 被包装的数据源在后.
+func unwrapSource(s Source) []Source {
+	sources := []Source{s}
+	switch s := s.(type) {
+	case *namedSource:
+		sources = append(sources, unwrapSource(s.Source)...)
+	case *schemaRoutingSource:
+		sources = append(sources, unwrapSource(s.inner)...)
+	case *recordingSource:
+		sources = append(sources, unwrapSource(s.inner)...)
+	case *featureFlagSource:
+		sources = append(sources, unwrapSource(s.primary)...)
+		sources = append(sources, unwrapSource(s.secondary)...)
+	case *lagAwareSource:
+		sources = append(sources, unwrapSource(s.primary)...)
+		for _, r := range s.replicas {
+			sources = append(sources, unwrapSource(r.Source)...)
+		}
+	}
+	return sources
+}
+
+// Keys 返回数据源的库名, 去重后排序. 数据源通过工厂函数创建时返回空.
+func (p *TransProvider) Keys() []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, key := range sourceKeys(p.loadSource()) {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// DB 返回库名对应的 DB, 不存在时返回 false, 库名见 Keys.
+func (p *TransProvider) DB(name string) (*gorm.DB, bool) {
+	return sourceDB(p.loadSource(), name)
+}
+
+// UseDBByName 返回库名对应的 DB, 不经过数据源路由, 用于跨租户管理等需要访问指定库的操作.
+//
+// context 在该库的事务内时返回事务 DB. 不加入其他库上开启的事务, context 所在事务的库与 name 不同时
+// 返回的 DB 在事务外执行, 不随该事务提交或回滚. 库名不存在时返回 *UnknownDBKeyError.
+func (p *TransProvider) UseDBByName(ctx context.Context, name string) (*gorm.DB, error) {
+	if err := transaction.CheckBoundary(ctx); err != nil {
+		return nil, err
+	}
+	if tc, ok := ctx.Value(p.ctxKeyOf(name)).(transaction.TransContext); ok && tc.InTransaction() {
+		return p.useDB(ctx, tc.GetTransDB().(*gorm.DB))
+	}
+	db, ok := p.DB(name)
+	if !ok {
+		return nil, &UnknownDBKeyError{Requested: name, Known: p.Keys()}
+	}
+	return p.useDB(ctx, db)
+}
@@ -0,0 +1,161 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestProviderKeysAndDB(t *testing.T) {
+	dir := t.TempDir()
+	o := MultiRWOptions{
+		"main.orders": {Write: &Options{Dialect: DialectSQLite, DBName: Ptr(filepath.Join(dir, "orders.db"))}},
+		"main.users":  {Write: &Options{Dialect: DialectSQLite, DBName: Ptr(filepath.Join(dir, "users.db"))}},
+	}
+	router := func(ctx context.Context) string {
+		return "main.orders"
+	}
+	s, err := o.ToSource(SQLiteDialector(), &gorm.Config{Logger: logger.Discard}, router)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewProvider(NewNamedSource("main", s))
+	t.Cleanup(func() { _ = p.ForceClose(context.Background()) })
+
+	want := []string{"main.orders", "main.users"}
+	if keys := p.Keys(); !reflect.DeepEqual(keys, want) {
+		t.Fatalf("Keys() = %v, want %v", keys, want)
+	}
+	for _, key := range p.Keys() {
+		db, ok := p.DB(key)
+		if !ok {
+			t.Fatalf("DB(%q) not found", key)
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sqlDB.Ping(); err != nil {
+			t.Errorf("ping %s: %v", key, err)
+		}
+	}
+	// DB 返回路由使用的同一 DB.
+	orders, _ := p.DB("main.orders")
+	if orders != s.getWriteDB(context.Background()) {
+		t.Error("DB(main.orders) differs from routed db")
+	}
+	if _, ok := p.DB("main.missing"); ok {
+		t.Error("DB(main.missing) found")
+	}
+}
+
+func TestSourceKeys(t *testing.T) {
+	write := newSQLiteProvider(t)
+	read := newSQLiteProvider(t)
+	writeDB, _ := write.DB("sqlite")
+	readDB, _ := read.DB("sqlite")
+
+	cases := []struct {
+		name   string
+		source Source
+		want   []string
+	}{
+		{"single", NewSource("main", writeDB), []string{"main"}},
+		{"write read", NewWriteReadSource("main.w", writeDB, "main.r", readDB), []string{"main.r", "main.w"}},
+		{"write read fallback", NewWriteReadSourceWithFallback("main.w", writeDB, "main.r", nil), []string{"main.w"}},
+		{"recording", NewRecordingSource(NewWriteReadSource("main.w", writeDB, "main.r", readDB), new(QueryRecording)), []string{"main.r", "main.w"}},
+		{"func", NewSourceWithFunc(
+			func(context.Context) string { return "main" },
+			func(context.Context) *gorm.DB { return writeDB },
+		), nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := NewProvider(c.source)
+			if keys := p.Keys(); !reflect.DeepEqual(keys, c.want) {
+				t.Errorf("Keys() = %v, want %v", keys, c.want)
+			}
+			for _, key := range c.want {
+				if _, ok := p.DB(key); !ok {
+					t.Errorf("DB(%q) not found", key)
+				}
+			}
+		})
+	}
+}
+
+func TestUseDBByName(t *testing.T) {
+	s, err := newTenantSource(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewProvider(s)
+	t.Cleanup(func() { _ = p.ForceClose(context.Background()) })
+	for _, key := range p.Keys() {
+		db, _ := p.DB(key)
+		if err := db.AutoMigrate(new(tenantItem)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func(key string) int64 {
+		t.Helper()
+		db, _ := p.DB(key)
+		var n int64
+		if err := db.Model(new(tenantItem)).Count(&n).Error; err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	ctx := context.WithValue(context.Background(), tenantCtxKey{}, "a")
+
+	// 不经过路由.
+	db, err := p.UseDBByName(ctx, "main.default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&tenantItem{Name: "default"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count("main.default") != 1 || count("main.a") != 0 {
+		t.Fatal("UseDBByName(main.default) did not bypass the router")
+	}
+
+	err = p.Transaction(ctx, func(ctx context.Context) error {
+		// 同库返回事务 DB.
+		db, err := p.UseDBByName(ctx, "main.a")
+		if err != nil {
+			return err
+		}
+		if err := db.Create(&tenantItem{Name: "a"}).Error; err != nil {
+			return err
+		}
+		// 其他库不加入事务.
+		other, err := p.UseDBByName(ctx, "main.default")
+		if err != nil {
+			return err
+		}
+		if err := other.Create(&tenantItem{Name: "default"}).Error; err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	if err == nil || err.Error() != "rollback" {
+		t.Fatalf("transaction err = %v", err)
+	}
+	if n := count("main.a"); n != 0 {
+		t.Errorf("main.a has %d rows after rollback, want 0", n)
+	}
+	if n := count("main.default"); n != 2 {
+		t.Errorf("main.default has %d rows, want 2", n)
+	}
+
+	_, err = p.UseDBByName(ctx, "main.b")
+	var unknown *UnknownDBKeyError
+	if !errors.As(err, &unknown) || unknown.Requested != "main.b" {
+		t.Errorf("UseDBByName(main.b) err = %v, want *UnknownDBKeyError", err)
+	}
+}
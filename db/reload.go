@@ -0,0 +1,195 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrSourceNotReloadable = errors.New("source is not a ReloadableSource")
+)
+
+// DefaultReloadDrainPeriod 默认移除或替换的连接池关闭前等待时间.
+const DefaultReloadDrainPeriod = 30 * time.Second
+
+// ReloadableSource 代表可在运行时按新配置增删改数据库的数据源, 按 key 路由, 读写使用同一 DB.
+type ReloadableSource struct {
+	router   func(context.Context) string
+	dial     Dialector
+	config   *gorm.Config
+	openOpts []OpenOption
+	drain    time.Duration
+
+	// 当前配置及连接, 路由时无锁读取.
+	state atomic.Pointer[reloadState]
+	// 保证 Reload 及 Close 串行执行.
+	mut sync.Mutex
+	// 等待关闭的连接池.
+	retiring map[*time.Timer]*gorm.DB
+	closed   bool
+	closer   dbCloser
+}
+
+var _ Source = new(ReloadableSource)
+
+// reloadState 某一时刻的配置及连接.
+type reloadState struct {
+	opts MultiRWOptions
+	dbs  map[string]*gorm.DB
+}
+
+// ToReloadableSource 转换配置为可重新加载的数据源, 路由同 ToSource.
+//
+// drain 为移除或替换的连接池关闭前等待时间, 不大于 0 时为 DefaultReloadDrainPeriod.
+// opts 同时用于 Reload 创建连接.
+func (o MultiRWOptions) ToReloadableSource(
+	dial Dialector, config *gorm.Config, router func(context.Context) string,
+	drain time.Duration, opts ...OpenOption,
+) (*ReloadableSource, error) {
+	dbs, err := o.OpenDBs(dial, config, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if drain <= 0 {
+		drain = DefaultReloadDrainPeriod
+	}
+	s := &ReloadableSource{
+		router:   router,
+		dial:     dial,
+		config:   config,
+		openOpts: opts,
+		drain:    drain,
+		retiring: make(map[*time.Timer]*gorm.DB),
+	}
+	s.state.Store(&reloadState{opts: o.Filter(notNilOptions), dbs: dbs})
+	return s, nil
+}
+
+func notNilOptions(_ string, opt *RWOptions) bool {
+	return opt != nil
+}
+
+// Reload 按新配置更新数据库, 返回移除及替换的 Key.
+//
+// 新增的 Key 创建连接, 配置变化的 Key 创建新连接后替换, 配置通过 reflect.DeepEqual 比较,
+// 设置了函数类型配置项时视为变化. 新连接全部创建成功后切换路由, 之后的路由使用新配置,
+// 移除及替换的连接池在 drain 后关闭, 期间进行中的事务可继续完成. 校验或创建失败时不做任何修改.
+func (s *ReloadableSource) Reload(ctx context.Context, opts MultiRWOptions) ([]string, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.closed {
+		return nil, ErrSourceClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	cur := s.state.Load()
+	changed := opts.Filter(func(key string, opt *RWOptions) bool {
+		return opt != nil && !reflect.DeepEqual(cur.opts[key], opt)
+	})
+	opened, err := changed.OpenDBs(s.dial, s.config, s.openOpts...)
+	if err != nil {
+		return nil, err
+	}
+	next := &reloadState{opts: opts.Filter(notNilOptions), dbs: make(map[string]*gorm.DB, len(opts))}
+	for key := range next.opts {
+		if db, ok := opened[key]; ok {
+			next.dbs[key] = db
+		} else {
+			next.dbs[key] = cur.dbs[key]
+		}
+	}
+	s.state.Store(next)
+
+	var retired []string
+	for _, key := range cur.opts.Keys() {
+		if db, ok := next.dbs[key]; ok && db == cur.dbs[key] {
+			continue
+		}
+		retired = append(retired, key)
+		s.retire(cur.dbs[key])
+	}
+	return retired, nil
+}
+
+// retire 在 drain 后关闭连接池, 需持有 mut.
+func (s *ReloadableSource) retire(db *gorm.DB) {
+	var timer *time.Timer
+	timer = time.AfterFunc(s.drain, func() {
+		s.mut.Lock()
+		delete(s.retiring, timer)
+		s.mut.Unlock()
+		_ = closeDBs(map[string]*gorm.DB{"": db})
+	})
+	s.retiring[timer] = db
+}
+
+func (s *ReloadableSource) lookup(ctx context.Context) *gorm.DB {
+	return s.state.Load().dbs[s.router(ctx)]
+}
+
+func (s *ReloadableSource) getWriteDBName(ctx context.Context) string {
+	return s.router(ctx)
+}
+
+func (s *ReloadableSource) getWriteDB(ctx context.Context) *gorm.DB {
+	return s.lookup(ctx)
+}
+
+func (s *ReloadableSource) getReadDBName(ctx context.Context) string {
+	return s.router(ctx)
+}
+
+func (s *ReloadableSource) getReadDB(ctx context.Context) *gorm.DB {
+	return s.lookup(ctx)
+}
+
+// Close 关闭当前连接及等待关闭的连接池, 之后 Reload 返回 ErrSourceClosed.
+func (s *ReloadableSource) Close(context.Context) error {
+	s.mut.Lock()
+	s.closed = true
+	dbs := make(map[string]*gorm.DB)
+	for key, db := range s.state.Load().dbs {
+		dbs[key] = db
+	}
+	for timer, db := range s.retiring {
+		if timer.Stop() {
+			dbs[fmt.Sprintf("retiring-%p", db)] = db
+		}
+	}
+	s.retiring = nil
+	s.mut.Unlock()
+	return s.closer.close(dbs)
+}
+
+// Dialector 返回当前连接共同的数据库方言.
+func (s *ReloadableSource) Dialector() gorm.Dialector {
+	return commonDialector(s.state.Load().dbs)
+}
+
+// Reload 重新加载 ReloadableSource 的配置, 见 ReloadableSource.Reload.
+//
+// 数据源不是 ReloadableSource 时返回 ErrSourceNotReloadable.
+func (p *TransProvider) Reload(ctx context.Context, opts MultiRWOptions) error {
+	s, ok := p.loadSource().(*ReloadableSource)
+	if !ok {
+		return ErrSourceNotReloadable
+	}
+	retired, err := s.Reload(ctx, opts)
+	if err != nil {
+		return err
+	}
+	for _, key := range retired {
+		p.metrics.untrackDB(key)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDialer 为每次创建连接返回新的 fake DB, host 为 bad 时返回错误.
+type fakeDialer struct {
+	mut    sync.Mutex
+	opened map[string][]*sql.DB
+}
+
+func (d *fakeDialer) dialector() Dialector {
+	return func(opts *Options) (gorm.Dialector, error) {
+		host := deref(opts.Host)
+		if host == "bad" {
+			return nil, errors.New("fake: unknown host")
+		}
+		sqlDB, _ := newFakeDB(nil)
+		d.mut.Lock()
+		defer d.mut.Unlock()
+		d.opened[host] = append(d.opened[host], sqlDB)
+		return mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), nil
+	}
+}
+
+func reloadOptions(hosts map[string]string) MultiRWOptions {
+	opts := make(MultiRWOptions)
+	for key, host := range hosts {
+		opts[key] = &RWOptions{Write: &Options{
+			Host: Ptr(host), Port: Ptr(3306), DBName: Ptr("app"), UserName: Ptr("app"),
+		}}
+	}
+	return opts
+}
+
+func newReloadableSource(t *testing.T, drain time.Duration) (*ReloadableSource, *fakeDialer, *string) {
+	d := &fakeDialer{opened: make(map[string][]*sql.DB)}
+	key := new(string)
+	s, err := reloadOptions(map[string]string{"a": "a1"}).ToReloadableSource(d.dialector(),
+		&gorm.Config{Logger: logger.Discard}, func(context.Context) string { return *key }, drain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, d, key
+}
+
+func TestReloadableSourceReload(t *testing.T) {
+	s, d, key := newReloadableSource(t, 50*time.Millisecond)
+	defer s.Close(context.Background())
+	ctx := context.Background()
+
+	retired, err := s.Reload(ctx, reloadOptions(map[string]string{"a": "a2", "b": "b1"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(retired) != 1 || retired[0] != "a" {
+		t.Fatalf("retired = %v, want [a]", retired)
+	}
+	*key = "a"
+	if got, _ := s.getWriteDB(ctx).DB(); got != d.opened["a2"][0] {
+		t.Error("a not routed to the updated host")
+	}
+	*key = "b"
+	if got, _ := s.getWriteDB(ctx).DB(); got != d.opened["b1"][0] {
+		t.Error("b not routed to the added host")
+	}
+
+	// 替换的连接池等待期内仍可使用, 之后关闭.
+	old := d.opened["a1"][0]
+	if err := old.Ping(); err != nil {
+		t.Fatalf("replaced pool closed before drain: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for old.Ping() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("replaced pool not closed after drain")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 配置未变化的 Key 保留连接.
+	retired, err = s.Reload(ctx, reloadOptions(map[string]string{"a": "a2"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(retired) != 1 || retired[0] != "b" {
+		t.Fatalf("retired = %v, want [b]", retired)
+	}
+	*key = "a"
+	if got, _ := s.getWriteDB(ctx).DB(); got != d.opened["a2"][0] {
+		t.Error("unchanged key reopened")
+	}
+}
+
+func TestReloadableSourceReloadOpenFailure(t *testing.T) {
+	s, d, key := newReloadableSource(t, time.Hour)
+	defer s.Close(context.Background())
+	ctx := context.Background()
+
+	_, err := s.Reload(ctx, reloadOptions(map[string]string{"a": "a1", "b": "b1", "c": "bad"}))
+	if err == nil {
+		t.Fatal("Reload succeeded with an unreachable host")
+	}
+	if len(d.opened["b1"]) != 1 {
+		t.Fatalf("b opened %d times, want 1", len(d.opened["b1"]))
+	}
+	if err := d.opened["b1"][0].Ping(); err == nil {
+		t.Error("pool opened before the failure not closed")
+	}
+	*key = "b"
+	if db := s.getWriteDB(ctx); db != nil {
+		t.Error("failed Reload changed routing")
+	}
+	*key = "a"
+	if err := d.opened["a1"][0].Ping(); err != nil {
+		t.Errorf("current pool closed by failed Reload: %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+	ErrReplayMismatch  = errors.New("replay: statement mismatch")
+	ErrReplayExhausted = errors.New("replay: no more recorded statements")
+)
+
+// replaySQLiteVersion 回放 SQLite 方言初始化查询时返回的版本.
+const replaySQLiteVersion = "3.41.2"
+
+// NewReplaySource 创建回放语句记录的数据源, 不连接数据库.
+//
+// 语句按记录顺序返回记录的结果, 语句与记录不一致时返回 ErrReplayMismatch, 记录用尽时返回 ErrReplayExhausted.
+// 仅比较 SQL, 不比较参数. 支持 mysql, postgres 及 sqlite 方言, 其他方言返回错误.
+func NewReplaySource(rec *QueryRecording) (Source, error) {
+	pool := &replayPool{rec: rec}
+	dialector, err := replayDialector(rec.Dialect, pool)
+	if err != nil {
+		return nil, err
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{DisableAutomaticPing: true, Logger: logger.Discard})
+	if err != nil {
+		return nil, fmt.Errorf("replay: open %s: %w", rec.Dialect, err)
+	}
+	return NewSource("replay", db), nil
+}
+
+// replayDialector 返回以 pool 执行语句的方言.
+func replayDialector(name string, pool *replayPool) (gorm.Dialector, error) {
+	switch name {
+	case "mysql":
+		return mysql.New(mysql.Config{Conn: pool, SkipInitializeWithVersion: true}), nil
+	case "postgres":
+		return postgres.New(postgres.Config{Conn: pool}), nil
+	case "sqlite":
+		return &sqlite.Dialector{Conn: &sqliteReplayPool{pool}}, nil
+	}
+	return nil, fmt.Errorf("replay: unsupported dialect %q", name)
+}
+
+// replayPool 按顺序返回记录的结果.
+type replayPool struct {
+	rec *QueryRecording
+}
+
+// next 返回下一条记录, SQL 不一致时返回错误.
+func (p *replayPool) next(query string) (QueryRecord, error) {
+	r := p.rec
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if r.pos >= len(r.Records) {
+		return QueryRecord{}, fmt.Errorf("%w: %s", ErrReplayExhausted, query)
+	}
+	record := r.Records[r.pos]
+	if record.SQL != query {
+		return QueryRecord{}, fmt.Errorf("%w: #%d expected %q, got %q", ErrReplayMismatch, r.pos, record.SQL, query)
+	}
+	r.pos++
+	return record, nil
+}
+
+func (p *replayPool) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, ErrPrepareNotSupported
+}
+
+func (p *replayPool) ExecContext(_ context.Context, query string, _ ...interface{}) (sql.Result, error) {
+	record, err := p.next(query)
+	if err != nil {
+		return nil, err
+	}
+	if record.Err != nil {
+		return nil, record.Err
+	}
+	return replayResult{record}, nil
+}
+
+func (p *replayPool) QueryContext(ctx context.Context, query string, _ ...interface{}) (*sql.Rows, error) {
+	record, err := p.next(query)
+	if err != nil {
+		return nil, err
+	}
+	return memoryRows(ctx, record)
+}
+
+func (p *replayPool) QueryRowContext(ctx context.Context, query string, _ ...interface{}) *sql.Row {
+	record, err := p.next(query)
+	if err != nil {
+		record = QueryRecord{SQL: query, Err: err}
+	}
+	return memoryRow(ctx, record)
+}
+
+func (p *replayPool) BeginTx(context.Context, *sql.TxOptions) (gorm.ConnPool, error) {
+	return &replayTx{p}, nil
+}
+
+// replayTx 回放事务内语句, 提交及回滚无操作.
+type replayTx struct {
+	*replayPool
+}
+
+func (replayTx) Commit() error {
+	return nil
+}
+
+func (replayTx) Rollback() error {
+	return nil
+}
+
+// replayResult 实现 sql.Result.
+type replayResult struct {
+	record QueryRecord
+}
+
+func (r replayResult) LastInsertId() (int64, error) {
+	return r.record.LastInsertID, nil
+}
+
+func (r replayResult) RowsAffected() (int64, error) {
+	return r.record.RowsAffected, nil
+}
+
+// sqliteReplayPool 响应 SQLite 方言初始化时的版本查询.
+type sqliteReplayPool struct {
+	*replayPool
+}
+
+func (p *sqliteReplayPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if query == "select sqlite_version()" {
+		return memoryRow(ctx, QueryRecord{
+			SQL:     query,
+			Columns: []string{"sqlite_version()"},
+			Rows:    []map[string]interface{}{{"sqlite_version()": replaySQLiteVersion}},
+		})
+	}
+	return p.replayPool.QueryRowContext(ctx, query, args...)
+}
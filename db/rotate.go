@@ -0,0 +1,150 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+	"sync"
+	"time"
+)
+
+var (
+	ErrCredentialsNotRotatable = errors.New("credentials not rotatable")
+)
+
+// connectors *sql.DB 到创建其的 passwordConnector, 用于 RotateCredentials 查找, 连接池关闭时移除.
+var connectors sync.Map
+
+// openConnector 通过 connector 创建连接池, passwordConnector 创建的连接池可轮换账号密码.
+func openConnector(connector driver.Connector) *sql.DB {
+	sqlDB := sql.OpenDB(connector)
+	if c, ok := connector.(*passwordConnector); ok {
+		c.sqlDB = sqlDB
+		connectors.Store(sqlDB, c)
+	}
+	return sqlDB
+}
+
+// CredentialUpdate 定义轮换后的账号密码.
+type CredentialUpdate struct {
+	// 新账号, 为空时沿用原账号.
+	UserName string
+	// 新密码, 替代 Password 及 PasswordProvider.
+	Password string
+	// 大于 0 时临时将连接最大存活时间设为该值, 到期后恢复配置值, 用于尽快替换使用旧密码的连接.
+	CycleConnsWithin time.Duration
+	// 轮换前使用新账号密码建立一次连接, 失败时不轮换.
+	Verify bool
+}
+
+// rotatablePool 可轮换账号密码的连接池.
+type rotatablePool struct {
+	sqlDB     *sql.DB
+	connector *passwordConnector
+}
+
+// RotateCredentials 轮换指定库之后新建连接使用的账号密码, 不关闭已有连接, 进行中的事务不受影响.
+//
+// key 为库名, 包括 dbresolver 注册的从库在内, 库的全部连接池均轮换.
+// 仅配置 RotatableCredentials 或 PasswordProvider 并通过 DefaultMySQLDialector 或 PostgresDialector
+// 创建的连接池可轮换, 否则返回 ErrCredentialsNotRotatable. 库不存在时返回 ErrDBNotFound.
+func (p *TransProvider) RotateCredentials(ctx context.Context, key string, update CredentialUpdate) error {
+	db := p.dbByName(key)
+	if db == nil {
+		return fmt.Errorf("%w: %s", ErrDBNotFound, key)
+	}
+	pools := rotatablePools(db)
+	if len(pools) == 0 {
+		return fmt.Errorf("%w: %s", ErrCredentialsNotRotatable, key)
+	}
+	creds := &credentials{userName: update.UserName, password: update.Password}
+	if update.Verify {
+		for _, pool := range pools {
+			if err := pool.verify(ctx, creds); err != nil {
+				return err
+			}
+		}
+	}
+	for _, pool := range pools {
+		pool.connector.creds.Store(creds)
+		if update.CycleConnsWithin > 0 {
+			pool.cycle(update.CycleConnsWithin)
+		}
+	}
+	return nil
+}
+
+// verify 使用账号密码建立一次连接.
+func (r rotatablePool) verify(ctx context.Context, creds *credentials) error {
+	opts := r.connector.withCredentials(creds)
+	conn, err := r.connector.connect(ctx, opts, creds.password)
+	if err != nil {
+		return fmt.Errorf("verify credentials for %s: %w", opts.fullName(), err)
+	}
+	return conn.Close()
+}
+
+// cycle 临时缩短连接最大存活时间, d 后恢复配置值.
+//
+// 多次轮换时以最后一次为准, 停止之前的恢复定时器.
+func (r rotatablePool) cycle(d time.Duration) {
+	c := r.connector
+	c.cycleMut.Lock()
+	defer c.cycleMut.Unlock()
+	if c.cycleTimer != nil {
+		c.cycleTimer.Stop()
+	}
+	r.sqlDB.SetConnMaxLifetime(d)
+	lifetime := secs(c.opts.ConnMaxLifetimeInSecs)
+	var timer *time.Timer
+	timer = time.AfterFunc(d, func() {
+		c.cycleMut.Lock()
+		defer c.cycleMut.Unlock()
+		if c.cycleTimer != timer {
+			return
+		}
+		c.cycleTimer = nil
+		r.sqlDB.SetConnMaxLifetime(lifetime)
+	})
+	c.cycleTimer = timer
+}
+
+// rotatablePools 返回 DB 可轮换账号密码的连接池, 包括 dbresolver 注册的从库.
+func rotatablePools(db *gorm.DB) []rotatablePool {
+	var pools []rotatablePool
+	seen := make(map[*sql.DB]bool)
+	add := func(pool gorm.ConnPool) error {
+		if prepared, ok := pool.(*gorm.PreparedStmtDB); ok {
+			pool = prepared.ConnPool
+		}
+		sqlDB, ok := pool.(*sql.DB)
+		if !ok || seen[sqlDB] {
+			return nil
+		}
+		seen[sqlDB] = true
+		if c, ok := connectors.Load(sqlDB); ok {
+			pools = append(pools, rotatablePool{sqlDB: sqlDB, connector: c.(*passwordConnector)})
+		}
+		return nil
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		_ = add(sqlDB)
+	}
+	if resolver, ok := db.Config.Plugins[(&dbresolver.DBResolver{}).Name()].(*dbresolver.DBResolver); ok {
+		_ = resolver.Call(add)
+	}
+	return pools
+}
+
+// dbByName 返回库名对应的 DB, 依次查找已使用的 DB 及数据源持有的 DB.
+func (p *TransProvider) dbByName(key string) *gorm.DB {
+	if db, ok := p.metrics.dbs.Load(key); ok {
+		return db.(*gorm.DB)
+	}
+	db, _ := p.DB(key)
+	return db
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"sync"
+	"testing"
+	"time"
+)
+
+// passwordDriver 记录建立连接使用的连接串.
+type passwordDriver struct {
+	fake *fakeConnector
+	mut  sync.Mutex
+	dsns []string
+}
+
+func (d *passwordDriver) Open(dsn string) (driver.Conn, error) {
+	d.mut.Lock()
+	d.dsns = append(d.dsns, dsn)
+	d.mut.Unlock()
+	return d.fake.Connect(context.Background())
+}
+
+func (d *passwordDriver) lastDSN() string {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	return d.dsns[len(d.dsns)-1]
+}
+
+func newRotatableProvider(t *testing.T) (*TransProvider, *passwordDriver, *passwordConnector) {
+	drv := &passwordDriver{fake: &fakeConnector{}}
+	opts := &Options{UserName: Ptr("app"), Password: Ptr("old"), RotatableCredentials: true}
+	connector := NewPasswordConnector(drv, opts, func(opts *Options, password string) string {
+		return deref(opts.UserName) + ":" + password
+	})
+	sqlDB := openConnector(connector)
+	// 每次使用建立新连接.
+	sqlDB.SetMaxIdleConns(0)
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	return NewProvider(NewSource("main", db)), drv, connector.(*passwordConnector)
+}
+
+func TestRotateCredentialsNewConnections(t *testing.T) {
+	p, drv, _ := newRotatableProvider(t)
+	ctx := context.Background()
+	ping := func() {
+		t.Helper()
+		if err := p.UseWriteDB(ctx).Exec("SELECT 1").Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ping()
+	if got := drv.lastDSN(); got != "app:old" {
+		t.Fatalf("dsn = %q, want app:old", got)
+	}
+	if err := p.RotateCredentials(ctx, "main", CredentialUpdate{UserName: "app2", Password: "new", Verify: true}); err != nil {
+		t.Fatal(err)
+	}
+	ping()
+	if got := drv.lastDSN(); got != "app2:new" {
+		t.Errorf("dsn after rotation = %q, want app2:new", got)
+	}
+	if err := p.RotateCredentials(ctx, "missing", CredentialUpdate{}); err == nil {
+		t.Error("RotateCredentials for unknown key succeeded")
+	}
+}
+
+func TestRotateCredentialsCycleTimers(t *testing.T) {
+	p, _, c := newRotatableProvider(t)
+	ctx := context.Background()
+
+	if err := p.RotateCredentials(ctx, "main", CredentialUpdate{Password: "a", CycleConnsWithin: 10 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.RotateCredentials(ctx, "main", CredentialUpdate{Password: "b", CycleConnsWithin: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	// 第一次轮换的定时器已停止, 不恢复第二次轮换缩短的存活时间.
+	time.Sleep(30 * time.Millisecond)
+	c.cycleMut.Lock()
+	pending := c.cycleTimer != nil
+	c.cycleMut.Unlock()
+	if !pending {
+		t.Fatal("first rotation timer cleared the second rotation")
+	}
+
+	// 关闭连接池时停止定时器并移除轮换记录.
+	sqlDB := c.sqlDB
+	if err := sqlDB.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := connectors.Load(sqlDB); ok {
+		t.Error("closed pool still registered for rotation")
+	}
+	if c.cycleTimer != nil {
+		t.Error("cycle timer not stopped on Close")
+	}
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// UnknownDBKeyError 代表路由到未配置的库, 通过 errors.Is 可判断为 ErrDBNotFound.
+type UnknownDBKeyError struct {
+	// 路由返回的 Key.
+	Requested string
+	// 已配置的 Key, 按字典序排列.
+	Known []string
+}
+
+func (e *UnknownDBKeyError) Error() string {
+	return fmt.Sprintf("%v: unknown key %q, known keys [%s]", ErrDBNotFound, e.Requested, strings.Join(e.Known, ", "))
+}
+
+func (e *UnknownDBKeyError) Unwrap() error {
+	return ErrDBNotFound
+}
+
+// RouteOption 定义按 Key 路由选项.
+type RouteOption func(*routeOptions)
+
+type routeOptions struct {
+	fallback string
+}
+
+// WithFallbackKey 设置路由到未配置 Key 时使用的 Key, 如 "main.default".
+//
+// 库名同样使用 fallback, 同一 context 的事务及非事务操作使用同一库.
+func WithFallbackKey(fallback string) RouteOption {
+	return func(o *routeOptions) {
+		o.fallback = fallback
+	}
+}
+
+// fallbackRouter 返回未配置 Key 时路由到 fallback 的路由函数, fallback 为空时返回 router.
+//
+// fallback 未配置时返回 *UnknownDBKeyError.
+func fallbackRouter(dbs map[string]*gorm.DB, router func(context.Context) string, opts []RouteOption) (func(context.Context) string, error) {
+	o := &routeOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.fallback == "" {
+		return router, nil
+	}
+	if _, ok := dbs[o.fallback]; !ok {
+		return nil, &UnknownDBKeyError{Requested: o.fallback, Known: sortedKeys(dbs)}
+	}
+	return func(ctx context.Context) string {
+		key := router(ctx)
+		if _, ok := dbs[key]; !ok {
+			return o.fallback
+		}
+		return key
+	}, nil
+}
+
+// unknownKey 返回 key 未配置时的错误, 已配置时返回 nil.
+func unknownKey(dbs map[string]*gorm.DB, key string) error {
+	if _, ok := dbs[key]; ok {
+		return nil
+	}
+	return &UnknownDBKeyError{Requested: key, Known: sortedKeys(dbs)}
+}
+
+// lookupError 返回按 Key 路由未命中的错误, 通过工厂函数创建的数据源返回 nil.
+func (s *source) lookupError(ctx context.Context) error {
+	if s.dbs == nil {
+		return nil
+	}
+	return unknownKey(s.dbs, s.writeDBName(ctx))
+}
+
+func (s *DynamicSource) lookupError(ctx context.Context) error {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return unknownKey(s.dbs, s.router(ctx))
+}
+
+func (s *ReloadableSource) lookupError(ctx context.Context) error {
+	return unknownKey(s.state.Load().dbs, s.router(ctx))
+}
+
+func (s *namedSource) lookupError(ctx context.Context) error {
+	if inner, ok := s.Source.(lookupErrorSource); ok {
+		return inner.lookupError(ctx)
+	}
+	return nil
+}
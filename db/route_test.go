@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// tenantRouter 按 tenantCtxKey 路由到 "main." 前缀的库.
+func tenantRouter(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantCtxKey{}).(string)
+	return "main." + tenant
+}
+
+// newTenantSource 创建 main.a, main.default 两个库的按 Key 路由数据源.
+func newTenantSource(t *testing.T, opts ...RouteOption) (Source, error) {
+	t.Helper()
+	dir := t.TempDir()
+	o := MultiRWOptions{
+		"main.a":       {Write: &Options{Dialect: DialectSQLite, DBName: Ptr(filepath.Join(dir, "a.db"))}},
+		"main.default": {Write: &Options{Dialect: DialectSQLite, DBName: Ptr(filepath.Join(dir, "default.db"))}},
+	}
+	return o.ToSource(SQLiteDialector(), &gorm.Config{Logger: logger.Discard}, tenantRouter, opts...)
+}
+
+func TestRouteUnknownKey(t *testing.T) {
+	s, err := newTenantSource(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewProvider(s)
+	t.Cleanup(func() { _ = p.ForceClose(context.Background()) })
+	ctx := context.WithValue(context.Background(), tenantCtxKey{}, "b")
+
+	check := func(name string, err error) {
+		t.Helper()
+		var unknown *UnknownDBKeyError
+		if !errors.As(err, &unknown) {
+			t.Fatalf("%s err = %v, want *UnknownDBKeyError", name, err)
+		}
+		if unknown.Requested != "main.b" || !reflect.DeepEqual(unknown.Known, []string{"main.a", "main.default"}) {
+			t.Errorf("%s err = %+v", name, unknown)
+		}
+		if !errors.Is(err, ErrDBNotFound) {
+			t.Errorf("%s err is not ErrDBNotFound", name)
+		}
+	}
+	_, err = p.TryUseDB(ctx)
+	check("TryUseDB", err)
+	_, err = p.TryUseWriteDB(ctx)
+	check("TryUseWriteDB", err)
+	func() {
+		defer func() {
+			err, _ := recover().(error)
+			check("UseDB panic", err)
+		}()
+		p.UseDB(ctx)
+	}()
+
+	known := context.WithValue(context.Background(), tenantCtxKey{}, "a")
+	if _, err := p.TryUseDB(known); err != nil {
+		t.Errorf("TryUseDB(main.a) err = %v", err)
+	}
+}
+
+func TestRouteFallbackKey(t *testing.T) {
+	s, err := newTenantSource(t, WithFallbackKey("main.default"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewProvider(s)
+	t.Cleanup(func() { _ = p.ForceClose(context.Background()) })
+	ctx := context.WithValue(context.Background(), tenantCtxKey{}, "b")
+
+	db, err := p.TryUseDB(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fallback, _ := p.DB("main.default"); db.Statement.ConnPool != fallback.Statement.ConnPool {
+		t.Error("unknown tenant not routed to main.default")
+	}
+	if name := s.getWriteDBName(ctx); name != "main.default" {
+		t.Errorf("db name = %q, want main.default", name)
+	}
+
+	// fallback 未配置时创建失败.
+	_, err = newTenantSource(t, WithFallbackKey("main.missing"))
+	var unknown *UnknownDBKeyError
+	if !errors.As(err, &unknown) || unknown.Requested != "main.missing" {
+		t.Errorf("ToSource with unknown fallback err = %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+	"sync/atomic"
+)
+
+// savepointSeqKey 保存点序号在事务范围数据中的 Key.
+type savepointSeqKey struct{}
+
+// savepointTransaction 以保存点执行嵌套事务.
+//
+// 回调返回错误或 panic 时显式回滚到保存点, 外层事务可继续使用.
+// panic 时回滚到保存点失败, 以包含回滚错误的 error 重新 panic, 不静默忽略.
+func (p *TransProvider) savepointTransaction(ctx context.Context, db *gorm.DB, callback func(db interface{}, bindCtx func(context.Context)) error) error {
+	name := p.nextSavepointName(ctx)
+	if err := db.SavePoint(name).Error; err != nil {
+		return err
+	}
+	panicked := true
+	defer func() {
+		if !panicked {
+			return
+		}
+		e := recover()
+		// runtime.Goexit 时 recover 返回 nil, 回滚后继续退出, 不转为 panic.
+		if e == nil {
+			_ = db.RollbackTo(name)
+			return
+		}
+		if err := db.RollbackTo(name).Error; err != nil {
+			panic(fmt.Errorf("%v; rollback to savepoint %s: %w", e, name, err))
+		}
+		panic(e)
+	}()
+	err := callback(db, func(ctx context.Context) {
+		db.Statement.Context = ctx
+	})
+	panicked = false
+	if err != nil {
+		if rbErr := db.RollbackTo(name).Error; rbErr != nil {
+			return fmt.Errorf("%w; rollback to savepoint %s: %v", err, name, rbErr)
+		}
+	}
+	return err
+}
+
+// nextSavepointName 返回当前根事务内唯一的保存点名.
+func (p *TransProvider) nextSavepointName(ctx context.Context) string {
+	var seq int64
+	if tc := p.findTransContext(ctx); tc != nil {
+		v, _ := tc.LoadOrStore(savepointSeqKey{}, new(int64))
+		seq = atomic.AddInt64(v.(*int64), 1)
+	}
+	return fmt.Sprintf("mtx_sp_%d", seq)
+}
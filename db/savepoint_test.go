@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestSavepointRollbackOnPanic(t *testing.T) {
+	base := newSQLiteProvider(t, new(tenantItem))
+	p := NewProviderWithOptions(base.loadSource(), WithNestedSavepoint())
+	ctx := context.Background()
+	create := func(ctx context.Context, name string) {
+		t.Helper()
+		if err := p.UseDB(ctx).Create(&tenantItem{Name: name}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var recovered interface{}
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		create(ctx, "before")
+		func() {
+			defer func() {
+				recovered = recover()
+			}()
+			_ = p.Transaction(ctx, func(ctx context.Context) error {
+				create(ctx, "nested")
+				panic("nested failed")
+			})
+		}()
+		// 外层事务可继续使用.
+		create(ctx, "after")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 嵌套事务 panic 回滚到保存点, 外层事务正常提交.
+	if recovered != "nested failed" {
+		t.Errorf("recovered %v, want nested failed", recovered)
+	}
+	var names []string
+	if err := p.UseDB(ctx).Model(new(tenantItem)).Order("id").Pluck("name", &names).Error; err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"before", "after"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+}
+
+func TestSavepointRollbackOnError(t *testing.T) {
+	base := newSQLiteProvider(t, new(tenantItem))
+	p := NewProviderWithOptions(base.loadSource(), WithNestedSavepoint())
+	ctx := context.Background()
+
+	var nestedErr error
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		for i := 0; i < 2; i++ {
+			nestedErr = p.Transaction(ctx, func(ctx context.Context) error {
+				if err := p.UseDB(ctx).Create(&tenantItem{Name: "nested"}).Error; err != nil {
+					return err
+				}
+				return errors.New("nested failed")
+			})
+		}
+		return p.UseDB(ctx).Create(&tenantItem{Name: "outer"}).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nestedErr == nil {
+		t.Error("nested transaction error not returned")
+	}
+	if n := countItems(t, p); n != 1 {
+		t.Errorf("%d rows after commit, want only the outer row", n)
+	}
+}
+
+func TestSavepointGoexit(t *testing.T) {
+	base := newSQLiteProvider(t, new(tenantItem))
+	p := NewProviderWithOptions(base.loadSource(), WithNestedSavepoint())
+	ctx := context.Background()
+
+	// 嵌套事务内 runtime.Goexit 不转为 panic.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = p.Transaction(ctx, func(ctx context.Context) error {
+			return p.Transaction(ctx, func(ctx context.Context) error {
+				if err := p.UseDB(ctx).Create(&tenantItem{Name: "nested"}).Error; err != nil {
+					return err
+				}
+				runtime.Goexit()
+				return nil
+			})
+		})
+		t.Error("transaction returned after Goexit")
+	}()
+	<-done
+	if n := countItems(t, p); n != 0 {
+		t.Errorf("%d rows after Goexit, want 0", n)
+	}
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"strings"
+)
+
+var (
+	ErrSchemaRoutingUnsupported = errors.New("schema routing: connection pool cannot be pinned")
+)
+
+// schemaRoutingPlugin 在语句所在连接上设置 PostgreSQL search_path, 执行后恢复原值.
+//
+// 事务内设置作用至事务结束.
+var schemaRoutingPlugin = connSettingPlugin{
+	name:       "mini_transaction:schema_routing",
+	get:        "SELECT current_setting('search_path')",
+	newValue:   func() interface{} { return new(string) },
+	set:        "SELECT set_config('search_path', $1, false)",
+	setLocal:   "SELECT set_config('search_path', $1, true)",
+	unpinnable: ErrSchemaRoutingUnsupported,
+}
+
+// schemaRoutingSource 按 context 中的 schema 设置 search_path.
+type schemaRoutingSource struct {
+	inner     Source
+	schemaKey interface{}
+}
+
+// NewSchemaRoutingSource 创建按 context 选择 PostgreSQL schema 的数据源, 用于 schema-per-tenant 隔离.
+//
+// ctx.Value(schemaKey) 为非空字符串时, 读写库语句执行前在同一连接上将 search_path 设为该 schema,
+// 执行后恢复原值, 事务外语句独占一个连接执行. schema 作为单个标识符引用, 不可包含多个 schema.
+// 未设置 schema 时使用 inner 的默认 search_path.
+//
+// 库名同 inner, 事务内 search_path 作用至事务结束, 语句使用开启事务时 context 的 schema.
+// 事务外的 Row, Rows 及 Raw().Scan 等返回结果集的语句无法在同一连接上恢复, 返回 ErrRowStatementUnsupported,
+// 避免在默认 schema 上执行; 使用 Raw().Find 或在事务内执行.
+func NewSchemaRoutingSource(inner Source, schemaKey interface{}) Source {
+	return &schemaRoutingSource{inner: inner, schemaKey: schemaKey}
+}
+
+// withSchema 为 DB 绑定 context 中的 schema.
+func (s *schemaRoutingSource) withSchema(ctx context.Context, db *gorm.DB) *gorm.DB {
+	schema, _ := ctx.Value(s.schemaKey).(string)
+	if db == nil || schema == "" {
+		return db
+	}
+	if err := checkPlugin(db, schemaRoutingPlugin.name); err != nil {
+		_ = db.AddError(err)
+		return db
+	}
+	return db.Set(schemaRoutingPlugin.name, quoteIdentifier(schema))
+}
+
+// quoteIdentifier 以双引号引用 PostgreSQL 标识符.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (s *schemaRoutingSource) getWriteDBName(ctx context.Context) string {
+	return s.inner.getWriteDBName(ctx)
+}
+
+func (s *schemaRoutingSource) getWriteDB(ctx context.Context) *gorm.DB {
+	return s.withSchema(ctx, s.inner.getWriteDB(ctx))
+}
+
+func (s *schemaRoutingSource) getReadDBName(ctx context.Context) string {
+	return s.inner.getReadDBName(ctx)
+}
+
+func (s *schemaRoutingSource) getReadDB(ctx context.Context) *gorm.DB {
+	return s.withSchema(ctx, s.inner.getReadDB(ctx))
+}
+
+func (s *schemaRoutingSource) Close(ctx context.Context) error {
+	return s.inner.Close(ctx)
+}
+
+func (s *schemaRoutingSource) Dialector() gorm.Dialector {
+	return s.inner.Dialector()
+}
+
+func (s *schemaRoutingSource) lookupError(ctx context.Context) error {
+	if ls, ok := s.inner.(lookupErrorSource); ok {
+		return ls.lookupError(ctx)
+	}
+	return nil
+}
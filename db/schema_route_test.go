@@ -0,0 +1,160 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"testing"
+)
+
+type tenantKey struct{}
+
+type tenantItem struct {
+	ID   int64
+	Name string
+}
+
+func newSchemaRoutingProvider(t *testing.T) (*TransProvider, *fakeConnector) {
+	sqlDB, fake := newFakeDB(map[string]interface{}{
+		"SELECT current_setting": "public",
+		"SELECT @@SESSION":       int64(50),
+	})
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewProvider(NewSchemaRoutingSource(NewSource("tenant", db), tenantKey{}))
+	return p, fake
+}
+
+func TestSchemaRoutingOutsideTransaction(t *testing.T) {
+	p, fake := newSchemaRoutingProvider(t)
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+
+	var items []tenantItem
+	if err := p.UseDB(ctx).Find(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+	log := fake.statements()
+	want := []string{
+		"SELECT current_setting('search_path')",
+		"SELECT set_config('search_path', $1, false)",
+		`SELECT * FROM "tenant_items"`,
+		"SELECT set_config('search_path', $1, false)",
+	}
+	if len(log) != len(want) {
+		t.Fatalf("statements = %+v", log)
+	}
+	for i, stmt := range log {
+		if stmt.query != want[i] {
+			t.Errorf("statement %d = %q, want %q", i, stmt.query, want[i])
+		}
+		if stmt.conn != log[0].conn {
+			t.Errorf("statement %d ran on conn %d, want %d", i, stmt.conn, log[0].conn)
+		}
+	}
+	if got := log[1].args[0]; got != `"acme"` {
+		t.Errorf("search_path = %v", got)
+	}
+	if got := log[3].args[0]; got != "public" {
+		t.Errorf("restored search_path = %v", got)
+	}
+
+	// 未设置 schema 时不修改 search_path.
+	if err := p.UseDB(context.Background()).Find(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+	if log := fake.statements(); len(log) != 1 {
+		t.Errorf("statements without schema = %+v", log)
+	}
+}
+
+func TestSchemaRoutingRowStatement(t *testing.T) {
+	p, fake := newSchemaRoutingProvider(t)
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+
+	var n int
+	err := p.UseDB(ctx).Raw("SELECT count(*) FROM tenant_items").Scan(&n).Error
+	if !errors.Is(err, ErrRowStatementUnsupported) {
+		t.Fatalf("Scan error = %v, want ErrRowStatementUnsupported", err)
+	}
+	if _, err := p.UseDB(ctx).Raw("SELECT 1").Rows(); !errors.Is(err, ErrRowStatementUnsupported) {
+		t.Fatalf("Rows error = %v, want ErrRowStatementUnsupported", err)
+	}
+	if log := fake.statements(); len(log) != 0 {
+		t.Errorf("statements = %+v", log)
+	}
+}
+
+func TestSchemaRoutingInTransaction(t *testing.T) {
+	p, fake := newSchemaRoutingProvider(t)
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		var items []tenantItem
+		if err := p.UseDB(ctx).Find(&items).Error; err != nil {
+			return err
+		}
+		var n int
+		return p.UseDB(ctx).Raw("SELECT count(*) FROM tenant_items").Scan(&n).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"BEGIN",
+		"SELECT set_config('search_path', $1, true)",
+		`SELECT * FROM "tenant_items"`,
+		"SELECT set_config('search_path', $1, true)",
+		"SELECT count(*) FROM tenant_items",
+		"COMMIT",
+	}
+	log := fake.statements()
+	if len(log) != len(want) {
+		t.Fatalf("statements = %+v", log)
+	}
+	for i, stmt := range log {
+		if stmt.query != want[i] {
+			t.Errorf("statement %d = %q, want %q", i, stmt.query, want[i])
+		}
+	}
+}
+
+func TestSchemaRoutingSharesPinnedConn(t *testing.T) {
+	p, fake := newSchemaRoutingProvider(t)
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	ctx = WithRowLockTimeout(ctx, 3)
+
+	if err := p.UseWriteDB(ctx).Create(&tenantItem{Name: "a"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	log := fake.statements()
+	restores := 0
+	for _, stmt := range log {
+		if stmt.conn != log[0].conn {
+			t.Fatalf("statements ran on multiple conns: %+v", log)
+		}
+		if len(stmt.args) == 1 && (stmt.args[0] == "public" || stmt.args[0] == int64(50)) {
+			restores++
+		}
+	}
+	if restores != 2 {
+		t.Errorf("restores = %d, statements = %+v", restores, log)
+	}
+}
+
+func TestSchemaRoutingLookupError(t *testing.T) {
+	dynamic := NewDynamicSource(func(ctx context.Context) string { return "missing" }, nil)
+	p := NewProvider(NewSchemaRoutingSource(dynamic, tenantKey{}))
+
+	_, err := p.TryUseDB(context.Background())
+	var unknown *UnknownDBKeyError
+	if !errors.As(err, &unknown) || unknown.Requested != "missing" {
+		t.Fatalf("error = %v, want *UnknownDBKeyError", err)
+	}
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"sync"
+)
+
+// SessionPool 限制并发使用的 DB 会话数.
+//
+// *gorm.DB 链式调用不可在多个 goroutine 间共享, 并发任务各自获取 Session 使用.
+type SessionPool struct {
+	p     *TransProvider
+	slots chan struct{}
+}
+
+// Session 代表从 SessionPool 获取的 DB 会话, 仅在获取的 goroutine 中使用.
+type Session struct {
+	db      *gorm.DB
+	release func()
+	once    sync.Once
+}
+
+// NewSessionPool 创建会话池, maxSessions 为最大并发会话数, 小于等于 0 时不限制.
+func NewSessionPool(base *TransProvider, maxSessions int) *SessionPool {
+	pool := &SessionPool{p: base}
+	if maxSessions > 0 {
+		pool.slots = make(chan struct{}, maxSessions)
+	}
+	return pool
+}
+
+// AcquireSession 获取会话, 达到最大会话数时等待释放, context 结束时返回 ctx.Err().
+//
+// context 在事务内时返回事务 DB, 不占用会话数.
+func (s *SessionPool) AcquireSession(ctx context.Context) (*Session, error) {
+	if s.p.InTransaction(ctx) {
+		db, err := s.p.TryUseDB(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &Session{db: db, release: func() {}}, nil
+	}
+	if s.slots != nil {
+		select {
+		case s.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release := func() {
+		if s.slots != nil {
+			<-s.slots
+		}
+	}
+	db, err := s.p.TryUseDB(ctx)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return &Session{db: db, release: release}, nil
+}
+
+// DB 返回会话 DB.
+func (s *Session) DB() *gorm.DB {
+	return s.db
+}
+
+// Release 归还会话, 重复调用无效果.
+func (s *Session) Release() {
+	s.once.Do(s.release)
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSessionPoolBlocking(t *testing.T) {
+	p := newSQLiteProvider(t, new(tenantItem))
+	pool := NewSessionPool(p, 1)
+	ctx := context.Background()
+
+	s1, err := pool.AcquireSession(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 达到最大会话数时等待, context 结束返回错误.
+	timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := pool.AcquireSession(timeoutCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("acquire on full pool = %v, want context.DeadlineExceeded", err)
+	}
+
+	acquired := make(chan *Session, 1)
+	go func() {
+		s, err := pool.AcquireSession(ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- s
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired session before release")
+	case <-time.After(20 * time.Millisecond):
+	}
+	s1.Release()
+	// 重复释放不归还额外名额.
+	s1.Release()
+	s2 := <-acquired
+	if err := s2.DB().Create(&tenantItem{Name: "a"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if held := len(pool.slots); held != 1 {
+		t.Errorf("held slots = %d after double release, want 1", held)
+	}
+	s2.Release()
+}
+
+func TestSessionPoolInTransaction(t *testing.T) {
+	p := newSQLiteProvider(t, new(tenantItem))
+	pool := NewSessionPool(p, 1)
+	ctx := context.Background()
+
+	held, err := pool.AcquireSession(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Release()
+	// 事务内返回事务 DB, 不占用会话数.
+	err = p.Transaction(ctx, func(ctx context.Context) error {
+		s, err := pool.AcquireSession(ctx)
+		if err != nil {
+			return err
+		}
+		defer s.Release()
+		if s.DB().Statement.ConnPool != p.UseDB(ctx).Statement.ConnPool {
+			t.Error("session in transaction does not use the transaction connection")
+		}
+		return s.DB().Create(&tenantItem{Name: "a"}).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"reflect"
+)
+
+var (
+	ErrNotSoftDeletable = errors.New("model has no gorm.DeletedAt field")
+)
+
+// SoftDeleteWithUndo 按主键软删除记录, 返回恢复记录的 undo.
+//
+// model 需包含 gorm.DeletedAt 字段及主键值. undo 将删除时间字段置为 NULL, 不触发模型钩子.
+// 记录不存在或调用前已被软删除时不影响任何行, undo 不执行操作, 避免恢复他人删除的记录.
+//
+// 在事务内时, 事务回滚即恢复记录, 无需调用 undo.
+func SoftDeleteWithUndo(ctx context.Context, p Provider, model interface{}) (func(context.Context) error, error) {
+	db := p.UseWriteDB(ctx)
+	field, err := deletedAtField(db, model)
+	if err != nil {
+		return nil, err
+	}
+	result := db.Delete(model)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return func(context.Context) error { return nil }, nil
+	}
+	undo := func(ctx context.Context) error {
+		return p.UseWriteDB(ctx).Unscoped().Model(model).UpdateColumn(field.DBName, nil).Error
+	}
+	return undo, nil
+}
+
+// deletedAtField 返回模型的 gorm.DeletedAt 字段.
+func deletedAtField(db *gorm.DB, model interface{}) (*schema.Field, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	deletedAtType := reflect.TypeOf(gorm.DeletedAt{})
+	for _, field := range stmt.Schema.Fields {
+		if field.FieldType == deletedAtType && field.DBName != "" {
+			return field, nil
+		}
+	}
+	return nil, ErrNotSoftDeletable
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"testing"
+)
+
+type softItem struct {
+	ID        int64
+	Name      string
+	DeletedAt gorm.DeletedAt
+}
+
+func TestSoftDeleteWithUndo(t *testing.T) {
+	p := newSQLiteProvider(t, new(softItem))
+	ctx := context.Background()
+	if err := p.UseDB(ctx).Create(&softItem{ID: 1, Name: "a"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	visible := func() int64 {
+		var count int64
+		if err := p.UseDB(ctx).Model(new(softItem)).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	undo, err := SoftDeleteWithUndo(ctx, p, &softItem{ID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := visible(); n != 0 {
+		t.Fatalf("%d rows visible after soft delete, want 0", n)
+	}
+	// 已删除的记录再次删除, 返回的 undo 不恢复记录.
+	noop, err := SoftDeleteWithUndo(ctx, p, &softItem{ID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := noop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := visible(); n != 0 {
+		t.Errorf("%d rows visible after no-op undo, want 0", n)
+	}
+	if err := undo(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := visible(); n != 1 {
+		t.Errorf("%d rows visible after undo, want 1", n)
+	}
+
+	// 事务回滚时恢复记录.
+	errRollback := errors.New("rollback")
+	err = p.Transaction(ctx, func(ctx context.Context) error {
+		if _, err := SoftDeleteWithUndo(ctx, p, &softItem{ID: 1}); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("err = %v, want %v", err, errRollback)
+	}
+	if n := visible(); n != 1 {
+		t.Errorf("%d rows visible after rollback, want 1", n)
+	}
+
+	if _, err := SoftDeleteWithUndo(ctx, p, &tenantItem{ID: 1}); !errors.Is(err, ErrNotSoftDeletable) {
+		t.Errorf("err = %v, want ErrNotSoftDeletable", err)
+	}
+}
@@ -15,6 +15,36 @@ type Source interface {
 	getReadDBName(context.Context) string
 	// 获取读库.
 	getReadDB(context.Context) *gorm.DB
+	// Close 关闭数据源管理的全部连接池, 重复调用返回首次结果.
+	//
+	// 通过工厂函数创建的数据源不持有 DB, 不关闭.
+	Close(ctx context.Context) error
+	// Dialector 返回数据源的数据库方言, 用于编写区分方言的逻辑.
+	//
+	// 无法确定时返回 nil, 如通过工厂函数创建或多个库方言不一致.
+	Dialector() gorm.Dialector
+}
+
+// NamedSource 代表具名数据源.
+type NamedSource interface {
+	Source
+	// Name 返回数据源名.
+	Name() string
+}
+
+// namedSource 为数据源附加名称.
+type namedSource struct {
+	Source
+	name string
+}
+
+// NewNamedSource 为数据源附加名称, 如多层配置中的外层 Key.
+func NewNamedSource(name string, source Source) NamedSource {
+	return &namedSource{Source: source, name: name}
+}
+
+func (s *namedSource) Name() string {
+	return s.name
 }
 
 // source 代表数据源.
@@ -23,6 +53,11 @@ type source struct {
 	writeDB     func(context.Context) *gorm.DB
 	readDBName  func(context.Context) string
 	readDB      func(context.Context) *gorm.DB
+	// 数据源持有的 DB, Close 时关闭.
+	dbs    map[string]*gorm.DB
+	closer dbCloser
+	// 构造时使用的数据库方言.
+	dialector gorm.Dialector
 }
 
 // NewSource 创建单库数据源.
@@ -35,12 +70,33 @@ func NewWriteReadSource(
 	writeDBName string, writeDB *gorm.DB,
 	readDBName string, readDB *gorm.DB,
 ) Source {
-	return NewWriteReadSourceWithFunc(
+	s := NewWriteReadSourceWithFunc(
 		func(_ context.Context) string { return writeDBName },
 		func(_ context.Context) *gorm.DB { return writeDB },
 		func(_ context.Context) string { return readDBName },
 		func(_ context.Context) *gorm.DB { return readDB },
-	)
+	).(*source)
+	s.dbs = map[string]*gorm.DB{writeDBName: writeDB, readDBName: readDB}
+	s.dialector = commonDialector(s.dbs)
+	installSourcePlugins(s.dbs)
+	return s
+}
+
+// NewWriteReadSourceWithFallback 创建读写分离数据源, 未配置读库时读写均使用写库.
+//
+// readDB 为 nil 时读库名同写库名, 写库配置了 Logger 时输出警告.
+func NewWriteReadSourceWithFallback(
+	writeDBName string, writeDB *gorm.DB,
+	readDBName string, readDB *gorm.DB,
+) Source {
+	if readDB == nil {
+		if writeDB != nil && writeDB.Logger != nil {
+			writeDB.Logger.Warn(context.Background(),
+				"read database %q not configured, fallback to write database %q", readDBName, writeDBName)
+		}
+		readDBName, readDB = writeDBName, writeDB
+	}
+	return NewWriteReadSource(writeDBName, writeDB, readDBName, readDB)
 }
 
 // NewSourceWithFunc 通过工厂函数创建数据源.
@@ -86,3 +142,88 @@ func (s *source) getReadDBName(ctx context.Context) string {
 func (s *source) getReadDB(ctx context.Context) *gorm.DB {
 	return s.readDB(ctx)
 }
+
+func (s *source) Close(context.Context) error {
+	return s.closer.close(s.dbs)
+}
+
+func (s *source) Dialector() gorm.Dialector {
+	return s.dialector
+}
+
+// commonDialector 返回 dbs 共同的数据库方言, 方言不一致时返回 nil.
+func commonDialector(dbs map[string]*gorm.DB) gorm.Dialector {
+	var dialector gorm.Dialector
+	for _, db := range dbs {
+		if db == nil || db.Dialector == nil {
+			continue
+		}
+		if dialector == nil {
+			dialector = db.Dialector
+			continue
+		}
+		if dialector.Name() != db.Dialector.Name() {
+			return nil
+		}
+	}
+	return dialector
+}
+
+// SecondarySourceFlag 切换到次数据源的 context 标记值.
+const SecondarySourceFlag = "secondary"
+
+// featureFlagSource 按 context 标记在主次数据源间切换.
+type featureFlagSource struct {
+	primary   Source
+	secondary Source
+	flagKey   interface{}
+}
+
+// NewFeatureFlagSource 创建按 context 标记切换的数据源.
+//
+// ctx.Value(flagKey) 为 "secondary" 时使用 secondary, 否则使用 primary.
+//
+// 用于数据库迁移时灰度切换数据源.
+func NewFeatureFlagSource(primary Source, secondary Source, flagKey interface{}) Source {
+	return &featureFlagSource{
+		primary:   primary,
+		secondary: secondary,
+		flagKey:   flagKey,
+	}
+}
+
+// WithSecondarySource 标记 context 使用次数据源.
+func WithSecondarySource(ctx context.Context, flagKey interface{}) context.Context {
+	return context.WithValue(ctx, flagKey, SecondarySourceFlag)
+}
+
+func (s *featureFlagSource) pick(ctx context.Context) Source {
+	if flag, ok := ctx.Value(s.flagKey).(string); ok && flag == SecondarySourceFlag {
+		return s.secondary
+	}
+	return s.primary
+}
+
+func (s *featureFlagSource) getWriteDBName(ctx context.Context) string {
+	return s.pick(ctx).getWriteDBName(ctx)
+}
+
+func (s *featureFlagSource) getWriteDB(ctx context.Context) *gorm.DB {
+	return s.pick(ctx).getWriteDB(ctx)
+}
+
+func (s *featureFlagSource) getReadDBName(ctx context.Context) string {
+	return s.pick(ctx).getReadDBName(ctx)
+}
+
+func (s *featureFlagSource) getReadDB(ctx context.Context) *gorm.DB {
+	return s.pick(ctx).getReadDB(ctx)
+}
+
+func (s *featureFlagSource) Close(ctx context.Context) error {
+	return closeSources(ctx, s.primary, s.secondary)
+}
+
+func (s *featureFlagSource) Dialector() gorm.Dialector {
+	return s.primary.Dialector()
+}
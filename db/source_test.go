@@ -0,0 +1,176 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type migrationFlagKey struct{}
+
+func TestFeatureFlagSource(t *testing.T) {
+	primary := newSQLiteProvider(t, new(tenantItem))
+	secondary := newSQLiteProvider(t, new(tenantItem))
+	p := NewProvider(NewFeatureFlagSource(primary.loadSource(), secondary.loadSource(), migrationFlagKey{}))
+	ctx := context.Background()
+	flagged := WithSecondarySource(ctx, migrationFlagKey{})
+
+	if err := p.UseDB(ctx).Create(&tenantItem{Name: "primary"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	err := p.Transaction(flagged, func(ctx context.Context) error {
+		items := []tenantItem{{Name: "secondary"}, {Name: "secondary"}}
+		return p.UseDB(ctx).Create(&items).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 其他值不切换数据源.
+	other := context.WithValue(ctx, migrationFlagKey{}, "primary")
+	if err := p.UseDB(other).Create(&tenantItem{Name: "primary"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if n := countItems(t, primary); n != 2 {
+		t.Errorf("primary has %d rows, want 2", n)
+	}
+	if n := countItems(t, secondary); n != 2 {
+		t.Errorf("secondary has %d rows, want 2", n)
+	}
+	var n int64
+	if err := p.UseReadDB(flagged).Model(new(tenantItem)).Where("name = ?", "secondary").Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("read from secondary counted %d rows, want 2", n)
+	}
+}
+
+func TestToSourceMap(t *testing.T) {
+	dir := t.TempDir()
+	o := MultiRWOptions{
+		"orders": {Write: &Options{Dialect: DialectSQLite, DBName: Ptr(filepath.Join(dir, "orders.db"))}},
+		"users":  {Write: &Options{Dialect: DialectSQLite, DBName: Ptr(filepath.Join(dir, "users.db"))}},
+	}
+	sources, err := o.ToSourceMap(SQLiteDialector(), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for key, s := range sources {
+		p := NewProvider(NewNamedSource("tech", s))
+		t.Cleanup(func() { _ = p.ForceClose(ctx) })
+		if name := s.getWriteDBName(ctx); name != key {
+			t.Errorf("source %s db name = %q", key, name)
+		}
+		if err := p.UseDB(ctx).AutoMigrate(new(tenantItem)); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.UseDB(ctx).Create(&tenantItem{Name: key}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(sources) != 2 {
+		t.Fatalf("sources = %d, want 2", len(sources))
+	}
+
+	// 各数据源连接各自的库.
+	for key := range o {
+		opts := &Options{DBName: Ptr(filepath.Join(dir, key+".db"))}
+		db, err := opts.OpenDB(SQLiteDialector(), &gorm.Config{Logger: logger.Discard})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		if err := db.Model(new(tenantItem)).Pluck("name", &names).Error; err != nil {
+			t.Fatal(err)
+		}
+		closeDB(db, make(map[interface{}]bool))
+		if len(names) != 1 || names[0] != key {
+			t.Errorf("%s.db rows = %v, want [%s]", key, names, key)
+		}
+	}
+	if s, ok := NewProvider(NewNamedSource("tech", sources["orders"])).loadSource().(NamedSource); !ok || s.Name() != "tech" {
+		t.Error("named source name not preserved")
+	}
+}
+
+// warnLogger 记录 Warn 日志.
+type warnLogger struct {
+	logger.Interface
+	warnings []string
+}
+
+func (l *warnLogger) Warn(_ context.Context, msg string, args ...interface{}) {
+	l.warnings = append(l.warnings, fmt.Sprintf(msg, args...))
+}
+
+func TestWriteReadSourceWithFallback(t *testing.T) {
+	base := newSQLiteProvider(t, new(tenantItem))
+	writeDB := base.UseWriteDB(context.Background())
+	log := &warnLogger{Interface: logger.Discard}
+	writeDB.Logger = log
+
+	s := NewWriteReadSourceWithFallback("main", writeDB, "replica", nil)
+	ctx := context.Background()
+	if got := s.getReadDB(ctx); got != writeDB {
+		t.Errorf("getReadDB = %p, want write db %p", got, writeDB)
+	}
+	if name := s.getReadDBName(ctx); name != "main" {
+		t.Errorf("read db name = %q, want main", name)
+	}
+	if len(log.warnings) != 1 || !strings.Contains(log.warnings[0], `"replica"`) {
+		t.Errorf("warnings = %v, want fallback warning", log.warnings)
+	}
+
+	p := NewProvider(s)
+	if err := p.UseWriteDB(ctx).Create(&tenantItem{Name: "a"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	if err := p.UseReadDB(ctx).Model(new(tenantItem)).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("read %d rows, want 1", n)
+	}
+}
+
+func TestSourceDialector(t *testing.T) {
+	mysqlProvider, _ := newFakeMySQLProvider(t)
+	sqlDB, _ := newFakeDB(nil)
+	pg, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqliteProvider := newSQLiteProvider(t)
+
+	if _, ok := mysqlProvider.Dialector().(*mysql.Dialector); !ok {
+		t.Errorf("mysql source dialector = %T", mysqlProvider.Dialector())
+	}
+	if _, ok := NewSource("postgres", pg).Dialector().(*postgres.Dialector); !ok {
+		t.Errorf("postgres source dialector = %T", NewSource("postgres", pg).Dialector())
+	}
+	if _, ok := sqliteProvider.Dialector().(*sqlite.Dialector); !ok {
+		t.Errorf("sqlite source dialector = %T", sqliteProvider.Dialector())
+	}
+
+	// 多库方言不一致时返回 nil.
+	mixed := NewDynamicSource(func(context.Context) string { return "" }, map[string]*gorm.DB{
+		"mysql":  mysqlProvider.UseWriteDB(context.Background()),
+		"sqlite": sqliteProvider.UseWriteDB(context.Background()),
+	})
+	if d := mixed.Dialector(); d != nil {
+		t.Errorf("mixed source dialector = %T, want nil", d)
+	}
+}
@@ -0,0 +1,21 @@
+package db
+
+import (
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// SQLiteMemory 内存数据库文件名.
+const SQLiteMemory = ":memory:"
+
+// SQLiteDialector 返回 SQLite 方言转换函数, DBName 为数据库文件路径.
+//
+// DBName 为 ":memory:" 时使用内存数据库. 内存数据库每个连接独立, 需通过 MaxOpenConns 限制为 1 个连接,
+// 此时事务外访问 DB 将等待进行中的事务结束.
+//
+// 驱动为纯 Go 实现, 不依赖 cgo, 主要用于测试.
+func SQLiteDialector() Dialector {
+	return func(opts *Options) (gorm.Dialector, error) {
+		return sqlite.Open(deref(opts.DBName)), nil
+	}
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
+	"path/filepath"
+	"testing"
+)
+
+// newSQLiteProvider 创建使用临时 SQLite 文件的 Provider 并迁移 models.
+func newSQLiteProvider(t *testing.T, models ...interface{}) *TransProvider {
+	t.Helper()
+	opts := &Options{Dialect: DialectSQLite, DBName: Ptr(filepath.Join(t.TempDir(), "test.db"))}
+	db, err := opts.OpenDB(SQLiteDialector(), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	p := NewProvider(NewSource("sqlite", db))
+	t.Cleanup(func() {
+		_ = p.ForceClose(context.Background())
+	})
+	return p
+}
+
+// newRWSQLiteProvider 创建写库及读库为不同 SQLite 文件的 Provider 并迁移 models.
+//
+// 返回的 replica 直接连接读库, 用于准备读库数据以区分读写库.
+func newRWSQLiteProvider(t *testing.T, models ...interface{}) (p *TransProvider, replica *gorm.DB) {
+	t.Helper()
+	dir := t.TempDir()
+	config := &gorm.Config{Logger: logger.Discard}
+	open := func(db *gorm.DB, err error) *gorm.DB {
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 迁移写库, 避免 dbresolver 以读库判断表已存在.
+		if err := db.Clauses(dbresolver.Write).AutoMigrate(models...); err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}
+	readOpts := &Options{Dialect: DialectSQLite, DBName: Ptr(filepath.Join(dir, "read.db"))}
+	replica = open(readOpts.OpenDB(SQLiteDialector(), config))
+	rwOpts := &RWOptions{
+		Write: &Options{Dialect: DialectSQLite, DBName: Ptr(filepath.Join(dir, "write.db"))},
+		Reads: []*Options{readOpts},
+	}
+	db := open(rwOpts.OpenDB(SQLiteDialector(), config))
+	p = NewProvider(NewSource("sqlite", db))
+	t.Cleanup(func() {
+		_ = p.ForceClose(context.Background())
+		closeDB(replica, make(map[interface{}]bool))
+	})
+	return p, replica
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"sync/atomic"
+)
+
+const statementLimitPluginName = "mini_transaction:statement_limit"
+
+var (
+	ErrTooManyStatements = errors.New("too many statements in transaction")
+)
+
+// StatementLimit 定义单个事务的语句数限制.
+//
+// 限制为 0 时不限制.
+type StatementLimit struct {
+	// 软限制, 语句数超过时调用 OnSoftLimit.
+	Soft int
+	// 硬限制, 执行第 Hard+1 条语句前返回 ErrTooManyStatements.
+	Hard int
+	// 超过软限制时回调, 每个事务仅回调一次.
+	OnSoftLimit func(ctx context.Context, count int64)
+}
+
+// statementCounterKey 语句计数在事务范围数据中的 Key.
+type statementCounterKey struct {
+	limit *StatementLimit
+}
+
+// statementCounter 记录单个事务的语句数.
+type statementCounter struct {
+	limit  *StatementLimit
+	count  int64
+	warned int32
+}
+
+// StatementCount 返回当前事务已执行的语句数.
+//
+// 未配置 WithStatementLimit 或不在事务内时返回 0.
+func (p *TransProvider) StatementCount(ctx context.Context) int64 {
+	tc := p.findTransContext(ctx)
+	if tc == nil || p.statementLimit == nil {
+		return 0
+	}
+	v, ok := tc.Load(statementCounterKey{limit: p.statementLimit})
+	if !ok {
+		return 0
+	}
+	return atomic.LoadInt64(&v.(*statementCounter).count)
+}
+
+// withStatementCounter 为事务内 DB 绑定语句计数.
+func (p *TransProvider) withStatementCounter(ctx context.Context, db *gorm.DB) *gorm.DB {
+	tc := p.findTransContext(ctx)
+	if tc == nil {
+		return db
+	}
+	if err := checkPlugin(db, statementLimitPluginName); err != nil {
+		_ = db.AddError(err)
+		return db
+	}
+	counter, _ := tc.LoadOrStore(statementCounterKey{limit: p.statementLimit}, &statementCounter{limit: p.statementLimit})
+	return db.Set(statementLimitPluginName, counter)
+}
+
+// beforeStatement 语句执行前计数.
+func (c *statementCounter) beforeStatement(ctx context.Context) error {
+	count := atomic.AddInt64(&c.count, 1)
+	if hard := c.limit.Hard; hard > 0 && count > int64(hard) {
+		return ErrTooManyStatements
+	}
+	if soft := c.limit.Soft; soft > 0 && count > int64(soft) &&
+		c.limit.OnSoftLimit != nil && atomic.CompareAndSwapInt32(&c.warned, 0, 1) {
+		c.limit.OnSoftLimit(ctx, count)
+	}
+	return nil
+}
+
+// statementLimitPlugin 通过 gorm 回调执行语句计数.
+//
+// 语句计数由 withStatementCounter 绑定到语句, 未绑定的语句不受影响.
+type statementLimitPlugin struct{}
+
+func (statementLimitPlugin) Name() string {
+	return statementLimitPluginName
+}
+
+func (statementLimitPlugin) Initialize(db *gorm.DB) error {
+	return registerBeforeStatement(db, statementLimitPluginName, func(db *gorm.DB) {
+		v, ok := db.Get(statementLimitPluginName)
+		if !ok {
+			return
+		}
+		if err := v.(*statementCounter).beforeStatement(db.Statement.Context); err != nil {
+			_ = db.AddError(err)
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestStatementLimit(t *testing.T) {
+	base := newSQLiteProvider(t, new(tenantItem))
+	var warnings []int64
+	p := NewProviderWithOptions(base.loadSource(), WithStatementLimit(StatementLimit{
+		Soft: 2,
+		Hard: 4,
+		OnSoftLimit: func(_ context.Context, count int64) {
+			warnings = append(warnings, count)
+		},
+	}))
+	ctx := context.Background()
+
+	statements := 0
+	var count int64
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		for i := 0; i < 2; i++ {
+			if err := p.UseDB(ctx).Create(&tenantItem{Name: "item"}).Error; err != nil {
+				return err
+			}
+			statements++
+		}
+		// 嵌套事务共享根事务的语句数.
+		err := p.Transaction(ctx, func(ctx context.Context) error {
+			for i := 0; i < 10; i++ {
+				if err := p.UseDB(ctx).Create(&tenantItem{Name: "item"}).Error; err != nil {
+					return err
+				}
+				statements++
+			}
+			return nil
+		})
+		count = p.StatementCount(ctx)
+		return err
+	})
+	if !errors.Is(err, ErrTooManyStatements) {
+		t.Fatalf("err = %v, want %v", err, ErrTooManyStatements)
+	}
+	if statements != 4 {
+		t.Errorf("executed %d statements, want 4", statements)
+	}
+	if count != 5 {
+		t.Errorf("StatementCount = %d, want 5", count)
+	}
+	if len(warnings) != 1 || warnings[0] != 3 {
+		t.Errorf("soft limit warnings = %v, want [3]", warnings)
+	}
+	if n := countItems(t, base); n != 0 {
+		t.Errorf("%d rows after rollback, want 0", n)
+	}
+
+	// 新事务重新计数, 事务外不计数.
+	if err := p.UseDB(ctx).Create(&tenantItem{Name: "item"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if n := p.StatementCount(ctx); n != 0 {
+		t.Errorf("StatementCount outside transaction = %d, want 0", n)
+	}
+	err = p.Transaction(ctx, func(ctx context.Context) error {
+		if err := p.UseDB(ctx).Create(&tenantItem{Name: "item"}).Error; err != nil {
+			return err
+		}
+		count = p.StatementCount(ctx)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("StatementCount in new transaction = %d, want 1", count)
+	}
+}
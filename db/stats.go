@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"mini_transaction/transaction"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	statsPluginName = "mini_transaction:stats"
+	// statsStartKey 语句开始时间在 Statement.Settings 中的 Key.
+	statsStartKey = statsPluginName + ":start"
+)
+
+// TransactionStats 定义事务语句统计.
+type TransactionStats struct {
+	// 语句数.
+	Queries int64
+	// 影响行数, 查询语句为返回行数.
+	RowsAffected int64
+	// 语句累计耗时.
+	DBTime time.Duration
+}
+
+type statsOptions struct {
+	report func(ctx context.Context, stats TransactionStats, err error)
+}
+
+// statsKey 语句统计在事务范围数据中的 Key.
+type statsKey struct {
+	opts *statsOptions
+}
+
+// statsCollector 累计单个事务的语句统计.
+type statsCollector struct {
+	queries      int64
+	rowsAffected int64
+	dbTime       int64
+}
+
+func (c *statsCollector) snapshot() TransactionStats {
+	return TransactionStats{
+		Queries:      atomic.LoadInt64(&c.queries),
+		RowsAffected: atomic.LoadInt64(&c.rowsAffected),
+		DBTime:       time.Duration(atomic.LoadInt64(&c.dbTime)),
+	}
+}
+
+// TransactionStats 返回当前事务的语句统计.
+//
+// 未配置 WithTransactionStats 或不在事务内时返回零值.
+func (p *TransProvider) TransactionStats(ctx context.Context) TransactionStats {
+	tc := p.findTransContext(ctx)
+	if tc == nil || p.stats == nil {
+		return TransactionStats{}
+	}
+	v, ok := tc.Load(statsKey{opts: p.stats})
+	if !ok {
+		return TransactionStats{}
+	}
+	return v.(*statsCollector).snapshot()
+}
+
+// withStats 为事务内 DB 绑定语句统计.
+func (p *TransProvider) withStats(ctx context.Context, db *gorm.DB) *gorm.DB {
+	tc := p.findTransContext(ctx)
+	if tc == nil {
+		return db
+	}
+	if err := checkPlugin(db, statsPluginName); err != nil {
+		_ = db.AddError(err)
+		return db
+	}
+	collector, _ := tc.LoadOrStore(statsKey{opts: p.stats}, &statsCollector{})
+	return db.Set(statsPluginName, collector)
+}
+
+// reportStats 根事务结束后回调统计结果.
+func (p *TransProvider) reportStats(ctx context.Context, tc transaction.TransContext, err error) {
+	if p.stats.report == nil {
+		return
+	}
+	var stats TransactionStats
+	if v, ok := tc.Load(statsKey{opts: p.stats}); ok {
+		stats = v.(*statsCollector).snapshot()
+	}
+	p.stats.report(ctx, stats, err)
+}
+
+// statsPlugin 通过 gorm 回调累计语句统计.
+//
+// 语句统计由 withStats 绑定到语句, 未绑定的语句不受影响.
+type statsPlugin struct{}
+
+func (statsPlugin) Name() string {
+	return statsPluginName
+}
+
+func (statsPlugin) Initialize(db *gorm.DB) error {
+	before := func(db *gorm.DB) {
+		if _, ok := db.Get(statsPluginName); ok {
+			db.Statement.Settings.Store(statsStartKey, time.Now())
+		}
+	}
+	after := func(db *gorm.DB) {
+		v, ok := db.Get(statsPluginName)
+		if !ok {
+			return
+		}
+		c := v.(*statsCollector)
+		atomic.AddInt64(&c.queries, 1)
+		atomic.AddInt64(&c.rowsAffected, db.RowsAffected)
+		if start, ok := db.Statement.Settings.Load(statsStartKey); ok {
+			atomic.AddInt64(&c.dbTime, int64(time.Since(start.(time.Time))))
+		}
+	}
+	if err := registerBeforeStatement(db, statsPluginName, before); err != nil {
+		return err
+	}
+	return registerAfterStatement(db, statsPluginName+":after", after)
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTransactionStats(t *testing.T) {
+	base := newSQLiteProvider(t, new(tenantItem))
+	var reported []TransactionStats
+	p := NewProviderWithOptions(base.loadSource(), WithTransactionStats(func(_ context.Context, stats TransactionStats, err error) {
+		if err != nil {
+			t.Errorf("report err = %v", err)
+		}
+		reported = append(reported, stats)
+	}))
+	ctx := context.Background()
+
+	var inTx TransactionStats
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		items := []tenantItem{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+		if err := p.UseDB(ctx).Create(&items).Error; err != nil {
+			return err
+		}
+		// 嵌套事务计入根事务统计.
+		return p.Transaction(ctx, func(ctx context.Context) error {
+			var found []tenantItem
+			if err := p.UseDB(ctx).Find(&found).Error; err != nil {
+				return err
+			}
+			inTx = p.TransactionStats(ctx)
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inTx.Queries != 2 || inTx.RowsAffected != 6 || inTx.DBTime <= 0 {
+		t.Errorf("stats = %+v, want 2 queries and 6 rows", inTx)
+	}
+	if len(reported) != 1 || reported[0] != inTx {
+		t.Errorf("reported = %+v, want [%+v]", reported, inTx)
+	}
+
+	// 事务外不统计.
+	if err := p.UseDB(ctx).Find(new([]tenantItem)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stats := p.TransactionStats(ctx); stats != (TransactionStats{}) {
+		t.Errorf("stats outside transaction = %+v", stats)
+	}
+	if len(reported) != 1 {
+		t.Errorf("reported %d times, want 1", len(reported))
+	}
+}
@@ -0,0 +1,28 @@
+package db
+
+import (
+	"context"
+	"gorm.io/gorm"
+)
+
+// StreamRows 分批读取查询结果并依次交给 handler 处理.
+//
+// 通过 UseDB 获取 DB, 在事务上下文内读取结果与事务快照一致.
+//
+// 返回首个 handler 错误并停止读取, 在事务内调用时由外层事务回调返回该错误以回滚事务.
+func StreamRows[T any](
+	ctx context.Context,
+	p Provider,
+	batchSize int,
+	query func(*gorm.DB) *gorm.DB,
+	handler func(batch []T) error,
+) error {
+	db := p.UseDB(ctx)
+	if query != nil {
+		db = query(db)
+	}
+	var batch []T
+	return db.FindInBatches(&batch, batchSize, func(*gorm.DB, int) error {
+		return handler(batch)
+	}).Error
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestStreamRows(t *testing.T) {
+	p := newSQLiteProvider(t, new(tenantItem))
+	ctx := context.Background()
+	items := make([]tenantItem, 250)
+	for i := range items {
+		items[i].Name = "item"
+	}
+	if err := p.UseWriteDB(ctx).Create(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var sizes []int
+	var next int64 = 1
+	err := StreamRows(ctx, p, 100, nil, func(batch []tenantItem) error {
+		sizes = append(sizes, len(batch))
+		for _, item := range batch {
+			if item.ID != next {
+				t.Fatalf("id = %d, want %d", item.ID, next)
+			}
+			next++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 3 || sizes[0] != 100 || sizes[1] != 100 || sizes[2] != 50 {
+		t.Errorf("batch sizes = %v, want [100 100 50]", sizes)
+	}
+}
+
+func TestStreamRowsHandlerErrorRollback(t *testing.T) {
+	p := newSQLiteProvider(t, new(tenantItem))
+	ctx := context.Background()
+	items := make([]tenantItem, 250)
+	for i := range items {
+		items[i].Name = "item"
+	}
+	if err := p.UseWriteDB(ctx).Create(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	errHandler := errors.New("handler")
+	calls := 0
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		return StreamRows(ctx, p, 100, func(db *gorm.DB) *gorm.DB {
+			return db.Where("name = ?", "item")
+		}, func(batch []tenantItem) error {
+			calls++
+			// 事务内的写入随处理错误回滚.
+			if err := p.UseDB(ctx).Model(&batch).Update("name", "done").Error; err != nil {
+				return err
+			}
+			if calls == 2 {
+				return errHandler
+			}
+			return nil
+		})
+	})
+	if !errors.Is(err, errHandler) {
+		t.Fatalf("err = %v, want %v", err, errHandler)
+	}
+	if calls != 2 {
+		t.Errorf("handler called %d times, want 2", calls)
+	}
+	var done int64
+	if err := p.UseWriteDB(ctx).Model(new(tenantItem)).Where("name = ?", "done").Count(&done).Error; err != nil {
+		t.Fatal(err)
+	}
+	if done != 0 {
+		t.Errorf("%d rows updated after rollback", done)
+	}
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrTransactionThrottled = errors.New("transaction throttled")
+)
+
+// throttle 限制并发根事务数.
+type throttle struct {
+	slots chan struct{}
+	wait  time.Duration
+	// 等待及持有的事务数.
+	waiting int64
+	held    int64
+}
+
+func newThrottle(n int, wait time.Duration) *throttle {
+	return &throttle{slots: make(chan struct{}, n), wait: wait}
+}
+
+// acquire 获取事务名额, 成功时返回释放函数.
+//
+// 等待超过 wait 返回 ErrTransactionThrottled, context 结束时返回 ctx.Err().
+func (t *throttle) acquire(ctx context.Context) (func(), error) {
+	select {
+	case t.slots <- struct{}{}:
+		return t.hold(), nil
+	default:
+	}
+	if t.wait <= 0 {
+		return nil, ErrTransactionThrottled
+	}
+	atomic.AddInt64(&t.waiting, 1)
+	defer atomic.AddInt64(&t.waiting, -1)
+	timer := time.NewTimer(t.wait)
+	defer timer.Stop()
+	select {
+	case t.slots <- struct{}{}:
+		return t.hold(), nil
+	case <-timer.C:
+		return nil, ErrTransactionThrottled
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (t *throttle) hold() func() {
+	atomic.AddInt64(&t.held, 1)
+	return func() {
+		atomic.AddInt64(&t.held, -1)
+		<-t.slots
+	}
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMaxConcurrentTransactions(t *testing.T) {
+	base := newSQLiteProvider(t, new(tenantItem))
+	p := NewProviderWithOptions(base.loadSource(), WithMaxConcurrentTransactions(1, 20*time.Millisecond))
+	ctx := context.Background()
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Transaction(ctx, func(ctx context.Context) error {
+			// 嵌套事务不占用名额.
+			err := p.Transaction(ctx, func(ctx context.Context) error {
+				return p.UseDB(ctx).Create(&tenantItem{Name: "nested"}).Error
+			})
+			close(entered)
+			<-release
+			return err
+		})
+	}()
+	<-entered
+	if held := atomic.LoadInt64(&p.throttle.held); held != 1 {
+		t.Errorf("held = %d, want 1", held)
+	}
+	err := p.Transaction(ctx, func(context.Context) error {
+		t.Error("throttled transaction callback executed")
+		return nil
+	})
+	if !errors.Is(err, ErrTransactionThrottled) {
+		t.Errorf("err = %v, want ErrTransactionThrottled", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if held := atomic.LoadInt64(&p.throttle.held); held != 0 {
+		t.Errorf("held after commit = %d, want 0", held)
+	}
+}
+
+func TestMaxConcurrentTransactionsWait(t *testing.T) {
+	base := newSQLiteProvider(t, new(tenantItem))
+	p := NewProviderWithOptions(base.loadSource(), WithMaxConcurrentTransactions(1, time.Second))
+	ctx := context.Background()
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	go func() {
+		_ = p.Transaction(ctx, func(context.Context) error {
+			close(entered)
+			<-release
+			return nil
+		})
+	}()
+	<-entered
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Transaction(ctx, func(context.Context) error { return nil })
+	}()
+	for atomic.LoadInt64(&p.throttle.waiting) != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	// 等待中的事务在名额释放后执行.
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if waiting := atomic.LoadInt64(&p.throttle.waiting); waiting != 0 {
+		t.Errorf("waiting = %d, want 0", waiting)
+	}
+}
+
+func TestMaxConcurrentTransactionsPanic(t *testing.T) {
+	base := newSQLiteProvider(t, new(tenantItem))
+	p := NewProviderWithOptions(base.loadSource(), WithMaxConcurrentTransactions(1, 0))
+	ctx := context.Background()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("panic not propagated")
+			}
+		}()
+		_ = p.Transaction(ctx, func(context.Context) error {
+			panic("boom")
+		})
+	}()
+	// panic 后名额已释放.
+	if err := p.Transaction(ctx, func(context.Context) error { return nil }); err != nil {
+		t.Errorf("err after panic = %v, want nil", err)
+	}
+}
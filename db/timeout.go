@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"sync/atomic"
+	"time"
+)
+
+// operationTimeoutPluginName 操作超时插件名, 同时为取消函数在 DB 中的设置 Key.
+const operationTimeoutPluginName = "mini_transaction:operation_timeout"
+
+type operationTimeoutKey struct{}
+
+// operationTimeout 单次操作超时提示.
+type operationTimeout struct {
+	timeout time.Duration
+	used    int32
+}
+
+// WithOperationTimeout 设置单次数据库操作超时.
+//
+// 使用返回 context 首次调用 UseDB 或 UseWriteDB 时, 返回的 DB 绑定超时为 timeout 的派生 context,
+// 之后的调用不再应用超时. 派生 context 在返回 DB 的首条语句结束后取消, 该 DB 仅用于单次操作,
+// 之后的语句返回 context.Canceled. Row, Rows 语句返回时结果集未读取, 不主动取消, 超时后释放.
+//
+// 派生 context 不影响请求 context, 但事务内超时时驱动中断语句并关闭所在连接, 如 MySQL,
+// 事务随之失败, 需回滚整个事务.
+func WithOperationTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, operationTimeoutKey{}, &operationTimeout{timeout: timeout})
+}
+
+// applyOperationTimeout 消费超时提示并返回派生 context 及取消函数, 无超时提示时取消函数为 nil.
+func applyOperationTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	hint, ok := ctx.Value(operationTimeoutKey{}).(*operationTimeout)
+	if !ok || !atomic.CompareAndSwapInt32(&hint.used, 0, 1) {
+		return ctx, nil
+	}
+	return context.WithTimeout(ctx, hint.timeout)
+}
+
+// withOperationCancel 绑定语句结束后调用的取消函数.
+func withOperationCancel(db *gorm.DB, cancel context.CancelFunc) *gorm.DB {
+	if err := checkPlugin(db, operationTimeoutPluginName); err != nil {
+		cancel()
+		_ = db.AddError(err)
+		return db
+	}
+	return db.Set(operationTimeoutPluginName, cancel)
+}
+
+// operationTimeoutPlugin 语句结束后取消操作超时的派生 context.
+type operationTimeoutPlugin struct{}
+
+func (operationTimeoutPlugin) Name() string {
+	return operationTimeoutPluginName
+}
+
+// Initialize 注册语句结束后的回调, 写语句在 gorm 默认事务提交后取消.
+func (p operationTimeoutPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:commit_or_rollback_transaction").Register(p.Name(), p.after); err != nil {
+		return err
+	}
+	if err := cb.Query().After("gorm:query").Register(p.Name(), p.after); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:commit_or_rollback_transaction").Register(p.Name(), p.after); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:commit_or_rollback_transaction").Register(p.Name(), p.after); err != nil {
+		return err
+	}
+	return cb.Raw().After("gorm:raw").Register(p.Name(), p.after)
+}
+
+func (operationTimeoutPlugin) after(db *gorm.DB) {
+	if cancel, ok := db.Get(operationTimeoutPluginName); ok {
+		cancel.(context.CancelFunc)()
+	}
+}
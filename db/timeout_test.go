@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"testing"
+	"time"
+)
+
+func newFakeMySQLProvider(t *testing.T) (*TransProvider, *fakeConnector) {
+	sqlDB, fake := newFakeDB(nil)
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewProvider(NewSource("mysql", db)), fake
+}
+
+func TestOperationTimeoutCancelsLongQuery(t *testing.T) {
+	p, _ := newFakeMySQLProvider(t)
+	ctx := WithOperationTimeout(context.Background(), 20*time.Millisecond)
+
+	start := time.Now()
+	var items []tenantItem
+	err := p.UseDB(ctx).Raw(fakeSleepQuery + "(10)").Find(&items).Error
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("query cancelled after %v", elapsed)
+	}
+	// 超时提示已消费, 请求 context 不受影响.
+	if db := p.UseDB(ctx); db.Statement.Context != ctx {
+		t.Error("timeout applied to a second UseDB call")
+	}
+}
+
+func TestOperationTimeoutReleasedAfterStatement(t *testing.T) {
+	p, _ := newFakeMySQLProvider(t)
+	ctx := WithOperationTimeout(context.Background(), time.Hour)
+
+	db := p.UseWriteDB(ctx)
+	if err := db.Model(&tenantItem{ID: 1}).Update("name", "a").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Statement.Context.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("operation context error = %v, want context.Canceled", err)
+	}
+	if err := ctx.Err(); err != nil {
+		t.Errorf("request context error = %v", err)
+	}
+}
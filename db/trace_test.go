@@ -0,0 +1,33 @@
+package db
+
+import (
+	"context"
+	"mini_transaction/transaction"
+	"runtime"
+	"testing"
+)
+
+func TestTransactionTraceSkipsDBWrappers(t *testing.T) {
+	newProvider := func() *TransProvider {
+		p, _ := newFakeMySQLProvider(t)
+		return NewProviderWithOptions(p.loadSource(), WithManagerOptions(transaction.WithCallStackCapture()))
+	}
+	a, b := newProvider(), newProvider()
+	c := CompositeProvider(a, b)
+
+	var frames []transaction.TransactionFrame
+	_, _, line, _ := runtime.Caller(0)
+	err := c.CompositeTransaction(context.Background(), func(ctx context.Context) error {
+		frames = transaction.TransactionTrace(ctx, a)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) == 0 {
+		t.Fatal("no frames")
+	}
+	if f := frames[0]; f.CallerFunc != "mini_transaction/db.TestTransactionTraceSkipsDBWrappers" || f.CallerLine != line+1 {
+		t.Errorf("frame = %+v, want call site line %d", f, line+1)
+	}
+}
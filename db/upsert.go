@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrUpsertFailed = errors.New("upsert failed")
+)
+
+// UpsertError 代表 Upsert 失败, errors.Is(err, ErrUpsertFailed) 为 true.
+type UpsertError struct {
+	Table string
+	Err   error
+}
+
+func (e *UpsertError) Error() string {
+	return fmt.Sprintf("%v: table %s: %v", ErrUpsertFailed, e.Table, e.Err)
+}
+
+func (e *UpsertError) Unwrap() error {
+	return e.Err
+}
+
+func (e *UpsertError) Is(target error) bool {
+	return target == ErrUpsertFailed
+}
+
+// UpsertWithConflict 插入记录, 冲突时更新 updateColumns.
+//
+// 语句由方言生成: MySQL 为 INSERT ... ON DUPLICATE KEY UPDATE, 忽略 conflictColumns;
+// PostgreSQL 及 SQLite 为 INSERT ... ON CONFLICT (conflictColumns) DO UPDATE.
+// updateColumns 为空时更新除主键外的全部列.
+//
+// 事务内使用事务 DB, 事务外使用写库. 失败返回 *UpsertError.
+func UpsertWithConflict(ctx context.Context, p Provider, record interface{}, conflictColumns []string, updateColumns []string) error {
+	db := p.UseWriteDB(ctx)
+	if err := db.Clauses(onConflict(conflictColumns, updateColumns)).Create(record).Error; err != nil {
+		return upsertError(db, record, err)
+	}
+	return nil
+}
+
+// BulkUpsert 批量插入记录, 冲突时更新 updateColumns, 每批最多 batchSize 条, batchSize 不大于 0 时单条语句插入.
+//
+// 冲突处理同 UpsertWithConflict.
+func BulkUpsert[T any](ctx context.Context, p Provider, records []T, conflictColumns []string, updateColumns []string, batchSize int) error {
+	if len(records) == 0 {
+		return nil
+	}
+	db := p.UseWriteDB(ctx).Clauses(onConflict(conflictColumns, updateColumns))
+	if batchSize > 0 {
+		db = db.CreateInBatches(records, batchSize)
+	} else {
+		db = db.Create(records)
+	}
+	if db.Error != nil {
+		return upsertError(db, records, db.Error)
+	}
+	return nil
+}
+
+func onConflict(conflictColumns []string, updateColumns []string) clause.OnConflict {
+	c := clause.OnConflict{}
+	for _, name := range conflictColumns {
+		c.Columns = append(c.Columns, clause.Column{Name: name})
+	}
+	if len(updateColumns) > 0 {
+		c.DoUpdates = clause.AssignmentColumns(updateColumns)
+	} else {
+		c.UpdateAll = true
+	}
+	return c
+}
+
+func upsertError(db *gorm.DB, value interface{}, err error) error {
+	table := db.Statement.Table
+	if table == "" {
+		if stmt := (&gorm.Statement{DB: db}); stmt.Parse(value) == nil {
+			table = stmt.Table
+		}
+	}
+	return &UpsertError{Table: table, Err: err}
+}
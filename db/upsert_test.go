@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestUpsertWithConflict(t *testing.T) {
+	p := newSQLiteProvider(t, new(tenantItem))
+	ctx := context.Background()
+	for _, name := range []string{"a", "b"} {
+		err := UpsertWithConflict(ctx, p, &tenantItem{ID: 1, Name: name}, []string{"id"}, []string{"name"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var items []tenantItem
+	if err := p.UseWriteDB(ctx).Find(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Name != "b" {
+		t.Errorf("items = %+v, want [{1 b}]", items)
+	}
+
+	err := UpsertWithConflict(ctx, p, &tenantItem{ID: 1, Name: "c"}, []string{"name"}, []string{"name"})
+	var upsertErr *UpsertError
+	if !errors.Is(err, ErrUpsertFailed) || !errors.As(err, &upsertErr) || upsertErr.Table != "tenant_items" {
+		t.Errorf("err = %v, want *UpsertError for tenant_items", err)
+	}
+}
+
+func TestBulkUpsert(t *testing.T) {
+	p := newSQLiteProvider(t, new(tenantItem))
+	ctx := context.Background()
+	if err := p.UseWriteDB(ctx).Create(&tenantItem{ID: 2, Name: "old"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		records := []tenantItem{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}, {ID: 3, Name: "c"}}
+		return BulkUpsert(ctx, p, records, []string{"id"}, nil, 2)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []tenantItem
+	if err := p.UseWriteDB(ctx).Order("id").Find(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 || items[0].Name != "a" || items[1].Name != "b" || items[2].Name != "c" {
+		t.Errorf("items = %+v, want a, b, c", items)
+	}
+}
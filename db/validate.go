@@ -0,0 +1,164 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 超时配置的合法范围, 单位毫秒, 0 表示使用默认值.
+const (
+	minTimeoutInMills = 10
+	maxTimeoutInMills = 60 * 60 * 1000
+)
+
+// FieldError 代表单个配置项的校验错误.
+type FieldError struct {
+	// 配置项路径, 以 yaml 字段名表示, 如 main.default.write.port.
+	Path    string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidationError 代表配置校验错误, 包含全部问题.
+type ValidationError struct {
+	Problems []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, 0, len(e.Problems))
+	for _, p := range e.Problems {
+		problems = append(problems, p.Error())
+	}
+	return "invalid options: " + strings.Join(problems, "; ")
+}
+
+// validator 收集校验问题.
+type validator struct {
+	problems []FieldError
+}
+
+func (v *validator) add(path, format string, args ...interface{}) {
+	v.problems = append(v.problems, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+// joinPath 拼接配置项路径.
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// Validate 校验配置, 返回包含全部问题的 *ValidationError.
+//
+// 检查方言取值, 必填项 host, port, db_name, username, 未配置 (nil) 及为空均视为缺失, 端口范围, host 与 socket 互斥,
+// max_idle_conns 不超过 max_open_conns 及超时范围. host 及 port 按 WithDefaults 填充默认值后检查.
+// 设置 socket 时不检查 host 及 port. SQLite 仅检查 db_name. PostgreSQL 不要求 username,
+// 未配置时驱动使用操作系统用户, 用于 trust 及 peer 认证.
+func (o *Options) Validate() error {
+	v := &validator{}
+	o.validate(v, "")
+	return v.err()
+}
+
+func (o *Options) validate(v *validator, prefix string) {
+	path := func(name string) string { return joinPath(prefix, name) }
+	dialect := o.dialect()
+	d := o.WithDefaults()
+	switch dialect {
+	case DialectMySQL, DialectPostgres, DialectSQLite:
+	default:
+		v.add(path("dialect"), "must be %s, %s or %s", DialectMySQL, DialectPostgres, DialectSQLite)
+	}
+	switch {
+	case dialect == DialectSQLite:
+	case o.Host != nil && o.Socket != "":
+		v.add(path("socket"), "mutually exclusive with host")
+	case o.Socket != "":
+	case deref(d.Host) == "":
+		v.add(path("host"), "required")
+	case *d.Port < 1 || *d.Port > 65535:
+		v.add(path("port"), "must be 1-65535")
+	}
+	if deref(o.DBName) == "" {
+		v.add(path("db_name"), "required")
+	}
+	if dialect == DialectMySQL && deref(o.UserName) == "" {
+		v.add(path("username"), "required")
+	}
+	if o.MaxOpenConns > 0 && o.MaxIdleConns > o.MaxOpenConns {
+		v.add(path("max_idle_conns"), "must not exceed max_open_conns %d", o.MaxOpenConns)
+	}
+	for _, t := range []struct {
+		name  string
+		value uint
+	}{
+		{"timeout_in_mills", o.TimeoutInMills},
+		{"read_timeout", o.ReadTimeoutInMills},
+		{"write_timeout", o.WriteTimeoutInMills},
+	} {
+		if t.value != 0 && (t.value < minTimeoutInMills || t.value > maxTimeoutInMills) {
+			v.add(path(t.name), "must be 0 or %d-%d milliseconds", minTimeoutInMills, maxTimeoutInMills)
+		}
+	}
+}
+
+// Validate 校验主从配置, 问题同 Options.Validate.
+func (o *RWOptions) Validate() error {
+	v := &validator{}
+	o.validate(v, "")
+	return v.err()
+}
+
+func (o *RWOptions) validate(v *validator, prefix string) {
+	if o.Write == nil {
+		v.add(joinPath(prefix, "write"), "required")
+	} else {
+		o.Write.validate(v, joinPath(prefix, "write"))
+	}
+	if o.Read != nil {
+		o.Read.validate(v, joinPath(prefix, "read"))
+	}
+	for i, read := range o.Reads {
+		path := fmt.Sprintf("%s[%d]", joinPath(prefix, "read"), i)
+		if read == nil {
+			v.add(path, "required")
+			continue
+		}
+		read.validate(v, path)
+	}
+	switch o.ReadPolicy {
+	case "", ReadPolicyRandom, ReadPolicyRoundRobin, ReadPolicyWeighted:
+	default:
+		v.add(joinPath(prefix, "read_policy"), "unknown policy %q", o.ReadPolicy)
+	}
+}
+
+// Validate 按 Key 顺序校验全部配置, 路径以 Key 开头, 问题同 Options.Validate.
+//
+// OpenDBs 及 ToSource 等在创建连接前调用.
+func (o MultiRWOptions) Validate() error {
+	v := &validator{}
+	keys := make([]string, 0, len(o))
+	for key := range o {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if opt := o[key]; opt != nil {
+			opt.validate(v, key)
+		}
+	}
+	return v.err()
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"strings"
+	"testing"
+)
+
+// problemPaths 返回校验错误中的配置项路径.
+func problemPaths(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("error = %v, want *ValidationError", err)
+	}
+	paths := make([]string, 0, len(verr.Problems))
+	for _, p := range verr.Problems {
+		paths = append(paths, p.Path)
+	}
+	return paths
+}
+
+func TestValidateDialect(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{"mysql defaults", Options{}, []string{"db_name", "username"}},
+		{"mysql empty host", Options{Host: Ptr(""), DBName: Ptr("app"), UserName: Ptr("app")}, []string{"host"}},
+		{"mysql socket", Options{Socket: "/tmp/mysql.sock", DBName: Ptr("app")}, []string{"username"}},
+		{"mysql host and socket", Options{Host: Ptr("db"), Socket: "/tmp/mysql.sock", DBName: Ptr("app"), UserName: Ptr("app")}, []string{"socket"}},
+		{"postgres trust", Options{Dialect: DialectPostgres, Host: Ptr("db"), Port: Ptr(5432), DBName: Ptr("app")}, nil},
+		{"postgres socket", Options{Dialect: DialectPostgres, Socket: "/var/run/postgresql", DBName: Ptr("app")}, nil},
+		{"sqlite", Options{Dialect: DialectSQLite, DBName: Ptr(SQLiteMemory)}, nil},
+		{"sqlite without file", Options{Dialect: DialectSQLite}, []string{"db_name"}},
+		{"unknown", Options{Dialect: "oracle", Host: Ptr("db"), Port: Ptr(1521), DBName: Ptr("app")}, []string{"dialect"}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			got := problemPaths(t, c.opts.Validate())
+			if len(got) != len(c.want) {
+				t.Fatalf("problems = %v, want %v", got, c.want)
+			}
+			for i := range got {
+				if got[i] != c.want[i] {
+					t.Errorf("problems = %v, want %v", got, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateMultiRWOptionsPaths(t *testing.T) {
+	o := MultiRWOptions{"main": {
+		Write: &Options{Host: Ptr("w"), Port: Ptr(70000), DBName: Ptr("app"), UserName: Ptr("u")},
+		Reads: []*Options{nil},
+	}}
+	got := problemPaths(t, o.Validate())
+	want := []string{"main.write.port", "main.read[0]"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("problems = %v, want %v", got, want)
+	}
+}
+
+func TestValidateAggregatesProblems(t *testing.T) {
+	o := MultiRWOptions{"main": {
+		Write: &Options{
+			Host: Ptr("w"), Port: Ptr(0), DBName: Ptr("app"), UserName: Ptr("u"),
+			MaxOpenConns: 2, MaxIdleConns: 5, TimeoutInMills: 5, WriteTimeoutInMills: 1000,
+		},
+	}}
+	err := o.Validate()
+	got := problemPaths(t, err)
+	want := []string{"main.write.port", "main.write.max_idle_conns", "main.write.timeout_in_mills"}
+	if len(got) != len(want) {
+		t.Fatalf("problems = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("problems = %v, want %v", got, want)
+		}
+	}
+	if msg := err.Error(); !strings.HasPrefix(msg, "invalid options: main.write.port: must be 1-65535; ") {
+		t.Errorf("message = %q", msg)
+	}
+
+	// OpenDBs 校验失败时不创建连接.
+	dialed := false
+	dial := func(*Options) (gorm.Dialector, error) {
+		dialed = true
+		return nil, errors.New("unexpected dial")
+	}
+	if _, err := o.OpenDBs(dial, nil); !errors.As(err, new(*ValidationError)) {
+		t.Errorf("OpenDBs err = %v, want *ValidationError", err)
+	}
+	if dialed {
+		t.Error("OpenDBs dialed with invalid options")
+	}
+}
+
+func TestDetectKeyCollisions(t *testing.T) {
+	opt := &RWOptions{Write: &Options{}}
+	groups := map[string]MultiRWOptions{
+		"a.b": {"c": opt, "d": opt},
+		"a":   {"b.c": opt, "e": opt},
+		"x":   {"y": opt},
+	}
+	if got := DetectKeyCollisions(groups, "."); len(got) != 1 || got[0] != "a.b.c" {
+		t.Errorf("collisions = %v, want [a.b.c]", got)
+	}
+	// 分隔符不出现在 Key 中时不冲突.
+	if got := DetectKeyCollisions(groups, "/"); len(got) != 0 {
+		t.Errorf("collisions with / = %v, want none", got)
+	}
+}
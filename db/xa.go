@@ -0,0 +1,299 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"math/rand"
+	"mini_transaction/transaction"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// xaGtridPrefix XATransaction 生成的全局事务 ID 前缀, 用于恢复时识别.
+const xaGtridPrefix = "mtx-"
+
+var (
+	ErrXABranchManaged        = errors.New("xa branch is managed by XATransaction")
+	ErrXADuplicateParticipant = errors.New("duplicate xa participant")
+)
+
+// XID 代表 XA 事务 ID.
+type XID struct {
+	FormatID int64
+	Gtrid    string
+	Bqual    string
+}
+
+func (x XID) String() string {
+	return fmt.Sprintf("X'%s',X'%s',%d", hex.EncodeToString([]byte(x.Gtrid)), hex.EncodeToString([]byte(x.Bqual)), x.FormatID)
+}
+
+// XACommitError 代表全部分支准备后部分分支提交失败.
+//
+// 其他分支可能已提交, 全局事务结果未知. 匹配 transaction.ErrOutcomeUnknown, 各参与者的提交及回滚回调均不执行.
+// Failed 中的分支保留为已准备状态, 需通过 RecoverXA 或 ResolveXA 处理.
+type XACommitError struct {
+	// 提交失败的分支.
+	Failed []XID
+	// 与 Failed 一一对应的错误.
+	Errs []error
+}
+
+func (e *XACommitError) Error() string {
+	msgs := make([]string, len(e.Failed))
+	for i, xid := range e.Failed {
+		msgs[i] = fmt.Sprintf("%s: %v", xid.Bqual, e.Errs[i])
+	}
+	return fmt.Sprintf("xa commit %s: %s, outcome unknown", e.Failed[0].Gtrid, strings.Join(msgs, "; "))
+}
+
+func (e *XACommitError) Is(target error) bool {
+	return target == transaction.ErrOutcomeUnknown
+}
+
+type xaCtxKey struct{}
+
+// xaCoordinator 协调单个 XA 事务的各分支.
+type xaCoordinator struct {
+	branches []*xaBranch
+	// 已提交或回滚.
+	finished bool
+}
+
+// xaBranch 代表 XA 事务分支.
+type xaBranch struct {
+	manager transaction.Manager
+	xid     XID
+	conn    *sql.Conn
+	db      *gorm.DB
+	// 分支已被 Provider 事务占用.
+	taken int32
+	// 分支已执行 XA END.
+	ended bool
+}
+
+// xaConnPool 绑定 XA 分支连接.
+//
+// 实现 gorm.TxCommitter, 使 dbresolver 等插件视其为事务连接而不切换连接.
+// 提交及回滚由 XATransaction 执行.
+type xaConnPool struct {
+	*sql.Conn
+}
+
+func (c xaConnPool) Commit() error {
+	return ErrXABranchManaged
+}
+
+func (c xaConnPool) Rollback() error {
+	return ErrXABranchManaged
+}
+
+// XATransaction 以 MySQL XA 在多个 Provider 的写库上执行分布式事务.
+//
+// 各参与者独占一个写库连接并执行 XA START, 回调内 UseDB 等方法返回对应参与者的 XA 分支 DB,
+// 回调成功后依次执行 XA END 及 XA PREPARE, 全部准备成功后执行 XA COMMIT, 否则回滚全部分支.
+//
+// XA 分支内禁用 dbresolver 读写分离, 查询均在分支连接上执行.
+//
+// 提交阶段失败时返回 *XACommitError, 已准备的分支保留在数据库中, 需通过 RecoverXA 处理.
+// 各参与者的提交及回滚回调在 XA 事务结束后执行.
+func XATransaction(ctx context.Context, providers []*TransProvider, callback func(context.Context) error) error {
+	coord := &xaCoordinator{}
+	gtrid := xaGtridPrefix + strconv.FormatUint(rand.Uint64(), 16)
+	defer func() {
+		if !coord.finished {
+			coord.rollback(ctx)
+		}
+		for _, b := range coord.branches {
+			_ = b.conn.Close()
+		}
+	}()
+	for i, p := range providers {
+		for _, b := range coord.branches {
+			if b.manager == p.Manager {
+				return ErrXADuplicateParticipant
+			}
+		}
+		b, err := startXABranch(ctx, p, XID{Gtrid: gtrid, Bqual: strconv.Itoa(i), FormatID: 1})
+		if err != nil {
+			return err
+		}
+		coord.branches = append(coord.branches, b)
+	}
+	ctx = context.WithValue(ctx, xaCtxKey{}, coord)
+	return coord.transaction(ctx, providers, func(ctx context.Context) error {
+		if err := callback(ctx); err != nil {
+			return err
+		}
+		return coord.commit(ctx)
+	})
+}
+
+// startXABranch 获取写库连接并开启 XA 分支.
+func startXABranch(ctx context.Context, p *TransProvider, xid XID) (*xaBranch, error) {
+	db := p.getWriteDB(ctx)
+	if db == nil {
+		return nil, p.dbNotFound(ctx)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, "XA START "+xid.String()); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	tx := db.Session(&gorm.Session{Context: ctx, NewDB: true})
+	tx.Statement.ConnPool = xaConnPool{Conn: conn}
+	return &xaBranch{manager: p.Manager, xid: xid, conn: conn, db: tx}, nil
+}
+
+// transaction 依次开启各参与者事务, 将参与者事务上下文绑定到 XA 分支.
+func (c *xaCoordinator) transaction(ctx context.Context, providers []*TransProvider, callback func(context.Context) error) error {
+	if len(providers) == 0 {
+		return callback(ctx)
+	}
+	return providers[0].Transaction(ctx, func(ctx context.Context) error {
+		return c.transaction(ctx, providers[1:], callback)
+	})
+}
+
+// commit 准备并提交全部分支.
+func (c *xaCoordinator) commit(ctx context.Context) error {
+	for _, b := range c.branches {
+		if err := b.end(ctx); err != nil {
+			return err
+		}
+		if _, err := b.conn.ExecContext(ctx, "XA PREPARE "+b.xid.String()); err != nil {
+			return fmt.Errorf("xa prepare %s: %w", b.xid.Bqual, err)
+		}
+	}
+	// 全部分支已准备, 之后的失败由 RecoverXA 处理.
+	c.finished = true
+	var commitErr *XACommitError
+	for _, b := range c.branches {
+		if _, err := b.conn.ExecContext(ctx, "XA COMMIT "+b.xid.String()); err != nil {
+			if commitErr == nil {
+				commitErr = &XACommitError{}
+			}
+			commitErr.Failed = append(commitErr.Failed, b.xid)
+			commitErr.Errs = append(commitErr.Errs, err)
+		}
+	}
+	if commitErr != nil {
+		return commitErr
+	}
+	return nil
+}
+
+// rollback 回滚全部分支.
+func (c *xaCoordinator) rollback(ctx context.Context) {
+	c.finished = true
+	for _, b := range c.branches {
+		if err := b.end(ctx); err != nil {
+			continue
+		}
+		_, _ = b.conn.ExecContext(ctx, "XA ROLLBACK "+b.xid.String())
+	}
+}
+
+func (b *xaBranch) end(ctx context.Context) error {
+	if b.ended {
+		return nil
+	}
+	if _, err := b.conn.ExecContext(ctx, "XA END "+b.xid.String()); err != nil {
+		return fmt.Errorf("xa end %s: %w", b.xid.Bqual, err)
+	}
+	b.ended = true
+	return nil
+}
+
+// takeXABranch 返回 context 中 Manager 对应的未占用 XA 分支.
+func takeXABranch(ctx context.Context, m transaction.Manager) *xaBranch {
+	coord, ok := ctx.Value(xaCtxKey{}).(*xaCoordinator)
+	if !ok || coord.finished {
+		return nil
+	}
+	for _, b := range coord.branches {
+		if b.manager == m && atomic.CompareAndSwapInt32(&b.taken, 0, 1) {
+			return b
+		}
+	}
+	return nil
+}
+
+// ListPreparedXA 返回写库中 XATransaction 遗留的已准备 XA 事务.
+func ListPreparedXA(ctx context.Context, p *TransProvider) ([]XID, error) {
+	db := p.getWriteDB(ctx)
+	if db == nil {
+		return nil, p.dbNotFound(ctx)
+	}
+	rows, err := db.WithContext(ctx).Raw("XA RECOVER").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var xids []XID
+	for rows.Next() {
+		var (
+			formatID, gtridLen, bqualLen int64
+			data                         []byte
+		)
+		if err := rows.Scan(&formatID, &gtridLen, &bqualLen, &data); err != nil {
+			return nil, err
+		}
+		if int64(len(data)) < gtridLen+bqualLen {
+			continue
+		}
+		xid := XID{
+			FormatID: formatID,
+			Gtrid:    string(data[:gtridLen]),
+			Bqual:    string(data[gtridLen : gtridLen+bqualLen]),
+		}
+		if strings.HasPrefix(xid.Gtrid, xaGtridPrefix) {
+			xids = append(xids, xid)
+		}
+	}
+	return xids, rows.Err()
+}
+
+// ResolveXA 提交或回滚已准备的 XA 事务.
+func ResolveXA(ctx context.Context, p *TransProvider, xid XID, commit bool) error {
+	db := p.getWriteDB(ctx)
+	if db == nil {
+		return p.dbNotFound(ctx)
+	}
+	stmt := "XA ROLLBACK "
+	if commit {
+		stmt = "XA COMMIT "
+	}
+	return db.WithContext(ctx).Exec(stmt + xid.String()).Error
+}
+
+// RecoverXA 处理各 Provider 写库中遗留的已准备 XA 事务, 通常在服务启动时调用.
+//
+// decide 返回 true 时提交, 否则回滚. 同一全局事务的分支可能部分已提交,
+// decide 应依据业务数据判断, 保证同一 Gtrid 的分支结果一致.
+func RecoverXA(ctx context.Context, providers []*TransProvider, decide func(XID) bool) error {
+	for _, p := range providers {
+		xids, err := ListPreparedXA(ctx, p)
+		if err != nil {
+			return err
+		}
+		for _, xid := range xids {
+			if err := ResolveXA(ctx, p, xid, decide(xid)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
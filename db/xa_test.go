@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"mini_transaction/transaction"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// xaStatements 返回 fake 记录的语句, XA 语句去除 XID.
+func xaStatements(fake *fakeConnector) []string {
+	var log []string
+	for _, s := range fake.statements() {
+		query := s.query
+		if strings.HasPrefix(query, "XA ") {
+			query = query[:strings.LastIndex(query, " ")]
+		}
+		log = append(log, query)
+	}
+	return log
+}
+
+func TestXATransaction(t *testing.T) {
+	wallet, walletFake := newFakeMySQLProvider(t)
+	ledger, ledgerFake := newFakeMySQLProvider(t)
+	providers := []*TransProvider{wallet, ledger}
+	ctx := context.Background()
+	write := func(ctx context.Context) error {
+		if err := wallet.UseDB(ctx).Exec("UPDATE wallets SET balance = balance - 1").Error; err != nil {
+			return err
+		}
+		return ledger.UseDB(ctx).Exec("INSERT INTO ledger VALUES (1)").Error
+	}
+
+	if err := XATransaction(ctx, providers, write); err != nil {
+		t.Fatal(err)
+	}
+	for name, c := range map[string]struct {
+		fake *fakeConnector
+		stmt string
+	}{
+		"wallet": {walletFake, "UPDATE wallets SET balance = balance - 1"},
+		"ledger": {ledgerFake, "INSERT INTO ledger VALUES (1)"},
+	} {
+		want := []string{"XA START", c.stmt, "XA END", "XA PREPARE", "XA COMMIT"}
+		if got := xaStatements(c.fake); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s statements = %q, want %q", name, got, want)
+		}
+	}
+
+	// 回调失败时回滚全部分支.
+	errRollback := errors.New("rollback")
+	err := XATransaction(ctx, providers, func(ctx context.Context) error {
+		if err := write(ctx); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("err = %v, want %v", err, errRollback)
+	}
+	want := []string{"XA START", "UPDATE wallets SET balance = balance - 1", "XA END", "XA ROLLBACK"}
+	if got := xaStatements(walletFake); !reflect.DeepEqual(got, want) {
+		t.Errorf("wallet statements after rollback = %q, want %q", got, want)
+	}
+
+	// 准备失败时回滚全部分支.
+	errPrepare := errors.New("prepare failed")
+	ledgerFake.errs = map[string]error{"XA PREPARE": errPrepare}
+	err = XATransaction(ctx, providers, write)
+	if !errors.Is(err, errPrepare) {
+		t.Fatalf("err = %v, want %v", err, errPrepare)
+	}
+	for name, fake := range map[string]*fakeConnector{"wallet": walletFake, "ledger": ledgerFake} {
+		got := xaStatements(fake)
+		if got[len(got)-1] != "XA ROLLBACK" {
+			t.Errorf("%s statements = %q, want rollback", name, got)
+		}
+	}
+
+	if err := XATransaction(ctx, []*TransProvider{wallet, wallet}, write); !errors.Is(err, ErrXADuplicateParticipant) {
+		t.Errorf("duplicate participant err = %v, want %v", err, ErrXADuplicateParticipant)
+	}
+}
+
+func TestXACommitOutcomeUnknown(t *testing.T) {
+	wallet, _ := newFakeMySQLProvider(t)
+	ledger, ledgerFake := newFakeMySQLProvider(t)
+	ctx := context.Background()
+	errCommit := errors.New("commit failed")
+	ledgerFake.errs = map[string]error{"XA COMMIT": errCommit}
+
+	var committed, rollbacked bool
+	err := XATransaction(ctx, []*TransProvider{wallet, ledger}, func(ctx context.Context) error {
+		wallet.OnCommitted(ctx, func(context.Context) { committed = true })
+		wallet.OnRollbacked(ctx, func(context.Context, error) { rollbacked = true })
+		return ledger.UseDB(ctx).Exec("INSERT INTO ledger VALUES (1)").Error
+	})
+	var commitErr *XACommitError
+	if !errors.As(err, &commitErr) || !errors.Is(err, transaction.ErrOutcomeUnknown) {
+		t.Fatalf("err = %v, want *XACommitError", err)
+	}
+	if len(commitErr.Failed) != 1 || commitErr.Failed[0].Bqual != "1" || !errors.Is(commitErr.Errs[0], errCommit) {
+		t.Errorf("failed branches = %+v, errs = %v", commitErr.Failed, commitErr.Errs)
+	}
+	// wallet 分支已提交, 结果未知时不执行回调.
+	if committed || rollbacked {
+		t.Errorf("committed %v, rollbacked %v, want no callbacks", committed, rollbacked)
+	}
+	if got := xaStatements(ledgerFake); got[len(got)-1] != "XA COMMIT" {
+		t.Errorf("ledger statements = %q, want no rollback after commit", got)
+	}
+}
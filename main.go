@@ -3,22 +3,14 @@ package main
 import (
 	"context"
 	"fmt"
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"mini_transaction/db"
 	"mini_transaction/transaction"
-	"time"
+	"strings"
 )
 
 const (
 	DriverName = "drive_name"
-	Charset    = "utf8mb4"
-)
-
-var (
-	DefaultTimeout      = 100 * time.Millisecond
-	DefaultReadTimeout  = 2 * time.Second
-	DefaultWriteTimeout = 5 * time.Second
 )
 
 type MysqlProviderParams struct {
@@ -47,17 +39,17 @@ func newTransProvider(params MysqlProviderParams) *TransProvider {
 	getDBKey := func(techID, bussID string) string {
 		return fmt.Sprintf("%s.%s", techID, bussID)
 	}
+	if keys := db.DetectKeyCollisions(params.Conf.Mysql, "."); len(keys) > 0 {
+		panic(fmt.Sprintf("mysql config key collision: %s", strings.Join(keys, ", ")))
+	}
 	for techID, opts := range params.Conf.Mysql {
 		for bussID, opt := range opts {
 			mysqlOpts[getDBKey(techID, bussID)] = opt
 		}
 	}
-	MyDialector := func(opts *db.Options) (gorm.Dialector, error) {
-		dsn := toTcpDSN(opts)
-		dl := mysql.New(mysql.Config{DriverName: DriverName, DSN: dsn})
-		return dl, nil
-	}
-	source, err := mysqlOpts.ToSource(MyDialector, nil, func(ctx context.Context) string {
+	MyDialector := db.DefaultMySQLDialector(db.WithMySQLDriverName(DriverName))
+	// 全部库开启 PrepareStmt, 单库可通过 db.Options 配置.
+	source, err := mysqlOpts.ToSource(MyDialector, &gorm.Config{PrepareStmt: true}, func(ctx context.Context) string {
 		var (
 			techID string = "main"
 			bussID string = "default"
@@ -67,42 +59,11 @@ func newTransProvider(params MysqlProviderParams) *TransProvider {
 	if err != nil {
 		panic(err)
 	}
-	dbProvider := db.NewProvider(source, func(g *gorm.DB) *gorm.DB {
-		g.PrepareStmt = true
-		return g
-	})
+	dbProvider := db.NewProvider(source)
 	p.TransProvider = dbProvider
 	return p
 }
 
-func toTcpDSN(opts *db.Options) string {
-	f := "%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=true&loc=Local&timeout=%s&readTimeout=%s&writeTimeout=%s"
-	return fmt.Sprintf(f, opts.UserName, opts.Password, opts.Host,
-		opts.Port, opts.DBName, Charset,
-		getTimeout(opts), getReadTimeout(opts), getWriteTimeout(opts))
-}
-
-func getTimeout(opts *db.Options) time.Duration {
-	if opts.TimeoutInMills > 0 {
-		return time.Duration(opts.TimeoutInMills) * time.Millisecond
-	}
-	return DefaultTimeout
-}
-
-func getReadTimeout(opts *db.Options) time.Duration {
-	if opts.ReadTimeoutInMills > 0 {
-		return time.Duration(opts.ReadTimeoutInMills) * time.Millisecond
-	}
-	return DefaultReadTimeout
-}
-
-func getWriteTimeout(opts *db.Options) time.Duration {
-	if opts.WriteTimeoutInMills > 0 {
-		return time.Duration(opts.WriteTimeoutInMills) * time.Millisecond
-	}
-	return DefaultWriteTimeout
-}
-
 func ToTransactionManager(tp *TransProvider) transaction.Manager {
 	return tp
 }
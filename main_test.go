@@ -0,0 +1,22 @@
+package main
+
+import (
+	"mini_transaction/db"
+	"strings"
+	"testing"
+)
+
+func TestNewTransProviderKeyCollision(t *testing.T) {
+	opt := &db.RWOptions{Write: &db.Options{}}
+	params := MysqlProviderParams{Conf: Configs{Mysql: map[string]db.MultiRWOptions{
+		"a.b": {"c": opt},
+		"a":   {"b.c": opt},
+	}}}
+	defer func() {
+		msg, _ := recover().(string)
+		if !strings.Contains(msg, "a.b.c") {
+			t.Errorf("panic = %q, want key collision a.b.c", msg)
+		}
+	}()
+	newTransProvider(params)
+}
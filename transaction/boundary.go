@@ -0,0 +1,161 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+var (
+	ErrContextBoundaryViolation = errors.New("transaction context used outside its callback")
+)
+
+// boundaryKey 事务回调 context 有效范围在 context 中存储的 Key.
+type boundaryKey struct{}
+
+// boundary 标记事务回调 context 是否已超出有效范围.
+type boundary struct {
+	expired atomic.Bool
+}
+
+// boundaryEnforcingManager 检查事务回调 context 是否在回调结束后继续使用.
+type boundaryEnforcingManager struct {
+	Manager
+}
+
+// NewBoundaryEnforcingManager 装饰事务管理器, Transaction 回调返回后将回调 context 标记为过期.
+//
+// 使用过期 context 调用 InTransaction 及 OnCommitted 等返回 bool 的方法时以 ErrContextBoundaryViolation panic,
+// 返回 error 的方法返回该错误, db 包查找 DB 时同样返回该错误.
+// EscapeTransaction 及事务回调的 context 不受限制.
+//
+// 用于开发及测试时发现逃逸到回调外或新 goroutine 的 context. 仅在 boundary_check 构建标签下生效,
+// 否则直接返回 base.
+func NewBoundaryEnforcingManager(base Manager) Manager {
+	if !boundaryCheck {
+		return base
+	}
+	return &boundaryEnforcingManager{Manager: base}
+}
+
+// BoundaryEnforcer 返回 NewBoundaryEnforcingManager 中间件.
+func BoundaryEnforcer() Middleware {
+	return NewBoundaryEnforcingManager
+}
+
+// CheckBoundary context 为已过期的事务回调 context 时返回 ErrContextBoundaryViolation.
+//
+// 用于事务管理器实现方在查找 DB 时检查, 未开启 boundary_check 构建标签时始终返回 nil.
+func CheckBoundary(ctx context.Context) error {
+	if !boundaryCheck {
+		return nil
+	}
+	if b, ok := ctx.Value(boundaryKey{}).(*boundary); ok && b.expired.Load() {
+		return ErrContextBoundaryViolation
+	}
+	return nil
+}
+
+// mustCheckBoundary 以 ErrContextBoundaryViolation panic.
+func mustCheckBoundary(ctx context.Context) {
+	if err := CheckBoundary(ctx); err != nil {
+		panic(err)
+	}
+}
+
+// withoutBoundary 清除 context 的有效范围标记.
+func withoutBoundary(ctx context.Context) context.Context {
+	if ctx.Value(boundaryKey{}) == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, boundaryKey{}, nil)
+}
+
+func (m *boundaryEnforcingManager) Unwrap() Manager {
+	return m.Manager
+}
+
+func (m *boundaryEnforcingManager) InTransaction(ctx context.Context) bool {
+	mustCheckBoundary(ctx)
+	return m.Manager.InTransaction(ctx)
+}
+
+func (m *boundaryEnforcingManager) Transaction(ctx context.Context, callback func(context.Context) error) error {
+	if err := CheckBoundary(ctx); err != nil {
+		return err
+	}
+	return m.Manager.Transaction(ctx, func(ctx context.Context) error {
+		b := &boundary{}
+		defer b.expired.Store(true)
+		return callback(context.WithValue(ctx, boundaryKey{}, b))
+	})
+}
+
+func (m *boundaryEnforcingManager) MustTransaction(ctx context.Context, callback func(context.Context)) {
+	if err := m.Transaction(ctx, func(ctx context.Context) error {
+		callback(ctx)
+		return nil
+	}); err != nil {
+		panic(err)
+	}
+}
+
+func (m *boundaryEnforcingManager) EscapeTransaction(ctx context.Context, callback func(context.Context) error) error {
+	if err := CheckBoundary(ctx); err != nil {
+		return err
+	}
+	return m.Manager.EscapeTransaction(withoutBoundary(ctx), callback)
+}
+
+func (m *boundaryEnforcingManager) OnCommitted(ctx context.Context, callback func(context.Context)) bool {
+	mustCheckBoundary(ctx)
+	return m.Manager.OnCommitted(ctx, func(ctx context.Context) { callback(withoutBoundary(ctx)) })
+}
+
+func (m *boundaryEnforcingManager) OnRollbacked(ctx context.Context, callback func(context.Context, error)) bool {
+	mustCheckBoundary(ctx)
+	return m.Manager.OnRollbacked(ctx, func(ctx context.Context, err error) { callback(withoutBoundary(ctx), err) })
+}
+
+func (m *boundaryEnforcingManager) OnCommittedStrict(ctx context.Context, callback func(context.Context)) error {
+	if err := CheckBoundary(ctx); err != nil {
+		return err
+	}
+	return OnCommittedStrict(ctx, m.Manager, func(ctx context.Context) { callback(withoutBoundary(ctx)) })
+}
+
+func (m *boundaryEnforcingManager) OnRollbackedStrict(ctx context.Context, callback func(context.Context, error)) error {
+	if err := CheckBoundary(ctx); err != nil {
+		return err
+	}
+	return OnRollbackedStrict(ctx, m.Manager, func(ctx context.Context, err error) { callback(withoutBoundary(ctx), err) })
+}
+
+func (m *boundaryEnforcingManager) OnCommittedBatch(ctx context.Context, callbacks ...func(context.Context)) int {
+	mustCheckBoundary(ctx)
+	wrapped := make([]func(context.Context), 0, len(callbacks))
+	for _, callback := range callbacks {
+		callback := callback
+		wrapped = append(wrapped, func(ctx context.Context) { callback(withoutBoundary(ctx)) })
+	}
+	return OnCommittedBatch(ctx, m.Manager, wrapped...)
+}
+
+func (m *boundaryEnforcingManager) OnRollbackedBatch(ctx context.Context, callbacks ...func(context.Context, error)) int {
+	mustCheckBoundary(ctx)
+	wrapped := make([]func(context.Context, error), 0, len(callbacks))
+	for _, callback := range callbacks {
+		callback := callback
+		wrapped = append(wrapped, func(ctx context.Context, err error) { callback(withoutBoundary(ctx), err) })
+	}
+	return OnRollbackedBatch(ctx, m.Manager, wrapped...)
+}
+
+// PropagateContext 同时传递有效范围标记, 事务回调返回后 dst 同样过期.
+func (m *boundaryEnforcingManager) PropagateContext(src, dst context.Context) context.Context {
+	dst = PropagateContext(m.Manager, src, dst)
+	if b, ok := src.Value(boundaryKey{}).(*boundary); ok {
+		dst = context.WithValue(dst, boundaryKey{}, b)
+	}
+	return dst
+}
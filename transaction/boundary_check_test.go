@@ -0,0 +1,82 @@
+//go:build boundary_check
+
+package transaction
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// expectViolation 执行 f 并检查以 ErrContextBoundaryViolation panic.
+func expectViolation(t *testing.T, name string, f func()) {
+	t.Helper()
+	defer func() {
+		if e := recover(); e != ErrContextBoundaryViolation {
+			t.Errorf("%s: panic = %v, want ErrContextBoundaryViolation", name, e)
+		}
+	}()
+	f()
+}
+
+func TestBoundaryEnforcer(t *testing.T) {
+	m := NewBoundaryEnforcingManager(newTestManager())
+	var escaped context.Context
+	committed := 0
+	err := m.Transaction(context.Background(), func(ctx context.Context) error {
+		escaped = ctx
+		if !m.InTransaction(ctx) {
+			t.Error("callback context not in transaction")
+		}
+		m.OnCommitted(ctx, func(ctx context.Context) {
+			// 提交回调的 context 不受限制.
+			if err := CheckBoundary(ctx); err != nil {
+				t.Errorf("committed callback context: %v", err)
+			}
+			committed++
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if committed != 1 {
+		t.Errorf("committed = %d, want 1", committed)
+	}
+
+	if err := CheckBoundary(escaped); !errors.Is(err, ErrContextBoundaryViolation) {
+		t.Errorf("CheckBoundary = %v, want ErrContextBoundaryViolation", err)
+	}
+	expectViolation(t, "InTransaction", func() { m.InTransaction(escaped) })
+	expectViolation(t, "OnCommitted", func() { m.OnCommitted(escaped, func(context.Context) {}) })
+	if err := m.Transaction(escaped, func(context.Context) error { return nil }); !errors.Is(err, ErrContextBoundaryViolation) {
+		t.Errorf("Transaction = %v, want ErrContextBoundaryViolation", err)
+	}
+	if err := OnCommittedStrict(escaped, m, func(context.Context) {}); !errors.Is(err, ErrContextBoundaryViolation) {
+		t.Errorf("OnCommittedStrict = %v, want ErrContextBoundaryViolation", err)
+	}
+}
+
+func TestBoundaryEnforcerGoroutine(t *testing.T) {
+	m := NewBoundaryEnforcingManager(newTestManager())
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	err := m.Transaction(context.Background(), func(ctx context.Context) error {
+		go func() {
+			<-release
+			done <- CheckBoundary(ctx)
+		}()
+		// 事务内 EscapeTransaction 的 context 不受限制.
+		return m.EscapeTransaction(ctx, func(ctx context.Context) error {
+			return CheckBoundary(ctx)
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 逃逸到新 goroutine 的 context 在事务结束后使用.
+	close(release)
+	if err := <-done; !errors.Is(err, ErrContextBoundaryViolation) {
+		t.Errorf("goroutine CheckBoundary = %v, want ErrContextBoundaryViolation", err)
+	}
+}
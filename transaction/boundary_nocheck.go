@@ -0,0 +1,6 @@
+//go:build !boundary_check
+
+package transaction
+
+// boundaryCheck 开启事务回调 context 有效范围检查.
+const boundaryCheck = false
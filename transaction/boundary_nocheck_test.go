@@ -0,0 +1,23 @@
+//go:build !boundary_check
+
+package transaction
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBoundaryEnforcerDisabled(t *testing.T) {
+	base := newTestManager()
+	if m := NewBoundaryEnforcingManager(base); m != base {
+		t.Fatalf("manager = %T, want base without boundary_check", m)
+	}
+	var escaped context.Context
+	_ = base.Transaction(context.Background(), func(ctx context.Context) error {
+		escaped = ctx
+		return nil
+	})
+	if err := CheckBoundary(escaped); err != nil {
+		t.Errorf("CheckBoundary = %v, want nil", err)
+	}
+}
@@ -0,0 +1,80 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// CompensationPolicy 定义补偿操作失败策略.
+type CompensationPolicy int
+
+const (
+	// CompensationContinue 补偿操作失败后继续执行剩余补偿操作.
+	CompensationContinue CompensationPolicy = iota
+	// CompensationStop 补偿操作失败后停止执行剩余补偿操作.
+	CompensationStop
+)
+
+// compensationKey 补偿操作在事务范围数据中的 Key.
+type compensationKey struct{}
+
+type compensation struct {
+	name string
+	undo func(context.Context) error
+	ctx  context.Context
+	tc   *transContext
+}
+
+// compensationStack 记录事务内注册的补偿操作.
+type compensationStack struct {
+	mut   sync.Mutex
+	items []compensation
+}
+
+// RegisterCompensation 在当前事务注册补偿操作.
+//
+// 注册成功返回 true, 不在事务内返回 false.
+//
+// 事务回滚时按注册的逆序在事务外执行补偿操作, 事务提交时丢弃.
+// 补偿操作失败时以包含 name 的错误交由 WithCallbackFailureHandler 处理, 未设置时忽略.
+// 是否继续执行剩余补偿操作由 WithCompensationPolicy 决定.
+func RegisterCompensation(ctx context.Context, name string, undo func(ctx context.Context) error) bool {
+	tc := currentTransContext(ctx)
+	if !tc.InTransaction() {
+		return false
+	}
+	v, loaded := tc.LoadOrStore(compensationKey{}, &compensationStack{})
+	stack := v.(*compensationStack)
+	if !loaded {
+		root := tc.root()
+		registerHook(root, func(rollbackedEvent) {
+			stack.run(root)
+		})
+	}
+
+	stack.mut.Lock()
+	defer stack.mut.Unlock()
+	stack.items = append(stack.items, compensation{name: name, undo: undo, ctx: ctx, tc: tc})
+	return true
+}
+
+// run 逆序执行已回滚事务注册的补偿操作.
+func (s *compensationStack) run(root *transContext) {
+	s.mut.Lock()
+	items := append([]compensation(nil), s.items...)
+	s.mut.Unlock()
+
+	for i := len(items) - 1; i >= 0; i-- {
+		item := items[i]
+		if item.tc.isRollbacked() == nil {
+			continue
+		}
+		if err := item.undo(root.cleanCtx(item.ctx)); err != nil {
+			root.callbackFailed(fmt.Errorf("compensation %q: %w", item.name, err))
+			if root.compensationPolicy == CompensationStop {
+				return
+			}
+		}
+	}
+}
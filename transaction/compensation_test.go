@@ -0,0 +1,87 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRegisterCompensation(t *testing.T) {
+	errRollback := errors.New("rollback")
+	errRelease := errors.New("release failed")
+
+	for _, c := range []struct {
+		policy CompensationPolicy
+		want   []string
+	}{
+		{CompensationContinue, []string{"notify", "inventory", "payment"}},
+		{CompensationStop, []string{"notify", "inventory"}},
+	} {
+		var failures []error
+		m := newTestManager(
+			WithCompensationPolicy(c.policy),
+			WithCallbackFailureHandler(func(_ context.Context, err error) {
+				failures = append(failures, err)
+			}),
+		)
+		var undone []string
+		undo := func(name string, err error) func(context.Context) error {
+			return func(ctx context.Context) error {
+				if m.InTransaction(ctx) {
+					t.Errorf("compensation %s ran inside transaction", name)
+				}
+				undone = append(undone, name)
+				return err
+			}
+		}
+
+		err := m.Transaction(context.Background(), func(ctx context.Context) error {
+			RegisterCompensation(ctx, "payment", undo("payment", nil))
+			RegisterCompensation(ctx, "inventory", undo("inventory", errRelease))
+			RegisterCompensation(ctx, "notify", undo("notify", nil))
+			return errRollback
+		})
+		if !errors.Is(err, errRollback) {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(undone, c.want) {
+			t.Errorf("policy %v: undone = %v, want %v", c.policy, undone, c.want)
+		}
+		if len(failures) != 1 || !errors.Is(failures[0], errRelease) || !strings.Contains(failures[0].Error(), `"inventory"`) {
+			t.Errorf("policy %v: failures = %v", c.policy, failures)
+		}
+	}
+}
+
+func TestRegisterCompensationCommitted(t *testing.T) {
+	m := newTestManager()
+	ctx := context.Background()
+	if RegisterCompensation(ctx, "outside", func(context.Context) error { return nil }) {
+		t.Error("RegisterCompensation returned true outside transaction")
+	}
+
+	var undone []string
+	undo := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			undone = append(undone, name)
+			return nil
+		}
+	}
+	err := m.Transaction(ctx, func(ctx context.Context) error {
+		RegisterCompensation(ctx, "root", undo("root"))
+		// 嵌套事务回滚, 根事务提交: 仅执行嵌套事务的补偿操作.
+		_ = m.Transaction(ctx, func(ctx context.Context) error {
+			RegisterCompensation(ctx, "nested", undo("nested"))
+			return errors.New("nested failed")
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(undone, []string{"nested"}) {
+		t.Errorf("undone = %v, want [nested]", undone)
+	}
+}
@@ -0,0 +1,57 @@
+package transaction
+
+import (
+	"context"
+)
+
+// Do 事务内执行回调并返回回调结果.
+//
+// 事务行为同 Manager.Transaction. 事务回滚时返回零值.
+func Do[T any](ctx context.Context, m Manager, f func(context.Context) (T, error)) (T, error) {
+	var ret T
+	err := m.Transaction(ctx, func(ctx context.Context) error {
+		v, err := f(ctx)
+		if err != nil {
+			return err
+		}
+		ret = v
+		return nil
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return ret, nil
+}
+
+// MustDo 事务内执行回调并返回回调结果.
+//
+// 事务行为同 Do, 事务异常以 panic 的形式抛出.
+func MustDo[T any](ctx context.Context, m Manager, f func(context.Context) (T, error)) T {
+	ret, err := Do(ctx, m, f)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+// TransactionWithHooks 通过 m 开启事务, 附加提交前及提交后回调.
+//
+// beforeCommit 在 callback 成功后于事务内执行, 返回错误时事务回滚, 用于提交前校验.
+// afterCommit 通过 OnCommitted 注册, 事务提交后执行. 回调为 nil 时忽略.
+func TransactionWithHooks(ctx context.Context, m Manager, beforeCommit func(context.Context) error, afterCommit func(context.Context), callback func(context.Context) error) error {
+	return m.Transaction(ctx, func(ctx context.Context) error {
+		if err := callback(ctx); err != nil {
+			return err
+		}
+		if beforeCommit != nil {
+			if err := beforeCommit(ctx); err != nil {
+				return err
+			}
+		}
+		if afterCommit != nil {
+			m.OnCommitted(ctx, afterCommit)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,117 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTransactionWithHooks(t *testing.T) {
+	m := NewTimeoutGuardManager(newTestManager())
+	ctx := context.Background()
+	var events []string
+	record := func(event string) { events = append(events, event) }
+
+	err := TransactionWithHooks(ctx, m,
+		func(ctx context.Context) error {
+			record("before commit")
+			return nil
+		},
+		func(ctx context.Context) {
+			if m.InTransaction(ctx) {
+				t.Error("afterCommit in transaction")
+			}
+			record("after commit")
+		},
+		func(ctx context.Context) error {
+			record("callback")
+			m.OnRollbacked(ctx, func(context.Context, error) { record("rollbacked") })
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"callback", "before commit", "after commit"}
+	if len(events) != len(want) {
+		t.Fatalf("events = %q, want %q", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("events = %q, want %q", events, want)
+		}
+	}
+
+	// beforeCommit 失败时回滚, 不执行 afterCommit.
+	events = nil
+	errCheck := errors.New("check")
+	err = TransactionWithHooks(ctx, m,
+		func(context.Context) error { return errCheck },
+		func(context.Context) { record("after commit") },
+		func(ctx context.Context) error {
+			m.OnRollbacked(ctx, func(context.Context, error) { record("rollbacked") })
+			return nil
+		},
+	)
+	if !errors.Is(err, errCheck) {
+		t.Fatalf("err = %v, want %v", err, errCheck)
+	}
+	if len(events) != 1 || events[0] != "rollbacked" {
+		t.Errorf("events = %q, want [rollbacked]", events)
+	}
+
+	// 回调为 nil 时忽略.
+	if err := TransactionWithHooks(ctx, m, nil, nil, func(context.Context) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDo(t *testing.T) {
+	m := newTestManager()
+	ctx := context.Background()
+	v, err := Do(ctx, m, func(ctx context.Context) (int, error) {
+		return 42, nil
+	})
+	if err != nil || v != 42 {
+		t.Errorf("Do = (%v, %v), want (42, nil)", v, err)
+	}
+
+	errRollback := errors.New("rollback")
+	v, err = Do(ctx, m, func(ctx context.Context) (int, error) {
+		return 42, errRollback
+	})
+	if !errors.Is(err, errRollback) || v != 0 {
+		t.Errorf("Do = (%v, %v), want (0, %v)", v, err, errRollback)
+	}
+
+	// 内层事务成功但外层回滚时返回零值.
+	v, err = Do(ctx, m, func(ctx context.Context) (int, error) {
+		inner, err := Do(ctx, m, func(ctx context.Context) (int, error) {
+			return 1, nil
+		})
+		if err != nil || inner != 1 {
+			t.Errorf("nested Do = (%v, %v), want (1, nil)", inner, err)
+		}
+		return inner, errRollback
+	})
+	if !errors.Is(err, errRollback) || v != 0 {
+		t.Errorf("Do = (%v, %v), want (0, %v)", v, err, errRollback)
+	}
+}
+
+func TestMustDo(t *testing.T) {
+	m := newTestManager()
+	ctx := context.Background()
+	if v := MustDo(ctx, m, func(ctx context.Context) (string, error) { return "ok", nil }); v != "ok" {
+		t.Errorf("MustDo = %q, want ok", v)
+	}
+
+	errRollback := errors.New("rollback")
+	defer func() {
+		if err, _ := recover().(error); !errors.Is(err, errRollback) {
+			t.Errorf("recovered %v, want %v", err, errRollback)
+		}
+	}()
+	MustDo(ctx, m, func(ctx context.Context) (string, error) { return "ok", errRollback })
+	t.Error("MustDo did not panic")
+}
@@ -0,0 +1,140 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// DurableAdapter 定义可靠提交回调的持久化适配器.
+type DurableAdapter interface {
+	// Persist 在事务提交前持久化回调数据, ctx 在事务内, 与业务写入同时提交.
+	Persist(ctx context.Context, txID string, payloads [][]byte) error
+	// MarkDone 在回调执行成功后标记完成.
+	MarkDone(ctx context.Context, txID string) error
+	// Pending 返回未完成的回调数据, Key 为事务 ID.
+	Pending(ctx context.Context) (map[string][][]byte, error)
+}
+
+// durableOptions 定义可靠提交回调配置.
+type durableOptions struct {
+	adapter DurableAdapter
+	handler func(ctx context.Context, payload []byte) error
+}
+
+// durableKey 可靠提交回调在事务范围数据中的 Key.
+type durableKey struct{}
+
+// durablePayloads 记录事务内注册的可靠提交回调数据.
+type durablePayloads struct {
+	mut   sync.Mutex
+	items []durableItem
+}
+
+type durableItem struct {
+	payload []byte
+	tc      *transContext
+}
+
+// OnCommittedDurable 在当前事务注册可靠提交回调.
+//
+// 注册成功返回 true, 不在事务内或未配置 WithDurableCallbacks 时返回 false.
+//
+// payload 在事务提交前通过 DurableAdapter 持久化, 提交后以 payload 调用 WithDurableCallbacks 设置的 handler,
+// 全部成功后标记完成. 进程在此之间退出时, 由 ReplayDurable 重新执行.
+func OnCommittedDurable(ctx context.Context, payload []byte) bool {
+	tc := currentTransContext(ctx)
+	if !tc.InTransaction() || tc.root().durable == nil {
+		return false
+	}
+	v, loaded := tc.LoadOrStore(durableKey{}, &durablePayloads{})
+	d := v.(*durablePayloads)
+	if !loaded {
+		root := tc.root()
+		registerHook(root, func(committedEvent) {
+			if root.isCommitted() {
+				d.run(root, root.cleanCtx(ctx))
+			}
+		})
+	}
+
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	d.items = append(d.items, durableItem{payload: payload, tc: tc})
+	return true
+}
+
+// committedPayloads 返回所在嵌套事务未失败的回调数据.
+func (d *durablePayloads) committedPayloads() [][]byte {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	var payloads [][]byte
+	for _, item := range d.items {
+		if item.tc.nestedCommitted() {
+			payloads = append(payloads, item.payload)
+		}
+	}
+	return payloads
+}
+
+// nestedCommitted 判断从当前节点到根节点之间的嵌套事务均未失败, 不检查根节点.
+func (t *transContext) nestedCommitted() bool {
+	for n := t; !n.isRoot(); n = n.parent {
+		if n.panicked || n.err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// persistDurable 在根事务提交前持久化可靠提交回调数据.
+func (t *transContext) persistDurable(ctx context.Context) error {
+	v, ok := t.Load(durableKey{})
+	if !ok {
+		return nil
+	}
+	payloads := v.(*durablePayloads).committedPayloads()
+	if len(payloads) == 0 {
+		return nil
+	}
+	return t.root().durable.adapter.Persist(ctx, t.ID(), payloads)
+}
+
+// run 执行已提交事务的可靠提交回调.
+//
+// 失败时交由 WithCallbackFailureHandler 处理, 不标记完成, 等待重放.
+func (d *durablePayloads) run(root *transContext, ctx context.Context) {
+	payloads := d.committedPayloads()
+	if len(payloads) == 0 {
+		return
+	}
+	if err := runDurable(ctx, root.durable.adapter, root.durable.handler, root.id, payloads); err != nil {
+		root.callbackFailed(err)
+	}
+}
+
+// ReplayDurable 重新执行未完成的可靠提交回调, 通常在服务启动时调用.
+//
+// 同一事务的回调可能已部分执行, handler 需保证幂等. 返回首个失败事务的错误.
+func ReplayDurable(ctx context.Context, adapter DurableAdapter, handler func(ctx context.Context, payload []byte) error) error {
+	pending, err := adapter.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	var first error
+	for txID, payloads := range pending {
+		if err := runDurable(ctx, adapter, handler, txID, payloads); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func runDurable(ctx context.Context, adapter DurableAdapter, handler func(ctx context.Context, payload []byte) error, txID string, payloads [][]byte) error {
+	for _, payload := range payloads {
+		if err := handler(ctx, payload); err != nil {
+			return fmt.Errorf("durable callback of transaction %s: %w", txID, err)
+		}
+	}
+	return adapter.MarkDone(ctx, txID)
+}
@@ -0,0 +1,91 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// EventPublisher 发布领域事件.
+type EventPublisher func(ctx context.Context, events []interface{}) error
+
+// eventsKey 领域事件在事务范围数据中的 Key.
+type eventsKey struct{}
+
+// eventBatch 按发布顺序记录事务内的领域事件.
+type eventBatch struct {
+	mut   sync.Mutex
+	items []eventItem
+}
+
+type eventItem struct {
+	event interface{}
+	tc    *transContext
+}
+
+// PublishEvent 发布领域事件.
+//
+// 发布成功返回 true, 不在事务内或未配置 WithEventPublisher 时返回 false.
+//
+// 事务内发布的事件按顺序记录在根事务, 根事务提交后以全部事件调用一次 EventPublisher,
+// 回滚时丢弃, 失败的嵌套事务内发布的事件同样丢弃.
+// EventPublisher 返回错误时交由 WithCallbackFailureHandler 处理, 未设置时忽略.
+//
+// 事务外无法确定 Manager, 不发布; 需在事务外发布时使用 db.TransProvider.PublishEvent.
+func PublishEvent(ctx context.Context, event interface{}) bool {
+	tc := currentTransContext(ctx)
+	if !tc.InTransaction() || tc.root().eventPublisher == nil {
+		return false
+	}
+	v, loaded := tc.LoadOrStore(eventsKey{}, &eventBatch{})
+	batch := v.(*eventBatch)
+	if !loaded {
+		root := tc.root()
+		registerHook(root, func(committedEvent) {
+			if root.isCommitted() {
+				batch.run(root, root.cleanCtx(ctx))
+			}
+		})
+	}
+
+	batch.mut.Lock()
+	defer batch.mut.Unlock()
+	batch.items = append(batch.items, eventItem{event: event, tc: tc})
+	return true
+}
+
+// run 以所在嵌套事务未失败的事件调用 EventPublisher.
+func (b *eventBatch) run(root *transContext, ctx context.Context) {
+	b.mut.Lock()
+	events := make([]interface{}, 0, len(b.items))
+	for _, item := range b.items {
+		if item.tc.nestedCommitted() {
+			events = append(events, item.event)
+		}
+	}
+	b.mut.Unlock()
+
+	if len(events) == 0 {
+		return
+	}
+	if err := root.eventPublisher(ctx, events); err != nil {
+		root.callbackFailed(fmt.Errorf("publish %d events: %w", len(events), err))
+	}
+}
+
+// PublishEvent 发布领域事件.
+//
+// 事务内同 PublishEvent; 事务外立即同步调用 EventPublisher, 错误交由 WithCallbackFailureHandler 处理.
+// 未配置 WithEventPublisher 时返回 false.
+func (m *manager) PublishEvent(ctx context.Context, event interface{}) bool {
+	if m.eventPublisher == nil {
+		return false
+	}
+	if m.InTransaction(ctx) {
+		return PublishEvent(ctx, event)
+	}
+	if err := m.eventPublisher(ctx, []interface{}{event}); err != nil && m.onCallbackFailure != nil {
+		m.onCallbackFailure(ctx, fmt.Errorf("publish event: %w", err))
+	}
+	return true
+}
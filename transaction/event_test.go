@@ -0,0 +1,85 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// eventRecorder 记录 EventPublisher 收到的批次.
+type eventRecorder struct {
+	batches [][]interface{}
+	err     error
+}
+
+func (r *eventRecorder) publish(_ context.Context, events []interface{}) error {
+	r.batches = append(r.batches, events)
+	return r.err
+}
+
+// eventPublishingManager 代表实现 PublishEvent 的 Manager.
+type eventPublishingManager interface {
+	Manager
+	PublishEvent(ctx context.Context, event interface{}) bool
+}
+
+func TestPublishEventBatchOnCommit(t *testing.T) {
+	r := &eventRecorder{}
+	m := newTestManager(WithEventPublisher(r.publish))
+	ctx := context.Background()
+
+	err := m.Transaction(ctx, func(ctx context.Context) error {
+		PublishEvent(ctx, 1)
+		_ = m.Transaction(ctx, func(ctx context.Context) error {
+			PublishEvent(ctx, 2)
+			return errors.New("nested failed")
+		})
+		PublishEvent(ctx, 3)
+		if len(r.batches) != 0 {
+			t.Error("events published before commit")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.batches) != 1 || len(r.batches[0]) != 2 || r.batches[0][0] != 1 || r.batches[0][1] != 3 {
+		t.Fatalf("batches = %v, want [[1 3]]", r.batches)
+	}
+
+	_ = m.Transaction(ctx, func(ctx context.Context) error {
+		PublishEvent(ctx, 4)
+		return errors.New("rollback")
+	})
+	if len(r.batches) != 1 {
+		t.Errorf("events published on rollback: %v", r.batches)
+	}
+}
+
+func TestPublishEventOutsideTransaction(t *testing.T) {
+	ctx := context.Background()
+	r := &eventRecorder{err: errors.New("broker down")}
+	var failure error
+	m := newTestManager(
+		WithEventPublisher(r.publish),
+		WithCallbackFailureHandler(func(_ context.Context, err error) { failure = err }),
+	)
+	// 包级函数在事务外不发布.
+	if PublishEvent(ctx, 1) {
+		t.Fatal("PublishEvent outside transaction returned true")
+	}
+
+	// Manager 方法以其配置的发布函数及错误处理同步发布.
+	if !m.(eventPublishingManager).PublishEvent(ctx, 1) {
+		t.Fatal("Manager.PublishEvent outside transaction returned false")
+	}
+	if len(r.batches) != 1 || len(r.batches[0]) != 1 || r.batches[0][0] != 1 {
+		t.Fatalf("batches = %v, want [[1]]", r.batches)
+	}
+	if !errors.Is(failure, r.err) {
+		t.Errorf("failure = %v, want %v", failure, r.err)
+	}
+	if newTestManager().(eventPublishingManager).PublishEvent(ctx, 1) {
+		t.Error("PublishEvent without publisher returned true")
+	}
+}
@@ -0,0 +1,142 @@
+package transaction
+
+import (
+	"context"
+)
+
+// Unwrapper 由嵌入其他事务管理器的装饰器实现, 返回被装饰的事务管理器.
+//
+// 扩展接口的包级函数沿 Unwrap 链查找实现, 装饰器无需转发扩展方法.
+type Unwrapper interface {
+	Unwrap() Manager
+}
+
+var (
+	_ StrictRegistrar = new(manager)
+	_ StrictRegistrar = new(boundaryEnforcingManager)
+	_ BatchRegistrar  = new(manager)
+	_ BatchRegistrar  = new(boundaryEnforcingManager)
+	_ ContextCopier   = new(manager)
+	_ ContextCopier   = new(boundaryEnforcingManager)
+	_ Unwrapper       = new(boundaryEnforcingManager)
+	_ Unwrapper       = new(timeoutGuardManager)
+)
+
+// extension 沿 Unwrap 链查找实现扩展接口 E 的事务管理器.
+func extension[E any](m Manager) (E, bool) {
+	for m != nil {
+		if e, ok := m.(E); ok {
+			return e, true
+		}
+		u, ok := m.(Unwrapper)
+		if !ok {
+			break
+		}
+		m = u.Unwrap()
+	}
+	var zero E
+	return zero, false
+}
+
+// StrictRegistrar 扩展 Manager, 注册回调失败时返回原因.
+type StrictRegistrar interface {
+	// OnCommittedStrict 同 Manager.OnCommitted, 注册失败时返回原因.
+	//
+	// context 未开启事务返回 ErrNotInTransaction, 事务已结束返回 ErrTransactionEnded.
+	OnCommittedStrict(ctx context.Context, callback func(context.Context)) error
+
+	// OnRollbackedStrict 同 Manager.OnRollbacked, 注册失败时返回原因.
+	//
+	// 错误同 OnCommittedStrict.
+	OnRollbackedStrict(ctx context.Context, callback func(context.Context, error)) error
+}
+
+// OnCommittedStrict 通过 m 注册事务提交回调, 注册失败时返回原因, 见 StrictRegistrar.
+//
+// m 未实现 StrictRegistrar 时通过 OnCommitted 注册, 失败返回 ErrNotInTransaction.
+func OnCommittedStrict(ctx context.Context, m Manager, callback func(context.Context)) error {
+	if r, ok := extension[StrictRegistrar](m); ok {
+		return r.OnCommittedStrict(ctx, callback)
+	}
+	if !m.OnCommitted(ctx, callback) {
+		return ErrNotInTransaction
+	}
+	return nil
+}
+
+// OnRollbackedStrict 通过 m 注册事务回滚回调, 注册失败时返回原因, 见 StrictRegistrar.
+//
+// m 未实现 StrictRegistrar 时通过 OnRollbacked 注册, 失败返回 ErrNotInTransaction.
+func OnRollbackedStrict(ctx context.Context, m Manager, callback func(context.Context, error)) error {
+	if r, ok := extension[StrictRegistrar](m); ok {
+		return r.OnRollbackedStrict(ctx, callback)
+	}
+	if !m.OnRollbacked(ctx, callback) {
+		return ErrNotInTransaction
+	}
+	return nil
+}
+
+// BatchRegistrar 扩展 Manager, 一次加锁批量注册回调.
+type BatchRegistrar interface {
+	// OnCommittedBatch 批量注册提交回调, 行为同 Manager.OnCommitted.
+	//
+	// 返回注册成功的回调数, 不在事务内时返回 0. 回调按参数顺序执行.
+	OnCommittedBatch(ctx context.Context, callbacks ...func(context.Context)) int
+
+	// OnRollbackedBatch 批量注册回滚回调, 行为同 Manager.OnRollbacked.
+	//
+	// 返回值同 OnCommittedBatch.
+	OnRollbackedBatch(ctx context.Context, callbacks ...func(context.Context, error)) int
+}
+
+// OnCommittedBatch 通过 m 批量注册事务提交回调, 返回注册成功的回调数, 见 BatchRegistrar.
+//
+// m 未实现 BatchRegistrar 时逐个通过 OnCommitted 注册.
+func OnCommittedBatch(ctx context.Context, m Manager, callbacks ...func(context.Context)) int {
+	if r, ok := extension[BatchRegistrar](m); ok {
+		return r.OnCommittedBatch(ctx, callbacks...)
+	}
+	n := 0
+	for _, callback := range callbacks {
+		if m.OnCommitted(ctx, callback) {
+			n++
+		}
+	}
+	return n
+}
+
+// OnRollbackedBatch 通过 m 批量注册事务回滚回调, 返回值同 OnCommittedBatch.
+//
+// m 未实现 BatchRegistrar 时逐个通过 OnRollbacked 注册.
+func OnRollbackedBatch(ctx context.Context, m Manager, callbacks ...func(context.Context, error)) int {
+	if r, ok := extension[BatchRegistrar](m); ok {
+		return r.OnRollbackedBatch(ctx, callbacks...)
+	}
+	n := 0
+	for _, callback := range callbacks {
+		if m.OnRollbacked(ctx, callback) {
+			n++
+		}
+	}
+	return n
+}
+
+// ContextCopier 扩展 Manager, 在 context 间传递事务标记.
+type ContextCopier interface {
+	// PropagateContext 将 src 的事务标记传递到 dst, 返回新的 context.
+	//
+	// 复制事务标记后, 设置了 WithContextCloner 时再由其处理.
+	PropagateContext(src, dst context.Context) context.Context
+}
+
+// PropagateContext 通过 m 将 src 的事务标记传递到 dst, 返回新的 context, 见 ContextCopier.
+//
+// 用于框架为 goroutine 复制请求 context, 复制结果丢失事务标记的场景.
+// m 未实现 ContextCopier 时返回 dst.
+func PropagateContext(m Manager, src, dst context.Context) context.Context {
+	if c, ok := extension[ContextCopier](m); ok {
+		return c.PropagateContext(src, dst)
+	}
+	return dst
+}
@@ -0,0 +1,169 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// wrappedManager 嵌入 Manager, 未实现扩展接口.
+type wrappedManager struct {
+	Manager
+}
+
+// unwrappableManager 嵌入 Manager 并实现 Unwrapper.
+type unwrappableManager struct {
+	Manager
+}
+
+func (m unwrappableManager) Unwrap() Manager {
+	return m.Manager
+}
+
+func TestOnCommittedStrict(t *testing.T) {
+	base := newTestManager()
+	for name, m := range map[string]Manager{
+		"manager":     base,
+		"unwrap":      unwrappableManager{base},
+		"decorated":   NewTimeoutGuardManager(base),
+		"no unwrap":   wrappedManager{base},
+		"unwrap deep": unwrappableManager{NewTimeoutGuardManager(unwrappableManager{base})},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if err := OnCommittedStrict(ctx, m, func(context.Context) {}); !errors.Is(err, ErrNotInTransaction) {
+				t.Errorf("outside transaction err = %v, want %v", err, ErrNotInTransaction)
+			}
+			if err := OnRollbackedStrict(ctx, m, func(context.Context, error) {}); !errors.Is(err, ErrNotInTransaction) {
+				t.Errorf("outside transaction err = %v, want %v", err, ErrNotInTransaction)
+			}
+
+			var committed, rollbacked int
+			var txCtx context.Context
+			err := m.Transaction(ctx, func(ctx context.Context) error {
+				txCtx = ctx
+				if err := OnCommittedStrict(ctx, m, func(context.Context) { committed++ }); err != nil {
+					return err
+				}
+				return OnRollbackedStrict(ctx, m, func(context.Context, error) { rollbacked++ })
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if committed != 1 || rollbacked != 0 {
+				t.Errorf("committed = %d, rollbacked = %d, want 1, 0", committed, rollbacked)
+			}
+
+			// 未实现扩展接口时无法区分事务已结束.
+			want := ErrTransactionEnded
+			if _, ok := m.(wrappedManager); ok {
+				want = ErrNotInTransaction
+			}
+			if err := OnCommittedStrict(txCtx, m, func(context.Context) {}); !errors.Is(err, want) {
+				t.Errorf("ended transaction err = %v, want %v", err, want)
+			}
+		})
+	}
+}
+
+func TestWithRegistrationDebug(t *testing.T) {
+	var logs []string
+	m := newTestManager(WithRegistrationDebug(func(format string, args ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}))
+	if m.OnCommitted(context.Background(), func(context.Context) {}) {
+		t.Fatal("OnCommitted outside transaction = true")
+	}
+	if len(logs) != 1 || !strings.Contains(logs[0], ErrNotInTransaction.Error()) || !strings.Contains(logs[0], "TestWithRegistrationDebug") {
+		t.Errorf("logs = %q, want the reason and stack", logs)
+	}
+	// 严格方法返回错误, 不输出日志.
+	_ = OnCommittedStrict(context.Background(), m, func(context.Context) {})
+	if len(logs) != 1 {
+		t.Errorf("strict registration logged %d times", len(logs)-1)
+	}
+}
+
+type clonedKey struct{}
+
+func TestPropagateContext(t *testing.T) {
+	m := newTestManager(WithContextCloner(func(src, dst context.Context) context.Context {
+		return context.WithValue(dst, clonedKey{}, true)
+	}))
+	committed := 0
+	err := m.Transaction(context.Background(), func(ctx context.Context) error {
+		dst := PropagateContext(unwrappableManager{m}, ctx, context.Background())
+		if !m.InTransaction(dst) || dst.Value(clonedKey{}) != true {
+			t.Error("transaction not propagated")
+		}
+		if !m.OnCommitted(dst, func(context.Context) { committed++ }) {
+			t.Error("OnCommitted with propagated context = false")
+		}
+		if dst := PropagateContext(wrappedManager{m}, ctx, context.Background()); m.InTransaction(dst) {
+			t.Error("propagated without ContextCopier")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if committed != 1 {
+		t.Errorf("committed = %d, want 1", committed)
+	}
+	// 事务外无事务标记, 仍由 cloner 处理.
+	if dst := PropagateContext(m, context.Background(), context.Background()); m.InTransaction(dst) || dst.Value(clonedKey{}) != true {
+		t.Error("unexpected propagation outside transaction")
+	}
+}
+
+func TestOnCommittedBatch(t *testing.T) {
+	base := newTestManager()
+	errRollback := errors.New("rollback")
+	for name, m := range map[string]Manager{
+		"unwrap":    unwrappableManager{base},
+		"no unwrap": wrappedManager{base},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			noop := func(context.Context) {}
+			if n := OnCommittedBatch(ctx, m, noop, noop); n != 0 {
+				t.Errorf("outside transaction registered %d", n)
+			}
+
+			var order []int
+			err := m.Transaction(ctx, func(ctx context.Context) error {
+				n := OnCommittedBatch(ctx, m,
+					func(context.Context) { order = append(order, 1) },
+					func(context.Context) { order = append(order, 2) },
+				)
+				if n != 2 {
+					t.Errorf("registered %d, want 2", n)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(order) != 2 || order[0] != 1 || order[1] != 2 {
+				t.Errorf("order = %v, want [1 2]", order)
+			}
+
+			var rollbacked []error
+			err = m.Transaction(ctx, func(ctx context.Context) error {
+				onRollback := func(_ context.Context, err error) { rollbacked = append(rollbacked, err) }
+				if n := OnRollbackedBatch(ctx, m, onRollback, onRollback); n != 2 {
+					t.Errorf("registered %d, want 2", n)
+				}
+				return errRollback
+			})
+			if !errors.Is(err, errRollback) {
+				t.Fatalf("err = %v, want %v", err, errRollback)
+			}
+			if len(rollbacked) != 2 || !errors.Is(rollbacked[0], errRollback) {
+				t.Errorf("rollbacked = %v", rollbacked)
+			}
+		})
+	}
+}
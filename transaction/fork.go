@@ -0,0 +1,45 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrNotInTransaction = errors.New("not in transaction")
+)
+
+type readOnlyCtxKey struct{}
+
+// ForkContext 为事务内启动的 goroutine 创建只读 context.
+//
+// 返回的 context 已清除事务标记, 并标记为只读, 资源提供方据此返回独立的读库连接,
+// 不与当前事务共享 DB.
+//
+// 只读 context 读取不到当前事务未提交的数据.
+//
+// 不在事务内时返回 ErrNotInTransaction.
+func ForkContext(ctx context.Context, m Manager) (context.Context, error) {
+	if !m.InTransaction(ctx) {
+		return nil, ErrNotInTransaction
+	}
+	var forked context.Context
+	_ = m.EscapeTransaction(ctx, func(ctx context.Context) error {
+		forked = ctx
+		return nil
+	})
+	return WithReadOnly(forked), nil
+}
+
+// WithReadOnly 标记 context 为只读, 资源提供方据此在事务外优先使用读库.
+//
+// 不清除事务标记, 事务内仍使用事务 DB.
+func WithReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyCtxKey{}, true)
+}
+
+// IsReadOnly 判断 context 是否为只读 context.
+func IsReadOnly(ctx context.Context) bool {
+	readOnly, _ := ctx.Value(readOnlyCtxKey{}).(bool)
+	return readOnly
+}
@@ -0,0 +1,71 @@
+package transaction
+
+import (
+	"context"
+	"reflect"
+)
+
+// Event 代表事务生命周期事件, 以事件类型区分.
+type Event interface{}
+
+// committedEvent 事务提交事件.
+type committedEvent struct{}
+
+// rollbackedEvent 事务回滚事件.
+type rollbackedEvent struct{}
+
+var (
+	committedEventType  = eventTypeOf[committedEvent]()
+	rollbackedEventType = eventTypeOf[rollbackedEvent]()
+)
+
+// currentTransCtxKey 当前事务上下文在 context 中存储的 Key.
+//
+// 用于不持有事务管理器的包级函数查找事务上下文.
+type currentTransCtxKey struct{}
+
+// currentTransContext 查找 context 最近开启的事务上下文.
+func currentTransContext(ctx context.Context) *transContext {
+	tc, _ := ctx.Value(currentTransCtxKey{}).(*transContext)
+	return tc
+}
+
+func eventTypeOf[E Event]() reflect.Type {
+	return reflect.TypeOf((*E)(nil)).Elem()
+}
+
+// RegisterHook 在当前事务注册事件回调.
+//
+// 注册成功返回 true, 不在事务内返回 false.
+//
+// 回调注册到根事务, 嵌套事务共享.
+func RegisterHook[E Event](ctx context.Context, hook func(E)) bool {
+	tc := currentTransContext(ctx)
+	if !tc.InTransaction() {
+		return false
+	}
+	registerHook(tc, hook)
+	return true
+}
+
+// FireHook 在当前事务触发事件, 按注册顺序同步执行事件回调.
+//
+// 触发成功返回 true, 不在事务内返回 false.
+func FireHook[E Event](ctx context.Context, event E) bool {
+	tc := currentTransContext(ctx)
+	if !tc.InTransaction() {
+		return false
+	}
+	fireHook(tc, event)
+	return true
+}
+
+func registerHook[E Event](tc *transContext, hook func(E)) {
+	tc.addHook(eventTypeOf[E](), hook)
+}
+
+func fireHook[E Event](tc *transContext, event E) {
+	for _, hook := range tc.hooks(eventTypeOf[E]()) {
+		hook.(func(E))(event)
+	}
+}
@@ -0,0 +1,56 @@
+package transaction
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// savepointEvent 测试用自定义事件.
+type savepointEvent struct {
+	name string
+}
+
+func TestCustomHook(t *testing.T) {
+	m := newTestManager()
+	ctx := context.Background()
+
+	if RegisterHook(ctx, func(savepointEvent) {}) {
+		t.Error("RegisterHook returned true outside transaction")
+	}
+	if FireHook(ctx, savepointEvent{}) {
+		t.Error("FireHook returned true outside transaction")
+	}
+
+	var trace []string
+	err := m.Transaction(ctx, func(ctx context.Context) error {
+		RegisterHook(ctx, func(e savepointEvent) {
+			trace = append(trace, "savepoint "+e.name)
+		})
+		// 其他类型的事件回调不受影响.
+		RegisterHook(ctx, func(string) {
+			t.Error("string hook fired by savepointEvent")
+		})
+		m.OnCommitted(ctx, func(context.Context) {
+			trace = append(trace, "committed")
+		})
+		return m.Transaction(ctx, func(ctx context.Context) error {
+			// 嵌套事务共享根事务注册的回调.
+			RegisterHook(ctx, func(e savepointEvent) {
+				trace = append(trace, "nested savepoint "+e.name)
+			})
+			if !FireHook(ctx, savepointEvent{name: "sp1"}) {
+				t.Error("FireHook returned false inside transaction")
+			}
+			trace = append(trace, "callback end")
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"savepoint sp1", "nested savepoint sp1", "callback end", "committed"}
+	if !reflect.DeepEqual(trace, want) {
+		t.Errorf("trace = %v, want %v", trace, want)
+	}
+}
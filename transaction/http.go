@@ -0,0 +1,116 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// HTTPStatusError 代表响应状态码导致的事务回滚.
+type HTTPStatusError struct {
+	Status int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("http status %d", e.Status)
+}
+
+// HTTPOption 定义 HTTPMiddleware 选项.
+type HTTPOption func(*httpOptions)
+
+type httpOptions struct {
+	skip    func(*http.Request) bool
+	onError func(*http.Request, error)
+}
+
+// WithHTTPSkipper 设置跳过事务的请求, 用于按路由关闭事务.
+func WithHTTPSkipper(skip func(*http.Request) bool) HTTPOption {
+	return func(o *httpOptions) {
+		o.skip = skip
+	}
+}
+
+// WithHTTPErrorHandler 设置事务异常处理.
+//
+// 事务回滚或提交失败时调用, 此时响应可能已写出.
+func WithHTTPErrorHandler(onError func(*http.Request, error)) HTTPOption {
+	return func(o *httpOptions) {
+		o.onError = onError
+	}
+}
+
+type skipHTTPCtxKey struct{}
+
+// SkipHTTPTransaction 标记请求不开启事务.
+//
+// 需在 HTTPMiddleware 之前的中间件中设置到请求 context.
+func SkipHTTPTransaction(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipHTTPCtxKey{}, true)
+}
+
+// HTTPMiddleware 创建每个请求一个事务的 HTTP 中间件.
+//
+// 响应状态码小于 400 时提交事务, 否则回滚并以 *HTTPStatusError 调用异常处理.
+// handler panic 时回滚事务并重新触发 panic.
+//
+// 未写出状态码时视为 200. 事务在 handler 返回后结束, 流式响应先写出状态码与响应体,
+// 提交失败无法再修改已写出的响应, 仅通过 WithHTTPErrorHandler 通知.
+func HTTPMiddleware(m Manager, opts ...HTTPOption) func(http.Handler) http.Handler {
+	o := &httpOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if skip, _ := r.Context().Value(skipHTTPCtxKey{}).(bool); skip || (o.skip != nil && o.skip(r)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			err := m.Transaction(r.Context(), func(ctx context.Context) error {
+				next.ServeHTTP(sw, r.WithContext(ctx))
+				if sw.status >= http.StatusBadRequest {
+					return &HTTPStatusError{Status: sw.status}
+				}
+				return nil
+			})
+			if err != nil && o.onError != nil {
+				o.onError(r, err)
+			}
+		})
+	}
+}
+
+// statusWriter 记录响应状态码.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush 支持流式响应.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap 支持 http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
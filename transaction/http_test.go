@@ -0,0 +1,102 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	m := newTestManager()
+	var outcome string
+	var handlerErr error
+	mw := HTTPMiddleware(m,
+		WithHTTPSkipper(func(r *http.Request) bool {
+			return r.URL.Path == "/skip"
+		}),
+		WithHTTPErrorHandler(func(_ *http.Request, err error) {
+			handlerErr = err
+		}),
+	)
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if !m.InTransaction(ctx) {
+			outcome = "no transaction"
+			return
+		}
+		m.OnCommitted(ctx, func(context.Context) {
+			outcome = "committed"
+		})
+		m.OnRollbacked(ctx, func(context.Context, error) {
+			outcome = "rollbacked"
+		})
+		switch r.URL.Path {
+		case "/created":
+			w.WriteHeader(http.StatusCreated)
+		case "/bad":
+			http.Error(w, "bad", http.StatusBadRequest)
+		case "/panic":
+			panic("handler failed")
+		case "/stream":
+			// 流式响应: 状态码先写出, 事务在响应体写完后提交.
+			w.WriteHeader(http.StatusOK)
+			for i := 0; i < 3; i++ {
+				_, _ = w.Write([]byte("chunk"))
+				w.(http.Flusher).Flush()
+				if outcome != "" {
+					t.Error("transaction ended while streaming")
+				}
+			}
+		default:
+			_, _ = w.Write([]byte("ok"))
+		}
+	}))
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		outcome, handlerErr = "", nil
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	for path, want := range map[string]string{
+		"/":        "committed",
+		"/created": "committed",
+		"/stream":  "committed",
+		"/bad":     "rollbacked",
+		"/skip":    "no transaction",
+	} {
+		serve(httptest.NewRequest(http.MethodGet, path, nil))
+		if outcome != want {
+			t.Errorf("%s: outcome = %q, want %q", path, outcome, want)
+		}
+	}
+
+	serve(httptest.NewRequest(http.MethodGet, "/bad", nil))
+	var statusErr *HTTPStatusError
+	if !errors.As(handlerErr, &statusErr) || statusErr.Status != http.StatusBadRequest {
+		t.Errorf("error handler err = %v, want http status 400", handlerErr)
+	}
+
+	// 通过 context 标记跳过事务.
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	serve(r.WithContext(SkipHTTPTransaction(r.Context())))
+	if outcome != "no transaction" {
+		t.Errorf("skipped by context: outcome = %q", outcome)
+	}
+
+	// panic 时回滚并重新触发.
+	func() {
+		defer func() {
+			if e := recover(); e != "handler failed" {
+				t.Errorf("recovered %v, want handler failed", e)
+			}
+		}()
+		serve(httptest.NewRequest(http.MethodGet, "/panic", nil))
+	}()
+	if outcome != "rollbacked" {
+		t.Errorf("/panic: outcome = %q, want rollbacked", outcome)
+	}
+}
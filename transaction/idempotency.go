@@ -0,0 +1,80 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultIdempotencyTTL 幂等 Key 默认保留时长.
+const DefaultIdempotencyTTL = 10 * time.Minute
+
+var (
+	ErrAlreadyCommitted = errors.New("transaction already committed")
+)
+
+type idempotencyCtxKey struct{}
+
+// WithIdempotencyKey 为 context 设置幂等 Key.
+//
+// 以该 context 开启的根事务提交后记录 Key, 保留期内以相同 Key 开启的根事务
+// 不执行回调并返回 ErrAlreadyCommitted. 嵌套事务忽略幂等 Key.
+//
+// Key 仅记录在当前进程内存中, 并发执行的相同 Key 事务均会执行.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyCtxKey{}, key)
+}
+
+func idempotencyKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyCtxKey{}).(string)
+	return key
+}
+
+// idempotencyStore 记录已提交的幂等 Key 及过期时间.
+type idempotencyStore struct {
+	ttl  time.Duration
+	keys sync.Map
+
+	mut       sync.Mutex
+	lastSweep time.Time
+}
+
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{ttl: ttl, lastSweep: time.Now()}
+}
+
+// committed 判断 Key 是否在保留期内已提交.
+func (s *idempotencyStore) committed(key string) bool {
+	v, ok := s.keys.Load(key)
+	if !ok {
+		return false
+	}
+	if time.Now().After(v.(time.Time)) {
+		s.keys.Delete(key)
+		return false
+	}
+	return true
+}
+
+// commit 记录已提交的 Key, 并定期清理过期 Key.
+func (s *idempotencyStore) commit(key string) {
+	now := time.Now()
+	s.keys.Store(key, now.Add(s.ttl))
+
+	s.mut.Lock()
+	sweep := now.Sub(s.lastSweep) > s.ttl
+	if sweep {
+		s.lastSweep = now
+	}
+	s.mut.Unlock()
+	if !sweep {
+		return
+	}
+	s.keys.Range(func(k, v interface{}) bool {
+		if now.After(v.(time.Time)) {
+			s.keys.Delete(k)
+		}
+		return true
+	})
+}
@@ -0,0 +1,75 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	m := newTestManager()
+	ctx := WithIdempotencyKey(context.Background(), "k1")
+	runs := 0
+	callback := func(context.Context) error {
+		runs++
+		return nil
+	}
+
+	if err := m.Transaction(ctx, callback); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Transaction(ctx, callback); !errors.Is(err, ErrAlreadyCommitted) {
+		t.Errorf("retry err = %v, want ErrAlreadyCommitted", err)
+	}
+	if runs != 1 {
+		t.Errorf("callback ran %d times, want 1", runs)
+	}
+
+	// 其他 Key 不受影响.
+	if err := m.Transaction(WithIdempotencyKey(context.Background(), "k2"), callback); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 2 {
+		t.Errorf("callback ran %d times, want 2", runs)
+	}
+}
+
+func TestIdempotencyKeyRollback(t *testing.T) {
+	m := newTestManager()
+	ctx := WithIdempotencyKey(context.Background(), "k1")
+	errFailed := errors.New("failed")
+
+	// 回滚的事务不记录 Key, 重试时正常执行.
+	if err := m.Transaction(ctx, func(context.Context) error { return errFailed }); !errors.Is(err, errFailed) {
+		t.Fatalf("err = %v, want %v", err, errFailed)
+	}
+	runs := 0
+	if err := m.Transaction(ctx, func(context.Context) error { runs++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 1 {
+		t.Errorf("callback ran %d times after rollback, want 1", runs)
+	}
+}
+
+func TestIdempotencyTTL(t *testing.T) {
+	m := newTestManager(WithIdempotencyTTL(20 * time.Millisecond))
+	ctx := WithIdempotencyKey(context.Background(), "k1")
+	runs := 0
+	callback := func(context.Context) error {
+		runs++
+		return nil
+	}
+
+	if err := m.Transaction(ctx, callback); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if err := m.Transaction(ctx, callback); err != nil {
+		t.Errorf("err after ttl = %v, want nil", err)
+	}
+	if runs != 2 {
+		t.Errorf("callback ran %d times, want 2", runs)
+	}
+}
@@ -0,0 +1,71 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Invalidator 定义缓存失效处理.
+type Invalidator interface {
+	// Invalidate 在事务提交后以去重的 Key 调用.
+	Invalidate(ctx context.Context, keys []string) error
+}
+
+// invalidationKey 缓存失效 Key 集合在事务范围数据中的 Key.
+type invalidationKey struct{}
+
+// invalidationSet 记录事务内需要失效的缓存 Key.
+type invalidationSet struct {
+	mut  sync.Mutex
+	keys map[string]struct{}
+}
+
+// InvalidateKeys 在当前事务登记提交后需要失效的缓存 Key.
+//
+// 登记成功返回 true, 不在事务内或未配置 WithInvalidator 时返回 false.
+//
+// 嵌套事务登记的 Key 合并到根事务, 根事务提交后以去重排序的 Key 调用一次 Invalidator,
+// 回滚时丢弃. Invalidator 返回错误时交由 WithCallbackFailureHandler 处理, 未设置时忽略.
+func InvalidateKeys(ctx context.Context, keys ...string) bool {
+	tc := currentTransContext(ctx)
+	if !tc.InTransaction() || tc.root().invalidator == nil {
+		return false
+	}
+	v, loaded := tc.LoadOrStore(invalidationKey{}, &invalidationSet{keys: make(map[string]struct{})})
+	set := v.(*invalidationSet)
+	if !loaded {
+		root := tc.root()
+		registerHook(root, func(committedEvent) {
+			if root.isCommitted() {
+				set.run(root, root.cleanCtx(ctx))
+			}
+		})
+	}
+
+	set.mut.Lock()
+	defer set.mut.Unlock()
+	for _, key := range keys {
+		set.keys[key] = struct{}{}
+	}
+	return true
+}
+
+// run 以去重排序的 Key 调用 Invalidator.
+func (s *invalidationSet) run(root *transContext, ctx context.Context) {
+	s.mut.Lock()
+	keys := make([]string, 0, len(s.keys))
+	for key := range s.keys {
+		keys = append(keys, key)
+	}
+	s.mut.Unlock()
+
+	if len(keys) == 0 {
+		return
+	}
+	sort.Strings(keys)
+	if err := root.invalidator.Invalidate(ctx, keys); err != nil {
+		root.callbackFailed(fmt.Errorf("invalidate %d keys: %w", len(keys), err))
+	}
+}
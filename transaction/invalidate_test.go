@@ -0,0 +1,71 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// invalidatorFunc 以函数实现 Invalidator.
+type invalidatorFunc func(ctx context.Context, keys []string) error
+
+func (f invalidatorFunc) Invalidate(ctx context.Context, keys []string) error {
+	return f(ctx, keys)
+}
+
+func TestInvalidateKeys(t *testing.T) {
+	var calls [][]string
+	var inTx bool
+	var m Manager
+	m = newTestManager(WithInvalidator(invalidatorFunc(func(ctx context.Context, keys []string) error {
+		inTx = m.InTransaction(ctx)
+		calls = append(calls, keys)
+		return nil
+	})))
+	ctx := context.Background()
+
+	if InvalidateKeys(ctx, "user:1") {
+		t.Error("InvalidateKeys returned true outside transaction")
+	}
+	err := m.Transaction(ctx, func(ctx context.Context) error {
+		InvalidateKeys(ctx, "user:2", "user:1")
+		InvalidateKeys(ctx, "user:1")
+		if len(calls) != 0 {
+			t.Error("invalidated before commit")
+		}
+		// 嵌套事务的 Key 合并到根事务.
+		return m.Transaction(ctx, func(ctx context.Context) error {
+			InvalidateKeys(ctx, "order:1", "user:2")
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"order:1", "user:1", "user:2"}}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("invalidate calls = %v, want %v", calls, want)
+	}
+	if inTx {
+		t.Error("Invalidator called inside transaction")
+	}
+
+	// 回滚时不失效.
+	calls = nil
+	_ = m.Transaction(ctx, func(ctx context.Context) error {
+		InvalidateKeys(ctx, "user:3")
+		return errors.New("rollback")
+	})
+	if len(calls) != 0 {
+		t.Errorf("invalidate calls after rollback = %v", calls)
+	}
+
+	// 未配置 Invalidator 时不登记.
+	m = newTestManager()
+	_ = m.Transaction(ctx, func(ctx context.Context) error {
+		if InvalidateKeys(ctx, "user:4") {
+			t.Error("InvalidateKeys returned true without invalidator")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,20 @@
+package transaction
+
+import (
+	"context"
+)
+
+type labelCtxKey struct{}
+
+// WithLabel 设置事务标签.
+//
+// 标签在开启根事务时记录, 用于日志、SQL 注释等场景区分业务事务.
+func WithLabel(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, labelCtxKey{}, label)
+}
+
+// labelFromContext 读取事务标签.
+func labelFromContext(ctx context.Context) string {
+	label, _ := ctx.Value(labelCtxKey{}).(string)
+	return label
+}
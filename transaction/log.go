@@ -0,0 +1,36 @@
+package transaction
+
+import (
+	"context"
+)
+
+// finishedTransCtxKey 已结束事务上下文在 context 中存储的 Key.
+//
+// 提交及回滚回调的 context 携带该值, 用于在回调内输出事务信息.
+type finishedTransCtxKey struct{}
+
+func withFinishedTransContext(ctx context.Context, tc *transContext) context.Context {
+	return context.WithValue(ctx, finishedTransCtxKey{}, tc)
+}
+
+// LogFields 返回当前事务的结构化日志字段.
+//
+// 字段包括 tx_db 库名, tx_id 事务 ID, tx_depth 嵌套深度, tx_label 事务标签,
+// tx_elapsed 事务耗时. 提交及回滚回调内返回已结束事务的字段, 不在事务内返回空 map.
+func LogFields(ctx context.Context) map[string]any {
+	tc := currentTransContext(ctx)
+	if !tc.InTransaction() {
+		tc, _ = ctx.Value(finishedTransCtxKey{}).(*transContext)
+	}
+	if tc == nil {
+		return map[string]any{}
+	}
+	root := tc.root()
+	return map[string]any{
+		"tx_db":      root.dbName,
+		"tx_id":      root.id,
+		"tx_depth":   tc.depth(),
+		"tx_label":   root.label,
+		"tx_elapsed": tc.elapsed(),
+	}
+}
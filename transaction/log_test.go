@@ -0,0 +1,48 @@
+package transaction
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLogFields(t *testing.T) {
+	m := newTestManager(WithDBName(func(context.Context) string {
+		return "orders"
+	}))
+	ctx := WithLabel(context.Background(), "checkout")
+	if fields := LogFields(ctx); len(fields) != 0 {
+		t.Errorf("fields outside transaction = %v", fields)
+	}
+
+	var root, nested, committed map[string]any
+	err := m.Transaction(ctx, func(ctx context.Context) error {
+		root = LogFields(ctx)
+		m.OnCommitted(ctx, func(ctx context.Context) {
+			committed = LogFields(ctx)
+		})
+		return m.Transaction(ctx, func(ctx context.Context) error {
+			time.Sleep(time.Millisecond)
+			nested = LogFields(ctx)
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, _ := root["tx_id"].(string)
+	if id == "" || root["tx_db"] != "orders" || root["tx_label"] != "checkout" || root["tx_depth"] != 1 {
+		t.Errorf("root fields = %v", root)
+	}
+	if nested["tx_id"] != id || nested["tx_depth"] != 2 {
+		t.Errorf("nested fields = %v, want tx %s at depth 2", nested, id)
+	}
+	if elapsed, _ := nested["tx_elapsed"].(time.Duration); elapsed < time.Millisecond {
+		t.Errorf("nested tx_elapsed = %v", nested["tx_elapsed"])
+	}
+	// 提交回调内返回已结束事务的字段.
+	if committed["tx_id"] != id || committed["tx_db"] != "orders" {
+		t.Errorf("committed fields = %v, want tx %s", committed, id)
+	}
+}
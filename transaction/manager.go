@@ -2,7 +2,20 @@ package transaction
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"runtime/debug"
+	"runtime/pprof"
+	"time"
+)
+
+var (
+	ErrNestedTransactionDisallowed = errors.New("nested transaction disallowed")
+	ErrTransactionEnded            = errors.New("transaction ended")
+	// ErrOutcomeUnknown 事务提交结果未知, 如提交超时.
+	//
+	// 事务实现返回的错误匹配 ErrOutcomeUnknown 时, 不执行提交及回滚回调.
+	ErrOutcomeUnknown = errors.New("transaction outcome unknown")
 )
 
 type manager struct {
@@ -12,6 +25,33 @@ type manager struct {
 	lookupDB func(context.Context) interface{}
 	// 实现事务开启并通过回调返回新 DB.
 	transaction func(ctx context.Context, db interface{}, callback func(db interface{}, bind func(context.Context)) error) error
+
+	// 提交回调并发数.
+	committedParallelism int
+	// 事务回调异常处理.
+	onCallbackFailure func(ctx context.Context, err error)
+	// 补偿操作失败策略.
+	compensationPolicy CompensationPolicy
+	// 为根事务回调设置 pprof 标签.
+	pprofLabels bool
+	// 返回事务库名.
+	dbName func(context.Context) string
+	// 禁止嵌套事务.
+	disallowNested bool
+	// 可靠提交回调配置.
+	durable *durableOptions
+	// 缓存失效处理.
+	invalidator Invalidator
+	// 领域事件发布.
+	eventPublisher EventPublisher
+	// 已提交的幂等 Key.
+	idempotency *idempotencyStore
+	// 传递事务标记到复制的 context.
+	contextCloner ContextCloner
+	// 记录事务调用位置.
+	callStackCapture bool
+	// 回调注册失败时输出日志.
+	registrationDebug func(format string, args ...interface{})
 }
 
 func NewManager(
@@ -21,12 +61,19 @@ func NewManager(
 	lookupDB func(context.Context) interface{},
 // 实现事务执行并通过回调返回新 DB.
 	transaction func(ctx context.Context, db interface{}, callback func(db interface{}, bindCtx func(context.Context)) error) error,
+// 事务管理器选项.
+	opts ...ManagerOption,
 ) Manager {
-	return &manager{
+	m := &manager{
 		ctxKeyF:     ctxKeyF,
 		lookupDB:    lookupDB,
 		transaction: transaction,
+		idempotency: newIdempotencyStore(DefaultIdempotencyTTL),
+	}
+	for _, opt := range opts {
+		opt(m)
 	}
+	return m
 }
 
 func (m *manager) InTransaction(ctx context.Context) bool {
@@ -35,15 +82,26 @@ func (m *manager) InTransaction(ctx context.Context) bool {
 }
 
 func (m *manager) Transaction(ctx context.Context, callback func(context.Context) error) error {
+	inTransaction := m.InTransaction(ctx)
+	if m.disallowNested && inTransaction {
+		return ErrNestedTransactionDisallowed
+	}
+	idempotencyKey := ""
+	if !inTransaction {
+		idempotencyKey = idempotencyKeyFromContext(ctx)
+	}
+	if idempotencyKey != "" && m.idempotency.committed(idempotencyKey) {
+		return ErrAlreadyCommitted
+	}
 	var transCtx *transContext
 	defer func() {
 		if transCtx == nil {
 			return
 		}
-		transCtx.done = true
+		transCtx.Finish()
 
 		// 没有回滚监测，不捕获 panic.
-		if len(transCtx.onRollbackedCallbacks) <= 0 {
+		if len(transCtx.hooks(rollbackedEventType)) <= 0 {
 			return
 		}
 
@@ -59,16 +117,55 @@ func (m *manager) Transaction(ctx context.Context, callback func(context.Context
 		}
 	}()
 
+	var caller *TransactionFrame
+	if m.callStackCapture {
+		caller = captureCaller()
+	}
+	outerCtx := ctx
 	prevTransCtx, db := m.findDBAndTransContext(ctx)
 	err := m.transaction(ctx, db, func(db interface{}, bindCtx func(context.Context)) error {
-		transCtx = prevTransCtx.Start(db)
+		transCtx = prevTransCtx.Start(ctx, db)
+		if caller != nil {
+			caller.Depth = transCtx.depth()
+			caller.StartedAt = time.Now()
+			transCtx.caller = caller
+		}
+		if transCtx.isRoot() {
+			transCtx.committedParallelism = m.committedParallelism
+			transCtx.compensationPolicy = m.compensationPolicy
+			transCtx.cleanCtx = m.cleanTransContext
+			transCtx.durable = m.durable
+			transCtx.invalidator = m.invalidator
+			transCtx.eventPublisher = m.eventPublisher
+			if m.dbName != nil {
+				transCtx.dbName = m.dbName(ctx)
+			}
+			if m.onCallbackFailure != nil {
+				transCtx.onCallbackFailure = func(err error) {
+					m.onCallbackFailure(outerCtx, err)
+				}
+			}
+		}
 		ctx = m.setTransContext(ctx, transCtx)
 		if bindCtx != nil {
 			bindCtx(ctx)
 		}
-		return callback(ctx)
+		var err error
+		if m.pprofLabels && transCtx.isRoot() {
+			err = m.doWithPprofLabels(ctx, transCtx, callback)
+		} else {
+			err = callback(ctx)
+		}
+		// 提交前持久化可靠提交回调.
+		if err == nil && transCtx.isRoot() && transCtx.durable != nil {
+			err = transCtx.persistDurable(ctx)
+		}
+		return err
 	})
 	transCtx.End(false, err)
+	if idempotencyKey != "" && err == nil {
+		m.idempotency.commit(idempotencyKey)
+	}
 	return err
 }
 
@@ -86,27 +183,112 @@ func (m *manager) EscapeTransaction(ctx context.Context, callback func(context.C
 }
 
 func (m *manager) OnCommitted(ctx context.Context, callback func(context.Context)) bool {
-	transCtx := m.findTransContext(ctx)
-	if !transCtx.InTransaction() {
-		// 未开启事务.
+	if err := m.OnCommittedStrict(ctx, callback); err != nil {
+		m.debugRegistration("OnCommitted", err)
 		return false
 	}
-	// 在事务外执行, 需要清理 context.
-	transCtx.OnCommitted(func() { callback(m.cleanTransContext(ctx)) })
 	return true
 }
 
 func (m *manager) OnRollbacked(ctx context.Context, callback func(context.Context, error)) bool {
-	transCtx := m.findTransContext(ctx)
-	if transCtx == nil {
-		// 未开启事务.
+	if err := m.OnRollbackedStrict(ctx, callback); err != nil {
+		m.debugRegistration("OnRollbacked", err)
 		return false
 	}
+	return true
+}
+
+func (m *manager) OnCommittedStrict(ctx context.Context, callback func(context.Context)) error {
+	transCtx, err := m.registrationTransContext(ctx)
+	if err != nil {
+		return err
+	}
+	// 在事务外执行, 需要清理 context.
+	transCtx.OnCommitted(func() { callback(withFinishedTransContext(m.cleanTransContext(ctx), transCtx)) })
+	return nil
+}
+
+func (m *manager) OnRollbackedStrict(ctx context.Context, callback func(context.Context, error)) error {
+	transCtx, err := m.registrationTransContext(ctx)
+	if err != nil {
+		return err
+	}
 	// 在事务外执行, 需要清理 context.
 	transCtx.OnRollbacked(func(err error) {
-		callback(m.cleanTransContext(ctx), err)
+		callback(withFinishedTransContext(m.cleanTransContext(ctx), transCtx), err)
 	})
-	return true
+	return nil
+}
+
+func (m *manager) OnCommittedBatch(ctx context.Context, callbacks ...func(context.Context)) int {
+	transCtx, err := m.registrationTransContext(ctx)
+	if err != nil {
+		m.debugRegistration("OnCommittedBatch", err)
+		return 0
+	}
+	wrapped := make([]func(), 0, len(callbacks))
+	for _, callback := range callbacks {
+		callback := callback
+		wrapped = append(wrapped, func() { callback(withFinishedTransContext(m.cleanTransContext(ctx), transCtx)) })
+	}
+	transCtx.OnCommittedBatch(wrapped)
+	return len(wrapped)
+}
+
+func (m *manager) OnRollbackedBatch(ctx context.Context, callbacks ...func(context.Context, error)) int {
+	transCtx, err := m.registrationTransContext(ctx)
+	if err != nil {
+		m.debugRegistration("OnRollbackedBatch", err)
+		return 0
+	}
+	wrapped := make([]func(error), 0, len(callbacks))
+	for _, callback := range callbacks {
+		callback := callback
+		wrapped = append(wrapped, func(err error) {
+			callback(withFinishedTransContext(m.cleanTransContext(ctx), transCtx), err)
+		})
+	}
+	transCtx.OnRollbackedBatch(wrapped)
+	return len(wrapped)
+}
+
+func (m *manager) PropagateContext(src, dst context.Context) context.Context {
+	if transCtx := m.findTransContext(src); transCtx != nil {
+		dst = m.setTransContext(dst, transCtx)
+	}
+	if m.contextCloner != nil {
+		dst = m.contextCloner(src, dst)
+	}
+	return dst
+}
+
+// registrationTransContext 返回可注册回调的事务上下文.
+func (m *manager) registrationTransContext(ctx context.Context) (*transContext, error) {
+	transCtx := m.findTransContext(ctx)
+	if transCtx == nil {
+		// 未开启事务.
+		return nil, ErrNotInTransaction
+	}
+	if !transCtx.InTransaction() {
+		return nil, fmt.Errorf("%w: %s", ErrTransactionEnded, transCtx.ID())
+	}
+	return transCtx, nil
+}
+
+// debugRegistration 输出回调注册失败原因及调用栈.
+func (m *manager) debugRegistration(method string, err error) {
+	if m.registrationDebug != nil {
+		m.registrationDebug("transaction: %s registration failed: %v\n%s", method, err, debug.Stack())
+	}
+}
+
+// doWithPprofLabels 以事务 pprof 标签执行回调.
+func (m *manager) doWithPprofLabels(ctx context.Context, tc *transContext, callback func(context.Context) error) error {
+	var err error
+	pprof.Do(ctx, pprof.Labels("tx_db", tc.dbName, "tx_label", tc.label), func(ctx context.Context) {
+		err = callback(ctx)
+	})
+	return err
 }
 
 // findTransContext 查找事务上下文.
@@ -132,12 +314,18 @@ func (m *manager) findDBAndTransContext(ctx context.Context) (*transContext, int
 }
 
 func (m *manager) setTransContext(ctx context.Context, tc *transContext) context.Context {
-	return context.WithValue(ctx, m.ctxKeyF(ctx), tc)
+	ctx = context.WithValue(ctx, m.ctxKeyF(ctx), tc)
+	return context.WithValue(ctx, currentTransCtxKey{}, tc)
 }
 
 func (m *manager) cleanTransContext(ctx context.Context) context.Context {
-	if !m.findTransContext(ctx).InTransaction() {
+	tc := m.findTransContext(ctx)
+	if tc == nil {
 		return ctx
 	}
-	return context.WithValue(ctx, m.ctxKeyF(ctx), nil)
+	ctx = context.WithValue(ctx, m.ctxKeyF(ctx), nil)
+	if current := currentTransContext(ctx); current != nil && current.root() == tc.root() {
+		ctx = context.WithValue(ctx, currentTransCtxKey{}, nil)
+	}
+	return ctx
 }
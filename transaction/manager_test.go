@@ -0,0 +1,199 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testCtxKey struct{}
+
+// newTestManager 创建不依赖数据库的事务管理器, 回调返回错误时视为回滚.
+func newTestManager(opts ...ManagerOption) Manager {
+	return NewManager(
+		func(context.Context) interface{} {
+			return testCtxKey{}
+		},
+		func(context.Context) interface{} {
+			return struct{}{}
+		},
+		func(ctx context.Context, db interface{}, callback func(db interface{}, bindCtx func(context.Context)) error) error {
+			return callback(db, nil)
+		},
+		opts...,
+	)
+}
+
+func TestNestedRollbackError(t *testing.T) {
+	m := newTestManager()
+	errNested := errors.New("nested failed")
+
+	var got error
+	var committed bool
+	err := m.Transaction(context.Background(), func(ctx context.Context) error {
+		m.OnCommitted(ctx, func(context.Context) {
+			committed = true
+		})
+		// 嵌套事务的异常被根事务吞掉, 根事务正常提交.
+		_ = m.Transaction(ctx, func(ctx context.Context) error {
+			if !m.OnRollbacked(ctx, func(_ context.Context, err error) {
+				got = err
+			}) {
+				t.Fatal("OnRollbacked returned false inside nested transaction")
+			}
+			return errNested
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !committed {
+		t.Error("root OnCommitted callback not called")
+	}
+	if !errors.Is(got, errNested) {
+		t.Errorf("OnRollbacked error = %v, want %v", got, errNested)
+	}
+}
+
+func TestParallelCommittedCallbacks(t *testing.T) {
+	var (
+		mut      sync.Mutex
+		failures []error
+	)
+	m := newTestManager(
+		WithParallelCommittedCallbacks(2),
+		WithCallbackFailureHandler(func(_ context.Context, err error) {
+			mut.Lock()
+			defer mut.Unlock()
+			failures = append(failures, err)
+		}),
+	)
+
+	var running, maxRunning, done int32
+	err := m.Transaction(context.Background(), func(ctx context.Context) error {
+		for i := 0; i < 6; i++ {
+			m.OnCommitted(ctx, func(context.Context) {
+				n := atomic.AddInt32(&running, 1)
+				defer atomic.AddInt32(&running, -1)
+				for {
+					prev := atomic.LoadInt32(&maxRunning)
+					if n <= prev || atomic.CompareAndSwapInt32(&maxRunning, prev, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				atomic.AddInt32(&done, 1)
+			})
+		}
+		m.OnCommitted(ctx, func(context.Context) {
+			panic("purge failed")
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Transaction 等待全部回调结束后返回.
+	if n := atomic.LoadInt32(&done); n != 6 {
+		t.Errorf("%d callbacks done when Transaction returned, want 6", n)
+	}
+	if maxRunning != 2 {
+		t.Errorf("max concurrent callbacks = %d, want 2", maxRunning)
+	}
+	if len(failures) != 1 || failures[0].Error() != "panic: purge failed" {
+		t.Errorf("failures = %v, want [panic: purge failed]", failures)
+	}
+}
+
+func TestPprofLabels(t *testing.T) {
+	labelsOf := func(ctx context.Context) map[string]string {
+		labels := make(map[string]string)
+		pprof.ForLabels(ctx, func(key, value string) bool {
+			labels[key] = value
+			return true
+		})
+		return labels
+	}
+	dbName := func(context.Context) string {
+		return "orders"
+	}
+	ctx := WithLabel(context.Background(), "checkout")
+
+	var labels, nestedLabels map[string]string
+	m := newTestManager(WithPprofLabels(dbName))
+	err := m.Transaction(ctx, func(ctx context.Context) error {
+		labels = labelsOf(ctx)
+		return m.Transaction(WithLabel(ctx, "nested"), func(ctx context.Context) error {
+			nestedLabels = labelsOf(ctx)
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"tx_db": "orders", "tx_label": "checkout"}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("labels = %v, want %v", labels, want)
+	}
+	// 仅根事务设置标签.
+	if !reflect.DeepEqual(nestedLabels, want) {
+		t.Errorf("nested labels = %v, want %v", nestedLabels, want)
+	}
+
+	// 默认关闭.
+	m = newTestManager(WithDBName(dbName))
+	_ = m.Transaction(ctx, func(ctx context.Context) error {
+		labels = labelsOf(ctx)
+		return nil
+	})
+	if len(labels) != 0 {
+		t.Errorf("labels without WithPprofLabels = %v", labels)
+	}
+}
+
+func TestDisallowNested(t *testing.T) {
+	m := newTestManager(DisallowNested())
+	ctx := context.Background()
+
+	var nestedErr, escapedErr error
+	var panicked interface{}
+	err := m.Transaction(ctx, func(ctx context.Context) error {
+		nestedErr = m.Transaction(ctx, func(context.Context) error {
+			t.Error("nested callback called")
+			return nil
+		})
+		func() {
+			defer func() {
+				panicked = recover()
+			}()
+			m.MustTransaction(ctx, func(context.Context) {
+				t.Error("nested MustTransaction callback called")
+			})
+		}()
+		// 逃脱事务后可开启新事务.
+		escapedErr = m.EscapeTransaction(ctx, func(ctx context.Context) error {
+			return m.Transaction(ctx, func(context.Context) error {
+				return nil
+			})
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(nestedErr, ErrNestedTransactionDisallowed) {
+		t.Errorf("nested Transaction err = %v, want %v", nestedErr, ErrNestedTransactionDisallowed)
+	}
+	if err, _ := panicked.(error); !errors.Is(err, ErrNestedTransactionDisallowed) {
+		t.Errorf("nested MustTransaction panicked with %v, want %v", panicked, ErrNestedTransactionDisallowed)
+	}
+	if escapedErr != nil {
+		t.Errorf("Transaction after EscapeTransaction err = %v", escapedErr)
+	}
+}
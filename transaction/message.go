@@ -0,0 +1,91 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+)
+
+// MessageOption 定义 ProcessMessage 选项.
+type MessageOption func(*messageOptions)
+
+type messageOptions struct {
+	isPoison   func(error) bool
+	deadLetter func(ctx context.Context, cause error) error
+}
+
+// WithPoisonMessage 设置毒消息处理.
+//
+// 处理失败且 isPoison 返回 true 时, 不调用 nack, 在独立的新事务中调用 deadLetter
+// 记录死信, 死信事务提交后确认消息. 死信事务失败时以死信错误调用 nack.
+func WithPoisonMessage(isPoison func(error) bool, deadLetter func(ctx context.Context, cause error) error) MessageOption {
+	return func(o *messageOptions) {
+		o.isPoison = isPoison
+		o.deadLetter = deadLetter
+	}
+}
+
+// ProcessMessage 在事务内处理一条消息, 事务提交后确认消息.
+//
+// ack 通过 OnCommitted 执行, 返回的错误交由 WithCallbackFailureHandler 处理,
+// 未设置时由 ProcessMessage 返回. nack 通过 OnRollbacked 以回滚原因执行.
+//
+// 返回事务错误. 毒消息成功记录死信时返回 nil.
+func ProcessMessage(
+	m Manager,
+	ctx context.Context,
+	handler func(ctx context.Context) error,
+	ack func(ctx context.Context) error,
+	nack func(ctx context.Context, err error),
+	opts ...MessageOption,
+) error {
+	o := &messageOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	isPoison := func(err error) bool {
+		return o.isPoison != nil && o.isPoison(err)
+	}
+
+	var ackErr error
+	onCommitted := func(ctx context.Context) {
+		tc := currentTransContext(ctx)
+		m.OnCommitted(ctx, func(ctx context.Context) {
+			if err := ack(ctx); err != nil {
+				err = fmt.Errorf("ack message: %w", err)
+				if !tc.callbackFailed(err) {
+					ackErr = err
+				}
+			}
+		})
+	}
+
+	err := m.Transaction(ctx, func(ctx context.Context) error {
+		onCommitted(ctx)
+		m.OnRollbacked(ctx, func(ctx context.Context, err error) {
+			if !isPoison(err) {
+				nack(ctx, err)
+			}
+		})
+		return handler(ctx)
+	})
+	if err == nil || !isPoison(err) {
+		if err == nil {
+			return ackErr
+		}
+		return err
+	}
+
+	// 毒消息在新事务中记录死信.
+	cause := err
+	err = m.EscapeTransaction(ctx, func(ctx context.Context) error {
+		return m.Transaction(ctx, func(ctx context.Context) error {
+			onCommitted(ctx)
+			return o.deadLetter(ctx, cause)
+		})
+	})
+	if err != nil {
+		nack(ctx, err)
+		return err
+	}
+	return ackErr
+}
@@ -0,0 +1,108 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestProcessMessage(t *testing.T) {
+	m := newTestManager()
+	ctx := context.Background()
+	errHandler := errors.New("handler failed")
+	errPoison := errors.New("malformed message")
+	errAck := errors.New("broker unavailable")
+
+	var (
+		acks       int
+		nacks      []error
+		deadLetter []error
+		ackErr     error
+	)
+	ack := func(context.Context) error {
+		acks++
+		return ackErr
+	}
+	nack := func(_ context.Context, err error) {
+		nacks = append(nacks, err)
+	}
+	poison := WithPoisonMessage(
+		func(err error) bool {
+			return errors.Is(err, errPoison)
+		},
+		func(ctx context.Context, cause error) error {
+			if !m.InTransaction(ctx) {
+				t.Error("dead letter recorded outside transaction")
+			}
+			deadLetter = append(deadLetter, cause)
+			return nil
+		},
+	)
+	process := func(handlerErr error, opts ...MessageOption) error {
+		acks, nacks, deadLetter = 0, nil, nil
+		return ProcessMessage(m, ctx, func(ctx context.Context) error {
+			// 提交前不确认消息.
+			if acks != 0 {
+				t.Error("message acked before commit")
+			}
+			return handlerErr
+		}, ack, nack, opts...)
+	}
+
+	if err := process(nil, poison); err != nil || acks != 1 || len(nacks) != 0 {
+		t.Errorf("success: err %v, acks %d, nacks %v", err, acks, nacks)
+	}
+
+	err := process(errHandler, poison)
+	if !errors.Is(err, errHandler) || acks != 0 || len(nacks) != 1 || !errors.Is(nacks[0], errHandler) {
+		t.Errorf("failure: err %v, acks %d, nacks %v", err, acks, nacks)
+	}
+
+	// 毒消息记录死信后确认.
+	err = process(errPoison, poison)
+	if err != nil || acks != 1 || len(nacks) != 0 || len(deadLetter) != 1 || !errors.Is(deadLetter[0], errPoison) {
+		t.Errorf("poison: err %v, acks %d, nacks %v, dead letters %v", err, acks, nacks, deadLetter)
+	}
+
+	// 未设置毒消息处理时按普通失败处理.
+	err = process(errPoison)
+	if !errors.Is(err, errPoison) || acks != 0 || len(nacks) != 1 {
+		t.Errorf("poison without option: err %v, acks %d, nacks %v", err, acks, nacks)
+	}
+
+	// 死信事务失败时 nack.
+	errDeadLetter := errors.New("dead letter table missing")
+	err = process(errPoison, WithPoisonMessage(
+		func(error) bool { return true },
+		func(context.Context, error) error { return errDeadLetter },
+	))
+	if !errors.Is(err, errDeadLetter) || acks != 0 || len(nacks) != 1 || !errors.Is(nacks[0], errDeadLetter) {
+		t.Errorf("dead letter failure: err %v, acks %d, nacks %v", err, acks, nacks)
+	}
+
+	// ack 失败时返回错误.
+	ackErr = errAck
+	if err := process(nil); !errors.Is(err, errAck) || acks != 1 {
+		t.Errorf("ack failure: err %v, acks %d", err, acks)
+	}
+}
+
+func TestProcessMessageAckFailureHandler(t *testing.T) {
+	var failures []error
+	m := newTestManager(WithCallbackFailureHandler(func(_ context.Context, err error) {
+		failures = append(failures, err)
+	}))
+	errAck := errors.New("broker unavailable")
+
+	err := ProcessMessage(m, context.Background(),
+		func(context.Context) error { return nil },
+		func(context.Context) error { return errAck },
+		func(context.Context, error) { t.Error("nack called after commit") },
+	)
+	if err != nil {
+		t.Errorf("err = %v, want nil", err)
+	}
+	if len(failures) != 1 || !errors.Is(failures[0], errAck) {
+		t.Errorf("failures = %v, want [%v]", failures, errAck)
+	}
+}
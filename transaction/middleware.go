@@ -0,0 +1,21 @@
+package transaction
+
+// Middleware 定义事务管理器装饰器.
+type Middleware func(Manager) Manager
+
+// Chain 按顺序组合中间件.
+//
+// 左侧中间件位于最外层: 进入时由外向内, 退出时由内向外.
+func Chain(middlewares ...Middleware) Middleware {
+	return func(m Manager) Manager {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			m = middlewares[i](m)
+		}
+		return m
+	}
+}
+
+// Build 使用中间件装饰事务管理器.
+func Build(base Manager, middlewares ...Middleware) Manager {
+	return Chain(middlewares...)(base)
+}
@@ -0,0 +1,50 @@
+package transaction
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// tracingManager 记录 Transaction 的进入与退出.
+type tracingManager struct {
+	Manager
+	name  string
+	trace *[]string
+}
+
+func (m tracingManager) Transaction(ctx context.Context, callback func(context.Context) error) error {
+	*m.trace = append(*m.trace, "enter "+m.name)
+	defer func() {
+		*m.trace = append(*m.trace, "exit "+m.name)
+	}()
+	return m.Manager.Transaction(ctx, callback)
+}
+
+func TestChain(t *testing.T) {
+	var trace []string
+	tracing := func(name string) Middleware {
+		return func(m Manager) Manager {
+			return tracingManager{Manager: m, name: name, trace: &trace}
+		}
+	}
+
+	m := Build(newTestManager(), tracing("a"), tracing("b"), tracing("c"))
+	err := m.Transaction(context.Background(), func(ctx context.Context) error {
+		trace = append(trace, "callback")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"enter a", "enter b", "enter c", "callback", "exit c", "exit b", "exit a"}
+	if !reflect.DeepEqual(trace, want) {
+		t.Errorf("trace = %v, want %v", trace, want)
+	}
+
+	// 不带中间件时返回原管理器.
+	base := newTestManager()
+	if got := Chain()(base); got != base {
+		t.Error("Chain() did not return the base manager")
+	}
+}
@@ -0,0 +1,58 @@
+package transaction
+
+import (
+	"context"
+	"sync"
+)
+
+// onceKey AcquireOnce 在事务范围数据中的 Key.
+type onceKey struct {
+	key string
+}
+
+// onceEntry 记录 AcquireOnce 回调执行状态.
+type onceEntry struct {
+	mut sync.Mutex
+	// 成功执行 f 的事务节点, 该节点或其外层嵌套事务失败后失效.
+	doneBy *transContext
+}
+
+// AcquireOnce 在当前事务内对同一 key 仅执行一次 f.
+//
+// 事务内 f 执行成功后, 以相同 key 再次调用直接返回 nil; f 返回错误时不记录, 下次调用重新执行.
+// key 在根事务范围内有效, 嵌套事务共享. f 所在的嵌套事务失败回滚后记录失效, 下次调用重新执行.
+// 不在事务内时总是执行 f.
+//
+// f 持有 key 对应的锁执行, f 内不可以相同 key 调用 AcquireOnce, 否则死锁.
+func AcquireOnce(ctx context.Context, key string, f func() error) error {
+	tc := currentTransContext(ctx)
+	if !tc.InTransaction() {
+		return f()
+	}
+	v, _ := tc.LoadOrStore(onceKey{key: key}, &onceEntry{})
+	entry := v.(*onceEntry)
+
+	entry.mut.Lock()
+	defer entry.mut.Unlock()
+	if entry.doneBy != nil && !entry.doneBy.nestedRolledBack() {
+		return nil
+	}
+	if err := f(); err != nil {
+		return err
+	}
+	entry.doneBy = tc
+	return nil
+}
+
+// nestedRolledBack 判断从当前节点到根节点之间是否有已结束且失败的嵌套事务, 不检查根节点.
+func (t *transContext) nestedRolledBack() bool {
+	root := t.root()
+	root.mut.Lock()
+	defer root.mut.Unlock()
+	for n := t; !n.isRoot(); n = n.parent {
+		if n.done && (n.panicked || n.err != nil) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAcquireOnce(t *testing.T) {
+	m := newTestManager()
+	runs := 0
+	f := func() error {
+		runs++
+		return nil
+	}
+
+	err := m.Transaction(context.Background(), func(ctx context.Context) error {
+		for i := 0; i < 2; i++ {
+			if err := AcquireOnce(ctx, "audit", f); err != nil {
+				return err
+			}
+		}
+		// 嵌套事务共享根事务的 key.
+		return m.Transaction(ctx, func(ctx context.Context) error {
+			return AcquireOnce(ctx, "audit", f)
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs != 1 {
+		t.Errorf("f ran %d times in transaction, want 1", runs)
+	}
+
+	// 新事务及事务外重新执行.
+	_ = m.Transaction(context.Background(), func(ctx context.Context) error {
+		return AcquireOnce(ctx, "audit", f)
+	})
+	for i := 0; i < 2; i++ {
+		_ = AcquireOnce(context.Background(), "audit", f)
+	}
+	if runs != 4 {
+		t.Errorf("f ran %d times, want 4", runs)
+	}
+}
+
+func TestAcquireOnceError(t *testing.T) {
+	m := newTestManager()
+	errFailed := errors.New("failed")
+	runs := 0
+
+	_ = m.Transaction(context.Background(), func(ctx context.Context) error {
+		if err := AcquireOnce(ctx, "k", func() error { runs++; return errFailed }); !errors.Is(err, errFailed) {
+			t.Errorf("err = %v, want %v", err, errFailed)
+		}
+		// 失败不记录, 再次调用重新执行.
+		if err := AcquireOnce(ctx, "k", func() error { runs++; return nil }); err != nil {
+			t.Error(err)
+		}
+		return nil
+	})
+	if runs != 2 {
+		t.Errorf("f ran %d times, want 2", runs)
+	}
+}
+
+func TestAcquireOnceNestedRollback(t *testing.T) {
+	m := newTestManager()
+	errFailed := errors.New("failed")
+	runs := 0
+	f := func() error {
+		runs++
+		return nil
+	}
+
+	err := m.Transaction(context.Background(), func(ctx context.Context) error {
+		// f 所在的嵌套事务失败, 记录失效.
+		_ = m.Transaction(ctx, func(ctx context.Context) error {
+			if err := AcquireOnce(ctx, "k", f); err != nil {
+				return err
+			}
+			return errFailed
+		})
+		if err := AcquireOnce(ctx, "k", f); err != nil {
+			return err
+		}
+		// 成功的嵌套事务内执行的记录保留.
+		if err := m.Transaction(ctx, func(ctx context.Context) error {
+			return AcquireOnce(ctx, "n", f)
+		}); err != nil {
+			return err
+		}
+		return AcquireOnce(ctx, "n", f)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs != 3 {
+		t.Errorf("f ran %d times, want 3", runs)
+	}
+}
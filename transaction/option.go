@@ -0,0 +1,124 @@
+package transaction
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// ManagerOption 定义事务管理器选项.
+type ManagerOption func(*manager)
+
+// WithCallbackFailureHandler 设置事务回调异常处理.
+//
+// 并发执行的提交回调 panic 时, 以 panic 转换的错误调用 handler.
+// ctx 为开启事务时传入的 context.
+func WithCallbackFailureHandler(handler func(ctx context.Context, err error)) ManagerOption {
+	return func(m *manager) {
+		m.onCallbackFailure = handler
+	}
+}
+
+// WithParallelCommittedCallbacks 并发执行提交回调.
+//
+// limit 为最大并发数, 小于等于 1 时串行执行.
+//
+// Transaction 等待全部提交回调结束后返回. 回调 panic 交由 WithCallbackFailureHandler
+// 处理, 未设置时在全部回调结束后重新触发首个 panic.
+//
+// 回滚回调通常为有顺序要求的补偿操作, 始终串行执行.
+func WithParallelCommittedCallbacks(limit int) ManagerOption {
+	return func(m *manager) {
+		m.committedParallelism = limit
+	}
+}
+
+// WithCompensationPolicy 设置补偿操作失败策略, 默认为 CompensationContinue.
+func WithCompensationPolicy(policy CompensationPolicy) ManagerOption {
+	return func(m *manager) {
+		m.compensationPolicy = policy
+	}
+}
+
+// WithPprofLabels 为根事务回调设置 pprof 标签.
+//
+// 回调通过 pprof.Do 执行, 标签 tx_db 为 dbName 返回的库名, tx_label 为事务标签,
+// 便于按事务归类 CPU profile 采样. dbName 为 nil 时使用 WithDBName 设置的库名.
+//
+// 默认关闭, 开启后每个根事务有少量额外开销.
+func WithPprofLabels(dbName func(context.Context) string) ManagerOption {
+	return func(m *manager) {
+		m.pprofLabels = true
+		if dbName != nil {
+			m.dbName = dbName
+		}
+	}
+}
+
+// DisallowNested 禁止嵌套事务.
+//
+// context 已在当前管理器事务内时, Transaction 返回 ErrNestedTransactionDisallowed 而非加入外层事务,
+// MustTransaction 以该错误 panic. EscapeTransaction 后的 context 可开启新事务.
+func DisallowNested() ManagerOption {
+	return func(m *manager) {
+		m.disallowNested = true
+	}
+}
+
+// WithDBName 设置根事务开启时获取库名的函数, 用于 LogFields 等诊断信息.
+func WithDBName(dbName func(context.Context) string) ManagerOption {
+	return func(m *manager) {
+		m.dbName = dbName
+	}
+}
+
+// WithDurableCallbacks 开启可靠提交回调, 见 OnCommittedDurable.
+//
+// handler 以注册的 payload 执行回调, 需保证幂等.
+func WithDurableCallbacks(adapter DurableAdapter, handler func(ctx context.Context, payload []byte) error) ManagerOption {
+	return func(m *manager) {
+		m.durable = &durableOptions{adapter: adapter, handler: handler}
+	}
+}
+
+// WithInvalidator 设置缓存失效处理, 见 InvalidateKeys.
+func WithInvalidator(invalidator Invalidator) ManagerOption {
+	return func(m *manager) {
+		m.invalidator = invalidator
+	}
+}
+
+// WithEventPublisher 设置领域事件发布, 见 PublishEvent.
+func WithEventPublisher(publisher EventPublisher) ManagerOption {
+	return func(m *manager) {
+		m.eventPublisher = publisher
+	}
+}
+
+// WithContextCloner 设置 PropagateContext 复制事务标记后的额外处理.
+//
+// 框架以其他方式保存 context 状态时, 通过 cloner 将携带事务标记的 context 写回框架.
+func WithContextCloner(cloner ContextCloner) ManagerOption {
+	return func(m *manager) {
+		m.contextCloner = cloner
+	}
+}
+
+// WithRegistrationDebug OnCommitted 及 OnRollbacked 注册失败时输出原因及调用栈.
+//
+// logf 为 nil 时使用 log.Printf. 用于排查回调未执行的问题, 不建议在生产环境开启.
+func WithRegistrationDebug(logf func(format string, args ...interface{})) ManagerOption {
+	if logf == nil {
+		logf = log.Printf
+	}
+	return func(m *manager) {
+		m.registrationDebug = logf
+	}
+}
+
+// WithIdempotencyTTL 设置幂等 Key 保留时长, 默认为 DefaultIdempotencyTTL.
+func WithIdempotencyTTL(ttl time.Duration) ManagerOption {
+	return func(m *manager) {
+		m.idempotency = newIdempotencyStore(ttl)
+	}
+}
@@ -0,0 +1,85 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var (
+	ErrInvalidPropagation = errors.New("invalid propagated transaction")
+)
+
+const (
+	// PropagationIDKey 载体中事务 ID 的 Key.
+	PropagationIDKey = "x-transaction-id"
+	// PropagationDepthKey 载体中事务嵌套深度的 Key.
+	PropagationDepthKey = "x-transaction-depth"
+)
+
+// Carrier 代表跨进程传递事务信息的载体, 如 HTTP Header 或 gRPC metadata.
+type Carrier interface {
+	// Get 返回 key 对应的值, 不存在时返回空.
+	Get(key string) string
+	// Set 设置 key 对应的值.
+	Set(key, value string)
+}
+
+// ContextPropagator 在 context 与载体间传递事务信息, 用于跨服务调用时在日志及 tracing 中关联事务.
+//
+// 仅传递事务 ID 及嵌套深度, 不传递数据库连接, 下游服务不加入上游事务.
+type ContextPropagator interface {
+	// Inject 写入当前事务信息到载体, 不在事务内时不写入.
+	Inject(ctx context.Context, carrier Carrier) error
+	// Extract 从载体读取上游事务信息, 返回携带 RemoteTransaction 的 context.
+	Extract(ctx context.Context, carrier Carrier) (context.Context, error)
+}
+
+// RemoteTransaction 代表上游服务的事务信息.
+type RemoteTransaction struct {
+	ID    string
+	Depth int
+}
+
+type remoteTransCtxKey struct{}
+
+// RemoteTransactionFrom 返回 Extract 写入 context 的上游事务信息.
+func RemoteTransactionFrom(ctx context.Context) (RemoteTransaction, bool) {
+	remote, ok := ctx.Value(remoteTransCtxKey{}).(RemoteTransaction)
+	return remote, ok
+}
+
+// TextMapPropagator 通过 PropagationIDKey 及 PropagationDepthKey 传递事务信息.
+type TextMapPropagator struct{}
+
+var _ ContextPropagator = TextMapPropagator{}
+
+// Inject 写入当前事务的 ID 及嵌套深度.
+//
+// 不在事务内但 context 携带上游事务信息时, 原样写入上游事务信息, 用于经过无事务的中间服务继续传递.
+func (TextMapPropagator) Inject(ctx context.Context, carrier Carrier) error {
+	remote, ok := RemoteTransactionFrom(ctx)
+	if tc := currentTransContext(ctx); tc.InTransaction() {
+		remote, ok = RemoteTransaction{ID: tc.ID(), Depth: tc.depth()}, true
+	}
+	if !ok {
+		return nil
+	}
+	carrier.Set(PropagationIDKey, remote.ID)
+	carrier.Set(PropagationDepthKey, strconv.Itoa(remote.Depth))
+	return nil
+}
+
+// Extract 读取上游事务信息, 载体不含事务 ID 时返回原 context, 嵌套深度无效时返回 ErrInvalidPropagation.
+func (TextMapPropagator) Extract(ctx context.Context, carrier Carrier) (context.Context, error) {
+	id := carrier.Get(PropagationIDKey)
+	if id == "" {
+		return ctx, nil
+	}
+	depth, err := strconv.Atoi(carrier.Get(PropagationDepthKey))
+	if err != nil || depth < 1 {
+		return ctx, fmt.Errorf("%w: depth %q", ErrInvalidPropagation, carrier.Get(PropagationDepthKey))
+	}
+	return context.WithValue(ctx, remoteTransCtxKey{}, RemoteTransaction{ID: id, Depth: depth}), nil
+}
@@ -0,0 +1,67 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// mapCarrier 以 map 实现 Carrier.
+type mapCarrier map[string]string
+
+func (c mapCarrier) Get(key string) string { return c[key] }
+
+func (c mapCarrier) Set(key, value string) { c[key] = value }
+
+func TestTextMapPropagator(t *testing.T) {
+	m := newTestManager()
+	p := TextMapPropagator{}
+	carrier := mapCarrier{}
+	var id string
+	_ = m.Transaction(context.Background(), func(ctx context.Context) error {
+		id = currentTransContext(ctx).ID()
+		return m.Transaction(ctx, func(ctx context.Context) error {
+			return p.Inject(ctx, carrier)
+		})
+	})
+	if carrier[PropagationIDKey] != id || carrier[PropagationDepthKey] != "2" {
+		t.Fatalf("carrier = %v, want id %s depth 2", carrier, id)
+	}
+
+	ctx, err := p.Extract(context.Background(), carrier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, ok := RemoteTransactionFrom(ctx)
+	if !ok || remote.ID != id || remote.Depth != 2 {
+		t.Fatalf("remote = %+v, %v", remote, ok)
+	}
+	// 下游未开启事务时原样继续传递.
+	forwarded := mapCarrier{}
+	if err := p.Inject(ctx, forwarded); err != nil {
+		t.Fatal(err)
+	}
+	if forwarded[PropagationIDKey] != id || forwarded[PropagationDepthKey] != "2" {
+		t.Errorf("forwarded carrier = %v", forwarded)
+	}
+	// 不传递数据库连接, 下游不在事务内.
+	if m.InTransaction(ctx) {
+		t.Error("extracted context in transaction")
+	}
+}
+
+func TestTextMapPropagatorEmptyAndInvalid(t *testing.T) {
+	p := TextMapPropagator{}
+	carrier := mapCarrier{}
+	if err := p.Inject(context.Background(), carrier); err != nil || len(carrier) != 0 {
+		t.Errorf("inject outside transaction = %v, carrier %v", err, carrier)
+	}
+	ctx, err := p.Extract(context.Background(), carrier)
+	if _, ok := RemoteTransactionFrom(ctx); ok || err != nil {
+		t.Errorf("extract from empty carrier = %v", err)
+	}
+	_, err = p.Extract(context.Background(), mapCarrier{PropagationIDKey: "tx", PropagationDepthKey: "zero"})
+	if !errors.Is(err, ErrInvalidPropagation) {
+		t.Errorf("err = %v, want ErrInvalidPropagation", err)
+	}
+}
@@ -0,0 +1,60 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// TimeoutError 代表事务因 context 超时或取消失败, 由 TimeoutGuard 以 panic 抛出.
+type TimeoutError struct {
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("transaction timeout: %v", e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// timeoutGuardManager 将根事务的超时错误转换为 panic.
+type timeoutGuardManager struct {
+	Manager
+}
+
+// NewTimeoutGuardManager 装饰事务管理器, 根事务返回 context.DeadlineExceeded 或 context.Canceled 时
+// 以 *TimeoutError panic, 用于由框架中间件统一处理超时. 其他错误正常返回.
+//
+// 嵌套事务不转换, 错误交由外层事务返回.
+func NewTimeoutGuardManager(base Manager) Manager {
+	return &timeoutGuardManager{Manager: base}
+}
+
+// TimeoutGuard 返回 NewTimeoutGuardManager 中间件.
+func TimeoutGuard() Middleware {
+	return NewTimeoutGuardManager
+}
+
+func (m *timeoutGuardManager) Unwrap() Manager {
+	return m.Manager
+}
+
+func (m *timeoutGuardManager) Transaction(ctx context.Context, callback func(context.Context) error) error {
+	root := !m.Manager.InTransaction(ctx)
+	err := m.Manager.Transaction(ctx, callback)
+	if root && (errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)) {
+		panic(&TimeoutError{Err: err})
+	}
+	return err
+}
+
+func (m *timeoutGuardManager) MustTransaction(ctx context.Context, callback func(context.Context)) {
+	if err := m.Transaction(ctx, func(ctx context.Context) error {
+		callback(ctx)
+		return nil
+	}); err != nil {
+		panic(err)
+	}
+}
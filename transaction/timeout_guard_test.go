@@ -0,0 +1,66 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// recoverTimeout 执行 f 并返回 panic 的 *TimeoutError.
+func recoverTimeout(t *testing.T, f func() error) (timeoutErr *TimeoutError, err error) {
+	t.Helper()
+	defer func() {
+		if e := recover(); e != nil {
+			var ok bool
+			if timeoutErr, ok = e.(*TimeoutError); !ok {
+				t.Fatalf("panic %T(%v), want *TimeoutError", e, e)
+			}
+		}
+	}()
+	return nil, f()
+}
+
+func TestTimeoutGuard(t *testing.T) {
+	m := NewTimeoutGuardManager(newTestManager())
+	for _, cause := range []error{context.DeadlineExceeded, context.Canceled} {
+		timeoutErr, _ := recoverTimeout(t, func() error {
+			return m.Transaction(context.Background(), func(context.Context) error {
+				return cause
+			})
+		})
+		if timeoutErr == nil || !errors.Is(timeoutErr, cause) {
+			t.Errorf("panic = %v, want TimeoutError wrapping %v", timeoutErr, cause)
+		}
+	}
+
+	// 其他错误正常返回.
+	errFailed := errors.New("failed")
+	timeoutErr, err := recoverTimeout(t, func() error {
+		return m.Transaction(context.Background(), func(context.Context) error {
+			return errFailed
+		})
+	})
+	if timeoutErr != nil || !errors.Is(err, errFailed) {
+		t.Errorf("panic = %v, err = %v, want err %v", timeoutErr, err, errFailed)
+	}
+}
+
+func TestTimeoutGuardNested(t *testing.T) {
+	m := NewTimeoutGuardManager(newTestManager())
+	var nestedErr error
+	timeoutErr, _ := recoverTimeout(t, func() error {
+		return m.Transaction(context.Background(), func(ctx context.Context) error {
+			// 嵌套事务不转换, 由根事务转换.
+			nestedErr = m.Transaction(ctx, func(context.Context) error {
+				return context.DeadlineExceeded
+			})
+			return nestedErr
+		})
+	})
+	if !errors.Is(nestedErr, context.DeadlineExceeded) {
+		t.Errorf("nested err = %v, want context.DeadlineExceeded", nestedErr)
+	}
+	if timeoutErr == nil {
+		t.Error("root transaction did not panic")
+	}
+}
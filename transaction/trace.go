@@ -0,0 +1,87 @@
+package transaction
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// TransactionFrame 事务开启位置.
+type TransactionFrame struct {
+	// 事务嵌套深度, 根事务为 1.
+	Depth int
+	// 调用 Transaction 的函数, 文件及行号.
+	CallerFunc string
+	CallerFile string
+	CallerLine int
+	// 事务开启时间.
+	StartedAt time.Time
+}
+
+// WithCallStackCapture 开启事务调用位置记录, 见 TransactionTrace.
+//
+// 默认关闭, 开启后每次 Transaction 调用有 runtime.Callers 的额外开销.
+func WithCallStackCapture() ManagerOption {
+	return func(m *manager) {
+		m.callStackCapture = true
+	}
+}
+
+// maxCallerDepth 查找调用位置时最多遍历的栈帧数.
+const maxCallerDepth = 32
+
+// modulePrefix 本模块包函数名前缀, 包括 db 等子包.
+var modulePrefix = strings.TrimSuffix(reflect.TypeOf(manager{}).PkgPath(), "transaction")
+
+// captureCaller 返回 Transaction 的调用位置.
+//
+// 跳过本模块的栈帧, 如 Do, TransactionWithHooks, 装饰器及 db 包的封装, 返回业务代码的调用位置.
+// 本模块的测试文件视为业务代码.
+func captureCaller() *TransactionFrame {
+	pcs := make([]uintptr, maxCallerDepth)
+	// 跳过 runtime.Callers 及 captureCaller.
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, modulePrefix) || strings.HasSuffix(frame.File, "_test.go") {
+			return &TransactionFrame{CallerFunc: frame.Function, CallerFile: frame.File, CallerLine: frame.Line}
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// Tracer 扩展 Manager, 返回事务开启位置.
+type Tracer interface {
+	// TransactionTrace 返回 context 所在事务及其上级事务的开启位置, 由内向外排列.
+	//
+	// 需开启 WithCallStackCapture, 未开启或不在事务内时返回 nil.
+	TransactionTrace(ctx context.Context) []TransactionFrame
+}
+
+var _ Tracer = new(manager)
+
+// TransactionTrace 通过 m 返回 context 所在事务及其上级事务的开启位置, 见 Tracer.
+//
+// m 未实现 Tracer 时返回 nil.
+func TransactionTrace(ctx context.Context, m Manager) []TransactionFrame {
+	if t, ok := extension[Tracer](m); ok {
+		return t.TransactionTrace(ctx)
+	}
+	return nil
+}
+
+// TransactionTrace 实现 Tracer.
+func (m *manager) TransactionTrace(ctx context.Context) []TransactionFrame {
+	var frames []TransactionFrame
+	for tc := m.findTransContext(ctx); tc != nil; tc = tc.parent {
+		if tc.caller != nil {
+			frames = append(frames, *tc.caller)
+		}
+	}
+	return frames
+}
@@ -0,0 +1,74 @@
+package transaction
+
+import (
+	"context"
+	"runtime"
+	"testing"
+)
+
+// line 返回调用处的行号.
+func line() int {
+	_, _, n, _ := runtime.Caller(1)
+	return n
+}
+
+func TestTransactionTrace(t *testing.T) {
+	m := newTestManager(WithCallStackCapture())
+	var frames []TransactionFrame
+	var outerLine, innerLine int
+
+	outerLine = line() + 1
+	err := m.Transaction(context.Background(), func(ctx context.Context) error {
+		innerLine = line() + 1
+		_, err := Do(ctx, m, func(ctx context.Context) (struct{}, error) {
+			frames = TransactionTrace(ctx, m)
+			return struct{}{}, nil
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("frames = %+v, want 2", frames)
+	}
+	for i, want := range []struct {
+		depth int
+		fn    string
+		line  int
+	}{
+		{2, "mini_transaction/transaction.TestTransactionTrace.func1", innerLine},
+		{1, "mini_transaction/transaction.TestTransactionTrace", outerLine},
+	} {
+		f := frames[i]
+		if f.Depth != want.depth || f.CallerFunc != want.fn || f.CallerLine != want.line {
+			t.Errorf("frame %d = %+v, want depth %d %s:%d", i, f, want.depth, want.fn, want.line)
+		}
+		if f.StartedAt.IsZero() {
+			t.Errorf("frame %d StartedAt not set", i)
+		}
+	}
+}
+
+func TestTransactionTraceDisabled(t *testing.T) {
+	m := newTestManager()
+	_ = m.Transaction(context.Background(), func(ctx context.Context) error {
+		if frames := TransactionTrace(ctx, m); len(frames) != 0 {
+			t.Errorf("frames = %+v, want none", frames)
+		}
+		return nil
+	})
+}
+
+func TestTransactionTraceExtension(t *testing.T) {
+	m := newTestManager(WithCallStackCapture())
+	_ = m.Transaction(context.Background(), func(ctx context.Context) error {
+		if frames := TransactionTrace(ctx, unwrappableManager{m}); len(frames) != 1 {
+			t.Errorf("frames through Unwrap = %+v, want 1", frames)
+		}
+		if frames := TransactionTrace(ctx, wrappedManager{m}); frames != nil {
+			t.Errorf("frames without Tracer = %+v, want nil", frames)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,72 @@
+package transaction
+
+import (
+	"context"
+	"time"
+)
+
+// ControlledTransContext 定义可控制生命周期的事务上下文.
+//
+// 用于自定义 Manager 或在测试中构造事务上下文, 回调语义与 Manager 一致.
+type ControlledTransContext interface {
+	TransContext
+	// End 标记事务执行结束, 根事务触发提交或回滚回调.
+	//
+	// panicked 或 err 不为 nil 时视为回滚.
+	End(panicked bool, err error)
+	// Finish 标记事务已结束, 之后 InTransaction 返回 false. 应在 End 之后调用.
+	Finish()
+	// OnCommitted 添加事务提交回调.
+	OnCommitted(callback func())
+	// OnRollbacked 添加事务回滚回调.
+	OnRollbacked(callback func(error))
+}
+
+// NewTransContext 创建事务上下文.
+//
+// parent 为 nil 时创建根事务, 否则创建 parent 的嵌套事务, parent 须由 NewTransContext 创建.
+func NewTransContext(parent TransContext, db interface{}) ControlledTransContext {
+	var p *transContext
+	if parent != nil {
+		var ok bool
+		if p, ok = parent.(*transContext); !ok {
+			panic("transaction: parent must be created by NewTransContext")
+		}
+	}
+	tc := p.Start(context.Background(), db)
+	if tc.isRoot() {
+		tc.cleanCtx = cleanCurrentTransContext
+	}
+	return tc
+}
+
+// cleanCurrentTransContext 清除 context 中的当前事务上下文.
+func cleanCurrentTransContext(ctx context.Context) context.Context {
+	if currentTransContext(ctx) == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, currentTransCtxKey{}, nil)
+}
+
+// ContextWithTransContext 以 key 将事务上下文存入 context.
+//
+// key 须与 Manager 查找事务上下文的 Key 一致. 存入 NewTransContext 创建的事务上下文时,
+// RegisterHook 等包级函数同样可以找到该事务.
+func ContextWithTransContext(ctx context.Context, key interface{}, tc TransContext) context.Context {
+	ctx = context.WithValue(ctx, key, tc)
+	if t, ok := tc.(*transContext); ok {
+		ctx = context.WithValue(ctx, currentTransCtxKey{}, t)
+	}
+	return ctx
+}
+
+func (t *transContext) Finish() {
+	root := t.root()
+	root.mut.Lock()
+	defer root.mut.Unlock()
+
+	t.done = true
+	if t.isRoot() {
+		t.endedAt = time.Now()
+	}
+}
@@ -0,0 +1,77 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// 配合 -race 检查事务结束标记的并发读写.
+func TestTransContextFinishConcurrent(t *testing.T) {
+	root := NewTransContext(nil, nil)
+	nested := NewTransContext(root, nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = nested.InTransaction()
+				_ = nested.EndedAt()
+			}
+		}()
+	}
+	nested.Finish()
+	root.End(false, nil)
+	root.Finish()
+	wg.Wait()
+
+	if root.InTransaction() || nested.InTransaction() {
+		t.Error("InTransaction after Finish = true")
+	}
+	if root.EndedAt().IsZero() || !nested.EndedAt().Equal(root.EndedAt()) {
+		t.Errorf("EndedAt = %v, %v", root.EndedAt(), nested.EndedAt())
+	}
+}
+
+func TestNewTransContext(t *testing.T) {
+	var trace []string
+	root := NewTransContext(nil, "db")
+	nested := NewTransContext(root, "db")
+	if root.GetTransDB() != "db" || !nested.InTransaction() {
+		t.Fatalf("root db %v, nested in transaction %v", root.GetTransDB(), nested.InTransaction())
+	}
+	nested.OnCommitted(func() { trace = append(trace, "committed") })
+	nested.OnRollbacked(func(error) { trace = append(trace, "rollbacked") })
+
+	nested.End(false, nil)
+	nested.Finish()
+	if len(trace) != 0 {
+		t.Errorf("callbacks fired at nested End: %v", trace)
+	}
+	root.End(false, nil)
+	root.Finish()
+	if len(trace) != 1 || trace[0] != "committed" {
+		t.Errorf("trace = %v, want [committed]", trace)
+	}
+
+	// 嵌套事务失败时回滚回调收到嵌套事务的错误.
+	errNested := errors.New("nested failed")
+	var got error
+	root = NewTransContext(nil, nil)
+	nested = NewTransContext(root, nil)
+	nested.OnRollbacked(func(err error) { got = err })
+	nested.End(false, errNested)
+	root.End(false, nil)
+	if !errors.Is(got, errNested) {
+		t.Errorf("rollback err = %v, want %v", got, errNested)
+	}
+
+	// 存入 context 后包级函数可找到事务.
+	root = NewTransContext(nil, nil)
+	ctx := ContextWithTransContext(context.Background(), testCtxKey{}, root)
+	if !RegisterHook(ctx, func(string) {}) {
+		t.Error("RegisterHook returned false for injected trans context")
+	}
+}
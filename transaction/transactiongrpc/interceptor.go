@@ -0,0 +1,57 @@
+// Package transactiongrpc 提供 gRPC 事务集成.
+package transactiongrpc
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"mini_transaction/transaction"
+)
+
+// UnaryServerInterceptor 创建每个 RPC 一个事务的一元拦截器.
+//
+// handler 返回 nil 时提交事务, 返回错误或 panic 时回滚.
+// handler 使用事务 context, Provider 从中获取事务 DB.
+//
+// shouldWrap 返回 false 的方法不开启事务, 用于只读 RPC. shouldWrap 为 nil 时全部开启.
+//
+// handler 成功但提交失败时, 将失败原因转换为 gRPC status 返回.
+func UnaryServerInterceptor(m transaction.Manager, shouldWrap func(info *grpc.UnaryServerInfo) bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if shouldWrap != nil && !shouldWrap(info) {
+			return handler(ctx, req)
+		}
+		var (
+			resp       interface{}
+			handlerErr error
+		)
+		err := m.Transaction(ctx, func(ctx context.Context) error {
+			resp, handlerErr = handler(ctx, req)
+			return handlerErr
+		})
+		if handlerErr != nil {
+			return resp, handlerErr
+		}
+		if err != nil {
+			return nil, commitStatus(err)
+		}
+		return resp, nil
+	}
+}
+
+// commitStatus 转换提交失败原因为 gRPC status.
+func commitStatus(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return status.Errorf(codes.Aborted, "transaction commit failed: %v", err)
+	}
+}
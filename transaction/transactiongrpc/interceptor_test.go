@@ -0,0 +1,229 @@
+package transactiongrpc
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"mini_transaction/db"
+	"mini_transaction/transaction"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+// stringCodec 以字符串作为请求及响应, 测试不依赖 protobuf 生成代码.
+type stringCodec struct{}
+
+func (stringCodec) Marshal(v interface{}) ([]byte, error) {
+	return []byte(*v.(*string)), nil
+}
+
+func (stringCodec) Unmarshal(data []byte, v interface{}) error {
+	*v.(*string) = string(data)
+	return nil
+}
+
+func (stringCodec) Name() string {
+	return "string"
+}
+
+type item struct {
+	ID   int64
+	Name string
+}
+
+var errCreateFailed = errors.New("create failed")
+
+// newItemServer 启动基于 bufconn 的测试服务, Create 写入一行, Count 返回是否在事务内.
+func newItemServer(t *testing.T, p *db.TransProvider) *grpc.ClientConn {
+	t.Helper()
+	unary := func(method string, handle func(ctx context.Context, req string) (string, error)) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+		return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			var req string
+			if err := dec(&req); err != nil {
+				return nil, err
+			}
+			info := &grpc.UnaryServerInfo{FullMethod: "/test.Items/" + method}
+			return interceptor(ctx, &req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				resp, err := handle(ctx, *req.(*string))
+				return &resp, err
+			})
+		}
+	}
+	desc := grpc.ServiceDesc{
+		ServiceName: "test.Items",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{
+			{
+				MethodName: "Create",
+				Handler: unary("Create", func(ctx context.Context, name string) (string, error) {
+					if err := p.UseDB(ctx).Create(&item{Name: name}).Error; err != nil {
+						return "", err
+					}
+					if name == "fail" {
+						return "", status.Error(codes.InvalidArgument, errCreateFailed.Error())
+					}
+					return "created", nil
+				}),
+			},
+			{
+				MethodName: "Get",
+				Handler: unary("Get", func(ctx context.Context, _ string) (string, error) {
+					if p.InTransaction(ctx) {
+						return "in transaction", nil
+					}
+					return "no transaction", nil
+				}),
+			},
+		},
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(
+		grpc.ForceServerCodec(stringCodec{}),
+		grpc.UnaryInterceptor(UnaryServerInterceptor(p, func(info *grpc.UnaryServerInfo) bool {
+			return info.FullMethod != "/test.Items/Get"
+		})),
+	)
+	srv.RegisterService(&desc, struct{}{})
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(stringCodec{})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+		srv.Stop()
+	})
+	return conn
+}
+
+func newSQLiteProvider(t *testing.T) *db.TransProvider {
+	t.Helper()
+	opts := &db.Options{Dialect: db.DialectSQLite, DBName: db.Ptr(filepath.Join(t.TempDir(), "test.db"))}
+	gdb, err := opts.OpenDB(db.SQLiteDialector(), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.AutoMigrate(new(item)); err != nil {
+		t.Fatal(err)
+	}
+	p := db.NewProvider(db.NewSource("sqlite", gdb))
+	t.Cleanup(func() {
+		_ = p.ForceClose(context.Background())
+	})
+	return p
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	p := newSQLiteProvider(t)
+	conn := newItemServer(t, p)
+	ctx := context.Background()
+
+	call := func(method, req string) (string, error) {
+		var resp string
+		err := conn.Invoke(ctx, "/test.Items/"+method, &req, &resp)
+		return resp, err
+	}
+	count := func() int64 {
+		var n int64
+		if err := p.UseDB(ctx).Model(new(item)).Count(&n).Error; err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	if resp, err := call("Create", "ok"); err != nil || resp != "created" {
+		t.Fatalf("Create = %q, %v", resp, err)
+	}
+	if n := count(); n != 1 {
+		t.Errorf("%d rows after commit, want 1", n)
+	}
+
+	// handler 返回错误时回滚, 错误原样返回.
+	_, err := call("Create", "fail")
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Create(fail) err = %v, want InvalidArgument", err)
+	}
+	if n := count(); n != 1 {
+		t.Errorf("%d rows after rollback, want 1", n)
+	}
+
+	// shouldWrap 拒绝的方法不开启事务.
+	if resp, err := call("Get", ""); err != nil || resp != "no transaction" {
+		t.Errorf("Get = %q, %v, want no transaction", resp, err)
+	}
+}
+
+// commitFailingManager 回调成功后返回提交失败.
+type commitFailingManager struct {
+	transaction.Manager
+	err error
+}
+
+func (m commitFailingManager) Transaction(ctx context.Context, callback func(context.Context) error) error {
+	if err := m.Manager.Transaction(ctx, callback); err != nil {
+		return err
+	}
+	return m.err
+}
+
+func TestUnaryServerInterceptorCommitFailure(t *testing.T) {
+	p := newSQLiteProvider(t)
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Items/Create"}
+	ok := func(context.Context, interface{}) (interface{}, error) {
+		return "created", nil
+	}
+
+	for _, c := range []struct {
+		err  error
+		code codes.Code
+	}{
+		{errors.New("connection reset"), codes.Aborted},
+		{context.DeadlineExceeded, codes.DeadlineExceeded},
+		{status.Error(codes.Unavailable, "db down"), codes.Unavailable},
+	} {
+		interceptor := UnaryServerInterceptor(commitFailingManager{Manager: p, err: c.err}, nil)
+		resp, err := interceptor(context.Background(), nil, info, ok)
+		if resp != nil || status.Code(err) != c.code {
+			t.Errorf("commit error %v: resp %v, err %v, want code %v", c.err, resp, err, c.code)
+		}
+	}
+
+	// handler panic 时回滚并重新触发.
+	interceptor := UnaryServerInterceptor(p, nil)
+	func() {
+		defer func() {
+			if e := recover(); e != "handler failed" {
+				t.Errorf("recovered %v, want handler failed", e)
+			}
+		}()
+		_, _ = interceptor(context.Background(), nil, info, func(ctx context.Context, _ interface{}) (interface{}, error) {
+			if err := p.UseDB(ctx).Create(&item{Name: "panic"}).Error; err != nil {
+				t.Fatal(err)
+			}
+			panic("handler failed")
+		})
+	}()
+	var n int64
+	if err := p.UseDB(context.Background()).Model(new(item)).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("%d rows after panic, want 0", n)
+	}
+}
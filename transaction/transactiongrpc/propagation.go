@@ -0,0 +1,74 @@
+package transactiongrpc
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"mini_transaction/transaction"
+)
+
+// MetadataCarrier 以 gRPC metadata 实现 transaction.Carrier.
+type MetadataCarrier metadata.MD
+
+func (c MetadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c MetadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+// GRPCMetadataPropagator 通过 gRPC metadata 传递事务 ID 及嵌套深度, 载体为 MetadataCarrier.
+type GRPCMetadataPropagator struct {
+	transaction.TextMapPropagator
+}
+
+var _ transaction.ContextPropagator = GRPCMetadataPropagator{}
+
+// InjectOutgoing 写入当前事务信息到 outgoing metadata, 不修改已有 metadata.
+func (p GRPCMetadataPropagator) InjectOutgoing(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	if err := p.Inject(ctx, MetadataCarrier(md)); err != nil {
+		return ctx, err
+	}
+	return metadata.NewOutgoingContext(ctx, md), nil
+}
+
+// ExtractIncoming 从 incoming metadata 读取上游事务信息, 见 transaction.RemoteTransactionFrom.
+func (p GRPCMetadataPropagator) ExtractIncoming(ctx context.Context) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx, nil
+	}
+	return p.Extract(ctx, MetadataCarrier(md))
+}
+
+// UnaryClientPropagationInterceptor 创建写入当前事务信息到请求 metadata 的一元客户端拦截器.
+func UnaryClientPropagationInterceptor() grpc.UnaryClientInterceptor {
+	p := GRPCMetadataPropagator{}
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, err := p.InjectOutgoing(ctx)
+		if err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// UnaryServerPropagationInterceptor 创建读取上游事务信息的一元服务端拦截器.
+//
+// 需在 UnaryServerInterceptor 之前执行, 上游事务信息无效时忽略.
+func UnaryServerPropagationInterceptor() grpc.UnaryServerInterceptor {
+	p := GRPCMetadataPropagator{}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if extracted, err := p.ExtractIncoming(ctx); err == nil {
+			ctx = extracted
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,76 @@
+package transactiongrpc
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"mini_transaction/transaction"
+	"testing"
+)
+
+func TestPropagationInterceptors(t *testing.T) {
+	p := newSQLiteProvider(t)
+	var sent metadata.MD
+	invoker := func(ctx context.Context, _ string, _, _ interface{}, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+		sent, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+	var id string
+	err := p.Transaction(context.Background(), func(ctx context.Context) error {
+		id = p.Inspect(ctx).TransactionID
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-request-id", "r1")
+		return UnaryClientPropagationInterceptor()(ctx, "/items.Items/Get", nil, nil, nil, invoker)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sent.Get(transaction.PropagationIDKey); len(got) != 1 || got[0] != id {
+		t.Fatalf("sent id = %v, want %s", got, id)
+	}
+	if got := sent.Get(transaction.PropagationDepthKey); len(got) != 1 || got[0] != "1" {
+		t.Errorf("sent depth = %v, want 1", got)
+	}
+	// 保留已有 metadata.
+	if got := sent.Get("x-request-id"); len(got) != 1 {
+		t.Errorf("existing metadata dropped: %v", sent)
+	}
+
+	var remote transaction.RemoteTransaction
+	var inTransaction bool
+	handler := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		remote, _ = transaction.RemoteTransactionFrom(ctx)
+		inTransaction = p.InTransaction(ctx)
+		return nil, nil
+	}
+	incoming := metadata.NewIncomingContext(context.Background(), sent)
+	if _, err := UnaryServerPropagationInterceptor()(incoming, nil, &grpc.UnaryServerInfo{}, handler); err != nil {
+		t.Fatal(err)
+	}
+	if remote.ID != id || remote.Depth != 1 {
+		t.Errorf("remote = %+v, want id %s depth 1", remote, id)
+	}
+	if inTransaction {
+		t.Error("server context joined the upstream transaction")
+	}
+}
+
+func TestPropagationOutsideTransaction(t *testing.T) {
+	ctx, err := GRPCMetadataPropagator{}.InjectOutgoing(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md, _ := metadata.FromOutgoingContext(ctx); len(md.Get(transaction.PropagationIDKey)) != 0 {
+		t.Errorf("metadata = %v outside transaction", md)
+	}
+	// 无效的上游事务信息被忽略.
+	incoming := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		transaction.PropagationIDKey, "tx", transaction.PropagationDepthKey, "-1"))
+	var ok bool
+	_, err = UnaryServerPropagationInterceptor()(incoming, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		_, ok = transaction.RemoteTransactionFrom(ctx)
+		return nil, nil
+	})
+	if err != nil || ok {
+		t.Errorf("invalid propagation: err = %v, remote present = %v", err, ok)
+	}
+}
@@ -0,0 +1,44 @@
+package transactiontest
+
+import (
+	"context"
+	"mini_transaction/transaction"
+)
+
+// FakeManager 实现不依赖数据库的事务管理器.
+//
+// 事务行为与真实事务管理器一致, 回调返回错误或 panic 时视为回滚.
+type FakeManager struct {
+	transaction.Manager
+}
+
+var _ transaction.Unwrapper = new(FakeManager)
+
+type fakeCtxKey struct {
+	m *FakeManager
+}
+
+// fakeDB 代表 FakeManager 的事务 DB.
+type fakeDB struct{}
+
+// NewFakeManager 创建 FakeManager.
+func NewFakeManager() *FakeManager {
+	m := &FakeManager{}
+	m.Manager = transaction.NewManager(
+		func(context.Context) interface{} {
+			return fakeCtxKey{m: m}
+		},
+		func(context.Context) interface{} {
+			return fakeDB{}
+		},
+		func(ctx context.Context, db interface{}, callback func(db interface{}, bindCtx func(context.Context)) error) error {
+			return callback(db, nil)
+		},
+	)
+	return m
+}
+
+// Unwrap 实现 transaction.Unwrapper.
+func (m *FakeManager) Unwrap() transaction.Manager {
+	return m.Manager
+}
@@ -0,0 +1,100 @@
+// Package transactiontest 提供事务回调相关的测试断言.
+package transactiontest
+
+import (
+	"context"
+	"mini_transaction/transaction"
+	"sync"
+	"testing"
+)
+
+// probe 记录事务回调的执行情况.
+type probe struct {
+	mut        sync.Mutex
+	committed  int
+	rollbacked int
+	err        error
+}
+
+func (p *probe) register(t testing.TB, m transaction.Manager, ctx context.Context) {
+	t.Helper()
+	ok := m.OnCommitted(ctx, func(context.Context) {
+		p.mut.Lock()
+		defer p.mut.Unlock()
+		p.committed++
+	})
+	ok = ok && m.OnRollbacked(ctx, func(_ context.Context, err error) {
+		p.mut.Lock()
+		defer p.mut.Unlock()
+		p.rollbacked++
+		p.err = err
+	})
+	if !ok {
+		t.Fatalf("transactiontest: context is not in transaction")
+	}
+}
+
+// CommitExpectation 断言事务提交.
+type CommitExpectation struct {
+	probe
+}
+
+// ExpectCommit 在事务内注册提交探针.
+//
+// ctx 需为 Transaction 回调的 context, 在 Transaction 返回后调用 Assert.
+func ExpectCommit(t testing.TB, m transaction.Manager, ctx context.Context) *CommitExpectation {
+	t.Helper()
+	e := &CommitExpectation{}
+	e.register(t, m, ctx)
+	return e
+}
+
+// Assert 断言提交回调执行且仅执行一次, 回滚回调未执行.
+func (e *CommitExpectation) Assert(t testing.TB) {
+	t.Helper()
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	if e.rollbacked > 0 {
+		t.Errorf("transactiontest: expected commit, got rollback: %v", e.err)
+		return
+	}
+	if e.committed != 1 {
+		t.Errorf("transactiontest: expected commit callback to run once, ran %d times", e.committed)
+	}
+}
+
+// RollbackExpectation 断言事务回滚.
+type RollbackExpectation struct {
+	probe
+	match func(error) bool
+}
+
+// RequireRolledBack 在事务内注册回滚探针.
+//
+// match 校验回滚错误, 为 nil 时接受任意错误.
+//
+// ctx 需为 Transaction 回调的 context, 在 Transaction 返回后调用 Assert.
+func RequireRolledBack(t testing.TB, m transaction.Manager, ctx context.Context, match func(error) bool) *RollbackExpectation {
+	t.Helper()
+	e := &RollbackExpectation{match: match}
+	e.register(t, m, ctx)
+	return e
+}
+
+// Assert 断言回滚回调执行且错误匹配, 提交回调未执行.
+func (e *RollbackExpectation) Assert(t testing.TB) {
+	t.Helper()
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	if e.committed > 0 {
+		t.Errorf("transactiontest: expected rollback, got commit")
+		return
+	}
+	if e.rollbacked != 1 {
+		t.Errorf("transactiontest: expected rollback callback to run once, ran %d times", e.rollbacked)
+		return
+	}
+	if e.match != nil && !e.match(e.err) {
+		t.Errorf("transactiontest: rollback error does not match: %v", e.err)
+	}
+}
@@ -0,0 +1,100 @@
+package transactiontest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mini_transaction/db/dbtest"
+	"mini_transaction/transaction"
+	"testing"
+)
+
+// recordingT 记录断言失败, 用于验证断言本身.
+type recordingT struct {
+	testing.TB
+	failures []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func (t *recordingT) Fatalf(format string, args ...interface{}) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func managers(t *testing.T) map[string]transaction.Manager {
+	return map[string]transaction.Manager{
+		"fake":   NewFakeManager(),
+		"sqlite": dbtest.NewProvider(t, nil),
+	}
+}
+
+func TestExpectCommit(t *testing.T) {
+	for name, m := range managers(t) {
+		t.Run(name, func(t *testing.T) {
+			var e *CommitExpectation
+			err := m.Transaction(context.Background(), func(ctx context.Context) error {
+				e = ExpectCommit(t, m, ctx)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			e.Assert(t)
+
+			// 回滚时断言失败.
+			rt := &recordingT{}
+			_ = m.Transaction(context.Background(), func(ctx context.Context) error {
+				e = ExpectCommit(rt, m, ctx)
+				return errors.New("rollback")
+			})
+			e.Assert(rt)
+			if len(rt.failures) != 1 {
+				t.Errorf("failures = %q, want one", rt.failures)
+			}
+		})
+	}
+}
+
+func TestRequireRolledBack(t *testing.T) {
+	errBusiness := errors.New("business")
+	for name, m := range managers(t) {
+		t.Run(name, func(t *testing.T) {
+			var e *RollbackExpectation
+			_ = m.Transaction(context.Background(), func(ctx context.Context) error {
+				e = RequireRolledBack(t, m, ctx, func(err error) bool { return errors.Is(err, errBusiness) })
+				return errBusiness
+			})
+			e.Assert(t)
+
+			for _, c := range []struct {
+				name string
+				err  error
+			}{
+				{"commit", nil},
+				{"other error", errors.New("other")},
+			} {
+				rt := &recordingT{}
+				_ = m.Transaction(context.Background(), func(ctx context.Context) error {
+					e = RequireRolledBack(rt, m, ctx, func(err error) bool { return errors.Is(err, errBusiness) })
+					return c.err
+				})
+				e.Assert(rt)
+				if len(rt.failures) != 1 {
+					t.Errorf("%s: failures = %q, want one", c.name, rt.failures)
+				}
+			}
+		})
+	}
+}
+
+func TestExpectCommitOutsideTransaction(t *testing.T) {
+	rt := &recordingT{}
+	ExpectCommit(rt, NewFakeManager(), context.Background())
+	if len(rt.failures) != 1 {
+		t.Errorf("failures = %q, want one", rt.failures)
+	}
+}
@@ -2,7 +2,12 @@ package transaction
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
 	"sync"
+	"time"
 )
 
 // Manager 定义事务管理器.
@@ -10,6 +15,8 @@ import (
 // 此事务管理器具有跨层事务能力.
 //
 // 具体实现由资源提供方提供，如: db.Provider 的具体实现.
+//
+// 可选能力定义为扩展接口, 如 StrictRegistrar, 通过同名包级函数调用.
 type Manager interface {
 	// InTransaction context 是否在事务内.
 	InTransaction(ctx context.Context) bool
@@ -60,6 +67,11 @@ type Manager interface {
 	OnRollbacked(ctx context.Context, callback func(context.Context, error)) bool
 }
 
+// ContextCloner 将 src 的事务状态传递到框架复制得到的 dst.
+//
+// dst 已携带事务标记, 用于框架在自身状态中保存 context 等额外处理.
+type ContextCloner func(src, dst context.Context) context.Context
+
 // TransContext 代表事务上下文.
 //
 // 用于事务管理器的具体实现从上下文中获取事务 DB.
@@ -68,22 +80,66 @@ type TransContext interface {
 	GetTransDB() interface{}
 	// InTransaction 判断是否在事务内.
 	InTransaction() bool
+	// ID 返回根事务 ID.
+	ID() string
+	// Label 返回根事务标签.
+	Label() string
+	// StartedAt 返回根事务开始时间.
+	StartedAt() time.Time
+	// EndedAt 返回根事务结束时间, 事务未结束时返回零值.
+	EndedAt() time.Time
+	// Depth 返回事务嵌套深度, 根事务为 1.
+	Depth() int
+	// Load 读取事务范围数据.
+	Load(key interface{}) (value interface{}, ok bool)
+	// LoadOrStore 读取事务范围数据, 不存在时写入 value.
+	//
+	// 数据存储在根事务上, 嵌套事务共享.
+	LoadOrStore(key, value interface{}) (actual interface{}, loaded bool)
 }
 
 // transContext 实现事务上下文.
 type transContext struct {
 	// 根节点属性.
-	mut                   sync.Mutex
-	onCommittedCallbacks  []func()
-	onRollbackedCallbacks []func()
+	// mut 保护事件回调及 done, endedAt.
+	mut sync.Mutex
+	// 事件回调, 值为 func(事件类型).
+	eventCallbacks map[reflect.Type][]interface{}
+	// 事务范围数据.
+	values sync.Map
+	// 事务 ID.
+	id string
+	// 事务标签.
+	label string
+	// 提交回调并发数.
+	committedParallelism int
+	// 事务回调异常处理.
+	onCallbackFailure func(error)
+	// 补偿操作失败策略.
+	compensationPolicy CompensationPolicy
+	// 清除 context 中的事务标记.
+	cleanCtx func(context.Context) context.Context
 
 	// 父节点. 父节点为 nil，则为根节点.
 	parent *transContext
 	// 当前事务 DB 实例.
 	db interface{}
+	// 事务调用位置, 未开启 WithCallStackCapture 时为 nil.
+	caller *TransactionFrame
 
-	// 标记事务已结束.
+	// 标记事务已结束, 由根节点 mut 保护.
 	done bool
+	// 根事务开始及结束时间.
+	startedAt time.Time
+	endedAt   time.Time
+	// 根事务库名.
+	dbName string
+	// 可靠提交回调配置.
+	durable *durableOptions
+	// 缓存失效处理.
+	invalidator Invalidator
+	// 领域事件发布.
+	eventPublisher EventPublisher
 	// 是否 panic. 事务开始前设置为 true , 事务结束时设置为 false.
 	panicked bool
 	// 当前事务执行结果是否异常.
@@ -103,12 +159,58 @@ func (t *transContext) InTransaction() bool {
 	if !t.isRoot() {
 		return t.parent.InTransaction()
 	}
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
 	return !t.done
 }
 
+func (t *transContext) Load(key interface{}) (interface{}, bool) {
+	return t.root().values.Load(key)
+}
+
+func (t *transContext) LoadOrStore(key, value interface{}) (interface{}, bool) {
+	return t.root().values.LoadOrStore(key, value)
+}
+
+func (t *transContext) ID() string {
+	return t.root().id
+}
+
+func (t *transContext) Label() string {
+	return t.root().label
+}
+
+func (t *transContext) StartedAt() time.Time {
+	return t.root().startedAt
+}
+
+func (t *transContext) EndedAt() time.Time {
+	root := t.root()
+	root.mut.Lock()
+	defer root.mut.Unlock()
+
+	return root.endedAt
+}
+
+func (t *transContext) Depth() int {
+	return t.depth()
+}
+
 // Start 标记新事务开启.
-func (t *transContext) Start(db interface{}) *transContext {
-	return &transContext{parent: t, db: db, panicked: true}
+func (t *transContext) Start(ctx context.Context, db interface{}) *transContext {
+	tc := &transContext{parent: t, db: db, panicked: true}
+	if tc.isRoot() {
+		tc.id = newTransID()
+		tc.label = labelFromContext(ctx)
+		tc.startedAt = time.Now()
+	}
+	return tc
+}
+
+// newTransID 生成事务 ID.
+func newTransID() string {
+	return fmt.Sprintf("%016x", rand.Uint64())
 }
 
 // End 标记当前事务结束.
@@ -123,6 +225,10 @@ func (t *transContext) End(panicked bool, err error) {
 	if !t.isRoot() {
 		return
 	}
+	// 结果未知时提交及回滚回调均不可靠, 不触发.
+	if errors.Is(err, ErrOutcomeUnknown) {
+		return
+	}
 	t.doOnCommittedCallbacks()
 	t.doOnRollbackedCallbacks()
 }
@@ -130,37 +236,96 @@ func (t *transContext) End(panicked bool, err error) {
 // doOnCommittedCallbacks 处理注册到根节点的回调.
 func (t *transContext) doOnCommittedCallbacks() {
 	var callbacks []func()
-
-	t.mut.Lock()
-	for _, callback := range t.onCommittedCallbacks {
-		callbacks = append(callbacks, callback)
+	for _, hook := range t.hooks(committedEventType) {
+		hook := hook.(func(committedEvent))
+		callbacks = append(callbacks, func() { hook(committedEvent{}) })
 	}
-	t.mut.Unlock()
 
+	if t.committedParallelism > 1 {
+		t.runParallel(callbacks, t.committedParallelism)
+		return
+	}
 	for _, callback := range callbacks {
 		callback()
 	}
 }
 
+// runParallel 以最大并发数 limit 执行回调, 等待全部回调结束.
+//
+// 回调 panic 交由 onCallbackFailure 处理, 未设置时重新触发首个 panic.
+func (t *transContext) runParallel(callbacks []func(), limit int) {
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		panicked interface{}
+		sem      = make(chan struct{}, limit)
+	)
+	for _, callback := range callbacks {
+		callback := callback
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			defer func() {
+				e := recover()
+				if e == nil {
+					return
+				}
+				if t.onCallbackFailure == nil {
+					once.Do(func() { panicked = e })
+					return
+				}
+				err, ok := e.(error)
+				if !ok {
+					err = fmt.Errorf("panic: %v", e)
+				}
+				t.onCallbackFailure(err)
+			}()
+			callback()
+		}()
+	}
+	wg.Wait()
+	if panicked != nil {
+		panic(panicked)
+	}
+}
+
 // doOnRollbackedCallbacks 处理注册到根节点的回调.
 func (t *transContext) doOnRollbackedCallbacks() {
-	var callbacks []func()
+	fireHook(t, rollbackedEvent{})
+}
 
-	t.mut.Lock()
-	for _, callback := range t.onRollbackedCallbacks {
-		callbacks = append(callbacks, callback)
+// depth 返回事务嵌套深度, 根事务为 1.
+func (t *transContext) depth() int {
+	if t.isRoot() {
+		return 1
 	}
-	t.mut.Unlock()
+	return t.parent.depth() + 1
+}
 
-	for _, callback := range callbacks {
-		callback()
+// elapsed 返回根事务耗时, 事务未结束时为已执行时长.
+func (t *transContext) elapsed() time.Duration {
+	root := t.root()
+	if endedAt := root.EndedAt(); !endedAt.IsZero() {
+		return endedAt.Sub(root.startedAt)
 	}
+	return time.Since(root.startedAt)
 }
 
 func (t *transContext) isRoot() bool {
 	return t.parent == nil
 }
 
+func (t *transContext) root() *transContext {
+	if t.isRoot() {
+		return t
+	}
+	return t.parent.root()
+}
+
 func (t *transContext) isCommitted() bool {
 	if t == nil {
 		return true
@@ -183,34 +348,81 @@ func (t *transContext) isRollbacked() error {
 
 // OnCommitted 添加事务提交回调.
 func (t *transContext) OnCommitted(callback func()) {
-	callbackFunc := func() {
-		if t.isCommitted() {
-			callback()
-		}
-	}
-	if t.parent == nil {
-		t.mut.Lock()
-		defer t.mut.Unlock()
+	t.OnCommittedBatch([]func(){callback})
+}
 
-		t.onCommittedCallbacks = append(t.onCommittedCallbacks, callbackFunc)
-		return
+// OnCommittedBatch 一次加锁添加多个事务提交回调.
+func (t *transContext) OnCommittedBatch(callbacks []func()) {
+	hooks := make([]interface{}, 0, len(callbacks))
+	for _, callback := range callbacks {
+		callback := callback
+		hooks = append(hooks, func(committedEvent) {
+			if t.isCommitted() {
+				callback()
+			}
+		})
 	}
-	t.parent.OnCommitted(callbackFunc)
+	t.addHooks(committedEventType, hooks...)
 }
 
 // OnRollbacked 添加事务回滚回调.
+//
+// 以当前节点向上查找的首个异常判断是否回滚.
 func (t *transContext) OnRollbacked(callback func(error)) {
-	callbackFunc := func() {
-		if err := t.isRollbacked(); err != nil {
-			callback(err)
-		}
+	t.OnRollbackedBatch([]func(error){callback})
+}
+
+// OnRollbackedBatch 一次加锁添加多个事务回滚回调.
+func (t *transContext) OnRollbackedBatch(callbacks []func(error)) {
+	hooks := make([]interface{}, 0, len(callbacks))
+	for _, callback := range callbacks {
+		callback := callback
+		hooks = append(hooks, func(rollbackedEvent) {
+			if err := t.isRollbacked(); err != nil {
+				callback(err)
+			}
+		})
 	}
-	if t.parent == nil {
-		t.mut.Lock()
-		defer t.mut.Unlock()
+	t.addHooks(rollbackedEventType, hooks...)
+}
 
-		t.onRollbackedCallbacks = append(t.onRollbackedCallbacks, callbackFunc)
-		return
+// callbackFailed 将回调异常交由根节点的异常处理.
+//
+// 未设置异常处理时返回 false.
+func (t *transContext) callbackFailed(err error) bool {
+	if t == nil {
+		return false
+	}
+	root := t.root()
+	if root.onCallbackFailure == nil {
+		return false
 	}
-	t.parent.OnRollbacked(callback)
+	root.onCallbackFailure(err)
+	return true
+}
+
+// addHook 添加事件回调到根节点.
+func (t *transContext) addHook(typ reflect.Type, hook interface{}) {
+	t.addHooks(typ, hook)
+}
+
+// addHooks 一次加锁添加多个事件回调到根节点.
+func (t *transContext) addHooks(typ reflect.Type, hooks ...interface{}) {
+	root := t.root()
+	root.mut.Lock()
+	defer root.mut.Unlock()
+
+	if root.eventCallbacks == nil {
+		root.eventCallbacks = make(map[reflect.Type][]interface{})
+	}
+	root.eventCallbacks[typ] = append(root.eventCallbacks[typ], hooks...)
+}
+
+// hooks 返回事件回调副本.
+func (t *transContext) hooks(typ reflect.Type) []interface{} {
+	root := t.root()
+	root.mut.Lock()
+	defer root.mut.Unlock()
+
+	return append([]interface{}(nil), root.eventCallbacks[typ]...)
 }